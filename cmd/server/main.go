@@ -2,10 +2,14 @@ package main
 
 import (
 	"log"
+	"os"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 
 	_ "github.com/IkoAfianando/mini_evv_logger_go/docs"
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/config"
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/handler"
 	"github.com/IkoAfianando/mini_evv_logger_go/pkg/router"
 	"github.com/IkoAfianando/mini_evv_logger_go/pkg/store"
 )
@@ -20,14 +24,54 @@ import (
 // @host           localhost:8080
 // @BasePath       /
 // @schemes http
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description Type "Bearer" followed by a space and an access token from POST /api/auth/login.
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+// @description An API key from POST /api/api-keys.
 func main() {
-	dataStore := store.NewStore()
-	dataStore.SetupInitialData()
-	app := fiber.New()
-	router.SetupRoutes(app, dataStore)
+	cfg, err := config.FromEnv()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	dbPath := os.Getenv("EVV_DB_PATH")
+	if dbPath == "" {
+		dbPath = "evv.db"
+	}
+	dataStore, err := store.NewSQLiteStore(dbPath)
+	if err != nil {
+		log.Fatalf("Failed to open data store %s: %v", dbPath, err)
+	}
+	defer dataStore.Close()
+	log.Printf("Using SQLite data store at %s", dbPath)
+	if err := handler.BootstrapUsers(dataStore, cfg); err != nil {
+		log.Fatalf("Cannot start: %v", err)
+	}
+
+	app := fiber.New(router.AppConfig())
+	if err := router.SetupRoutes(app, dataStore, cfg); err != nil {
+		log.Fatalf("Cannot start: %v", err)
+	}
+	go expandRecurrences(dataStore, cfg)
 
 	port := "8080"
 	log.Printf("Starting server on port %s", port)
 	log.Printf("Swagger UI is available at http://localhost:%s/swagger/index.html", port)
 	log.Fatal(app.Listen(":" + port))
 }
+
+// expandRecurrences keeps the schedules of recurring bookings generated up to
+// the end of the window: now, and then every hour.
+func expandRecurrences(repo store.Repository, cfg config.Config) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		if err := handler.ExpandRecurrences(repo, cfg); err != nil {
+			log.Printf("Error expanding recurrences: %v", err)
+		}
+		<-ticker.C
+	}
+}
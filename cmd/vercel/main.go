@@ -1,8 +1,13 @@
 package handler
 
 import (
+	"log"
 	"net/http"
+	"os"
+	"sync"
 
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/config"
+	evvhandler "github.com/IkoAfianando/mini_evv_logger_go/pkg/handler"
 	"github.com/IkoAfianando/mini_evv_logger_go/pkg/router"
 	"github.com/IkoAfianando/mini_evv_logger_go/pkg/store"
 
@@ -10,10 +15,52 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
+var (
+	appOnce sync.Once
+	app     *fiber.App
+)
+
+// setupApp opens the data store and builds the router once per instance, so
+// warm invocations keep the records written by earlier requests.
+//
+// EVV_DB_PATH must be set. The only writable path in the Vercel runtime is
+// /tmp, which is per instance and wiped on every cold start, so choosing it
+// is left to the deployment rather than made the default: it suits a demo
+// but loses visit records.
+//
+// Demo mode is refused, since anyone could log in with the demo accounts;
+// the admin comes from EVV_ADMIN_PASSWORD instead.
+func setupApp() {
+	cfg, err := config.FromEnv()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if cfg.Demo {
+		log.Fatal("EVV_DEMO is not allowed on Vercel: the deployment is public and the demo passwords are not; set EVV_JWT_KEYS and EVV_ADMIN_PASSWORD as project secrets")
+	}
+	dbPath := os.Getenv("EVV_DB_PATH")
+	if dbPath == "" {
+		log.Fatal("EVV_DB_PATH is not set; set it to the SQLite database path (/tmp/evv.db for a throwaway demo, since /tmp does not survive cold starts)")
+	}
+	dataStore, err := store.NewSQLiteStore(dbPath)
+	if err != nil {
+		log.Fatalf("Failed to open data store %s: %v", dbPath, err)
+	}
+	if err := evvhandler.BootstrapUsers(dataStore, cfg); err != nil {
+		log.Fatalf("Cannot start: %v", err)
+	}
+	app = fiber.New(router.AppConfig())
+	if err := router.SetupRoutes(app, dataStore, cfg); err != nil {
+		log.Fatalf("Cannot start: %v", err)
+	}
+	// Functions cannot run the server's hourly job, so recurrences are
+	// expanded when an instance starts and on POST /api/recurrences/roll.
+	if err := evvhandler.ExpandRecurrences(dataStore, cfg); err != nil {
+		log.Printf("Error expanding recurrences: %v", err)
+	}
+}
+
 func Handler(w http.ResponseWriter, r *http.Request) {
-	dataStore := store.NewStore()
-	dataStore.SetupInitialData()
-	app := fiber.New()
-	router.SetupRoutes(app, dataStore)
+	appOnce.Do(setupApp)
 	adaptor.FiberApp(app)(w, r)
 }
@@ -22,35 +22,52 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/reset": {
-            "post": {
-                "description": "Resets the in-memory data to the initial set of schedules and tasks, useful for testing.",
-                "consumes": [
-                    "application/json"
+        "/api/api-keys": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
+                "description": "Lists every API key, revoked ones included, oldest first. The keys themselves are never shown again after creation.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Admin"
+                    "API Keys"
                 ],
-                "summary": "Reset data store",
+                "summary": "Get all API keys",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.APIKey"
                             }
                         }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
                     }
                 }
-            }
-        },
-        "/api/schedules": {
-            "get": {
-                "description": "Fetches a list of all caregiver schedules, sorted chronologically",
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Issues a key for an unattended integration, limited to the given scopes. The only scope so far is \"schedules:read\" (read every schedule). The key is sent as the X-API-Key header and is returned only in this response; store it then.",
                 "consumes": [
                     "application/json"
                 ],
@@ -58,65 +75,64 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Schedules"
+                    "API Keys"
                 ],
-                "summary": "Get all schedules",
-                "responses": {
-                    "200": {
-                        "description": "OK",
+                "summary": "Create an API key",
+                "parameters": [
+                    {
+                        "description": "API key",
+                        "name": "key",
+                        "in": "body",
+                        "required": true,
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.Schedule"
-                            }
+                            "$ref": "#/definitions/models.CreateAPIKeyRequest"
                         }
                     }
-                }
-            }
-        },
-        "/api/schedules/today": {
-            "get": {
-                "description": "Fetches all schedules scheduled for the current date",
-                "consumes": [
-                    "application/json"
                 ],
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "Schedules"
-                ],
-                "summary": "Get today's schedules",
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.Schedule"
-                            }
+                            "$ref": "#/definitions/models.CreatedAPIKey"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
                         }
                     }
                 }
             }
         },
-        "/api/schedules/{id}": {
-            "get": {
-                "description": "Fetches the details of a single schedule using its ID",
-                "consumes": [
-                    "application/json"
+        "/api/api-keys/{id}/revoke": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
+                "description": "Stops the key from working at once. The key stays in the listing with its revocation time; revoking it again changes nothing.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Schedules"
+                    "API Keys"
                 ],
-                "summary": "Get schedule by ID",
+                "summary": "Revoke an API key",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Schedule ID",
+                        "description": "API key ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -126,24 +142,27 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Schedule"
+                            "$ref": "#/definitions/models.APIKey"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
                         }
                     },
                     "404": {
                         "description": "Not Found",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/models.Problem"
                         }
                     }
                 }
             }
         },
-        "/api/schedules/{id}/cancel-clock-in": {
+        "/api/auth/login": {
             "post": {
-                "description": "Cancels the clock-in by clearing time and location, and sets status back to \"scheduled\"",
+                "description": "Checks the username and password and returns a short-lived access token (a JWT to send as \"Authorization: Bearer \u003ctoken\u003e\") and a refresh token to get the next one with. Wrong credentials return 401 with code \"invalid_credentials\".",
                 "consumes": [
                     "application/json"
                 ],
@@ -151,81 +170,119 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Visits"
+                    "Auth"
                 ],
-                "summary": "Cancel clock-in",
+                "summary": "Log in",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Schedule ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Credentials",
+                        "name": "credentials",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.LoginRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Schedule"
+                            "$ref": "#/definitions/models.TokenResponse"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
                         }
                     }
                 }
             }
         },
-        "/api/schedules/{id}/clock-in": {
-            "get": {
-                "description": "Records the clock-in time and location for a schedule.",
+        "/api/auth/logout": {
+            "post": {
+                "description": "Revokes the refresh token. Access tokens already issued stay valid until they expire.",
                 "consumes": [
                     "application/json"
                 ],
-                "produces": [
-                    "application/json"
-                ],
                 "tags": [
-                    "Visits"
+                    "Auth"
                 ],
-                "summary": "Clock in for a schedule",
+                "summary": "Log out",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Schedule ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Refresh token",
+                        "name": "token",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.RefreshRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/auth/me": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
                     }
                 ],
+                "description": "Returns the principal the access token or API key identifies",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Get the current user",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Schedule"
+                            "$ref": "#/definitions/models.Principal"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/models.Problem"
                         }
                     }
                 }
             }
         },
-        "/api/schedules/{id}/end": {
+        "/api/auth/refresh": {
             "post": {
-                "description": "Marks an in-progress visit as \"completed\" and records the end time and location.",
+                "description": "Exchanges a refresh token for a new access token and a new refresh token; the old refresh token stops working. Presenting a refresh token that was already exchanged revokes every refresh token of the user, since it may have been stolen.",
                 "consumes": [
                     "application/json"
                 ],
@@ -233,24 +290,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Visits"
+                    "Auth"
                 ],
-                "summary": "End a visit",
+                "summary": "Refresh tokens",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Schedule ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "End Location",
-                        "name": "location",
+                        "description": "Refresh token",
+                        "name": "token",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.EndVisitRequest"
+                            "$ref": "#/definitions/models.RefreshRequest"
                         }
                     }
                 ],
@@ -258,33 +308,35 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Schedule"
+                            "$ref": "#/definitions/models.TokenResponse"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/models.Problem"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/models.Problem"
                         }
                     }
                 }
             }
         },
-        "/api/schedules/{id}/start": {
-            "post": {
-                "description": "Marks a scheduled visit as \"in_progress\" and records the start time and location.",
+        "/api/caregivers": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Fetches every caregiver, ordered by ID",
                 "consumes": [
                     "application/json"
                 ],
@@ -292,58 +344,91 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Visits"
+                    "Caregivers"
                 ],
-                "summary": "Start a visit",
-                "parameters": [
+                "summary": "Get all caregivers",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Caregiver"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
                     {
-                        "type": "string",
-                        "description": "Schedule ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "BearerAuth": []
                     },
                     {
-                        "description": "Start Location",
-                        "name": "location",
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Adds a caregiver with a generated ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Caregivers"
+                ],
+                "summary": "Create a caregiver",
+                "parameters": [
+                    {
+                        "description": "Caregiver",
+                        "name": "caregiver",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.StartVisitRequest"
+                            "$ref": "#/definitions/models.CaregiverRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/models.Schedule"
+                            "$ref": "#/definitions/models.Caregiver"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/models.Problem"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "403": {
+                        "description": "Forbidden",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/models.Problem"
                         }
                     }
                 }
             }
         },
-        "/api/schedules/{id}/tasks": {
-            "post": {
-                "description": "Adds a new task with name and description to the given schedule",
+        "/api/caregivers/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Fetches a single caregiver using their ID",
                 "consumes": [
                     "application/json"
                 ],
@@ -351,24 +436,74 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Tasks"
+                    "Caregivers"
                 ],
-                "summary": "Add a task to schedule",
+                "summary": "Get caregiver by ID",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Schedule ID",
+                        "description": "Caregiver ID",
                         "name": "id",
                         "in": "path",
                         "required": true
-                    },
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Caregiver"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
                     {
-                        "description": "Task to add",
-                        "name": "task",
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Replaces the name, credentials, phone and time zone of a caregiver",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Caregivers"
+                ],
+                "summary": "Update a caregiver",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Caregiver ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Caregiver",
+                        "name": "caregiver",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.AddTaskRequest"
+                            "$ref": "#/definitions/models.CaregiverRequest"
                         }
                     }
                 ],
@@ -376,33 +511,88 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Schedule"
+                            "$ref": "#/definitions/models.Caregiver"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
                         }
                     },
                     "404": {
                         "description": "Not Found",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Removes a caregiver. Returns 409 while schedules are still assigned to them; reassign those first.",
+                "tags": [
+                    "Caregivers"
+                ],
+                "summary": "Delete a caregiver",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Caregiver ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
                         }
                     }
                 }
             }
         },
-        "/api/tasks/{taskId}/update": {
-            "put": {
-                "description": "Updates the status of a specific task to \"completed\" or \"not_completed\".",
+        "/api/caregivers/{id}/schedules": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Fetches the schedules assigned to a caregiver, sorted chronologically. \"from\" and \"to\" (YYYY-MM-DD, inclusive) limit the shifts returned to those starting on those days, read in the \"tz\" zone, else the caregiver's, else the agency's.",
                 "consumes": [
                     "application/json"
                 ],
@@ -410,150 +600,3925 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Tasks"
+                    "Caregivers"
                 ],
-                "summary": "Update a task status",
+                "summary": "Get a caregiver's schedules",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Task ID",
-                        "name": "taskId",
+                        "type": "string",
+                        "description": "Caregiver ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Task Status Update",
-                        "name": "update",
-                        "in": "body",
-                        "required": true,
+                        "type": "string",
+                        "description": "First shift date (YYYY-MM-DD)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last shift date (YYYY-MM-DD)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone the dates are read in",
+                        "name": "tz",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.UpdateTaskRequest"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Schedule"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
                         }
                     }
+                }
+            }
+        },
+        "/api/clients": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Fetches every care recipient, ordered by ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Clients"
                 ],
+                "summary": "Get all clients",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Task"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Client"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Adds a care recipient with a generated ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Clients"
+                ],
+                "summary": "Create a client",
+                "parameters": [
+                    {
+                        "description": "Client",
+                        "name": "client",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ClientRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Client"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/clients/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Fetches a single care recipient using their ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Clients"
+                ],
+                "summary": "Get client by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Client ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Client"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
                         }
                     },
                     "404": {
                         "description": "Not Found",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Replaces a care recipient's details. The new name, contact details and location are copied onto the client's upcoming visits: those still scheduled, never clocked into and starting in the future. Visits under way or over keep the location their clock events were checked against.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Clients"
+                ],
+                "summary": "Update a client",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Client ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Client",
+                        "name": "client",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ClientRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Client"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Removes a care recipient. Returns 409 while schedules still reference them.",
+                "tags": [
+                    "Clients"
+                ],
+                "summary": "Delete a client",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Client ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/clients/{id}/care-plan": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Fetches the templates every new visit to the client starts with. A client without a plan has an empty one.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Care Plans"
+                ],
+                "summary": "Get a client's care plan",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Client ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.CarePlan"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Replaces the list of templates, in order, that are copied onto every schedule created or generated for the client from now on. Existing schedules keep their tasks.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Care Plans"
+                ],
+                "summary": "Set a client's care plan",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Client ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Care plan",
+                        "name": "plan",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.CarePlanRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.CarePlan"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/recurrences": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Fetches every recurring booking",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Recurrences"
+                ],
+                "summary": "Get all recurrences",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Recurrence"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Books recurring visits from an RFC 5545 rule. Supported: FREQ=DAILY or WEEKLY, INTERVAL, BYDAY (weekdays such as MO,WE,FR), COUNT and UNTIL. Schedules are generated for the occurrences from today through the configured window, and the window rolls forward hourly.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Recurrences"
+                ],
+                "summary": "Create a recurrence",
+                "parameters": [
+                    {
+                        "description": "Recurrence",
+                        "name": "recurrence",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.RecurrenceRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Recurrence"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/recurrences/roll": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Generates the missing schedules of every recurring booking up to the end of the window, as the server does hourly. For deployments that cannot run the hourly job, such as serverless ones. Schedules that exist or were deleted are left alone.",
+                "tags": [
+                    "Recurrences"
+                ],
+                "summary": "Expand recurrences",
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/recurrences/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Fetches a single recurring booking using its ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Recurrences"
+                ],
+                "summary": "Get recurrence by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Recurrence ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Recurrence"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Ends the series: upcoming schedules that have not started are removed along with the rule. Past schedules and any with clock data are kept.",
+                "tags": [
+                    "Recurrences"
+                ],
+                "summary": "Delete a recurrence",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Recurrence ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/recurrences/{id}/occurrences/{date}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "With scope=this (the default) the date becomes an exception and its schedule is removed; 409 if that visit has clock data. With scope=following the series ends the day before; upcoming schedules that have not started are removed and any with clock data are kept.",
+                "tags": [
+                    "Recurrences"
+                ],
+                "summary": "Delete an occurrence",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Recurrence ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Occurrence date (YYYY-MM-DD)",
+                        "name": "date",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "this",
+                            "following"
+                        ],
+                        "type": "string",
+                        "description": "this or following",
+                        "name": "scope",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the occurrence's schedule the change is based on (scope=this)",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "With scope=this (the default) only the schedule for that date changes, exactly like PATCH /api/schedules/{id}. With scope=following the series is split: the original rule ends the day before, and a new rule with the changes (including a new rrule) takes over from that date. Upcoming schedules that have not started are regenerated; schedules with clock data are kept as they are.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Recurrences"
+                ],
+                "summary": "Update an occurrence",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Recurrence ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Occurrence date (YYYY-MM-DD)",
+                        "name": "date",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "this",
+                            "following"
+                        ],
+                        "type": "string",
+                        "description": "this or following",
+                        "name": "scope",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the occurrence's schedule the change is based on (scope=this)",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Fields to change",
+                        "name": "changes",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.UpdateOccurrenceRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "scope=this; scope=following returns the models.Recurrence now covering the date",
+                        "schema": {
+                            "$ref": "#/definitions/models.Schedule"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/reset": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Resets the stored data to the initial set of schedules and tasks, useful for testing. Users, refresh tokens and API keys are kept.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Reset data store",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/schedules": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Lists schedules matching the filters, a page at a time. \"from\" and \"to\" (YYYY-MM-DD, inclusive) limit the shifts returned to those starting on those days, read in the \"tz\" zone, else the caregiver's zone when \"caregiverId\" is given, else the agency's. \"q\" searches client and service names, notes, address and tasks. The X-Total-Count header holds the number of matches; when there are more, X-Next-Cursor and a Link rel=\"next\" header give the next page, which stays consistent while schedules change.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schedules"
+                ],
+                "summary": "List schedules",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Comma-separated visit statuses",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only this client's schedules",
+                        "name": "clientId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only this caregiver's schedules",
+                        "name": "caregiverId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "First shift date (YYYY-MM-DD)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last shift date (YYYY-MM-DD)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone the dates are read in",
+                        "name": "tz",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Free-text search",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "shiftStart (default), clientName, serviceName or status; prefix with - to reverse",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 100, max 500)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "X-Next-Cursor from the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Schedule"
+                            }
+                        },
+                        "headers": {
+                            "X-Next-Cursor": {
+                                "type": "string",
+                                "description": "Cursor for the next page, if any"
+                            },
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "Number of matching schedules"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Books a visit for a client, optionally assigned to a caregiver. The client's name, contact details and location are copied onto the schedule, and its care plan tasks come before the tasks in the request. The shift is given in the legacy shiftDate/shiftTime/amOrPm form.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schedules"
+                ],
+                "summary": "Create a schedule",
+                "parameters": [
+                    {
+                        "description": "Schedule",
+                        "name": "schedule",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.CreateScheduleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Schedule"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/schedules/today": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Fetches all schedules starting today, where \"today\" is read in the \"tz\" zone, else the caregiver's zone when \"caregiverId\" is given, else the agency's.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schedules"
+                ],
+                "summary": "Get today's schedules",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only this caregiver's schedules",
+                        "name": "caregiverId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone",
+                        "name": "tz",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Schedule"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/schedules/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Fetches the details of a single schedule using its ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schedules"
+                ],
+                "summary": "Get schedule by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag from an earlier response",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Schedule"
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified"
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Removes a schedule with its tasks. Schedules whose visit has clock data are part of the EVV record and return 409; cancel the visit instead. Deleting an occurrence of a recurrence adds its date to the recurrence's exceptionDates, so it is not generated again.",
+                "tags": [
+                    "Schedules"
+                ],
+                "summary": "Delete a schedule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag the change is based on",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Changes the fields present in the body. Once a visit has clock data only serviceNotes can change; anything else returns 409. Visit status, clock data and tasks have their own endpoints.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schedules"
+                ],
+                "summary": "Update a schedule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Fields to change",
+                        "name": "changes",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.UpdateScheduleRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag the change is based on",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Schedule"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/schedules/{id}/cancel": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Moves a \"scheduled\" visit to \"cancelled\". Returns 409 from any other status.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Visits"
+                ],
+                "summary": "Cancel a visit",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag the change is based on",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Schedule"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/schedules/{id}/cancel-clock-in": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Cancels the clock-in by clearing time and location, and sets status back to \"scheduled\". The cancelled clock-in stays in the visit event log.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Visits"
+                ],
+                "summary": "Cancel clock-in",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag the change is based on",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Schedule"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/schedules/{id}/clock-in": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Records the clock-in time for a schedule. No location is sent, so the visit gets a \"clock_in_location_missing\" exception, or a 422 when geofences are enforced.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Visits"
+                ],
+                "summary": "Clock in for a schedule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag the change is based on",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Schedule"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/schedules/{id}/end": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Marks an in-progress visit as \"completed\" and records the end time and location. An optional device \"timestamp\" is honoured like on start, and must not precede the clock-in. Returns 409 unless the visit is \"in_progress\", or (code \"required_tasks_incomplete\") while a required task is neither completed nor given a not-completed reason. The location is checked against the client's geofence like on start.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Visits"
+                ],
+                "summary": "End a visit",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "End Location",
+                        "name": "location",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.EndVisitRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag the change is based on",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Schedule"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/schedules/{id}/events": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Fetches the immutable, ordered log of visit and task events recorded for a schedule",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Visits"
+                ],
+                "summary": "Get visit events",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.VisitEvent"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/schedules/{id}/mark-missed": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Moves a \"scheduled\" visit to \"missed\". Returns 409 from any other status.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Visits"
+                ],
+                "summary": "Mark a visit missed",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag the change is based on",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Schedule"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/schedules/{id}/start": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Marks a scheduled visit as \"in_progress\" and records the start time and location. An optional RFC 3339 \"timestamp\" from the device (for events queued offline) is used as the clock-in time if it is not in the future, not too old and not well before the shift; otherwise 422. The server receive time is stored alongside. Returns 409 unless the visit is \"scheduled\". The location is checked against the client's geofence: outside it the visit is flagged with an exception, or rejected with 422 when geofences are enforced.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Visits"
+                ],
+                "summary": "Start a visit",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Start Location",
+                        "name": "location",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.StartVisitRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag the change is based on",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Schedule"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/schedules/{id}/tasks": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Adds a new task with name and description to the given schedule, optionally marked required",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Tasks"
+                ],
+                "summary": "Add a task to schedule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Task to add",
+                        "name": "task",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.AddTaskRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag the change is based on",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Schedule"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/schedules/{id}/tasks/order": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Puts the schedule's tasks in the given order. taskIds must list every task of the schedule exactly once.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Tasks"
+                ],
+                "summary": "Reorder tasks",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Task order",
+                        "name": "order",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ReorderTasksRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag the change is based on",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Task"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/schedules/{id}/tasks/{taskId}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Fetches a task, with its outcome, from the schedule that owns it",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Tasks"
+                ],
+                "summary": "Get a task",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Task ID",
+                        "name": "taskId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag from an earlier response",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Task"
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Marks a task of the given schedule as completed, or not completed with a reason. Outcomes are only recorded while the visit is in progress; a visit not yet started, completed, missed or cancelled refuses them (409).",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Tasks"
+                ],
+                "summary": "Update a task status",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Task ID",
+                        "name": "taskId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Task Status Update",
+                        "name": "update",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.UpdateTaskRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag the change is based on",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Task"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Removes a task from the schedule. Tasks that were already marked completed or not completed stay part of the visit record and cannot be deleted (409).",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Tasks"
+                ],
+                "summary": "Delete a task",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Task ID",
+                        "name": "taskId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag the change is based on",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Renames or re-describes a task, or marks it required or optional. Fields left out are not changed. A task that already has an outcome, or whose visit has clock data, is part of the visit record and cannot be edited (409).",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Tasks"
+                ],
+                "summary": "Edit a task",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schedule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Task ID",
+                        "name": "taskId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Task changes",
+                        "name": "task",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.EditTaskRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag the change is based on",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Task"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/sync": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Replays start-visit, end-visit and task-update mutations in order. Each mutation carries a client-generated idempotency key; a key that was already applied is reported as a duplicate and not applied again, so batches are safe to retry. One rejected mutation does not stop the rest of the batch.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Sync"
+                ],
+                "summary": "Sync offline mutations",
+                "parameters": [
+                    {
+                        "description": "Queued mutations",
+                        "name": "batch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SyncRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SyncResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/task-outcomes": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Lists the not-completed reason codes and the bounds of every measurement. Readings outside min..max are rejected; readings outside the normal range are recorded and flagged on the visit.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Tasks"
+                ],
+                "summary": "Get task outcome codes",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.TaskOutcomeOptions"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/task-templates": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Fetches the catalog of reusable tasks, ordered by code",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Care Plans"
+                ],
+                "summary": "Get all task templates",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.TaskTemplate"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Adds a reusable task. The code (upper-case letters, digits, \"_\" and \"-\") identifies it and cannot be changed later. Returns 409 if the code is taken.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Care Plans"
+                ],
+                "summary": "Create a task template",
+                "parameters": [
+                    {
+                        "description": "Task template",
+                        "name": "template",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.TaskTemplateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.TaskTemplate"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/task-templates/{code}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Fetches a task template using its code",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Care Plans"
+                ],
+                "summary": "Get task template by code",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Template code",
+                        "name": "code",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.TaskTemplate"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Replaces a template's name, description, category and required flag. Schedules keep the copies they already have; care plans pick up the change for visits created from now on.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Care Plans"
+                ],
+                "summary": "Update a task template",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Template code",
+                        "name": "code",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Task template",
+                        "name": "template",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.TaskTemplateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.TaskTemplate"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Removes a task template. Returns 409 while care plans still list it.",
+                "tags": [
+                    "Care Plans"
+                ],
+                "summary": "Delete a task template",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Template code",
+                        "name": "code",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/tasks/{taskId}/update": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Updates the status of a specific task to \"completed\" or \"not_completed\". Task IDs are unique across schedules, so the owning schedule is looked up; prefer PUT /api/schedules/{id}/tasks/{taskId}.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Tasks"
+                ],
+                "summary": "Update a task status (deprecated)",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Task ID",
+                        "name": "taskId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Task Status Update",
+                        "name": "update",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.UpdateTaskRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag the change is based on",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Task"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "models.APIKey": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "createdBy": {
+                    "type": "string",
+                    "example": "u-admin"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "4b8f2c1e-7a3d-4f6b-9e2a-1c5d8f0b3a7e"
+                },
+                "lastUsedAt": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Payroll export"
+                },
+                "prefix": {
+                    "description": "Prefix is the start of the key, to tell keys apart.",
+                    "type": "string",
+                    "example": "evv_3f9a1c2b"
+                },
+                "revokedAt": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "schedules:read"
+                    ]
+                }
+            }
+        },
+        "models.AddTaskRequest": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "required": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "models.CarePlan": {
+            "type": "object",
+            "properties": {
+                "clientId": {
+                    "type": "string",
+                    "example": "1"
+                },
+                "tasks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.CarePlanTask"
+                    }
+                }
+            }
+        },
+        "models.CarePlanRequest": {
+            "type": "object",
+            "properties": {
+                "tasks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.CarePlanTask"
+                    }
+                }
+            }
+        },
+        "models.CarePlanTask": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string",
+                    "example": "Metformin 500 mg with breakfast."
+                },
+                "required": {
+                    "type": "boolean"
+                },
+                "templateCode": {
+                    "type": "string",
+                    "example": "MED"
+                }
+            }
+        },
+        "models.Caregiver": {
+            "type": "object",
+            "properties": {
+                "credentials": {
+                    "description": "Credentials lists the caregiver's certifications, e.g. \"HHA\" or \"CNA\".",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "HHA",
+                        "CPR"
+                    ]
+                },
+                "id": {
+                    "type": "string",
+                    "example": "1"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Sarah Lee"
+                },
+                "phone": {
+                    "type": "string",
+                    "example": "+1 555 987 6543"
+                },
+                "timeZone": {
+                    "description": "TimeZone (IANA) is where the caregiver works, for reading \"today\" and\ndate ranges in their listings. Empty means the agency's zone.",
+                    "type": "string",
+                    "example": "America/Chicago"
+                }
+            }
+        },
+        "models.CaregiverRequest": {
+            "type": "object",
+            "properties": {
+                "credentials": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "HHA",
+                        "CPR"
+                    ]
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Sarah Lee"
+                },
+                "phone": {
+                    "type": "string",
+                    "example": "+1 555 987 6543"
+                },
+                "timeZone": {
+                    "type": "string",
+                    "example": "America/Chicago"
+                }
+            }
+        },
+        "models.Client": {
+            "type": "object",
+            "properties": {
+                "careNotes": {
+                    "type": "string",
+                    "example": "Uses a walker; keep hallways clear."
+                },
+                "contact": {
+                    "$ref": "#/definitions/models.ClientContact"
+                },
+                "emergencyContacts": {
+                    "description": "EmergencyContacts are the people to call about the client, in order.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.EmergencyContact"
+                    }
+                },
+                "id": {
+                    "type": "string",
+                    "example": "1"
+                },
+                "location": {
+                    "$ref": "#/definitions/models.Location"
+                },
+                "medicaidId": {
+                    "type": "string",
+                    "example": "NY12345678"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Melisa Adam"
+                }
+            }
+        },
+        "models.ClientContact": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "example": "melisa@example.com"
+                },
+                "phone": {
+                    "type": "string",
+                    "example": "+44 1232 212 3233"
+                }
+            }
+        },
+        "models.ClientRequest": {
+            "type": "object",
+            "properties": {
+                "careNotes": {
+                    "type": "string",
+                    "example": "Uses a walker; keep hallways clear."
+                },
+                "contact": {
+                    "$ref": "#/definitions/models.ClientContact"
+                },
+                "emergencyContacts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.EmergencyContact"
+                    }
+                },
+                "location": {
+                    "$ref": "#/definitions/models.Location"
+                },
+                "medicaidId": {
+                    "type": "string",
+                    "example": "NY12345678"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Melisa Adam"
+                }
+            }
+        },
+        "models.CreateAPIKeyRequest": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "example": "Payroll export"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "schedules:read"
+                    ]
+                }
+            }
+        },
+        "models.CreateScheduleRequest": {
+            "type": "object",
+            "properties": {
+                "amOrPm": {
+                    "type": "string",
+                    "example": "AM"
+                },
+                "caregiverId": {
+                    "type": "string",
+                    "example": "1"
+                },
+                "clientId": {
+                    "type": "string",
+                    "example": "1"
+                },
+                "serviceName": {
+                    "type": "string",
+                    "example": "Casa Grande Apartment"
+                },
+                "serviceNotes": {
+                    "type": "string",
+                    "example": "Client may be a bit groggy."
+                },
+                "shiftDate": {
+                    "type": "string",
+                    "example": "2025-01-15"
+                },
+                "shiftEnd": {
+                    "type": "string",
+                    "example": "2025-01-15T10:00:00-06:00"
+                },
+                "shiftStart": {
+                    "type": "string",
+                    "example": "2025-01-15T09:00:00-06:00"
+                },
+                "shiftTime": {
+                    "type": "string",
+                    "example": "09:00 - 10:00"
+                },
+                "tasks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AddTaskRequest"
+                    }
+                },
+                "timeZone": {
+                    "type": "string",
+                    "example": "America/Chicago"
+                }
+            }
+        },
+        "models.CreatedAPIKey": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "createdBy": {
+                    "type": "string",
+                    "example": "u-admin"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "4b8f2c1e-7a3d-4f6b-9e2a-1c5d8f0b3a7e"
+                },
+                "key": {
+                    "type": "string",
+                    "example": "evv_3f9a1c2b_Jx0q5mZ2c4b7t9v1w3y5A7C9E1G3I5K7M9O1Q3S5U7W"
+                },
+                "lastUsedAt": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Payroll export"
+                },
+                "prefix": {
+                    "description": "Prefix is the start of the key, to tell keys apart.",
+                    "type": "string",
+                    "example": "evv_3f9a1c2b"
+                },
+                "revokedAt": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "schedules:read"
+                    ]
+                }
+            }
+        },
+        "models.EditTaskRequest": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Give medication"
+                },
+                "required": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "models.EmergencyContact": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "example": "Tom Adam"
+                },
+                "phone": {
+                    "type": "string",
+                    "example": "+1 555 222 3333"
+                },
+                "relationship": {
+                    "type": "string",
+                    "example": "Son"
+                }
+            }
+        },
+        "models.EndVisitRequest": {
+            "type": "object",
+            "properties": {
+                "location": {
+                    "$ref": "#/definitions/models.Geolocation"
+                },
+                "timestamp": {
+                    "description": "Timestamp is the device's RFC 3339 clock time, for events queued\noffline. Optional; the server time is used when empty.",
+                    "type": "string",
+                    "example": "2025-01-15T10:05:00-05:00"
+                }
+            }
+        },
+        "models.Geolocation": {
+            "type": "object",
+            "properties": {
+                "latitude": {
+                    "type": "number",
+                    "example": -6.2
+                },
+                "longitude": {
+                    "type": "number",
+                    "example": 106.816666
+                }
+            }
+        },
+        "models.Location": {
+            "type": "object",
+            "properties": {
+                "address": {
+                    "type": "string",
+                    "example": "123 Main St"
+                },
+                "coordinates": {
+                    "$ref": "#/definitions/models.Geolocation"
+                },
+                "geofenceRadiusMeters": {
+                    "description": "GeofenceRadiusMeters overrides the configured default radius for\nclock-in/out verification at this address.",
+                    "type": "number",
+                    "example": 150
+                }
+            }
+        },
+        "models.LoginRequest": {
+            "type": "object",
+            "properties": {
+                "password": {
+                    "type": "string",
+                    "example": "coordinator-demo"
+                },
+                "username": {
+                    "type": "string",
+                    "example": "coordinator"
+                }
+            }
+        },
+        "models.MeasurementRange": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string",
+                    "example": "pulseBpm"
+                },
+                "label": {
+                    "type": "string",
+                    "example": "Pulse"
+                },
+                "max": {
+                    "type": "number",
+                    "example": 250
+                },
+                "min": {
+                    "type": "number",
+                    "example": 20
+                },
+                "normalMax": {
+                    "type": "number",
+                    "example": 100
+                },
+                "normalMin": {
+                    "type": "number",
+                    "example": 60
+                },
+                "unit": {
+                    "type": "string",
+                    "example": "bpm"
+                }
+            }
+        },
+        "models.Measurements": {
+            "type": "object",
+            "properties": {
+                "diastolicBp": {
+                    "type": "number",
+                    "example": 80
+                },
+                "glucoseMgDl": {
+                    "type": "number",
+                    "example": 110
+                },
+                "insulinUnits": {
+                    "type": "number",
+                    "example": 6
+                },
+                "pulseBpm": {
+                    "type": "number",
+                    "example": 72
+                },
+                "systolicBp": {
+                    "type": "number",
+                    "example": 120
+                }
+            }
+        },
+        "models.NotCompletedReason": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "client_refused"
+                },
+                "label": {
+                    "type": "string",
+                    "example": "Client refused"
+                }
+            }
+        },
+        "models.Principal": {
+            "type": "object",
+            "properties": {
+                "apiKeyId": {
+                    "description": "APIKeyID is set when the caller used an API key; it has no role and\nmay do only what the key's Scopes allow.",
+                    "type": "string"
+                },
+                "caregiverId": {
+                    "type": "string",
+                    "example": "1"
+                },
+                "role": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Role"
+                        }
+                    ],
+                    "example": "coordinator"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "userId": {
+                    "type": "string",
+                    "example": "u-coordinator"
+                },
+                "username": {
+                    "type": "string",
+                    "example": "coordinator"
+                }
+            }
+        },
+        "models.Problem": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "not_found"
+                },
+                "detail": {
+                    "type": "string",
+                    "example": "Schedule 42 not found"
+                },
+                "instance": {
+                    "type": "string",
+                    "example": "/api/schedules/42"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 404
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Not Found"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "/problems/not_found"
+                }
+            }
+        },
+        "models.Recurrence": {
+            "type": "object",
+            "properties": {
+                "amOrPm": {
+                    "type": "string",
+                    "example": "AM"
+                },
+                "caregiverId": {
+                    "type": "string",
+                    "example": "1"
+                },
+                "clientId": {
+                    "type": "string",
+                    "example": "1"
+                },
+                "exceptionDates": {
+                    "description": "ExceptionDates (YYYY-MM-DD) are skipped, like EXDATE.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "string",
+                    "example": "6f0b8c1e-2d4a-4c8e-9a7b-1e2f3a4b5c6d"
+                },
+                "rrule": {
+                    "description": "RRule is an RFC 5545 rule; see ParseRRule for the supported subset.",
+                    "type": "string",
+                    "example": "FREQ=WEEKLY;BYDAY=MO,WE,FR;UNTIL=20250630"
+                },
+                "serviceName": {
+                    "type": "string",
+                    "example": "Casa Grande Apartment"
+                },
+                "serviceNotes": {
+                    "type": "string",
+                    "example": "Client may be a bit groggy."
+                },
+                "shiftTime": {
+                    "type": "string",
+                    "example": "09:00 - 10:00"
+                },
+                "startDate": {
+                    "description": "StartDate (YYYY-MM-DD) is the first possible occurrence, like DTSTART.",
+                    "type": "string",
+                    "example": "2025-01-13"
+                },
+                "tasks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AddTaskRequest"
+                    }
+                },
+                "timeZone": {
+                    "description": "TimeZone (IANA) is the zone the shift time is read in on each date.",
+                    "type": "string",
+                    "example": "America/Chicago"
+                }
+            }
+        },
+        "models.RecurrenceRequest": {
+            "type": "object",
+            "properties": {
+                "amOrPm": {
+                    "type": "string",
+                    "example": "AM"
+                },
+                "caregiverId": {
+                    "type": "string",
+                    "example": "1"
+                },
+                "clientId": {
+                    "type": "string",
+                    "example": "1"
+                },
+                "exceptionDates": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "rrule": {
+                    "type": "string",
+                    "example": "FREQ=WEEKLY;BYDAY=MO,WE,FR;UNTIL=20250630"
+                },
+                "serviceName": {
+                    "type": "string",
+                    "example": "Casa Grande Apartment"
+                },
+                "serviceNotes": {
+                    "type": "string",
+                    "example": "Client may be a bit groggy."
+                },
+                "shiftTime": {
+                    "type": "string",
+                    "example": "09:00 - 10:00"
+                },
+                "startDate": {
+                    "type": "string",
+                    "example": "2025-01-13"
+                },
+                "tasks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AddTaskRequest"
+                    }
+                },
+                "timeZone": {
+                    "type": "string",
+                    "example": "America/Chicago"
+                }
+            }
+        },
+        "models.RefreshRequest": {
+            "type": "object",
+            "properties": {
+                "refreshToken": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ReorderTasksRequest": {
+            "type": "object",
+            "properties": {
+                "taskIds": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        2,
+                        1
+                    ]
+                }
+            }
+        },
+        "models.Role": {
+            "type": "string",
+            "enum": [
+                "caregiver",
+                "coordinator",
+                "admin"
+            ],
+            "x-enum-varnames": [
+                "RoleCaregiver",
+                "RoleCoordinator",
+                "RoleAdmin"
+            ]
+        },
+        "models.Schedule": {
+            "type": "object",
+            "properties": {
+                "amOrPm": {
+                    "description": "\"AM\" or \"PM\"",
+                    "type": "string",
+                    "example": "AM"
+                },
+                "caregiverId": {
+                    "type": "string",
+                    "example": "1"
+                },
+                "clientContact": {
+                    "$ref": "#/definitions/models.ClientContact"
+                },
+                "clientId": {
+                    "type": "string",
+                    "example": "1"
+                },
+                "clientName": {
+                    "type": "string",
+                    "example": "Melisa Adam"
+                },
+                "clockInDistanceMeters": {
+                    "description": "Distance of each clock event from the client's coordinates.",
+                    "type": "number",
+                    "example": 42.5
+                },
+                "clockInLocation": {
+                    "$ref": "#/definitions/models.Geolocation"
+                },
+                "clockInReceivedAt": {
+                    "type": "string"
+                },
+                "clockInTime": {
+                    "description": "Clock times as reported by the device (or the server when the device\nsent none), and when the server actually received them.",
+                    "type": "string"
+                },
+                "clockOutDistanceMeters": {
+                    "type": "number",
+                    "example": 12.1
+                },
+                "clockOutLocation": {
+                    "$ref": "#/definitions/models.Geolocation"
+                },
+                "clockOutReceivedAt": {
+                    "type": "string"
+                },
+                "clockOutTime": {
+                    "type": "string"
+                },
+                "exceptions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.VisitException"
+                    }
+                },
+                "id": {
+                    "type": "string",
+                    "example": "1"
+                },
+                "location": {
+                    "$ref": "#/definitions/models.Location"
+                },
+                "occurrenceDate": {
+                    "type": "string",
+                    "example": "2025-01-15"
+                },
+                "recurrenceId": {
+                    "description": "RecurrenceID and OccurrenceDate are set on schedules generated from a\nRecurrence. OccurrenceDate keeps the generated date when the shift is\nmoved.",
+                    "type": "string",
+                    "example": "6f0b8c1e-2d4a-4c8e-9a7b-1e2f3a4b5c6d"
+                },
+                "serviceName": {
+                    "type": "string",
+                    "example": "Casa Grande Apartment"
+                },
+                "serviceNotes": {
+                    "type": "string",
+                    "example": "Client may be a bit groggy."
+                },
+                "shiftDate": {
+                    "description": "ShiftDate, ShiftTime and AmOrPm are the legacy form of the shift,\nderived from ShiftStart and ShiftEnd for older clients.",
+                    "type": "string",
+                    "example": "2025-01-15"
+                },
+                "shiftEnd": {
+                    "type": "string",
+                    "example": "2025-01-15T10:00:00-06:00"
+                },
+                "shiftStart": {
+                    "description": "ShiftStart and ShiftEnd are the planned instants, rendered in TimeZone\n(an IANA name). Set them with SetShift or SetLegacyShift.",
+                    "type": "string",
+                    "example": "2025-01-15T09:00:00-06:00"
+                },
+                "shiftTime": {
+                    "type": "string",
+                    "example": "09:00 - 10:00"
+                },
+                "status": {
+                    "enum": [
+                        "scheduled",
+                        "in_progress",
+                        "completed",
+                        "missed",
+                        "cancelled"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.VisitStatus"
+                        }
+                    ],
+                    "example": "scheduled"
+                },
+                "tasks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Task"
+                    }
+                },
+                "timeZone": {
+                    "type": "string",
+                    "example": "America/Chicago"
+                },
+                "version": {
+                    "description": "Version counts the changes to the schedule: edits to its plan or\ntasks, and every visit event recorded against it. It is served as the\nschedule's ETag.",
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "models.StartVisitRequest": {
+            "type": "object",
+            "properties": {
+                "location": {
+                    "$ref": "#/definitions/models.Geolocation"
+                },
+                "timestamp": {
+                    "description": "Timestamp is the device's RFC 3339 clock time, for events queued\noffline. Optional; the server time is used when empty.",
+                    "type": "string",
+                    "example": "2025-01-15T09:02:00-05:00"
+                }
+            }
+        },
+        "models.SyncMutation": {
+            "type": "object",
+            "properties": {
+                "completed": {
+                    "description": "update_task only.",
+                    "type": "boolean"
+                },
+                "idempotencyKey": {
+                    "type": "string",
+                    "example": "3f1c9a6e-5d7b-4e2a-9c1f-0b8d2e4a6c71"
+                },
+                "location": {
+                    "$ref": "#/definitions/models.Geolocation"
+                },
+                "measurements": {
+                    "$ref": "#/definitions/models.Measurements"
+                },
+                "notCompletedReason": {
+                    "type": "string"
+                },
+                "reasonCode": {
+                    "type": "string"
+                },
+                "scheduleId": {
+                    "description": "ScheduleID is required for start_visit and end_visit. For update_task\nit is optional; when given it must own the task.",
+                    "type": "string",
+                    "example": "1"
+                },
+                "taskId": {
+                    "description": "TaskID is required for update_task.",
+                    "type": "integer",
+                    "example": 1
+                },
+                "timestamp": {
+                    "description": "start_visit and end_visit only.",
+                    "type": "string",
+                    "example": "2025-01-15T09:02:00-05:00"
+                },
+                "type": {
+                    "enum": [
+                        "start_visit",
+                        "end_visit",
+                        "update_task"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.SyncMutationType"
                         }
-                    }
+                    ],
+                    "example": "start_visit"
                 }
             }
-        }
-    },
-    "definitions": {
-        "models.AddTaskRequest": {
+        },
+        "models.SyncMutationType": {
+            "type": "string",
+            "enum": [
+                "start_visit",
+                "end_visit",
+                "update_task"
+            ],
+            "x-enum-varnames": [
+                "MutationStartVisit",
+                "MutationEndVisit",
+                "MutationUpdateTask"
+            ]
+        },
+        "models.SyncRequest": {
             "type": "object",
             "properties": {
-                "description": {
-                    "type": "string"
+                "deviceId": {
+                    "type": "string",
+                    "example": "tablet-7"
                 },
-                "name": {
-                    "type": "string"
+                "mutations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SyncMutation"
+                    }
                 }
             }
         },
-        "models.ClientContact": {
+        "models.SyncResponse": {
             "type": "object",
             "properties": {
-                "email": {
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SyncResult"
+                    }
+                }
+            }
+        },
+        "models.SyncResult": {
+            "type": "object",
+            "properties": {
+                "code": {
                     "type": "string",
-                    "example": "melisa@example.com"
+                    "example": "illegal_status_transition"
                 },
-                "phone": {
+                "error": {
                     "type": "string",
-                    "example": "+44 1232 212 3233"
+                    "example": "visit cannot move from completed to in_progress"
+                },
+                "eventId": {
+                    "description": "EventID is the visit event recorded for the key, for applied and\nduplicate results.",
+                    "type": "integer",
+                    "example": 12
+                },
+                "idempotencyKey": {
+                    "type": "string",
+                    "example": "3f1c9a6e-5d7b-4e2a-9c1f-0b8d2e4a6c71"
+                },
+                "status": {
+                    "enum": [
+                        "applied",
+                        "duplicate",
+                        "rejected"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.SyncStatus"
+                        }
+                    ],
+                    "example": "applied"
                 }
             }
         },
-        "models.EndVisitRequest": {
+        "models.SyncStatus": {
+            "type": "string",
+            "enum": [
+                "applied",
+                "duplicate",
+                "rejected"
+            ],
+            "x-enum-varnames": [
+                "SyncApplied",
+                "SyncDuplicate",
+                "SyncRejected"
+            ]
+        },
+        "models.Task": {
             "type": "object",
             "properties": {
-                "location": {
-                    "$ref": "#/definitions/models.Geolocation"
+                "completed": {
+                    "type": "boolean"
                 },
-                "timestamp": {
-                    "type": "string"
+                "description": {
+                    "type": "string",
+                    "example": "Administer morning pills with water."
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "measurements": {
+                    "$ref": "#/definitions/models.Measurements"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Give medication"
+                },
+                "notCompletedReason": {
+                    "type": "string",
+                    "example": "Client refused medication."
+                },
+                "reasonCode": {
+                    "description": "ReasonCode is the coded reason a task was not completed, one of\nNotCompletedReasons.",
+                    "type": "string",
+                    "example": "client_refused"
+                },
+                "required": {
+                    "description": "Required tasks must be completed, or given a not-completed reason,\nbefore the visit can end.",
+                    "type": "boolean"
+                },
+                "templateCode": {
+                    "description": "TemplateCode is the catalog template the task was copied from, if any.",
+                    "type": "string",
+                    "example": "MED"
+                },
+                "version": {
+                    "description": "Version counts the edits to the task and the times it was marked. It\nis served as the task's ETag.",
+                    "type": "integer",
+                    "example": 2
                 }
             }
         },
-        "models.Geolocation": {
+        "models.TaskOutcomeOptions": {
             "type": "object",
             "properties": {
-                "latitude": {
-                    "type": "number",
-                    "example": -6.2
+                "measurementRanges": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.MeasurementRange"
+                    }
                 },
-                "longitude": {
-                    "type": "number",
-                    "example": 106.816666
+                "notCompletedReasons": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.NotCompletedReason"
+                    }
                 }
             }
         },
-        "models.Location": {
+        "models.TaskTemplate": {
             "type": "object",
             "properties": {
-                "address": {
+                "category": {
                     "type": "string",
-                    "example": "123 Main St"
+                    "example": "medication"
                 },
-                "coordinates": {
-                    "$ref": "#/definitions/models.Geolocation"
+                "code": {
+                    "type": "string",
+                    "example": "MED"
+                },
+                "description": {
+                    "type": "string",
+                    "example": "Administer medication as prescribed."
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Give medication"
+                },
+                "required": {
+                    "type": "boolean"
                 }
             }
         },
-        "models.Schedule": {
+        "models.TaskTemplateRequest": {
             "type": "object",
             "properties": {
-                "amOrPm": {
-                    "description": "\"AM\" or \"PM\"",
+                "category": {
                     "type": "string",
-                    "example": "AM"
+                    "example": "medication"
                 },
-                "clientContact": {
-                    "$ref": "#/definitions/models.ClientContact"
+                "code": {
+                    "description": "Code is only read on create; it cannot be changed afterwards.",
+                    "type": "string",
+                    "example": "MED"
                 },
-                "clientName": {
+                "description": {
                     "type": "string",
-                    "example": "Melisa Adam"
+                    "example": "Administer medication as prescribed."
                 },
-                "clockInLocation": {
-                    "$ref": "#/definitions/models.Geolocation"
+                "name": {
+                    "type": "string",
+                    "example": "Give medication"
                 },
-                "clockInTime": {
+                "required": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "models.TokenResponse": {
+            "type": "object",
+            "properties": {
+                "accessToken": {
                     "type": "string"
                 },
-                "clockOutLocation": {
-                    "$ref": "#/definitions/models.Geolocation"
+                "expiresIn": {
+                    "description": "ExpiresIn is the lifetime of the access token in seconds.",
+                    "type": "integer",
+                    "example": 900
                 },
-                "clockOutTime": {
+                "principal": {
+                    "$ref": "#/definitions/models.Principal"
+                },
+                "refreshExpiresAt": {
                     "type": "string"
                 },
-                "id": {
+                "refreshToken": {
+                    "type": "string"
+                },
+                "tokenType": {
+                    "type": "string",
+                    "example": "Bearer"
+                }
+            }
+        },
+        "models.UpdateOccurrenceRequest": {
+            "type": "object",
+            "properties": {
+                "amOrPm": {
+                    "type": "string",
+                    "example": "AM"
+                },
+                "caregiverId": {
+                    "type": "string",
+                    "example": "2"
+                },
+                "clientId": {
                     "type": "string",
                     "example": "1"
                 },
-                "location": {
-                    "$ref": "#/definitions/models.Location"
+                "rrule": {
+                    "type": "string",
+                    "example": "FREQ=WEEKLY;BYDAY=TU,TH"
                 },
                 "serviceName": {
                     "type": "string",
@@ -561,74 +4526,235 @@ const docTemplate = `{
                 },
                 "serviceNotes": {
                     "type": "string",
-                    "example": "Client may be a bit groggy."
+                    "example": "Bring the new glucose meter."
                 },
                 "shiftDate": {
                     "type": "string",
-                    "example": "2025-01-15"
+                    "example": "2025-01-16"
+                },
+                "shiftEnd": {
+                    "type": "string",
+                    "example": "2025-01-16T10:00:00-06:00"
+                },
+                "shiftStart": {
+                    "type": "string",
+                    "example": "2025-01-16T09:00:00-06:00"
                 },
                 "shiftTime": {
                     "type": "string",
                     "example": "09:00 - 10:00"
                 },
-                "status": {
-                    "description": "\"scheduled\", \"in-progress\", \"completed\", \"missed\", \"cancelled\"",
+                "timeZone": {
                     "type": "string",
-                    "example": "scheduled"
+                    "example": "America/Chicago"
+                }
+            }
+        },
+        "models.UpdateScheduleRequest": {
+            "type": "object",
+            "properties": {
+                "amOrPm": {
+                    "type": "string",
+                    "example": "AM"
                 },
-                "tasks": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/models.Task"
-                    }
+                "caregiverId": {
+                    "type": "string",
+                    "example": "2"
+                },
+                "clientId": {
+                    "type": "string",
+                    "example": "1"
+                },
+                "serviceName": {
+                    "type": "string",
+                    "example": "Casa Grande Apartment"
+                },
+                "serviceNotes": {
+                    "type": "string",
+                    "example": "Bring the new glucose meter."
+                },
+                "shiftDate": {
+                    "type": "string",
+                    "example": "2025-01-16"
+                },
+                "shiftEnd": {
+                    "type": "string",
+                    "example": "2025-01-16T10:00:00-06:00"
+                },
+                "shiftStart": {
+                    "type": "string",
+                    "example": "2025-01-16T09:00:00-06:00"
+                },
+                "shiftTime": {
+                    "type": "string",
+                    "example": "09:00 - 10:00"
+                },
+                "timeZone": {
+                    "type": "string",
+                    "example": "America/Chicago"
                 }
             }
         },
-        "models.StartVisitRequest": {
+        "models.UpdateTaskRequest": {
             "type": "object",
             "properties": {
-                "location": {
-                    "$ref": "#/definitions/models.Geolocation"
+                "completed": {
+                    "type": "boolean"
                 },
-                "timestamp": {
+                "measurements": {
+                    "$ref": "#/definitions/models.Measurements"
+                },
+                "notCompletedReason": {
                     "type": "string"
+                },
+                "reasonCode": {
+                    "type": "string",
+                    "example": "client_refused"
                 }
             }
         },
-        "models.Task": {
+        "models.VisitEvent": {
             "type": "object",
             "properties": {
                 "completed": {
                     "type": "boolean"
                 },
-                "description": {
-                    "type": "string",
-                    "example": "Administer morning pills with water."
+                "deviceTime": {
+                    "type": "string"
+                },
+                "distanceMeters": {
+                    "description": "Clock events only: distance from the client and the radius it was\nchecked against. DistanceMeters is nil when no location was sent.",
+                    "type": "number",
+                    "example": 42.5
+                },
+                "geofenceRadiusMeters": {
+                    "type": "number",
+                    "example": 150
                 },
                 "id": {
                     "type": "integer",
                     "example": 1
                 },
-                "name": {
+                "idempotencyKey": {
+                    "description": "IdempotencyKey is the client-generated key of the synced mutation that\nproduced the event, if any. Keys are unique across the log.",
                     "type": "string",
-                    "example": "Give medication"
+                    "example": "3f1c9a6e-5d7b-4e2a-9c1f-0b8d2e4a6c71"
+                },
+                "location": {
+                    "$ref": "#/definitions/models.Geolocation"
+                },
+                "measurements": {
+                    "$ref": "#/definitions/models.Measurements"
                 },
                 "notCompletedReason": {
+                    "type": "string"
+                },
+                "occurredAt": {
+                    "description": "OccurredAt is when the event happened: the device's timestamp when\none was sent, otherwise ReceivedAt.",
+                    "type": "string"
+                },
+                "reasonCode": {
+                    "type": "string"
+                },
+                "receivedAt": {
+                    "type": "string"
+                },
+                "scheduleId": {
                     "type": "string",
-                    "example": "Client refused medication."
+                    "example": "1"
+                },
+                "taskId": {
+                    "description": "TaskMarked only.",
+                    "type": "integer",
+                    "example": 1
+                },
+                "type": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.VisitEventType"
+                        }
+                    ],
+                    "example": "VisitStarted"
                 }
             }
         },
-        "models.UpdateTaskRequest": {
+        "models.VisitEventType": {
+            "type": "string",
+            "enum": [
+                "VisitStarted",
+                "VisitEnded",
+                "ClockedIn",
+                "ClockInCancelled",
+                "VisitMissed",
+                "VisitCancelled",
+                "TaskMarked"
+            ],
+            "x-enum-varnames": [
+                "EventVisitStarted",
+                "EventVisitEnded",
+                "EventClockedIn",
+                "EventClockInCancelled",
+                "EventVisitMissed",
+                "EventVisitCancelled",
+                "EventTaskMarked"
+            ]
+        },
+        "models.VisitException": {
             "type": "object",
             "properties": {
-                "completed": {
-                    "type": "boolean"
+                "code": {
+                    "type": "string",
+                    "example": "clock_in_outside_geofence"
                 },
-                "notCompletedReason": {
-                    "type": "string"
+                "eventId": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "field": {
+                    "type": "string",
+                    "example": "pulseBpm"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "Clock-in recorded 420m from the client, outside the 150m geofence."
+                },
+                "taskId": {
+                    "description": "TaskID and Field say which task reading an exception is about.",
+                    "type": "integer",
+                    "example": 6
                 }
             }
+        },
+        "models.VisitStatus": {
+            "type": "string",
+            "enum": [
+                "scheduled",
+                "in_progress",
+                "completed",
+                "missed",
+                "cancelled"
+            ],
+            "x-enum-varnames": [
+                "StatusScheduled",
+                "StatusInProgress",
+                "StatusCompleted",
+                "StatusMissed",
+                "StatusCancelled"
+            ]
+        }
+    },
+    "securityDefinitions": {
+        "ApiKeyAuth": {
+            "description": "An API key from POST /api/api-keys.",
+            "type": "apiKey",
+            "name": "X-API-Key",
+            "in": "header"
+        },
+        "BearerAuth": {
+            "description": "Type \"Bearer\" followed by a space and an access token from POST /api/auth/login.",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
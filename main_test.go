@@ -2,28 +2,154 @@ package main
 
 import (
 	"bytes"
+	"crypto/ed25519"
+	"database/sql"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/auth"
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/config"
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/handler"
 	"github.com/IkoAfianando/mini_evv_logger_go/pkg/models"
 	"github.com/IkoAfianando/mini_evv_logger_go/pkg/router"
 	"github.com/IkoAfianando/mini_evv_logger_go/pkg/store"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
-	_ "time"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+// testKey signs the access tokens of every test app, so the tests need no
+// configured keys and run offline.
+var testKey = must(auth.GenerateKey("test"))
+
+// adminToken is the access token of the demo admin.
+var adminToken = must(must(auth.NewKeyRing([]auth.Key{testKey}, time.Hour)).Issue(models.Principal{
+	UserID: "u-admin", Username: "admin", Role: models.RoleAdmin,
+}))
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
 func setupTest() (*fiber.App, *store.Store) {
 	dataStore := store.NewStore()
 	dataStore.SetupInitialData()
+	return newApp(dataStore, config.Default()), dataStore
+}
+
+// newApp builds the API over repo in demo mode, with the demo accounts.
+// Requests without an Authorization header are sent as the demo admin, so
+// tests that are not about authentication can leave it out.
+func newApp(repo store.Repository, cfg config.Config) *fiber.App {
+	cfg.SigningKeys = []auth.Key{testKey}
+	cfg.Demo = true
+	if err := handler.BootstrapUsers(repo, cfg); err != nil {
+		panic(err)
+	}
+	app := fiber.New(router.AppConfig())
+	app.Use(func(c *fiber.Ctx) error {
+		if c.Get(fiber.HeaderAuthorization) == "" {
+			c.Request().Header.Set(fiber.HeaderAuthorization, "Bearer "+adminToken)
+		}
+		return c.Next()
+	})
+	if err := router.SetupRoutes(app, repo, cfg); err != nil {
+		panic(err)
+	}
+	return app
+}
+
+func getSchedule(t *testing.T, repo store.Repository, id string) *models.Schedule {
+	t.Helper()
+	schedule, err := repo.GetSchedule(id)
+	require.NoError(t, err)
+	return schedule
+}
+
+// persists runs mutate on a new SQLite store, closes it and runs check on the
+// same database opened again, so check only sees what was written to disk.
+func persists(t *testing.T, mutate, check func(repo *store.SQLiteStore)) {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "evv.db")
+	sqliteStore, err := store.NewSQLiteStore(dbPath)
+	require.NoError(t, err)
+	mutate(sqliteStore)
+	require.NoError(t, sqliteStore.Close())
+
+	reopened, err := store.NewSQLiteStore(dbPath)
+	require.NoError(t, err)
+	defer reopened.Close()
+	check(reopened)
+}
+
+// migratedStore opens a SQLite store on a database that had stmts run on it
+// directly, so that startup migrates what an older release left behind.
+func migratedStore(t *testing.T, stmts ...string) *store.SQLiteStore {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "evv.db")
+	sqliteStore, err := store.NewSQLiteStore(dbPath)
+	require.NoError(t, err)
+	require.NoError(t, sqliteStore.Close())
+
+	db, err := sql.Open("sqlite", dbPath)
+	require.NoError(t, err)
+	for _, stmt := range stmts {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+	require.NoError(t, db.Close())
+
+	sqliteStore, err = store.NewSQLiteStore(dbPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { sqliteStore.Close() })
+	return sqliteStore
+}
+
+// startVisit clocks into schedule id at its client's location, so that task
+// outcomes can be recorded on it.
+func startVisit(t *testing.T, app *fiber.App, id string) {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/schedules/"+id, nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var schedule models.Schedule
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&schedule))
+
+	body := fmt.Sprintf(`{"location": {"latitude": %v, "longitude": %v}}`,
+		schedule.Location.Coordinates.Latitude, schedule.Location.Coordinates.Longitude)
+	req := httptest.NewRequest("POST", "/api/schedules/"+id+"/start", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
 
-	app := fiber.New()
-	router.SetupRoutes(app, dataStore)
+// validationProblem is the body of a 400 from a request that failed
+// validation.
+type validationProblem struct {
+	Code   string              `json:"code"`
+	Fields []models.FieldError `json:"fields"`
+}
 
-	return app, dataStore
+func decodeValidation(t *testing.T, resp *http.Response) validationProblem {
+	t.Helper()
+	var problem validationProblem
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+	return problem
 }
 
 func TestScheduleHandlers(t *testing.T) {
@@ -45,6 +171,8 @@ func TestScheduleHandlers(t *testing.T) {
 		assert.Equal(t, "3", schedules[1].ID)
 		assert.Equal(t, "2", schedules[2].ID)
 		assert.Equal(t, "4", schedules[3].ID)
+		assert.Equal(t, "6", schedules[4].ID)
+		assert.Equal(t, "5", schedules[5].ID)
 	})
 
 	t.Run("Get Today's Schedules - Success", func(t *testing.T) {
@@ -80,6 +208,7 @@ func TestScheduleHandlers(t *testing.T) {
 
 func TestTaskHandlers(t *testing.T) {
 	app, dataStore := setupTest()
+	startVisit(t, app, "1")
 
 	t.Run("Update Task - Success", func(t *testing.T) {
 		updateBody := `{"completed": true}`
@@ -93,7 +222,7 @@ func TestTaskHandlers(t *testing.T) {
 		json.NewDecoder(resp.Body).Decode(&updatedTask)
 		assert.True(t, updatedTask.Completed)
 
-		assert.True(t, dataStore.Schedules["1"].Tasks[0].Completed)
+		assert.True(t, getSchedule(t, dataStore, "1").Tasks[0].Completed)
 	})
 
 	t.Run("Update Task - Not Completed with Reason", func(t *testing.T) {
@@ -109,7 +238,7 @@ func TestTaskHandlers(t *testing.T) {
 		assert.False(t, updatedTask.Completed)
 		assert.Equal(t, "Client refused", updatedTask.NotCompletedReason)
 
-		assert.Equal(t, "Client refused", dataStore.Schedules["1"].Tasks[1].NotCompletedReason)
+		assert.Equal(t, "Client refused", getSchedule(t, dataStore, "1").Tasks[1].NotCompletedReason)
 	})
 
 	t.Run("Update Task - Not Found", func(t *testing.T) {
@@ -121,7 +250,7 @@ func TestTaskHandlers(t *testing.T) {
 	})
 
 	t.Run("Add Task to Schedule - Success", func(t *testing.T) {
-		assert.Len(t, dataStore.Schedules["2"].Tasks, 2)
+		assert.Len(t, getSchedule(t, dataStore, "2").Tasks, 2)
 
 		taskBody := `{"name": "New Task", "description": "A new test task"}`
 		req := httptest.NewRequest("POST", "/api/schedules/2/tasks", bytes.NewBufferString(taskBody))
@@ -130,8 +259,9 @@ func TestTaskHandlers(t *testing.T) {
 		resp, _ := app.Test(req)
 		assert.Equal(t, http.StatusOK, resp.StatusCode)
 
-		assert.Len(t, dataStore.Schedules["2"].Tasks, 3)
-		lastTask := dataStore.Schedules["2"].Tasks[2]
+		tasks := getSchedule(t, dataStore, "2").Tasks
+		assert.Len(t, tasks, 3)
+		lastTask := tasks[2]
 		assert.Equal(t, "New Task", lastTask.Name)
 		assert.Equal(t, "A new test task", lastTask.Description)
 	})
@@ -141,7 +271,7 @@ func TestVisitFlow(t *testing.T) {
 	app, dataStore := setupTest()
 
 	t.Run("Start and End Visit Flow", func(t *testing.T) {
-		assert.Equal(t, "scheduled", dataStore.Schedules["2"].Status)
+		assert.Equal(t, models.StatusScheduled, getSchedule(t, dataStore, "2").Status)
 
 		startBody := `{"location": {"latitude": 10.0, "longitude": 20.0}}`
 		startReq := httptest.NewRequest("POST", "/api/schedules/2/start", bytes.NewBufferString(startBody))
@@ -149,8 +279,8 @@ func TestVisitFlow(t *testing.T) {
 		startResp, _ := app.Test(startReq)
 		assert.Equal(t, http.StatusOK, startResp.StatusCode)
 
-		schedule := dataStore.Schedules["2"]
-		assert.Equal(t, "in_progress", schedule.Status)
+		schedule := getSchedule(t, dataStore, "2")
+		assert.Equal(t, models.StatusInProgress, schedule.Status)
 		assert.NotNil(t, schedule.ClockInTime)
 		assert.Equal(t, 10.0, schedule.ClockInLocation.Latitude)
 
@@ -160,45 +290,2732 @@ func TestVisitFlow(t *testing.T) {
 		endResp, _ := app.Test(endReq)
 		assert.Equal(t, http.StatusOK, endResp.StatusCode)
 
-		assert.Equal(t, "completed", schedule.Status)
+		schedule = getSchedule(t, dataStore, "2")
+		assert.Equal(t, models.StatusCompleted, schedule.Status)
 		assert.NotNil(t, schedule.ClockOutTime)
 		assert.Equal(t, 10.1, schedule.ClockOutLocation.Latitude)
 	})
 
 	t.Run("Clock-in and Cancel Flow", func(t *testing.T) {
-		assert.Equal(t, "scheduled", dataStore.Schedules["4"].Status)
+		assert.Equal(t, models.StatusScheduled, getSchedule(t, dataStore, "4").Status)
 
 		clockInReq := httptest.NewRequest("GET", "/api/schedules/4/clock-in", nil)
 		clockInResp, _ := app.Test(clockInReq)
 		assert.Equal(t, http.StatusOK, clockInResp.StatusCode)
 
-		schedule := dataStore.Schedules["4"]
-		assert.Equal(t, "in_progress", schedule.Status)
+		schedule := getSchedule(t, dataStore, "4")
+		assert.Equal(t, models.StatusInProgress, schedule.Status)
 		assert.NotNil(t, schedule.ClockInTime)
 
 		cancelReq := httptest.NewRequest("POST", "/api/schedules/4/cancel-clock-in", nil)
 		cancelResp, _ := app.Test(cancelReq)
 		assert.Equal(t, http.StatusOK, cancelResp.StatusCode)
 
-		assert.Equal(t, "scheduled", schedule.Status)
+		schedule = getSchedule(t, dataStore, "4")
+		assert.Equal(t, models.StatusScheduled, schedule.Status)
 		assert.Nil(t, schedule.ClockInTime)
 		assert.Nil(t, schedule.ClockInLocation)
 	})
+
+	t.Run("Illegal Transitions Are Rejected", func(t *testing.T) {
+		endReq := httptest.NewRequest("POST", "/api/schedules/1/end", bytes.NewBufferString(`{"location": {"latitude": 1, "longitude": 2}}`))
+		endReq.Header.Set("Content-Type", "application/json")
+		endResp, _ := app.Test(endReq)
+		assert.Equal(t, http.StatusConflict, endResp.StatusCode)
+
+		var body map[string]string
+		json.NewDecoder(endResp.Body).Decode(&body)
+		assert.Equal(t, "illegal_status_transition", body["code"])
+		assert.Equal(t, "scheduled", body["from"])
+		assert.Equal(t, "completed", body["to"])
+		assert.Equal(t, models.StatusScheduled, getSchedule(t, dataStore, "1").Status)
+
+		startReq := httptest.NewRequest("POST", "/api/schedules/2/start", bytes.NewBufferString(`{"location": {"latitude": 1, "longitude": 2}}`))
+		startReq.Header.Set("Content-Type", "application/json")
+		startResp, _ := app.Test(startReq)
+		assert.Equal(t, http.StatusConflict, startResp.StatusCode)
+
+		missedResp, _ := app.Test(httptest.NewRequest("GET", "/api/schedules/6/clock-in", nil))
+		assert.Equal(t, http.StatusConflict, missedResp.StatusCode)
+	})
+
+	t.Run("Cancel and Miss Scheduled Visits", func(t *testing.T) {
+		cancelResp, _ := app.Test(httptest.NewRequest("POST", "/api/schedules/5/cancel", nil))
+		assert.Equal(t, http.StatusOK, cancelResp.StatusCode)
+		assert.Equal(t, models.StatusCancelled, getSchedule(t, dataStore, "5").Status)
+
+		missResp, _ := app.Test(httptest.NewRequest("POST", "/api/schedules/5/mark-missed", nil))
+		assert.Equal(t, http.StatusConflict, missResp.StatusCode)
+
+		missResp, _ = app.Test(httptest.NewRequest("POST", "/api/schedules/1/mark-missed", nil))
+		assert.Equal(t, http.StatusOK, missResp.StatusCode)
+		assert.Equal(t, models.StatusMissed, getSchedule(t, dataStore, "1").Status)
+	})
+
+	t.Run("Event Log Keeps Cancelled Clock-In", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/schedules/4/events", nil)
+		resp, _ := app.Test(req)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var events []models.VisitEvent
+		json.NewDecoder(resp.Body).Decode(&events)
+		require.Len(t, events, 2)
+		assert.Equal(t, models.EventClockedIn, events[0].Type)
+		assert.Equal(t, models.EventClockInCancelled, events[1].Type)
+		assert.Less(t, events[0].ID, events[1].ID)
+
+		notFoundResp, _ := app.Test(httptest.NewRequest("GET", "/api/schedules/999/events", nil))
+		assert.Equal(t, http.StatusNotFound, notFoundResp.StatusCode)
+	})
 }
 
 func TestAdminHandlers(t *testing.T) {
 	app, dataStore := setupTest()
 
 	t.Run("Reset Store", func(t *testing.T) {
-		originalStatus := dataStore.Schedules["1"].Status
-		dataStore.Schedules["1"].Status = "testing_change"
-		assert.NotEqual(t, originalStatus, dataStore.Schedules["1"].Status)
+		originalStatus := getSchedule(t, dataStore, "1").Status
+		clockInResp, _ := app.Test(httptest.NewRequest("GET", "/api/schedules/1/clock-in", nil))
+		assert.Equal(t, http.StatusOK, clockInResp.StatusCode)
+		assert.NotEqual(t, originalStatus, getSchedule(t, dataStore, "1").Status)
 
 		req := httptest.NewRequest("POST", "/api/reset", nil)
 		resp, _ := app.Test(req)
 
 		assert.Equal(t, http.StatusOK, resp.StatusCode)
 
-		assert.Equal(t, originalStatus, dataStore.Schedules["1"].Status)
+		assert.Equal(t, originalStatus, getSchedule(t, dataStore, "1").Status)
+	})
+}
+
+func TestSQLiteStorePersistence(t *testing.T) {
+	t.Run("Visit and Task Updates Survive Restart", func(t *testing.T) {
+		persists(t, func(dataStore *store.SQLiteStore) {
+			app := newApp(dataStore, config.Default())
+			startBody := `{"location": {"latitude": 10.0, "longitude": 20.0}}`
+			startReq := httptest.NewRequest("POST", "/api/schedules/2/start", bytes.NewBufferString(startBody))
+			startReq.Header.Set("Content-Type", "application/json")
+			startResp, _ := app.Test(startReq)
+			assert.Equal(t, http.StatusOK, startResp.StatusCode)
+
+			updateReq := httptest.NewRequest("PUT", "/api/tasks/3/update", bytes.NewBufferString(`{"completed": true}`))
+			updateReq.Header.Set("Content-Type", "application/json")
+			updateResp, _ := app.Test(updateReq)
+			assert.Equal(t, http.StatusOK, updateResp.StatusCode)
+		}, func(reopened *store.SQLiteStore) {
+			schedule, err := reopened.GetSchedule("2")
+			assert.NoError(t, err)
+			assert.Equal(t, models.StatusInProgress, schedule.Status)
+			assert.NotNil(t, schedule.ClockInTime)
+			assert.Equal(t, 10.0, schedule.ClockInLocation.Latitude)
+			assert.Nil(t, schedule.ClockOutTime)
+			assert.True(t, schedule.Tasks[0].Completed)
+
+			schedules, err := reopened.ListSchedules()
+			assert.NoError(t, err)
+			assert.Len(t, schedules, 6)
+
+			events, err := reopened.ListEvents("2")
+			assert.NoError(t, err)
+			require.Len(t, events, 2)
+			assert.Equal(t, models.EventVisitStarted, events[0].Type)
+			assert.Equal(t, models.EventTaskMarked, events[1].Type)
+			assert.Equal(t, 3, events[1].TaskID)
+
+			keyed := models.VisitEvent{ScheduleID: "2", Type: models.EventTaskMarked, OccurredAt: time.Now(), TaskID: 4, IdempotencyKey: "k-1"}
+			require.NoError(t, reopened.AppendEvent(&keyed))
+			again := keyed
+			assert.ErrorIs(t, reopened.AppendEvent(&again), store.ErrDuplicateKey)
+			found, err := reopened.GetEventByIdempotencyKey("k-1")
+			require.NoError(t, err)
+			assert.Equal(t, keyed.ID, found.ID)
+
+			caregiver, err := reopened.GetCaregiver("1")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"HHA", "CPR"}, caregiver.Credentials)
+			assert.Equal(t, "2", schedule.CaregiverID)
+			assert.ErrorIs(t, reopened.DeleteCaregiver("2"), store.ErrInUse)
+
+			client, err := reopened.GetClient("2")
+			require.NoError(t, err)
+			client.Location.Address = "458 Oak Ave, Springfield, IL"
+			require.NoError(t, reopened.UpdateClient(client))
+			client, err = reopened.GetClient("2")
+			require.NoError(t, err)
+			assert.Equal(t, "458 Oak Ave, Springfield, IL", client.Location.Address)
+			// The visit under way keeps the address it was clocked into at.
+			assert.Equal(t, "456 Oak Ave, Springfield, IL", getSchedule(t, reopened, "2").Location.Address)
+			assert.ErrorIs(t, reopened.DeleteClient("2"), store.ErrInUse)
+
+			require.NoError(t, reopened.DeleteSchedule("4"))
+			_, err = reopened.GetSchedule("4")
+			assert.ErrorIs(t, err, store.ErrNotFound)
+			assert.NoError(t, reopened.DeleteClient("4"))
+		})
+	})
+}
+
+func TestGeofence(t *testing.T) {
+	postJSON := func(app *fiber.App, url, body string) *http.Response {
+		req := httptest.NewRequest("POST", url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+		return resp
+	}
+	atClient := `{"location": {"latitude": 40.7128, "longitude": -74.0060}}`
+	farAway := `{"location": {"latitude": 40.7300, "longitude": -74.0060}}`
+
+	t.Run("Flag Mode Records Distance and Exceptions", func(t *testing.T) {
+		app, dataStore := setupTest()
+
+		resp := postJSON(app, "/api/schedules/2/start", atClient)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		schedule := getSchedule(t, dataStore, "2")
+		require.NotNil(t, schedule.ClockInDistanceMeters)
+		assert.Less(t, *schedule.ClockInDistanceMeters, 150.0)
+		assert.Empty(t, schedule.Exceptions)
+
+		resp = postJSON(app, "/api/schedules/2/end", farAway)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		schedule = getSchedule(t, dataStore, "2")
+		require.NotNil(t, schedule.ClockOutDistanceMeters)
+		assert.InDelta(t, 1900, *schedule.ClockOutDistanceMeters, 50)
+		require.Len(t, schedule.Exceptions, 1)
+		assert.Equal(t, "clock_out_outside_geofence", schedule.Exceptions[0].Code)
+	})
+
+	t.Run("Cancelled Clock-In Drops Its Exception", func(t *testing.T) {
+		app, dataStore := setupTest()
+
+		clockInResp, _ := app.Test(httptest.NewRequest("GET", "/api/schedules/4/clock-in", nil))
+		assert.Equal(t, http.StatusOK, clockInResp.StatusCode)
+		schedule := getSchedule(t, dataStore, "4")
+		require.Len(t, schedule.Exceptions, 1)
+		assert.Equal(t, "clock_in_location_missing", schedule.Exceptions[0].Code)
+
+		resp := postJSON(app, "/api/schedules/4/cancel-clock-in", "")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Empty(t, getSchedule(t, dataStore, "4").Exceptions)
+	})
+
+	t.Run("Reject Mode Refuses Clock Events Outside the Geofence", func(t *testing.T) {
+		dataStore := store.NewStore()
+		dataStore.SetupInitialData()
+		cfg := config.Default()
+		cfg.GeofenceMode = config.GeofenceReject
+		app := newApp(dataStore, cfg)
+
+		resp := postJSON(app, "/api/schedules/2/start", farAway)
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+		var body map[string]any
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Equal(t, "outside_geofence", body["code"])
+		assert.Equal(t, 150.0, body["radiusMeters"])
+		assert.Equal(t, models.StatusScheduled, getSchedule(t, dataStore, "2").Status)
+
+		clockInResp, _ := app.Test(httptest.NewRequest("GET", "/api/schedules/4/clock-in", nil))
+		assert.Equal(t, http.StatusUnprocessableEntity, clockInResp.StatusCode)
+
+		resp = postJSON(app, "/api/schedules/2/start", atClient)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		// A per-schedule radius overrides the configured default.
+		schedule := getSchedule(t, dataStore, "5")
+		schedule.Location.GeofenceRadiusMeters = 5000
+		require.NoError(t, dataStore.UpdateSchedule(schedule))
+		resp = postJSON(app, "/api/schedules/5/start", farAway)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+}
+
+func TestOfflineTimestamps(t *testing.T) {
+	app, dataStore := setupTest()
+	post := func(url string, timestamp string) *http.Response {
+		body := `{"timestamp": "` + timestamp + `", "location": {"latitude": 40.7128, "longitude": -74.0060}}`
+		req := httptest.NewRequest("POST", url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+		return resp
+	}
+	codeOf := func(resp *http.Response) string {
+		var body map[string]any
+		json.NewDecoder(resp.Body).Decode(&body)
+		code, _ := body["code"].(string)
+		return code
+	}
+	deviceZone := time.FixedZone("UTC-5", -5*60*60)
+
+	t.Run("Device Time Is Stored With Receive Time", func(t *testing.T) {
+		deviceTime := time.Now().Add(-10 * time.Minute).In(deviceZone).Truncate(time.Second)
+		resp := post("/api/schedules/1/start", deviceTime.Format(time.RFC3339))
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		schedule := getSchedule(t, dataStore, "1")
+		require.NotNil(t, schedule.ClockInTime)
+		assert.True(t, deviceTime.Equal(*schedule.ClockInTime))
+		require.NotNil(t, schedule.ClockInReceivedAt)
+		assert.WithinDuration(t, time.Now(), *schedule.ClockInReceivedAt, time.Minute)
+
+		events, err := dataStore.ListEvents("1")
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.NotNil(t, events[0].DeviceTime)
+		assert.True(t, deviceTime.Equal(*events[0].DeviceTime))
+	})
+
+	t.Run("Out of Bounds Device Times Are Rejected", func(t *testing.T) {
+		clockIn := *getSchedule(t, dataStore, "1").ClockInTime
+
+		resp := post("/api/schedules/1/end", clockIn.Add(-time.Minute).Format(time.RFC3339))
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+		assert.Equal(t, "timestamp_before_clock_in", codeOf(resp))
+
+		resp = post("/api/schedules/1/end", time.Now().Add(10*time.Minute).Format(time.RFC3339))
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+		assert.Equal(t, "timestamp_in_future", codeOf(resp))
+
+		resp = post("/api/schedules/2/start", time.Now().Add(-96*time.Hour).Format(time.RFC3339))
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+		assert.Equal(t, "timestamp_too_old", codeOf(resp))
+
+		y, m, d := time.Now().Date()
+		beforeShift := time.Date(y, m, d-1, 23, 0, 0, 0, time.Local)
+		resp = post("/api/schedules/5/start", beforeShift.Format(time.RFC3339))
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+		assert.Equal(t, "timestamp_before_shift", codeOf(resp))
+
+		resp = post("/api/schedules/2/start", "yesterday at noon")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "invalid_timestamp", codeOf(resp))
+
+		assert.Equal(t, models.StatusScheduled, getSchedule(t, dataStore, "2").Status)
+		assert.Equal(t, models.StatusInProgress, getSchedule(t, dataStore, "1").Status)
+	})
+}
+
+func TestOfflineSync(t *testing.T) {
+	app, dataStore := setupTest()
+	sync := func(body string) models.SyncResponse {
+		req := httptest.NewRequest("POST", "/api/sync", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var result models.SyncResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		return result
+	}
+	batch := `{"deviceId": "tablet-7", "mutations": [
+		{"idempotencyKey": "k-start", "type": "start_visit", "scheduleId": "1", "location": {"latitude": 40.7128, "longitude": -74.0060}},
+		{"idempotencyKey": "k-task", "type": "update_task", "taskId": 1, "completed": true},
+		{"idempotencyKey": "k-end", "type": "end_visit", "scheduleId": "1", "location": {"latitude": 40.7128, "longitude": -74.0060}},
+		{"idempotencyKey": "k-late", "type": "start_visit", "scheduleId": "3", "location": {"latitude": 40.7128, "longitude": -74.0060}},
+		{"type": "update_task", "taskId": 2, "completed": true}
+	]}`
+
+	t.Run("Batch Applies In Order And Rejects With Reasons", func(t *testing.T) {
+		result := sync(batch)
+		require.Len(t, result.Results, 5)
+		for _, r := range result.Results[:3] {
+			assert.Equal(t, models.SyncApplied, r.Status, r.IdempotencyKey)
+			assert.NotZero(t, r.EventID)
+		}
+		assert.Equal(t, models.SyncRejected, result.Results[3].Status)
+		assert.Equal(t, "illegal_status_transition", result.Results[3].Code)
+		assert.Equal(t, models.SyncRejected, result.Results[4].Status)
+		assert.Equal(t, "missing_idempotency_key", result.Results[4].Code)
+
+		schedule := getSchedule(t, dataStore, "1")
+		assert.Equal(t, models.StatusCompleted, schedule.Status)
+		assert.True(t, schedule.Tasks[0].Completed)
+		assert.False(t, schedule.Tasks[1].Completed)
+	})
+
+	t.Run("Retried Batch Is Reported As Duplicate", func(t *testing.T) {
+		before, err := dataStore.ListEvents("1")
+		require.NoError(t, err)
+
+		result := sync(batch)
+		require.Len(t, result.Results, 5)
+		for i, r := range result.Results[:3] {
+			assert.Equal(t, models.SyncDuplicate, r.Status, r.IdempotencyKey)
+			assert.Equal(t, before[i].ID, r.EventID)
+		}
+
+		after, err := dataStore.ListEvents("1")
+		require.NoError(t, err)
+		assert.Len(t, after, len(before))
+	})
+
+	t.Run("Reused Key For Another Mutation Is Rejected", func(t *testing.T) {
+		result := sync(`{"mutations": [{"idempotencyKey": "k-start", "type": "start_visit", "scheduleId": "2"}]}`)
+		require.Len(t, result.Results, 1)
+		assert.Equal(t, models.SyncRejected, result.Results[0].Status)
+		assert.Equal(t, "idempotency_key_reused", result.Results[0].Code)
+	})
+}
+
+func TestCaregivers(t *testing.T) {
+	app, dataStore := setupTest()
+	send := func(method, url, body string) *http.Response {
+		req := httptest.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+		return resp
+	}
+
+	t.Run("Create, Update and Delete", func(t *testing.T) {
+		resp := send("POST", "/api/caregivers", `{"name": "Ana Ruiz", "credentials": ["HHA"], "phone": "+1 555 000 1111"}`)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		var created models.Caregiver
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+		assert.NotEmpty(t, created.ID)
+		assert.Equal(t, []string{"HHA"}, created.Credentials)
+
+		resp = send("PUT", "/api/caregivers/"+created.ID, `{"name": "Ana Ruiz", "credentials": ["HHA", "CNA"]}`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		stored, err := dataStore.GetCaregiver(created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"HHA", "CNA"}, stored.Credentials)
+
+		resp = send("DELETE", "/api/caregivers/"+created.ID, "")
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		resp = send("GET", "/api/caregivers/"+created.ID, "")
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("Name Is Required", func(t *testing.T) {
+		resp := send("POST", "/api/caregivers", `{"phone": "+1 555 000 1111"}`)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Caregiver With Schedules Cannot Be Deleted", func(t *testing.T) {
+		resp := send("DELETE", "/api/caregivers/1", "")
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+		_, err := dataStore.GetCaregiver("1")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Schedules Are Filtered By Caregiver and Date", func(t *testing.T) {
+		resp := send("GET", "/api/caregivers/1/schedules", "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var schedules []models.Schedule
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&schedules))
+		require.Len(t, schedules, 3)
+		for _, schedule := range schedules {
+			assert.Equal(t, "1", schedule.CaregiverID)
+		}
+
+		tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+		resp = send("GET", "/api/caregivers/1/schedules?from="+tomorrow, "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&schedules))
+		assert.Empty(t, schedules)
+
+		resp = send("GET", "/api/caregivers/1/schedules?to=soon", "")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		resp = send("GET", "/api/caregivers/nobody/schedules", "")
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestClients(t *testing.T) {
+	app, dataStore := setupTest()
+	send := func(method, url, body string) *http.Response {
+		req := httptest.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+		return resp
+	}
+
+	t.Run("Create and Delete", func(t *testing.T) {
+		body := `{"name": "Rosa Diaz", "medicaidId": "IL20000001", "careNotes": "Hard of hearing.",
+			"contact": {"email": "rosa@example.com", "phone": "+1 555 111 2222"},
+			"location": {"address": "12 Elm St", "coordinates": {"latitude": 40.7, "longitude": -74.0}},
+			"emergencyContacts": [{"name": "Luis Diaz", "relationship": "Son", "phone": "+1 555 333 4444"}]}`
+		resp := send("POST", "/api/clients", body)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		var created models.Client
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+		assert.NotEmpty(t, created.ID)
+
+		stored, err := dataStore.GetClient(created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "IL20000001", stored.MedicaidID)
+		require.Len(t, stored.EmergencyContacts, 1)
+		assert.Equal(t, "Son", stored.EmergencyContacts[0].Relationship)
+
+		resp = send("DELETE", "/api/clients/"+created.ID, "")
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	})
+
+	t.Run("Invalid Client Is Rejected", func(t *testing.T) {
+		resp := send("POST", "/api/clients", `{"name": "  "}`)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		resp = send("POST", "/api/clients", `{"name": "Rosa", "location": {"coordinates": {"latitude": 95, "longitude": 0}}}`)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Address Fix Reaches Upcoming Visits Only", func(t *testing.T) {
+		tomorrow := time.Now().AddDate(0, 0, 1)
+		resp := send("POST", "/api/schedules", `{"clientId": "1", "serviceName": "Home Care", "shiftStart": "`+
+			tomorrow.Format(time.RFC3339)+`", "shiftEnd": "`+tomorrow.Add(time.Hour).Format(time.RFC3339)+`"}`)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		var upcoming models.Schedule
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&upcoming))
+
+		body := `{"name": "Melisa Adam", "contact": {"email": "melisa@example.com", "phone": "+1 555 000 0000"},
+			"location": {"address": "125 Main St, Springfield, IL", "coordinates": {"latitude": 40.7130, "longitude": -74.0062}}}`
+		resp = send("PUT", "/api/clients/1", body)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		schedule := getSchedule(t, dataStore, upcoming.ID)
+		assert.Equal(t, "125 Main St, Springfield, IL", schedule.Location.Address)
+		assert.Equal(t, 40.7130, schedule.Location.Coordinates.Latitude)
+		assert.Equal(t, "+1 555 000 0000", schedule.ClientContact.Phone)
+		assert.Equal(t, upcoming.Version+1, schedule.Version)
+		// Schedule 1 started earlier today.
+		assert.Equal(t, "123 Main St, Springfield, IL", getSchedule(t, dataStore, "1").Location.Address)
+		assert.Equal(t, "456 Oak Ave, Springfield, IL", getSchedule(t, dataStore, "2").Location.Address)
+	})
+
+	t.Run("Completed Visit Keeps Its Address", func(t *testing.T) {
+		before := getSchedule(t, dataStore, "3")
+		require.Equal(t, models.StatusCompleted, before.Status)
+		body := `{"name": "Moved Client", "location": {"address": "1 New Rd", "coordinates": {"latitude": 41.0, "longitude": -75.0}}}`
+		require.Equal(t, http.StatusOK, send("PUT", "/api/clients/3", body).StatusCode)
+
+		after := getSchedule(t, dataStore, "3")
+		assert.Equal(t, before.Location, after.Location)
+		assert.Equal(t, before.ClientName, after.ClientName)
+		assert.Equal(t, before.Version, after.Version)
+	})
+
+	t.Run("Address Fix Waits For The Visit Lock", func(t *testing.T) {
+		tomorrow := time.Now().AddDate(0, 0, 1)
+		resp := send("POST", "/api/schedules", `{"clientId": "2", "serviceName": "Home Care", "shiftStart": "`+
+			tomorrow.Format(time.RFC3339)+`", "shiftEnd": "`+tomorrow.Add(time.Hour).Format(time.RFC3339)+`"}`)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		var upcoming models.Schedule
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&upcoming))
+
+		unlock := dataStore.LockSchedule(upcoming.ID)
+		done := make(chan int, 1)
+		go func() {
+			done <- send("PUT", "/api/clients/2", `{"name": "John Doe", "location": {"address": "460 Oak Ave, Springfield, IL",
+				"coordinates": {"latitude": 40.7, "longitude": -74.0}}}`).StatusCode
+		}()
+		select {
+		case <-done:
+			t.Fatal("client update went ahead while the schedule was locked")
+		case <-time.After(50 * time.Millisecond):
+		}
+		unlock()
+		assert.Equal(t, http.StatusOK, <-done)
+		assert.Equal(t, "460 Oak Ave, Springfield, IL", getSchedule(t, dataStore, upcoming.ID).Location.Address)
+	})
+
+	t.Run("Client With Schedules Cannot Be Deleted", func(t *testing.T) {
+		resp := send("DELETE", "/api/clients/1", "")
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+		resp = send("GET", "/api/clients/missing", "")
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestScheduleCRUD(t *testing.T) {
+	app, dataStore := setupTest()
+	send := func(method, url, body string) *http.Response {
+		req := httptest.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+		return resp
+	}
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+	var created models.Schedule
+
+	t.Run("Create Copies Client Details", func(t *testing.T) {
+		body := `{"clientId": "2", "caregiverId": "1", "serviceName": "Senior Living Center",
+			"shiftDate": "` + tomorrow + `", "shiftTime": "09:00 - 10:00", "amOrPm": "am",
+			"tasks": [{"name": "Prepare breakfast", "description": "Oatmeal."}]}`
+		resp := send("POST", "/api/schedules", body)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+
+		assert.Equal(t, "John Doe", created.ClientName)
+		assert.Equal(t, "456 Oak Ave, Springfield, IL", created.Location.Address)
+		assert.Equal(t, "AM", created.AmOrPm)
+		assert.Equal(t, models.StatusScheduled, created.Status)
+		require.Len(t, created.Tasks, 1)
+		assert.Equal(t, 13, created.Tasks[0].ID)
+		assert.Equal(t, "1", getSchedule(t, dataStore, created.ID).CaregiverID)
+	})
+
+	t.Run("Create Validates Input", func(t *testing.T) {
+		base := `"serviceName": "Visit", "shiftDate": "` + tomorrow + `", "shiftTime": "09:00 - 10:00", "amOrPm": "AM"`
+		for _, body := range []string{
+			`{"clientId": "nobody", ` + base + `}`,
+			`{"clientId": "1", "caregiverId": "nobody", ` + base + `}`,
+			`{` + base + `}`,
+			`{"clientId": "1", "serviceName": "Visit", "shiftDate": "tomorrow", "shiftTime": "09:00 - 10:00", "amOrPm": "AM"}`,
+			`{"clientId": "1", "serviceName": "Visit", "shiftDate": "` + tomorrow + `", "shiftTime": "9 to 10", "amOrPm": "AM"}`,
+		} {
+			resp := send("POST", "/api/schedules", body)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, body)
+		}
+	})
+
+	t.Run("Patch Changes Only Given Fields", func(t *testing.T) {
+		resp := send("PATCH", "/api/schedules/"+created.ID, `{"caregiverId": "2", "shiftTime": "10:00 - 11:00"}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		schedule := getSchedule(t, dataStore, created.ID)
+		assert.Equal(t, "2", schedule.CaregiverID)
+		assert.Equal(t, "10:00 - 11:00", schedule.ShiftTime)
+		assert.Equal(t, "Senior Living Center", schedule.ServiceName)
+		assert.Len(t, schedule.Tasks, 1)
+	})
+
+	t.Run("Clocked-In Schedule Is Protected", func(t *testing.T) {
+		resp := send("POST", "/api/schedules/1/start", `{"location": {"latitude": 40.7128, "longitude": -74.0060}}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp = send("PATCH", "/api/schedules/1", `{"shiftDate": "`+tomorrow+`"}`)
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+		resp = send("DELETE", "/api/schedules/1", "")
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+		resp = send("PATCH", "/api/schedules/1", `{"serviceNotes": "Client asked for tea."}`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		schedule := getSchedule(t, dataStore, "1")
+		assert.Equal(t, "Client asked for tea.", schedule.ServiceNotes)
+		assert.Equal(t, models.StatusInProgress, schedule.Status)
+	})
+
+	t.Run("Cancelled Clock-In Still Protects", func(t *testing.T) {
+		resp := send("POST", "/api/schedules/1/cancel-clock-in", "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		resp = send("DELETE", "/api/schedules/1", "")
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	})
+
+	t.Run("Recorded Events Protect", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, send("POST", "/api/schedules/2/mark-missed", "").StatusCode)
+		resp := send("DELETE", "/api/schedules/2", "")
+		require.Equal(t, http.StatusConflict, resp.StatusCode)
+		var problem map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+		assert.Equal(t, "schedule_has_events", problem["code"])
+		events, err := dataStore.ListEvents("2")
+		require.NoError(t, err)
+		assert.Len(t, events, 1)
+	})
+
+	t.Run("Delete Unstarted Schedule", func(t *testing.T) {
+		resp := send("DELETE", "/api/schedules/"+created.ID, "")
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		resp = send("GET", "/api/schedules/"+created.ID, "")
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestRecurrences(t *testing.T) {
+	app, dataStore := setupTest()
+	send := func(method, url, body string) *http.Response {
+		req := httptest.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+		return resp
+	}
+	day := func(offset int) string {
+		return time.Now().AddDate(0, 0, offset).Format("2006-01-02")
+	}
+	instances := func(recurrenceID string) map[string]*models.Schedule {
+		schedules, err := dataStore.ListSchedules()
+		require.NoError(t, err)
+		byDate := make(map[string]*models.Schedule)
+		for _, schedule := range schedules {
+			if schedule.RecurrenceID == recurrenceID {
+				byDate[schedule.OccurrenceDate] = schedule
+			}
+		}
+		return byDate
+	}
+
+	var series models.Recurrence
+	t.Run("Create Expands Occurrences", func(t *testing.T) {
+		body := `{"clientId": "1", "caregiverId": "1", "serviceName": "Daily check-in",
+			"startDate": "` + day(0) + `", "shiftTime": "00:00 - 11:59", "amOrPm": "AM",
+			"rrule": "FREQ=DAILY;COUNT=6", "exceptionDates": ["` + day(4) + `"],
+			"tasks": [{"name": "Check vitals", "description": "Blood pressure."}]}`
+		resp := send("POST", "/api/recurrences", body)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&series))
+
+		byDate := instances(series.ID)
+		assert.Len(t, byDate, 5)
+		assert.NotContains(t, byDate, day(4))
+		require.Contains(t, byDate, day(0))
+		assert.Equal(t, "Melisa Adam", byDate[day(0)].ClientName)
+		// Client 1's care plan comes before the recurrence's own tasks.
+		tasks := byDate[day(0)].Tasks
+		require.Len(t, tasks, 3)
+		assert.Equal(t, "MED", tasks[0].TemplateCode)
+		assert.Equal(t, "Check vitals", tasks[2].Name)
+	})
+
+	t.Run("Invalid Rules Are Rejected", func(t *testing.T) {
+		for _, rrule := range []string{"FREQ=MONTHLY", "FREQ=WEEKLY;BYDAY=XX", "FREQ=DAILY;COUNT=2;UNTIL=20300101", "BYDAY=MO"} {
+			body := `{"clientId": "1", "serviceName": "Visit", "startDate": "` + day(0) + `",
+				"shiftTime": "09:00 - 10:00", "amOrPm": "AM", "rrule": "` + rrule + `"}`
+			resp := send("POST", "/api/recurrences", body)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, rrule)
+		}
+	})
+
+	t.Run("Weekly Rule Honours Interval and Until", func(t *testing.T) {
+		start := time.Now()
+		until := start.AddDate(0, 0, 27).Format("20060102")
+		body := `{"clientId": "2", "serviceName": "Fortnightly", "startDate": "` + day(0) + `",
+			"shiftTime": "09:00 - 10:00", "amOrPm": "AM", "rrule": "FREQ=WEEKLY;INTERVAL=2;UNTIL=` + until + `"}`
+		resp := send("POST", "/api/recurrences", body)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		var weekly models.Recurrence
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&weekly))
+
+		byDate := instances(weekly.ID)
+		assert.Len(t, byDate, 2)
+		assert.Contains(t, byDate, day(0))
+		assert.Contains(t, byDate, day(14))
+	})
+
+	t.Run("Edit This Occurrence Only", func(t *testing.T) {
+		resp := send("PATCH", "/api/recurrences/"+series.ID+"/occurrences/"+day(1), `{"caregiverId": "2"}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		byDate := instances(series.ID)
+		assert.Equal(t, "2", byDate[day(1)].CaregiverID)
+		assert.Equal(t, "1", byDate[day(2)].CaregiverID)
+
+		resp = send("PATCH", "/api/recurrences/"+series.ID+"/occurrences/"+day(4), `{"caregiverId": "2"}`)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("Edit This and Following Keeps Clocked-In Visits", func(t *testing.T) {
+		resp := send("POST", "/api/schedules/"+series.InstanceID(time.Now())+"/start", `{"location": {"latitude": 40.7128, "longitude": -74.0060}}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp = send("PATCH", "/api/recurrences/"+series.ID+"/occurrences/"+day(0)+"?scope=following", `{"caregiverId": "2", "shiftTime": "01:00 - 02:00"}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		byDate := instances(series.ID)
+		assert.Len(t, byDate, 5)
+		assert.Equal(t, "1", byDate[day(0)].CaregiverID)
+		assert.Equal(t, models.StatusInProgress, byDate[day(0)].Status)
+		assert.Equal(t, "2", byDate[day(3)].CaregiverID)
+		assert.Equal(t, "01:00 - 02:00", byDate[day(3)].ShiftTime)
+	})
+
+	t.Run("Split Series From a Later Date", func(t *testing.T) {
+		resp := send("PATCH", "/api/recurrences/"+series.ID+"/occurrences/"+day(3)+"?scope=following", `{"serviceName": "Evening check-in"}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var following models.Recurrence
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&following))
+		assert.NotEqual(t, series.ID, following.ID)
+		assert.Equal(t, day(3), following.StartDate)
+		assert.Equal(t, "FREQ=DAILY;COUNT=3", following.RRule)
+
+		original, err := dataStore.GetRecurrence(series.ID)
+		require.NoError(t, err)
+		assert.Contains(t, original.RRule, "UNTIL="+time.Now().AddDate(0, 0, 2).Format("20060102"))
+
+		assert.Len(t, instances(series.ID), 3)
+		byDate := instances(following.ID)
+		assert.Len(t, byDate, 2)
+		assert.Equal(t, "Evening check-in", byDate[day(5)].ServiceName)
+	})
+
+	t.Run("Delete This Occurrence Adds an Exception", func(t *testing.T) {
+		resp := send("DELETE", "/api/recurrences/"+series.ID+"/occurrences/"+day(2), "")
+		require.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.NotContains(t, instances(series.ID), day(2))
+		original, err := dataStore.GetRecurrence(series.ID)
+		require.NoError(t, err)
+		assert.Contains(t, original.ExceptionDates, day(2))
+
+		resp = send("DELETE", "/api/recurrences/"+series.ID+"/occurrences/"+day(0), "")
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	})
+
+	t.Run("Delete Series Keeps Started Visits", func(t *testing.T) {
+		resp := send("DELETE", "/api/recurrences/"+series.ID, "")
+		require.Equal(t, http.StatusNoContent, resp.StatusCode)
+		byDate := instances(series.ID)
+		assert.Len(t, byDate, 1)
+		assert.Contains(t, byDate, day(0))
+	})
+
+	var daily models.Recurrence
+	t.Run("Rolling Fills Gaps Only Once", func(t *testing.T) {
+		body := `{"clientId": "2", "caregiverId": "2", "serviceName": "Daily visit",
+			"startDate": "` + day(0) + `", "shiftTime": "09:00 - 10:00", "amOrPm": "AM", "rrule": "FREQ=DAILY;COUNT=4"}`
+		resp := send("POST", "/api/recurrences", body)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&daily))
+		require.Len(t, instances(daily.ID), 4)
+
+		// A schedule lost without going through the API is generated again.
+		require.NoError(t, dataStore.DeleteSchedule(daily.InstanceID(time.Now().AddDate(0, 0, 1))))
+		require.Len(t, instances(daily.ID), 3)
+		require.Equal(t, http.StatusNoContent, send("POST", "/api/recurrences/roll", "").StatusCode)
+		require.Equal(t, http.StatusNoContent, send("POST", "/api/recurrences/roll", "").StatusCode)
+		assert.Len(t, instances(daily.ID), 4)
+	})
+
+	t.Run("Deleted Occurrences Stay Deleted", func(t *testing.T) {
+		id := daily.InstanceID(time.Now().AddDate(0, 0, 2))
+		require.Equal(t, http.StatusNoContent, send("DELETE", "/api/schedules/"+id, "").StatusCode)
+		recurrence, err := dataStore.GetRecurrence(daily.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{day(2)}, recurrence.ExceptionDates)
+
+		require.NoError(t, handler.ExpandRecurrences(dataStore, config.Default()))
+		byDate := instances(daily.ID)
+		assert.Len(t, byDate, 3)
+		assert.NotContains(t, byDate, day(2))
+	})
+
+	t.Run("Rolling Races With Deletes", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for offset := range 4 {
+			if offset == 2 {
+				continue
+			}
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, handler.ExpandRecurrences(dataStore, config.Default()))
+			}()
+			go func() {
+				defer wg.Done()
+				id := daily.InstanceID(time.Now().AddDate(0, 0, offset))
+				assert.Equal(t, http.StatusNoContent, send("DELETE", "/api/schedules/"+id, "").StatusCode)
+			}()
+		}
+		wg.Wait()
+
+		require.NoError(t, handler.ExpandRecurrences(dataStore, config.Default()))
+		assert.Empty(t, instances(daily.ID))
+		recurrence, err := dataStore.GetRecurrence(daily.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{day(0), day(1), day(2), day(3)}, recurrence.ExceptionDates)
+	})
+
+	t.Run("SQLite Keeps Exceptions", func(t *testing.T) {
+		var weekly models.Recurrence
+		persists(t, func(sqliteStore *store.SQLiteStore) {
+			sqliteApp := newApp(sqliteStore, config.Default())
+			body := `{"clientId": "1", "serviceName": "Weekly visit", "startDate": "` + day(0) + `",
+			"shiftTime": "09:00 - 10:00", "amOrPm": "AM", "rrule": "FREQ=WEEKLY;COUNT=2"}`
+			req := httptest.NewRequest("POST", "/api/recurrences", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, _ := sqliteApp.Test(req)
+			require.Equal(t, http.StatusCreated, resp.StatusCode)
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&weekly))
+
+			resp, _ = sqliteApp.Test(httptest.NewRequest("DELETE", "/api/schedules/"+weekly.InstanceID(time.Now().AddDate(0, 0, 7)), nil))
+			require.Equal(t, http.StatusNoContent, resp.StatusCode)
+		}, func(reopened *store.SQLiteStore) {
+			recurrence, err := reopened.GetRecurrence(weekly.ID)
+			require.NoError(t, err)
+			assert.Equal(t, []string{day(7)}, recurrence.ExceptionDates)
+			require.NoError(t, handler.ExpandRecurrences(reopened, config.Default()))
+			_, err = reopened.GetSchedule(weekly.InstanceID(time.Now().AddDate(0, 0, 7)))
+			assert.ErrorIs(t, err, store.ErrNotFound)
+		})
+	})
+}
+
+func TestShiftTimes(t *testing.T) {
+	app, dataStore := setupTest()
+	send := func(method, url, body string) *http.Response {
+		req := httptest.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+		return resp
+	}
+	decode := func(resp *http.Response) models.Schedule {
+		var schedule models.Schedule
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&schedule))
+		return schedule
+	}
+
+	t.Run("Seeded Legacy Shifts Have Instants", func(t *testing.T) {
+		schedule := getSchedule(t, dataStore, "5")
+		assert.Equal(t, 18, schedule.ShiftStart.Hour())
+		assert.Equal(t, 23, schedule.ShiftEnd.Hour())
+		assert.Equal(t, "06:00 - 11:59", schedule.ShiftTime)
+		assert.Equal(t, "PM", schedule.AmOrPm)
+		assert.NotEmpty(t, schedule.TimeZone)
+
+		schedule = getSchedule(t, dataStore, "2")
+		assert.Equal(t, 6*time.Hour, schedule.ShiftEnd.Sub(schedule.ShiftStart))
+		assert.Equal(t, "06:00 - 12:00", schedule.ShiftTime)
+	})
+
+	t.Run("Create With Instants Renders Legacy Fields", func(t *testing.T) {
+		resp := send("POST", "/api/schedules", `{"clientId": "1", "serviceName": "Visit",
+			"shiftStart": "2030-01-15T15:00:00Z", "shiftEnd": "2030-01-15T16:30:00Z", "timeZone": "America/Chicago"}`)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		created := decode(resp)
+		assert.Equal(t, "2030-01-15", created.ShiftDate)
+		assert.Equal(t, "09:00 - 10:30", created.ShiftTime)
+		assert.Equal(t, "AM", created.AmOrPm)
+		assert.Equal(t, "America/Chicago", created.TimeZone)
+		_, offset := created.ShiftStart.Zone()
+		assert.Equal(t, -6*60*60, offset)
+	})
+
+	t.Run("Create With Legacy Fields Reads Them in the Zone", func(t *testing.T) {
+		resp := send("POST", "/api/schedules", `{"clientId": "1", "serviceName": "Visit", "timeZone": "America/New_York",
+			"shiftDate": "2030-07-04", "shiftTime": "11:00 - 1:00", "amOrPm": "PM"}`)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		created := decode(resp)
+		assert.True(t, time.Date(2030, 7, 5, 3, 0, 0, 0, time.UTC).Equal(created.ShiftStart))
+		assert.True(t, time.Date(2030, 7, 5, 5, 0, 0, 0, time.UTC).Equal(created.ShiftEnd))
+		assert.Equal(t, "11:00 - 01:00", created.ShiftTime)
+	})
+
+	t.Run("Daylight Saving Days Keep Wall-Clock Times", func(t *testing.T) {
+		for date, startUTC := range map[string]int{"2025-03-09": 14, "2025-11-02": 15} {
+			resp := send("POST", "/api/schedules", `{"clientId": "1", "serviceName": "Visit", "timeZone": "America/Chicago",
+				"shiftDate": "`+date+`", "shiftTime": "09:00 - 10:00", "amOrPm": "AM"}`)
+			require.Equal(t, http.StatusCreated, resp.StatusCode, date)
+			created := decode(resp)
+			assert.Equal(t, startUTC, created.ShiftStart.UTC().Hour(), date)
+			assert.Equal(t, time.Hour, created.ShiftEnd.Sub(created.ShiftStart), date)
+			assert.Equal(t, "09:00 - 10:00", created.ShiftTime, date)
+			assert.Equal(t, date, created.ShiftDate)
+		}
+
+		// A weekly series across the autumn change keeps its hour.
+		recurrence := &models.Recurrence{ID: "dst", StartDate: "2025-10-20", ShiftTime: "09:00 - 10:00",
+			AmOrPm: "AM", TimeZone: "America/Chicago", RRule: "FREQ=WEEKLY;BYDAY=MO"}
+		dates, err := recurrence.Dates(time.Date(2025, 10, 20, 0, 0, 0, 0, time.UTC), time.Date(2025, 11, 10, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+		require.Len(t, dates, 4)
+		for _, date := range dates {
+			instance, err := recurrence.Instance(date)
+			require.NoError(t, err)
+			assert.Equal(t, 9, instance.ShiftStart.Hour(), instance.OccurrenceDate)
+			assert.Equal(t, "09:00 - 10:00", instance.ShiftTime, instance.OccurrenceDate)
+		}
+	})
+
+	t.Run("Invalid Shifts Are Rejected", func(t *testing.T) {
+		for _, shift := range []string{
+			`"shiftStart": "2030-01-15T16:00:00Z", "shiftEnd": "2030-01-15T15:00:00Z"`,
+			`"shiftStart": "2030-01-15T15:00:00Z"`,
+			`"shiftStart": "2030-01-15 15:00", "shiftEnd": "2030-01-15 16:00"`,
+			`"shiftStart": "2030-01-15T15:00:00Z", "shiftEnd": "2030-01-15T16:00:00Z", "shiftDate": "2030-01-15"`,
+			`"shiftDate": "2030-01-15", "shiftTime": "09:00 - 10:00", "amOrPm": "AM", "timeZone": "Mars/Olympus"`,
+			`"shiftDate": "2030-01-15", "shiftTime": "09:00 - 10:00", "amOrPm": "AM", "timeZone": "Local"`,
+		} {
+			resp := send("POST", "/api/schedules", `{"clientId": "1", "serviceName": "Visit", `+shift+`}`)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, shift)
+		}
+	})
+
+	t.Run("Patch Instants and Zone", func(t *testing.T) {
+		resp := send("PATCH", "/api/schedules/4", `{"shiftStart": "2030-01-15T15:00:00Z", "shiftEnd": "2030-01-15T16:00:00Z", "timeZone": "UTC"}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		patched := decode(resp)
+		assert.Equal(t, "03:00 - 04:00", patched.ShiftTime)
+		assert.Equal(t, "PM", patched.AmOrPm)
+
+		resp = send("PATCH", "/api/schedules/4", `{"timeZone": "Asia/Tokyo"}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		patched = decode(resp)
+		assert.True(t, time.Date(2030, 1, 15, 15, 0, 0, 0, time.UTC).Equal(patched.ShiftStart))
+		assert.Equal(t, "2030-01-16", patched.ShiftDate)
+		assert.Equal(t, "00:00 - 01:00", patched.ShiftTime)
+		assert.Equal(t, "AM", patched.AmOrPm)
+
+		resp = send("PATCH", "/api/schedules/4", `{"shiftTime": "10:00 - 11:00"}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		patched = decode(resp)
+		assert.True(t, time.Date(2030, 1, 16, 1, 0, 0, 0, time.UTC).Equal(patched.ShiftStart))
+	})
+
+	t.Run("SQLite Migrates Legacy Rows", func(t *testing.T) {
+		sqliteStore := migratedStore(t, `UPDATE schedules SET shift_start = NULL, shift_end = NULL, time_zone = NULL,
+			shift_date = '2030-01-15', shift_time = '6:00 - 11:59' WHERE id = '5'`)
+		schedule := getSchedule(t, sqliteStore, "5")
+		assert.Equal(t, models.DefaultTimeZone(), schedule.TimeZone)
+		assert.Equal(t, "2030-01-15", schedule.ShiftStart.Format("2006-01-02"))
+		assert.Equal(t, 18, schedule.ShiftStart.Hour())
+		assert.Equal(t, "06:00 - 11:59", schedule.ShiftTime)
+	})
+}
+
+func TestTimeZoneDates(t *testing.T) {
+	app, _ := setupTest()
+	send := func(method, url, body string) *http.Response {
+		req := httptest.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+		return resp
+	}
+	ids := func(url string) []string {
+		resp := send("GET", url, "")
+		require.Equal(t, http.StatusOK, resp.StatusCode, url)
+		var schedules []models.Schedule
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&schedules))
+		ids := make([]string, 0, len(schedules))
+		for _, schedule := range schedules {
+			ids = append(ids, schedule.ID)
+		}
+		return ids
+	}
+	create := func(caregiverID string, start time.Time) string {
+		body := `{"clientId": "1", "caregiverId": "` + caregiverID + `", "serviceName": "Evening visit",
+			"shiftStart": "` + start.Format(time.RFC3339) + `", "shiftEnd": "` + start.Add(time.Hour).Format(time.RFC3339) + `"}`
+		resp := send("POST", "/api/schedules", body)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		var schedule models.Schedule
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&schedule))
+		return schedule.ID
+	}
+
+	// 20:30 on the 14th in Chicago is already the 15th in UTC.
+	evening := create("1", time.Date(2030, 1, 15, 2, 30, 0, 0, time.UTC))
+
+	t.Run("Date Range Is Read in the Requested Zone", func(t *testing.T) {
+		assert.Equal(t, []string{evening}, ids("/api/schedules?from=2030-01-14&to=2030-01-14&tz=America/Chicago"))
+		assert.Empty(t, ids("/api/schedules?from=2030-01-14&to=2030-01-14&tz=UTC"))
+		assert.Equal(t, []string{evening}, ids("/api/schedules?from=2030-01-15&to=2030-01-15&tz=UTC"))
+		assert.Equal(t, []string{evening}, ids("/api/schedules?from=2030-01-01&tz=UTC"))
+		assert.Len(t, ids("/api/schedules?to=2030-01-14&tz=UTC"), 6)
+	})
+
+	t.Run("Caregiver Zone Applies to Their Listings", func(t *testing.T) {
+		resp := send("PUT", "/api/caregivers/2", `{"name": "Marcus Bell", "credentials": ["CNA"], "timeZone": "Pacific/Auckland"}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		// 15:30 on the 14th in UTC is the 15th in Auckland.
+		afternoon := create("2", time.Date(2030, 1, 14, 15, 30, 0, 0, time.UTC))
+
+		assert.Equal(t, []string{afternoon}, ids("/api/caregivers/2/schedules?from=2030-01-15&to=2030-01-15"))
+		assert.Equal(t, []string{afternoon}, ids("/api/schedules?caregiverId=2&from=2030-01-15&to=2030-01-15"))
+		assert.Empty(t, ids("/api/schedules?caregiverId=2&from=2030-01-15&to=2030-01-15&tz=UTC"))
+	})
+
+	t.Run("Today Is Read in the Requested Zone", func(t *testing.T) {
+		kiritimati, err := time.LoadLocation("Pacific/Kiritimati")
+		require.NoError(t, err)
+		now := time.Now().In(kiritimati)
+		noonAt := time.Date(now.Year(), now.Month(), now.Day(), 12, 0, 0, 0, kiritimati)
+		noon := create("1", noonAt)
+
+		for _, zone := range []string{"Pacific/Kiritimati", "UTC", "Pacific/Pago_Pago"} {
+			loc, err := time.LoadLocation(zone)
+			require.NoError(t, err)
+			if noonAt.In(loc).Format("2006-01-02") == time.Now().In(loc).Format("2006-01-02") {
+				assert.Contains(t, ids("/api/schedules/today?tz="+zone), noon, zone)
+			} else {
+				assert.NotContains(t, ids("/api/schedules/today?tz="+zone), noon, zone)
+			}
+		}
+		assert.Contains(t, ids("/api/schedules/today?tz=Pacific/Kiritimati"), noon)
+	})
+
+	t.Run("Invalid Queries Are Rejected", func(t *testing.T) {
+		for _, url := range []string{
+			"/api/schedules?tz=Mars/Olympus",
+			"/api/schedules?from=15-01-2030",
+			"/api/schedules?from=2030-01-15&to=2030-01-14",
+			"/api/schedules?caregiverId=nobody",
+			"/api/schedules/today?tz=Local",
+			"/api/caregivers/1/schedules?tz=Nowhere",
+		} {
+			assert.Equal(t, http.StatusBadRequest, send("GET", url, "").StatusCode, url)
+		}
+		resp := send("POST", "/api/caregivers", `{"name": "New", "timeZone": "Nowhere"}`)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestScheduleListing(t *testing.T) {
+	app, _ := setupTest()
+	list := func(query string) (*http.Response, []string) {
+		resp, _ := app.Test(httptest.NewRequest("GET", "/api/schedules?"+query, nil))
+		if resp.StatusCode != http.StatusOK {
+			return resp, nil
+		}
+		var schedules []models.Schedule
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&schedules))
+		ids := make([]string, 0, len(schedules))
+		for _, schedule := range schedules {
+			ids = append(ids, schedule.ID)
+		}
+		return resp, ids
+	}
+
+	t.Run("Filters", func(t *testing.T) {
+		cases := map[string][]string{
+			"status=completed":                 {"3"},
+			"status=missed,completed":          {"3", "6"},
+			"clientId=2":                       {"2"},
+			"caregiverId=1&status=scheduled":   {"1", "5"},
+			"q=INSULIN":                        {"4"},
+			"q=oak%20ave":                      {"2"},
+			"q=groggy&status=scheduled":        {"1"},
+			"q=nothing%20matches%20this%20one": {},
+		}
+		for query, want := range cases {
+			resp, ids := list(query)
+			require.Equal(t, http.StatusOK, resp.StatusCode, query)
+			assert.Equal(t, want, ids, query)
+			assert.Equal(t, strconv.Itoa(len(want)), resp.Header.Get("X-Total-Count"), query)
+		}
+	})
+
+	t.Run("Sort Keys", func(t *testing.T) {
+		_, ids := list("sort=clientName")
+		assert.Equal(t, []string{"4", "5", "6", "3", "2", "1"}, ids)
+		_, ids = list("sort=-clientName")
+		assert.Equal(t, []string{"1", "2", "3", "6", "5", "4"}, ids)
+		_, ids = list("sort=-shiftStart")
+		assert.Equal(t, []string{"5", "6", "4", "2", "3", "1"}, ids)
+		_, ids = list("sort=status")
+		assert.Equal(t, []string{"3", "6", "1", "2", "4", "5"}, ids)
+	})
+
+	t.Run("Cursor Pages Survive Concurrent Changes", func(t *testing.T) {
+		resp, first := list("limit=2")
+		assert.Equal(t, []string{"1", "3"}, first)
+		assert.Equal(t, "6", resp.Header.Get("X-Total-Count"))
+		cursor := resp.Header.Get("X-Next-Cursor")
+		require.NotEmpty(t, cursor)
+		assert.Contains(t, resp.Header.Get("Link"), "cursor="+cursor)
+		assert.Contains(t, resp.Header.Get("Link"), "limit=2")
+
+		// A schedule added before the cursor and one removed after it must
+		// not shift the following pages.
+		yesterday := time.Now().AddDate(0, 0, -1)
+		body := `{"clientId": "1", "serviceName": "Earlier", "shiftStart": "` + yesterday.Format(time.RFC3339) +
+			`", "shiftEnd": "` + yesterday.Add(time.Hour).Format(time.RFC3339) + `"}`
+		req := httptest.NewRequest("POST", "/api/schedules", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		created, _ := app.Test(req)
+		require.Equal(t, http.StatusCreated, created.StatusCode)
+		deleted, _ := app.Test(httptest.NewRequest("DELETE", "/api/schedules/4", nil))
+		require.Equal(t, http.StatusNoContent, deleted.StatusCode)
+
+		seen := append([]string{}, first...)
+		for cursor != "" {
+			resp, ids := list("limit=2&cursor=" + cursor)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			seen = append(seen, ids...)
+			cursor = resp.Header.Get("X-Next-Cursor")
+		}
+		assert.Equal(t, []string{"1", "3", "2", "6", "5"}, seen)
+	})
+
+	t.Run("Invalid Parameters", func(t *testing.T) {
+		resp, _ := list("sort=clientName&limit=1")
+		cursor := resp.Header.Get("X-Next-Cursor")
+		for _, query := range []string{
+			"status=done",
+			"sort=caregiver",
+			"limit=0",
+			"limit=501",
+			"cursor=not-a-cursor",
+			"sort=shiftStart&cursor=" + cursor,
+		} {
+			resp, _ := list(query)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+		}
+	})
+}
+
+func TestTaskIdentity(t *testing.T) {
+	app, dataStore := setupTest()
+	send := func(method, path, body string) *http.Response {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+		return resp
+	}
+	addTask := func(scheduleID, name string) int {
+		resp := send("POST", "/api/schedules/"+scheduleID+"/tasks", `{"name": "`+name+`"}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var schedule models.Schedule
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&schedule))
+		return schedule.Tasks[len(schedule.Tasks)-1].ID
+	}
+
+	t.Run("Added Tasks Get Unique IDs", func(t *testing.T) {
+		first := addTask("1", "Walk")
+		second := addTask("2", "Walk")
+		assert.Equal(t, 13, first)
+		assert.Equal(t, 14, second)
+
+		owner, err := dataStore.TaskSchedule(second)
+		require.NoError(t, err)
+		assert.Equal(t, "2", owner)
+
+		// Deleting a schedule frees its tasks, but their IDs are not reused.
+		resp := send("DELETE", "/api/schedules/2", "")
+		require.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Equal(t, 15, addTask("3", "Walk"))
+	})
+
+	t.Run("Scoped Update Touches Only The Owning Schedule", func(t *testing.T) {
+		startVisit(t, app, "1")
+		resp := send("PUT", "/api/schedules/1/tasks/1", `{"completed": true}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var task models.Task
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&task))
+		assert.Equal(t, 1, task.ID)
+		assert.True(t, task.Completed)
+
+		resp = send("GET", "/api/schedules/1/tasks/1", "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp = send("PUT", "/api/schedules/3/tasks/1", `{"completed": true}`)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		resp = send("GET", "/api/schedules/3/tasks/1", "")
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		resp = send("PUT", "/api/schedules/1/tasks/abc", `{"completed": true}`)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		events, err := dataStore.ListEvents("3")
+		require.NoError(t, err)
+		assert.Empty(t, events)
+	})
+
+	t.Run("Legacy Route Finds The Owner", func(t *testing.T) {
+		startVisit(t, app, "4")
+		resp := send("PUT", "/api/tasks/7/update", `{"completed": false, "notCompletedReason": "Asleep"}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "Asleep", getSchedule(t, dataStore, "4").Tasks[0].NotCompletedReason)
+	})
+
+	t.Run("Reset Does Not Reuse IDs", func(t *testing.T) {
+		before := addTask("1", "Walk")
+		require.Equal(t, http.StatusOK, send("POST", "/api/reset", "").StatusCode)
+		assert.Equal(t, before+1, addTask("1", "Walk"))
+
+		sqliteStore, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "evv.db"))
+		require.NoError(t, err)
+		defer sqliteStore.Close()
+		task := models.Task{Name: "Walk"}
+		require.NoError(t, sqliteStore.CreateTask("1", &task))
+		require.NoError(t, sqliteStore.Reset())
+		again := models.Task{Name: "Walk"}
+		require.NoError(t, sqliteStore.CreateTask("1", &again))
+		assert.Equal(t, task.ID+1, again.ID)
+		assert.Equal(t, 1, getSchedule(t, sqliteStore, "1").Tasks[0].ID)
+	})
+
+	t.Run("SQLite Renumbers Duplicate IDs", func(t *testing.T) {
+		// Rewind to before task IDs were unique and add a task that reuses
+		// ID 1, with an event that marks it.
+		sqliteStore := migratedStore(t,
+			`DROP TABLE api_keys`,
+			`DROP TABLE refresh_tokens`,
+			`DROP TABLE users`,
+			`ALTER TABLE schedules DROP COLUMN version`,
+			`ALTER TABLE tasks DROP COLUMN version`,
+			`DROP INDEX tasks_id`,
+			`DROP TABLE task_sequence`,
+			`DROP TABLE care_plan_tasks`,
+			`DROP TABLE task_templates`,
+			`ALTER TABLE tasks DROP COLUMN template_code`,
+			`ALTER TABLE tasks DROP COLUMN required`,
+			`DELETE FROM schema_migrations WHERE version >= 11`,
+			`INSERT INTO tasks (schedule_id, id, position, name) VALUES ('4', 1, 2, 'Reused')`,
+			`INSERT INTO visit_events (schedule_id, type, occurred_at, data)
+				VALUES ('4', 'TaskMarked', '2030-01-15T08:00:00Z', '{"scheduleId": "4", "type": "TaskMarked", "taskId": 1, "completed": true}')`,
+		)
+
+		tasks := getSchedule(t, sqliteStore, "4").Tasks
+		require.Len(t, tasks, 3)
+		assert.Equal(t, "Reused", tasks[2].Name)
+		assert.Greater(t, tasks[2].ID, 12)
+		assert.True(t, tasks[2].Completed)
+		assert.False(t, getSchedule(t, sqliteStore, "1").Tasks[0].Completed)
+
+		task := models.Task{Name: "Fresh"}
+		require.NoError(t, sqliteStore.CreateTask("4", &task))
+		assert.Equal(t, tasks[2].ID+1, task.ID)
+		owner, err := sqliteStore.TaskSchedule(task.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "4", owner)
+	})
+}
+
+func TestTaskCRUD(t *testing.T) {
+	app, dataStore := setupTest()
+	send := func(method, path, body string) *http.Response {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+		return resp
+	}
+	taskIDs := func(scheduleID string) []int {
+		ids := make([]int, 0)
+		for _, task := range getSchedule(t, dataStore, scheduleID).Tasks {
+			ids = append(ids, task.ID)
+		}
+		return ids
+	}
+	const here = `{"location": {"latitude": 40.7128, "longitude": -74.0060}}`
+
+	t.Run("Edit", func(t *testing.T) {
+		resp := send("PATCH", "/api/schedules/1/tasks/2", `{"name": " Check vitals ", "required": true}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var task models.Task
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&task))
+		assert.Equal(t, "Check vitals", task.Name)
+		assert.True(t, task.Required)
+
+		resp = send("PATCH", "/api/schedules/1/tasks/2", `{"description": "Pulse and blood pressure"}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		edited := getSchedule(t, dataStore, "1").Tasks[1]
+		assert.Equal(t, "Check vitals", edited.Name)
+		assert.Equal(t, "Pulse and blood pressure", edited.Description)
+		assert.True(t, edited.Required)
+
+		assert.Equal(t, http.StatusBadRequest, send("PATCH", "/api/schedules/1/tasks/2", `{"name": "  "}`).StatusCode)
+		assert.Equal(t, http.StatusNotFound, send("PATCH", "/api/schedules/1/tasks/3", `{"required": true}`).StatusCode)
+	})
+
+	t.Run("Reorder", func(t *testing.T) {
+		resp := send("POST", "/api/schedules/1/tasks", `{"name": "Make lunch"}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, []int{1, 2, 13}, taskIDs("1"))
+
+		resp = send("PUT", "/api/schedules/1/tasks/order", `{"taskIds": [13, 1, 2]}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var tasks []models.Task
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&tasks))
+		require.Len(t, tasks, 3)
+		assert.Equal(t, "Make lunch", tasks[0].Name)
+		assert.Equal(t, []int{13, 1, 2}, taskIDs("1"))
+
+		for _, body := range []string{
+			`{"taskIds": [13, 1]}`,
+			`{"taskIds": [13, 1, 1]}`,
+			`{"taskIds": [13, 1, 3]}`,
+		} {
+			assert.Equal(t, http.StatusBadRequest, send("PUT", "/api/schedules/1/tasks/order", body).StatusCode, body)
+		}
+		assert.Equal(t, []int{13, 1, 2}, taskIDs("1"))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, send("DELETE", "/api/schedules/1/tasks/13", "").StatusCode)
+		assert.Equal(t, []int{1, 2}, taskIDs("1"))
+		assert.Equal(t, http.StatusNotFound, send("DELETE", "/api/schedules/1/tasks/13", "").StatusCode)
+
+		// Task 5 was not completed on visit 3.
+		resp := send("DELETE", "/api/schedules/3/tasks/5", "")
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, "task_has_outcome", body["code"])
+	})
+
+	t.Run("Required Tasks Block Ending The Visit", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, send("POST", "/api/schedules/1/start", here).StatusCode)
+
+		resp := send("POST", "/api/schedules/1/end", here)
+		require.Equal(t, http.StatusConflict, resp.StatusCode)
+		var body struct {
+			Code    string `json:"code"`
+			TaskIDs []int  `json:"taskIds"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, "required_tasks_incomplete", body.Code)
+		assert.Equal(t, []int{2}, body.TaskIDs)
+		assert.Equal(t, models.StatusInProgress, getSchedule(t, dataStore, "1").Status)
+
+		// Offline sync hits the same rule.
+		sync := `{"deviceId": "tablet-7", "mutations": [
+			{"idempotencyKey": "k-end", "type": "end_visit", "scheduleId": "1", "location": {"latitude": 40.7128, "longitude": -74.0060}}
+		]}`
+		resp = send("POST", "/api/sync", sync)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var result models.SyncResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		assert.Equal(t, models.SyncRejected, result.Results[0].Status)
+		assert.Equal(t, "required_tasks_incomplete", result.Results[0].Code)
+
+		// A reason is as good as completing the task.
+		require.Equal(t, http.StatusOK, send("PUT", "/api/schedules/1/tasks/2", `{"completed": false, "notCompletedReason": "Cuff missing"}`).StatusCode)
+		require.Equal(t, http.StatusOK, send("POST", "/api/schedules/1/end", here).StatusCode)
+		assert.Equal(t, models.StatusCompleted, getSchedule(t, dataStore, "1").Status)
+	})
+
+	t.Run("SQLite Keeps Required Flag And Order", func(t *testing.T) {
+		var task models.Task
+		persists(t, func(sqliteStore *store.SQLiteStore) {
+			task = models.Task{Name: "Make lunch", Required: true}
+			require.NoError(t, sqliteStore.CreateTask("1", &task))
+			require.NoError(t, sqliteStore.ReorderTasks("1", []int{task.ID, 2, 1}))
+			assert.Error(t, sqliteStore.ReorderTasks("1", []int{task.ID, 2}))
+
+			first, err := sqliteStore.GetTask("1", 1)
+			require.NoError(t, err)
+			first.Required = true
+			require.NoError(t, sqliteStore.UpdateTask("1", first))
+			require.NoError(t, sqliteStore.DeleteTask("1", 2))
+			assert.ErrorIs(t, sqliteStore.DeleteTask("1", 2), store.ErrNotFound)
+		}, func(reopened *store.SQLiteStore) {
+			tasks := getSchedule(t, reopened, "1").Tasks
+			require.Len(t, tasks, 2)
+			assert.Equal(t, task.ID, tasks[0].ID)
+			assert.True(t, tasks[0].Required)
+			assert.Equal(t, 1, tasks[1].ID)
+			assert.True(t, tasks[1].Required)
+		})
+	})
+
+	t.Run("Edit Is Refused Once Part Of The Visit Record", func(t *testing.T) {
+		code := func(resp *http.Response) string {
+			var body map[string]any
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			return fmt.Sprint(body["code"])
+		}
+
+		// Task 6 was completed on visit 3.
+		resp := send("PATCH", "/api/schedules/3/tasks/6", `{"name": "Something else"}`)
+		require.Equal(t, http.StatusConflict, resp.StatusCode)
+		assert.Equal(t, "task_has_outcome", code(resp))
+
+		// A task without an outcome on a visit that was clocked into.
+		resp = send("POST", "/api/schedules/3/tasks", `{"name": "Late addition"}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		added := getSchedule(t, dataStore, "3").Tasks[2]
+		resp = send("PATCH", fmt.Sprintf("/api/schedules/3/tasks/%d", added.ID), `{"required": true}`)
+		require.Equal(t, http.StatusConflict, resp.StatusCode)
+		assert.Equal(t, "schedule_has_clock_data", code(resp))
+		assert.False(t, getSchedule(t, dataStore, "3").Tasks[2].Required)
+	})
+}
+
+func TestCarePlans(t *testing.T) {
+	app, dataStore := setupTest()
+	send := func(method, path, body string) *http.Response {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+		return resp
+	}
+
+	t.Run("Template Catalog", func(t *testing.T) {
+		resp := send("GET", "/api/task-templates", "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var templates []models.TaskTemplate
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&templates))
+		assert.Len(t, templates, 8)
+
+		resp = send("POST", "/api/task-templates", `{"code": "ROM", "name": "Range of motion", "category": "clinical", "required": true}`)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		resp = send("POST", "/api/task-templates", `{"code": "ROM", "name": "Again"}`)
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, "already_exists", body["code"])
+
+		for _, bad := range []string{`{"code": "rom", "name": "Lower case"}`, `{"name": "No code"}`, `{"code": "X"}`} {
+			assert.Equal(t, http.StatusBadRequest, send("POST", "/api/task-templates", bad).StatusCode, bad)
+		}
+
+		resp = send("PUT", "/api/task-templates/ROM", `{"name": "Range-of-motion exercises", "description": "Arms and legs.", "category": "clinical", "required": true}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		template, err := dataStore.GetTaskTemplate("ROM")
+		require.NoError(t, err)
+		assert.Equal(t, "Range-of-motion exercises", template.Name)
+		assert.Equal(t, http.StatusBadRequest, send("PUT", "/api/task-templates/ROM", `{"code": "ROM2", "name": "Renamed"}`).StatusCode)
+		assert.Equal(t, http.StatusNotFound, send("PUT", "/api/task-templates/NOPE", `{"name": "Missing"}`).StatusCode)
+		assert.Equal(t, http.StatusNotFound, send("GET", "/api/task-templates/NOPE", "").StatusCode)
+	})
+
+	t.Run("Care Plan Is Copied Onto New Schedules", func(t *testing.T) {
+		resp := send("GET", "/api/clients/2/care-plan", "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var plan models.CarePlan
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&plan))
+		assert.Empty(t, plan.Tasks)
+
+		resp = send("PUT", "/api/clients/2/care-plan", `{"tasks": [
+			{"templateCode": "VITALS", "required": false},
+			{"templateCode": "ROM", "description": "Left knee only."}
+		]}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, http.StatusBadRequest, send("PUT", "/api/clients/2/care-plan", `{"tasks": [{"templateCode": "NOPE"}]}`).StatusCode)
+		assert.Equal(t, http.StatusNotFound, send("PUT", "/api/clients/99/care-plan", `{"tasks": []}`).StatusCode)
+
+		day := time.Now().AddDate(0, 0, 1)
+		body := `{"clientId": "2", "serviceName": "Rehab", "shiftStart": "` + day.Format(time.RFC3339) +
+			`", "shiftEnd": "` + day.Add(time.Hour).Format(time.RFC3339) + `", "tasks": [{"name": "Water plants"}]}`
+		resp = send("POST", "/api/schedules", body)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		var schedule models.Schedule
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&schedule))
+		require.Len(t, schedule.Tasks, 3)
+		assert.Equal(t, "Check vitals", schedule.Tasks[0].Name)
+		assert.Equal(t, "VITALS", schedule.Tasks[0].TemplateCode)
+		assert.False(t, schedule.Tasks[0].Required)
+		assert.Equal(t, "Range-of-motion exercises", schedule.Tasks[1].Name)
+		assert.Equal(t, "Left knee only.", schedule.Tasks[1].Description)
+		assert.True(t, schedule.Tasks[1].Required)
+		assert.Equal(t, "Water plants", schedule.Tasks[2].Name)
+		assert.NotEqual(t, schedule.Tasks[0].ID, schedule.Tasks[1].ID)
+
+		// Later plan changes leave existing schedules alone.
+		require.Equal(t, http.StatusOK, send("PUT", "/api/clients/2/care-plan", `{"tasks": []}`).StatusCode)
+		assert.Len(t, getSchedule(t, dataStore, schedule.ID).Tasks, 3)
+	})
+
+	t.Run("Templates On A Care Plan Cannot Be Deleted", func(t *testing.T) {
+		resp := send("DELETE", "/api/task-templates/MED", "")
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+		assert.Equal(t, http.StatusNoContent, send("DELETE", "/api/task-templates/ROM", "").StatusCode)
+		assert.Equal(t, http.StatusNotFound, send("DELETE", "/api/task-templates/ROM", "").StatusCode)
+	})
+
+	t.Run("SQLite", func(t *testing.T) {
+		sqliteStore, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "evv.db"))
+		require.NoError(t, err)
+		defer sqliteStore.Close()
+
+		plan, err := sqliteStore.GetCarePlan("4")
+		require.NoError(t, err)
+		require.Len(t, plan.Tasks, 2)
+		assert.Equal(t, "MED", plan.Tasks[0].TemplateCode)
+
+		optional := false
+		require.NoError(t, sqliteStore.SetCarePlan(&models.CarePlan{ClientID: "5", Tasks: []models.CarePlanTask{
+			{TemplateCode: "COMPANION"},
+			{TemplateCode: "VITALS", Required: &optional},
+		}}))
+		plan, err = sqliteStore.GetCarePlan("5")
+		require.NoError(t, err)
+		require.Len(t, plan.Tasks, 2)
+		assert.Nil(t, plan.Tasks[0].Required)
+		require.NotNil(t, plan.Tasks[1].Required)
+		assert.False(t, *plan.Tasks[1].Required)
+
+		assert.ErrorIs(t, sqliteStore.SetCarePlan(&models.CarePlan{ClientID: "5", Tasks: []models.CarePlanTask{{TemplateCode: "NOPE"}}}), store.ErrNotFound)
+		assert.ErrorIs(t, sqliteStore.CreateTaskTemplate(&models.TaskTemplate{Code: "MED", Name: "Again"}), store.ErrExists)
+		assert.ErrorIs(t, sqliteStore.DeleteTaskTemplate("VITALS"), store.ErrInUse)
+		require.NoError(t, sqliteStore.DeleteTaskTemplate("MEAL"))
+
+		assert.Equal(t, "VITALS", getSchedule(t, sqliteStore, "3").Tasks[1].TemplateCode)
+	})
+}
+
+func TestTaskOutcomes(t *testing.T) {
+	app, dataStore := setupTest()
+	send := func(method, path, body string) *http.Response {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+		return resp
+	}
+	flags := func(scheduleID string) []models.VisitException {
+		var flagged []models.VisitException
+		for _, exception := range getSchedule(t, dataStore, scheduleID).Exceptions {
+			if exception.Code == "measurement_out_of_range" {
+				flagged = append(flagged, exception)
+			}
+		}
+		return flagged
+	}
+	startVisit(t, app, "4")
+	startVisit(t, app, "5")
+
+	t.Run("Outcome Codes", func(t *testing.T) {
+		resp := send("GET", "/api/task-outcomes", "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var options models.TaskOutcomeOptions
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&options))
+		assert.Contains(t, options.NotCompletedReasons, models.NotCompletedReason{Code: "client_refused", Label: "Client refused"})
+		assert.Len(t, options.MeasurementRanges, 5)
+	})
+
+	t.Run("Vitals Out Of Normal Range Are Flagged", func(t *testing.T) {
+		resp := send("PUT", "/api/schedules/5/tasks/9", `{"completed": true,
+			"measurements": {"systolicBp": 165, "diastolicBp": 95, "pulseBpm": 72}}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var task models.Task
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&task))
+		require.NotNil(t, task.Measurements)
+		assert.Equal(t, 165.0, *task.Measurements.SystolicBP)
+		assert.Nil(t, task.Measurements.GlucoseMgDL)
+
+		flagged := flags("5")
+		require.Len(t, flagged, 2)
+		assert.Equal(t, 9, flagged[0].TaskID)
+		assert.Equal(t, "systolicBp", flagged[0].Field)
+		assert.Contains(t, flagged[0].Message, "above the normal range 90-140")
+		assert.Equal(t, "diastolicBp", flagged[1].Field)
+
+		// A new reading replaces the flags of the old one.
+		resp = send("PUT", "/api/schedules/5/tasks/9", `{"completed": true,
+			"measurements": {"systolicBp": 120, "diastolicBp": 80, "pulseBpm": 48}}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		flagged = flags("5")
+		require.Len(t, flagged, 1)
+		assert.Equal(t, "pulseBpm", flagged[0].Field)
+		assert.Contains(t, flagged[0].Message, "below")
+	})
+
+	t.Run("Coded Reasons", func(t *testing.T) {
+		resp := send("PUT", "/api/schedules/4/tasks/7", `{"completed": false, "reasonCode": "client_refused"}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var task models.Task
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&task))
+		assert.Equal(t, "client_refused", task.ReasonCode)
+		assert.Equal(t, "Client refused", task.NotCompletedReason)
+
+		resp = send("PUT", "/api/schedules/4/tasks/8", `{"completed": false, "notCompletedReason": "Walker broken"}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&task))
+		assert.Equal(t, "other", task.ReasonCode)
+		assert.Equal(t, "Walker broken", task.NotCompletedReason)
+	})
+
+	t.Run("Invalid Outcomes Are Rejected", func(t *testing.T) {
+		cases := map[string]string{
+			`{"completed": true, "measurements": {"pulseBpm": 400}}`:                     "measurements.pulseBpm",
+			`{"completed": true, "measurements": {"systolicBp": 120}}`:                   "measurements.diastolicBp",
+			`{"completed": true, "measurements": {"systolicBp": 80, "diastolicBp": 90}}`: "measurements.diastolicBp",
+			`{"completed": true, "measurements": {"insulinUnits": 0}}`:                   "measurements.insulinUnits",
+			`{"completed": false, "reasonCode": "bored"}`:                                "reasonCode",
+			`{"completed": true, "reasonCode": "client_refused"}`:                        "reasonCode",
+			`{"completed": false, "reasonCode": "other"}`:                                "notCompletedReason",
+			`{"completed": false, "measurements": {"glucoseMgDl": 100}}`:                 "measurements",
+		}
+		for body, field := range cases {
+			resp := send("PUT", "/api/schedules/4/tasks/7", body)
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode, body)
+			problem := decodeValidation(t, resp)
+			assert.Equal(t, "validation_failed", problem.Code, body)
+			require.NotEmpty(t, problem.Fields, body)
+			assert.Equal(t, field, problem.Fields[0].Field, body)
+		}
+		assert.Equal(t, "client_refused", getSchedule(t, dataStore, "4").Tasks[0].ReasonCode)
+	})
+
+	t.Run("Sync Carries Outcomes", func(t *testing.T) {
+		body := `{"mutations": [
+			{"idempotencyKey": "k-glucose", "type": "update_task", "taskId": 7, "completed": true, "measurements": {"glucoseMgDl": 250, "insulinUnits": 8}},
+			{"idempotencyKey": "k-bad", "type": "update_task", "taskId": 8, "completed": false, "reasonCode": "bored"}
+		]}`
+		resp := send("POST", "/api/sync", body)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var result models.SyncResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		assert.Equal(t, models.SyncApplied, result.Results[0].Status)
+		assert.Equal(t, models.SyncRejected, result.Results[1].Status)
+		assert.Equal(t, "validation_failed", result.Results[1].Code)
+
+		flagged := flags("4")
+		require.Len(t, flagged, 1)
+		assert.Equal(t, "glucoseMgDl", flagged[0].Field)
+		assert.Equal(t, 8.0, *getSchedule(t, dataStore, "4").Tasks[0].Measurements.InsulinUnits)
+	})
+
+	t.Run("Visits Not In Progress Refuse Outcomes", func(t *testing.T) {
+		for path, status := range map[string]models.VisitStatus{
+			"/api/schedules/2/tasks/3": models.StatusScheduled,
+			"/api/schedules/3/tasks/6": models.StatusCompleted,
+			"/api/tasks/11/update":     models.StatusMissed,
+		} {
+			resp := send("PUT", path, `{"completed": true}`)
+			require.Equal(t, http.StatusConflict, resp.StatusCode, path)
+			var body map[string]any
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			assert.Equal(t, "visit_not_in_progress", body["code"], path)
+			assert.Equal(t, string(status), body["visitStatus"], path)
+		}
+		assert.Equal(t, "Client was not home.", getSchedule(t, dataStore, "6").Tasks[0].NotCompletedReason)
+		events, err := dataStore.ListEvents("2")
+		require.NoError(t, err)
+		assert.Empty(t, events)
+
+		resp := send("POST", "/api/sync", `{"mutations": [{"idempotencyKey": "k-late", "type": "update_task", "taskId": 5, "completed": true}]}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var result models.SyncResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		assert.Equal(t, models.SyncRejected, result.Results[0].Status)
+		assert.Equal(t, "visit_not_in_progress", result.Results[0].Code)
+		assert.False(t, getSchedule(t, dataStore, "3").Tasks[0].Completed)
+	})
+
+	t.Run("SQLite Keeps Readings", func(t *testing.T) {
+		persists(t, func(sqliteStore *store.SQLiteStore) {
+			sqliteApp := newApp(sqliteStore, config.Default())
+			startVisit(t, sqliteApp, "5")
+			req := httptest.NewRequest("PUT", "/api/schedules/5/tasks/9", bytes.NewBufferString(`{"completed": true, "measurements": {"systolicBp": 190, "diastolicBp": 85}}`))
+			req.Header.Set("Content-Type", "application/json")
+			resp, _ := sqliteApp.Test(req)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+		}, func(reopened *store.SQLiteStore) {
+			schedule := getSchedule(t, reopened, "5")
+			require.NotNil(t, schedule.Tasks[0].Measurements)
+			assert.Equal(t, 190.0, *schedule.Tasks[0].Measurements.SystolicBP)
+			require.Len(t, schedule.Exceptions, 1)
+			assert.Equal(t, "systolicBp", schedule.Exceptions[0].Field)
+		})
+	})
+}
+
+func TestTaskValidation(t *testing.T) {
+	app, dataStore := setupTest()
+	send := func(method, path, body string) *http.Response {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+		return resp
+	}
+	fields := func(problem validationProblem) map[string]string {
+		codes := make(map[string]string, len(problem.Fields))
+		for _, field := range problem.Fields {
+			codes[field.Field] = field.Code
+		}
+		return codes
+	}
+
+	t.Run("Not Completed Needs A Reason", func(t *testing.T) {
+		resp := send("PUT", "/api/schedules/1/tasks/1", `{"completed": false, "notCompletedReason": "   "}`)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		problem := decodeValidation(t, resp)
+		assert.Equal(t, "validation_failed", problem.Code)
+		assert.Equal(t, map[string]string{"reasonCode": "required"}, fields(problem))
+		assert.False(t, getSchedule(t, dataStore, "1").Tasks[0].HasOutcome())
+	})
+
+	t.Run("Completed Task Cannot Carry A Reason", func(t *testing.T) {
+		resp := send("PUT", "/api/schedules/1/tasks/1", `{"completed": true, "notCompletedReason": "Client refused"}`)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, map[string]string{"notCompletedReason": "conflict"}, fields(decodeValidation(t, resp)))
+	})
+
+	t.Run("Reason Length Is Limited", func(t *testing.T) {
+		body := fmt.Sprintf(`{"completed": false, "notCompletedReason": %q}`, strings.Repeat("x", models.MaxReasonLength+1))
+		resp := send("PUT", "/api/schedules/1/tasks/1", body)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, map[string]string{"notCompletedReason": "too_long"}, fields(decodeValidation(t, resp)))
+	})
+
+	t.Run("Every Field Of A New Task Is Reported", func(t *testing.T) {
+		body := fmt.Sprintf(`{"name": " ", "description": %q}`, strings.Repeat("x", models.MaxTaskDescriptionLength+1))
+		resp := send("POST", "/api/schedules/1/tasks", body)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, map[string]string{"name": "required", "description": "too_long"}, fields(decodeValidation(t, resp)))
+
+		resp = send("POST", "/api/schedules/1/tasks", `{"name": "  Prepare lunch  "}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		tasks := getSchedule(t, dataStore, "1").Tasks
+		assert.Equal(t, "Prepare lunch", tasks[len(tasks)-1].Name)
+	})
+
+	t.Run("Task Edits Are Validated", func(t *testing.T) {
+		body := fmt.Sprintf(`{"name": %q}`, strings.Repeat("x", models.MaxTaskNameLength+1))
+		resp := send("PATCH", "/api/schedules/1/tasks/1", body)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, map[string]string{"name": "too_long"}, fields(decodeValidation(t, resp)))
+	})
+
+	t.Run("Schedule Tasks Are Reported By Index", func(t *testing.T) {
+		body := `{"clientId": "1", "caregiverId": "1", "serviceName": "Visit",
+			"shiftDate": "2025-03-01", "shiftTime": "09:00-10:00", "amOrPm": "AM",
+			"tasks": [{"name": "Walk"}, {"name": ""}]}`
+		resp := send("POST", "/api/schedules", body)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, map[string]string{"tasks[1].name": "required"}, fields(decodeValidation(t, resp)))
+	})
+
+	t.Run("Sync Rejects Invalid Updates", func(t *testing.T) {
+		resp := send("POST", "/api/sync", `{"mutations": [
+			{"idempotencyKey": "k-no-reason", "type": "update_task", "taskId": 2, "completed": false}
+		]}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var result models.SyncResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		assert.Equal(t, models.SyncRejected, result.Results[0].Status)
+		assert.Equal(t, "validation_failed", result.Results[0].Code)
+		assert.Contains(t, result.Results[0].Error, "reasonCode")
+	})
+}
+
+func TestProblemDetails(t *testing.T) {
+	app, _ := setupTest()
+	send := func(method, path, body string) *http.Response {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+		return resp
+	}
+	decode := func(t *testing.T, resp *http.Response) map[string]any {
+		t.Helper()
+		assert.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+		var problem map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+		return problem
+	}
+
+	t.Run("Not Found Is The Same Everywhere", func(t *testing.T) {
+		for _, req := range [][2]string{
+			{"GET", "/api/schedules/missing"},
+			{"DELETE", "/api/schedules/missing"},
+			{"POST", "/api/schedules/missing/start"},
+			{"GET", "/api/schedules/missing/events"},
+		} {
+			resp := send(req[0], req[1], `{"location": {"latitude": 1, "longitude": 2}}`)
+			require.Equal(t, http.StatusNotFound, resp.StatusCode, req)
+			problem := decode(t, resp)
+			assert.Equal(t, "not_found", problem["code"], req)
+			assert.Equal(t, "/problems/not_found", problem["type"], req)
+			assert.Equal(t, "Not Found", problem["title"], req)
+			assert.Equal(t, 404.0, problem["status"], req)
+			assert.Equal(t, "Schedule missing not found", problem["detail"], req)
+			assert.Equal(t, req[1], problem["instance"], req)
+		}
+	})
+
+	t.Run("Request Errors Have Stable Codes", func(t *testing.T) {
+		cases := []struct {
+			method, path, body string
+			status             int
+			code               string
+		}{
+			{"POST", "/api/schedules", `{not json`, http.StatusBadRequest, "invalid_body"},
+			{"GET", "/api/schedules/1/tasks/abc", "", http.StatusBadRequest, "invalid_task_id"},
+			{"GET", "/api/schedules?sort=bogus", "", http.StatusBadRequest, "bad_request"},
+			{"GET", "/api/nowhere", "", http.StatusNotFound, "route_not_found"},
+			{"DELETE", "/api/clients/1", "", http.StatusConflict, "in_use"},
+		}
+		for _, tc := range cases {
+			resp := send(tc.method, tc.path, tc.body)
+			require.Equal(t, tc.status, resp.StatusCode, tc.path)
+			problem := decode(t, resp)
+			assert.Equal(t, tc.code, problem["code"], tc.path)
+			assert.NotEmpty(t, problem["detail"], tc.path)
+		}
+	})
+
+	t.Run("Extensions Sit Beside The Standard Members", func(t *testing.T) {
+		resp := send("POST", "/api/clients", `{"name": "", "location": {"coordinates": {"latitude": 10, "longitude": 20}}}`)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		problem := decode(t, resp)
+		assert.Equal(t, "validation_failed", problem["code"])
+		fields, _ := problem["fields"].([]any)
+		require.Len(t, fields, 1)
+		assert.Equal(t, "name", fields[0].(map[string]any)["field"])
+
+		resp = send("POST", "/api/schedules/1/end", `{"location": {"latitude": 1, "longitude": 2}}`)
+		require.Equal(t, http.StatusConflict, resp.StatusCode)
+		problem = decode(t, resp)
+		assert.Equal(t, "illegal_status_transition", problem["code"])
+		assert.Equal(t, "scheduled", problem["from"])
+	})
+}
+
+// TestConcurrentVisits hammers the visit endpoints from many goroutines at
+// once. Run it with -race.
+func TestConcurrentVisits(t *testing.T) {
+	app, dataStore := setupTest()
+	send := func(method, path, body string) int {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	countEvents := func(scheduleID string, eventType models.VisitEventType) int {
+		events, err := dataStore.ListEvents(scheduleID)
+		require.NoError(t, err)
+		n := 0
+		for _, event := range events {
+			if event.Type == eventType {
+				n++
+			}
+		}
+		return n
+	}
+	scheduleIDs := []string{"1", "2", "4", "5"}
+	const workers = 8
+	location := `{"location": {"latitude": 40.7128, "longitude": -74.0060}}`
+
+	t.Run("Requests Wait For The Schedule Lock", func(t *testing.T) {
+		unlock := dataStore.LockSchedule("1")
+		done := make(chan int, 1)
+		go func() { done <- send("POST", "/api/schedules/1/start", location) }()
+
+		// Other schedules are not held up.
+		require.Equal(t, http.StatusOK, send("POST", "/api/schedules/2/start", location))
+		require.Equal(t, http.StatusOK, send("POST", "/api/schedules/2/cancel-clock-in", ""))
+		select {
+		case <-done:
+			t.Fatal("start went ahead while the schedule was locked")
+		case <-time.After(50 * time.Millisecond):
+		}
+		unlock()
+		assert.Equal(t, http.StatusOK, <-done)
+		require.Equal(t, http.StatusOK, send("POST", "/api/schedules/1/cancel-clock-in", ""))
+	})
+
+	t.Run("Reset Waits For Held Locks", func(t *testing.T) {
+		unlock := dataStore.LockSchedule("1")
+		done := make(chan error, 1)
+		go func() { done <- dataStore.Reset() }()
+		select {
+		case <-done:
+			t.Fatal("reset went ahead while a schedule was locked")
+		case <-time.After(50 * time.Millisecond):
+		}
+		unlock()
+		assert.NoError(t, <-done)
+	})
+
+	// The plan can no longer change once the visit starts.
+	require.Equal(t, http.StatusOK, send("PATCH", "/api/schedules/1/tasks/1", `{"required": true}`))
+
+	t.Run("Each Visit Starts Once", func(t *testing.T) {
+		var wg sync.WaitGroup
+		var started atomic.Int32
+		for _, id := range scheduleIDs {
+			for range workers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if send("POST", "/api/schedules/"+id+"/start", location) == http.StatusOK {
+						started.Add(1)
+					}
+				}()
+			}
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(len(scheduleIDs)), started.Load())
+		for _, id := range scheduleIDs {
+			assert.Equal(t, 1, countEvents(id, models.EventVisitStarted), id)
+			assert.Equal(t, models.StatusInProgress, getSchedule(t, dataStore, id).Status)
+		}
+	})
+
+	t.Run("Visits End Only After Required Tasks", func(t *testing.T) {
+		var wg sync.WaitGroup
+		var ended atomic.Int32
+		for _, id := range scheduleIDs {
+			schedule := getSchedule(t, dataStore, id)
+			for _, task := range schedule.Tasks {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					path := fmt.Sprintf("/api/schedules/%s/tasks/%d", id, task.ID)
+					for range workers {
+						send("PUT", path, `{"completed": true}`)
+					}
+				}()
+			}
+			for range workers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for range 50 {
+						switch send("POST", "/api/schedules/"+id+"/end", location) {
+						case http.StatusOK:
+							ended.Add(1)
+							return
+						case http.StatusConflict:
+							if getSchedule(t, dataStore, id).Status == models.StatusCompleted {
+								return
+							}
+						}
+						time.Sleep(time.Millisecond)
+					}
+				}()
+			}
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(len(scheduleIDs)), ended.Load())
+		for _, id := range scheduleIDs {
+			assert.Equal(t, 1, countEvents(id, models.EventVisitEnded), id)
+		}
+		// The required task was marked before the visit ended.
+		events, err := dataStore.ListEvents("1")
+		require.NoError(t, err)
+		marked := false
+		for _, event := range events {
+			if event.Type == models.EventTaskMarked && event.TaskID == 1 {
+				marked = true
+			}
+			if event.Type == models.EventVisitEnded {
+				assert.True(t, marked)
+			}
+		}
+	})
+
+	t.Run("Retried Sync Applies Once", func(t *testing.T) {
+		// Every visit has ended by now; start over so task 2 takes outcomes.
+		require.NoError(t, dataStore.Reset())
+		startVisit(t, app, "1")
+		body := `{"mutations": [{"idempotencyKey": "k-race", "type": "update_task", "taskId": 2, "completed": true}]}`
+		var wg sync.WaitGroup
+		for range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				send("POST", "/api/sync", body)
+			}()
+		}
+		wg.Wait()
+
+		event, err := dataStore.GetEventByIdempotencyKey("k-race")
+		require.NoError(t, err)
+		assert.Equal(t, 2, event.TaskID)
+	})
+
+	t.Run("Reset Does Not Interleave With Requests", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range 10 {
+					if i == 0 {
+						send("POST", "/api/reset", "")
+						continue
+					}
+					id := scheduleIDs[i%len(scheduleIDs)]
+					send("POST", "/api/schedules/"+id+"/start", location)
+					send("GET", "/api/schedules/"+id, "")
+					send("POST", "/api/schedules/"+id+"/cancel-clock-in", "")
+				}
+			}()
+		}
+		wg.Wait()
+
+		require.Equal(t, http.StatusOK, send("POST", "/api/reset", ""))
+		for _, id := range scheduleIDs {
+			assert.Equal(t, models.StatusScheduled, getSchedule(t, dataStore, id).Status)
+		}
+	})
+}
+
+func TestETags(t *testing.T) {
+	app, dataStore := setupTest()
+	send := func(method, path, body string, headers ...string) *http.Response {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		resp, _ := app.Test(req)
+		return resp
+	}
+	etagOf := func(t *testing.T, path string) string {
+		t.Helper()
+		resp := send("GET", path, "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		tag := resp.Header.Get("ETag")
+		require.NotEmpty(t, tag)
+		return tag
+	}
+	location := `{"location": {"latitude": 40.7128, "longitude": -74.0060}}`
+
+	t.Run("Schedules Carry Their Version", func(t *testing.T) {
+		resp := send("GET", "/api/schedules/1", "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var schedule models.Schedule
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&schedule))
+		assert.Equal(t, int64(1), schedule.Version)
+		assert.Equal(t, `"1"`, resp.Header.Get("ETag"))
+		assert.Equal(t, int64(1), schedule.Tasks[0].Version)
+	})
+
+	t.Run("If-None-Match Gives 304 Until The Schedule Changes", func(t *testing.T) {
+		tag := etagOf(t, "/api/schedules/2")
+		resp := send("GET", "/api/schedules/2", "", "If-None-Match", tag)
+		assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+		assert.Equal(t, tag, resp.Header.Get("ETag"))
+		body, _ := io.ReadAll(resp.Body)
+		assert.Empty(t, body)
+
+		require.Equal(t, http.StatusOK, send("POST", "/api/schedules/2/start", location).StatusCode)
+		resp = send("GET", "/api/schedules/2", "", "If-None-Match", tag)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, `"2"`, resp.Header.Get("ETag"))
+	})
+
+	t.Run("Every Change Bumps The Version", func(t *testing.T) {
+		version := func() int64 { return getSchedule(t, dataStore, "4").Version }
+		before := version()
+		for _, req := range [][3]string{
+			{"PATCH", "/api/schedules/4", `{"serviceNotes": "Bring gloves"}`},
+			{"POST", "/api/schedules/4/tasks", `{"name": "Water plants"}`},
+			{"PATCH", "/api/schedules/4/tasks/7", `{"required": true}`},
+			{"PUT", "/api/schedules/4/tasks/order", `{"taskIds": [8, 7, 13]}`},
+			{"DELETE", "/api/schedules/4/tasks/13", ""},
+			{"POST", "/api/schedules/4/start", location},
+			{"PUT", "/api/schedules/4/tasks/7", `{"completed": true}`},
+		} {
+			resp := send(req[0], req[1], req[2])
+			require.Less(t, resp.StatusCode, 300, req)
+			after := version()
+			assert.Greater(t, after, before, req)
+			before = after
+		}
+	})
+
+	t.Run("Stale If-Match Fails With 412", func(t *testing.T) {
+		tag := etagOf(t, "/api/schedules/5")
+		require.Equal(t, http.StatusOK, send("PATCH", "/api/schedules/5", `{"serviceNotes": "First"}`, "If-Match", tag).StatusCode)
+
+		resp := send("PATCH", "/api/schedules/5", `{"serviceNotes": "Second"}`, "If-Match", tag)
+		require.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+		var problem map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+		assert.Equal(t, "precondition_failed", problem["code"])
+		current := etagOf(t, "/api/schedules/5")
+		assert.Equal(t, current, problem["etag"])
+		assert.Equal(t, "First", getSchedule(t, dataStore, "5").ServiceNotes)
+
+		for _, req := range [][3]string{
+			{"POST", "/api/schedules/5/start", location},
+			{"POST", "/api/schedules/5/cancel", ""},
+			{"POST", "/api/schedules/5/tasks", `{"name": "Stale"}`},
+			{"PUT", "/api/schedules/5/tasks/order", `{"taskIds": [10, 9]}`},
+			{"DELETE", "/api/schedules/5", ""},
+		} {
+			assert.Equal(t, http.StatusPreconditionFailed, send(req[0], req[1], req[2], "If-Match", tag).StatusCode, req)
+		}
+		assert.Equal(t, models.StatusScheduled, getSchedule(t, dataStore, "5").Status)
+		assert.Len(t, getSchedule(t, dataStore, "5").Tasks, 2)
+
+		resp = send("POST", "/api/schedules/5/start", location, "If-Match", `"0", `+current)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.NotEqual(t, current, resp.Header.Get("ETag"))
+		assert.Equal(t, http.StatusOK, send("POST", "/api/schedules/5/cancel-clock-in", "", "If-Match", "*").StatusCode)
+	})
+
+	t.Run("Task Endpoints Match The Task Version", func(t *testing.T) {
+		tag := etagOf(t, "/api/schedules/1/tasks/1")
+		assert.Equal(t, http.StatusNotModified, send("GET", "/api/schedules/1/tasks/1", "", "If-None-Match", tag).StatusCode)
+
+		// Changes to another task leave this one's version alone.
+		require.Equal(t, http.StatusOK, send("PATCH", "/api/schedules/1/tasks/2", `{"name": "Other"}`).StatusCode)
+		resp := send("PATCH", "/api/schedules/1/tasks/1", `{"name": "Renamed"}`, "If-Match", tag)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		renamed := resp.Header.Get("ETag")
+		assert.NotEqual(t, tag, renamed)
+		startVisit(t, app, "1")
+
+		assert.Equal(t, http.StatusPreconditionFailed, send("PUT", "/api/schedules/1/tasks/1", `{"completed": true}`, "If-Match", tag).StatusCode)
+		assert.Equal(t, http.StatusPreconditionFailed, send("PUT", "/api/tasks/1/update", `{"completed": true}`, "If-Match", tag).StatusCode)
+		assert.Equal(t, http.StatusPreconditionFailed, send("DELETE", "/api/schedules/1/tasks/1", "", "If-Match", tag).StatusCode)
+		assert.False(t, getSchedule(t, dataStore, "1").Tasks[0].Completed)
+
+		resp = send("PUT", "/api/schedules/1/tasks/1", `{"completed": true}`, "If-Match", renamed)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, etagOf(t, "/api/schedules/1/tasks/1"), resp.Header.Get("ETag"))
+	})
+
+	t.Run("Occurrence Edits Match The Schedule Version", func(t *testing.T) {
+		day := time.Now().AddDate(0, 0, 2)
+		date := day.Format("2006-01-02")
+		resp := send("POST", "/api/recurrences", `{"clientId": "1", "caregiverId": "1", "serviceName": "Check-in",
+			"startDate": "`+date+`", "shiftTime": "09:00 - 10:00", "amOrPm": "AM", "rrule": "FREQ=DAILY;COUNT=2"}`)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		var series models.Recurrence
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&series))
+		occurrence := "/api/recurrences/" + series.ID + "/occurrences/" + date
+		id := series.InstanceID(day)
+		instance := "/api/schedules/" + id
+		tag := etagOf(t, instance)
+
+		require.Equal(t, http.StatusOK, send("PATCH", instance, `{"serviceNotes": "First"}`).StatusCode)
+		assert.Equal(t, http.StatusPreconditionFailed, send("PATCH", occurrence, `{"serviceNotes": "Second"}`, "If-Match", tag).StatusCode)
+		assert.Equal(t, http.StatusPreconditionFailed, send("DELETE", occurrence, "", "If-Match", tag).StatusCode)
+		assert.Equal(t, "First", getSchedule(t, dataStore, id).ServiceNotes)
+
+		resp = send("PATCH", occurrence, `{"serviceNotes": "Second"}`, "If-Match", etagOf(t, instance))
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, etagOf(t, instance), resp.Header.Get("ETag"))
+		assert.Equal(t, http.StatusNoContent, send("DELETE", occurrence, "", "If-Match", resp.Header.Get("ETag")).StatusCode)
+	})
+
+	t.Run("Sync Ignores Versions", func(t *testing.T) {
+		resp := send("POST", "/api/sync", `{"mutations": [
+			{"idempotencyKey": "etag-1", "type": "update_task", "scheduleId": "1", "taskId": 2, "completed": true}
+		]}`, "If-Match", `"0"`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var result models.SyncResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		assert.Equal(t, models.SyncApplied, result.Results[0].Status)
+	})
+
+	t.Run("SQLite Keeps Versions", func(t *testing.T) {
+		var before int64
+		persists(t, func(sqliteStore *store.SQLiteStore) {
+			before = getSchedule(t, sqliteStore, "1").Version
+			task := getSchedule(t, sqliteStore, "1").Tasks[0]
+			task.Name = "Renamed"
+			require.NoError(t, sqliteStore.UpdateTask("1", &task))
+			assert.Equal(t, int64(2), task.Version)
+			require.NoError(t, sqliteStore.CreateTask("1", &models.Task{Name: "New"}))
+		}, func(reopened *store.SQLiteStore) {
+			schedule := getSchedule(t, reopened, "1")
+			assert.Equal(t, before+2, schedule.Version)
+			assert.Equal(t, int64(2), schedule.Tasks[0].Version)
+			assert.Equal(t, int64(1), schedule.Tasks[2].Version)
+		})
+	})
+}
+
+func TestAuthentication(t *testing.T) {
+	dataStore := store.NewStore()
+	dataStore.SetupInitialData()
+	appWith := func(repo store.Repository, keys ...auth.Key) *fiber.App {
+		cfg := config.Default()
+		cfg.SigningKeys = keys
+		cfg.Demo = true
+		if err := handler.BootstrapUsers(repo, cfg); err != nil {
+			panic(err)
+		}
+		app := fiber.New(router.AppConfig())
+		if err := router.SetupRoutes(app, repo, cfg); err != nil {
+			panic(err)
+		}
+		return app
+	}
+	app := appWith(dataStore, testKey)
+	send := func(app *fiber.App, method, path, body, token string) *http.Response {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		// bcrypt can outlast the default one-second timeout under -race.
+		resp, _ := app.Test(req, -1)
+		return resp
+	}
+	login := func(t *testing.T, app *fiber.App, username string) models.TokenResponse {
+		t.Helper()
+		resp := send(app, "POST", "/api/auth/login", fmt.Sprintf(`{"username": %q, "password": %q}`, username, username+"-demo"), "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var tokens models.TokenResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&tokens))
+		return tokens
+	}
+	refresh := func(app *fiber.App, refreshToken string) *http.Response {
+		return send(app, "POST", "/api/auth/refresh", fmt.Sprintf(`{"refreshToken": %q}`, refreshToken), "")
+	}
+	problemCode := func(t *testing.T, resp *http.Response) string {
+		t.Helper()
+		var problem map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+		return problem["code"].(string)
+	}
+
+	t.Run("Requests Need A Token", func(t *testing.T) {
+		for _, req := range [][2]string{
+			{"GET", "/api/schedules"},
+			{"POST", "/api/reset"},
+			{"POST", "/api/sync"},
+			{"GET", "/api/auth/me"},
+		} {
+			resp := send(app, req[0], req[1], "", "")
+			require.Equal(t, http.StatusUnauthorized, resp.StatusCode, req)
+			assert.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"), req)
+			assert.Equal(t, `Bearer realm="evv"`, resp.Header.Get("WWW-Authenticate"), req)
+			assert.Equal(t, "unauthorized", problemCode(t, resp), req)
+		}
+		assert.Equal(t, http.StatusOK, send(app, "GET", "/", "", "").StatusCode)
+		assert.Len(t, getSchedule(t, dataStore, "1").Tasks, 2)
+	})
+
+	t.Run("Login Issues An Access Token", func(t *testing.T) {
+		tokens := login(t, app, "sarah")
+		assert.Equal(t, "Bearer", tokens.TokenType)
+		assert.Equal(t, 900, tokens.ExpiresIn)
+		assert.NotEmpty(t, tokens.RefreshToken)
+		assert.Equal(t, models.RoleCaregiver, tokens.Principal.Role)
+
+		resp := send(app, "GET", "/api/auth/me", "", tokens.AccessToken)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var principal models.Principal
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&principal))
+		assert.Equal(t, models.Principal{UserID: "u-sarah", Username: "sarah", Role: models.RoleCaregiver, CaregiverID: "1"}, principal)
+		assert.Equal(t, http.StatusOK, send(app, "GET", "/api/schedules/1", "", tokens.AccessToken).StatusCode)
+	})
+
+	t.Run("Wrong Credentials Are Refused", func(t *testing.T) {
+		for _, body := range []string{
+			`{"username": "sarah", "password": "wrong"}`,
+			`{"username": "nobody", "password": "nobody-demo"}`,
+			`{"username": "sarah"}`,
+		} {
+			resp := send(app, "POST", "/api/auth/login", body, "")
+			require.Equal(t, http.StatusUnauthorized, resp.StatusCode, body)
+			assert.Equal(t, "invalid_credentials", problemCode(t, resp), body)
+		}
+	})
+
+	t.Run("Bad Tokens Are Refused", func(t *testing.T) {
+		valid := login(t, app, "coordinator").AccessToken
+		otherKey := must(auth.GenerateKey("test"))
+		forged := must(must(auth.NewKeyRing([]auth.Key{otherKey}, time.Hour)).Issue(models.Principal{UserID: "u-admin", Role: models.RoleAdmin}))
+		header, _, _ := strings.Cut(valid, ".")
+		unsigned := header + ".eyJzdWIiOiJ1LWFkbWluIiwicm9sZSI6ImFkbWluIn0."
+
+		for name, token := range map[string]string{
+			"tampered":  valid[:len(valid)-4] + "AAAA",
+			"forged":    forged,
+			"unsigned":  unsigned,
+			"malformed": "not-a-jwt",
+		} {
+			resp := send(app, "GET", "/api/schedules", "", token)
+			require.Equal(t, http.StatusUnauthorized, resp.StatusCode, name)
+			assert.Equal(t, "invalid_token", problemCode(t, resp), name)
+		}
+
+		expired := must(must(auth.NewKeyRing([]auth.Key{testKey}, -time.Minute)).Issue(models.Principal{UserID: "u-admin", Role: models.RoleAdmin}))
+		resp := send(app, "GET", "/api/schedules", "", expired)
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.Equal(t, "token_expired", problemCode(t, resp))
+	})
+
+	t.Run("Keys Rotate", func(t *testing.T) {
+		secret := bytes.Repeat([]byte{7}, auth.MinHMACKeyBytes)
+		keys, err := auth.ParseKeys("hs-2:HS256:" + base64.StdEncoding.EncodeToString(secret) + ", ed-1:EdDSA:" +
+			base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, ed25519.SeedSize)))
+		require.NoError(t, err)
+		require.Len(t, keys, 2)
+		assert.Equal(t, "HS256", keys[0].Algorithm())
+		assert.Equal(t, "EdDSA", keys[1].Algorithm())
+
+		oldApp := appWith(dataStore, keys[1])
+		oldToken := login(t, oldApp, "coordinator").AccessToken
+		rotated := appWith(dataStore, keys...)
+		newToken := login(t, rotated, "coordinator").AccessToken
+		assert.Equal(t, http.StatusOK, send(rotated, "GET", "/api/schedules", "", oldToken).StatusCode)
+		assert.Equal(t, http.StatusOK, send(rotated, "GET", "/api/schedules", "", newToken).StatusCode)
+
+		retired := appWith(dataStore, keys[0])
+		assert.Equal(t, http.StatusUnauthorized, send(retired, "GET", "/api/schedules", "", oldToken).StatusCode)
+		assert.Equal(t, http.StatusOK, send(retired, "GET", "/api/schedules", "", newToken).StatusCode)
+
+		// An HS256 token naming the EdDSA key is not checked against it.
+		confused := must(must(auth.NewKeyRing([]auth.Key{must(auth.NewHMACKey("ed-1", secret))}, time.Hour)).Issue(models.Principal{UserID: "u-admin"}))
+		assert.Equal(t, http.StatusUnauthorized, send(rotated, "GET", "/api/schedules", "", confused).StatusCode)
+
+		for _, spec := range []string{
+			"short:HS256:" + base64.StdEncoding.EncodeToString([]byte("too short")),
+			"rsa:RS256:" + base64.StdEncoding.EncodeToString(secret),
+			"nokey",
+			"a:HS256:" + base64.StdEncoding.EncodeToString(secret) + ",a:HS256:" + base64.StdEncoding.EncodeToString(secret),
+		} {
+			_, err := auth.ParseKeys(spec)
+			assert.Error(t, err, spec)
+		}
+	})
+
+	t.Run("Startup Needs Valid Signing Keys", func(t *testing.T) {
+		secret := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, auth.MinHMACKeyBytes))
+
+		t.Setenv("EVV_JWT_KEYS", "hs-1:HS256:"+secret)
+		cfg, err := config.FromEnv()
+		require.NoError(t, err)
+		require.Len(t, cfg.SigningKeys, 1)
+		assert.Equal(t, "hs-1", cfg.SigningKeys[0].ID)
+
+		// A malformed key is not replaced by a generated one, even in demo
+		// mode.
+		t.Setenv("EVV_JWT_KEYS", "hs-1:HS256:"+base64.StdEncoding.EncodeToString([]byte("too short")))
+		_, err = config.FromEnv()
+		assert.ErrorContains(t, err, "EVV_JWT_KEYS")
+		t.Setenv("EVV_DEMO", "true")
+		_, err = config.FromEnv()
+		assert.ErrorContains(t, err, "EVV_JWT_KEYS")
+
+		// Only demo mode may run without keys, and signs with a generated
+		// one.
+		t.Setenv("EVV_JWT_KEYS", "")
+		cfg, err = config.FromEnv()
+		require.NoError(t, err)
+		assert.True(t, cfg.Demo)
+		require.Len(t, cfg.SigningKeys, 1)
+		assert.True(t, strings.HasPrefix(cfg.SigningKeys[0].ID, "generated-"))
+		require.NoError(t, router.SetupRoutes(fiber.New(router.AppConfig()), dataStore, cfg))
+		cfg.SigningKeys = nil
+		assert.ErrorContains(t, router.SetupRoutes(fiber.New(router.AppConfig()), dataStore, cfg), "signing keys")
+		t.Setenv("EVV_DEMO", "")
+		_, err = config.FromEnv()
+		assert.ErrorContains(t, err, "EVV_JWT_KEYS is not set")
+		t.Setenv("EVV_DEMO", "yes please")
+		_, err = config.FromEnv()
+		assert.ErrorContains(t, err, "EVV_DEMO")
+	})
+
+	t.Run("Demo Accounts Only In Demo Mode", func(t *testing.T) {
+		repo := store.NewStore()
+		repo.SetupInitialData()
+		cfg := config.Default()
+		cfg.SigningKeys = []auth.Key{testKey}
+		assert.ErrorContains(t, handler.BootstrapUsers(repo, cfg), "no admin account")
+
+		t.Setenv("EVV_JWT_KEYS", "hs-1:HS256:"+base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, auth.MinHMACKeyBytes)))
+		t.Setenv("EVV_ADMIN_USERNAME", "ops")
+		t.Setenv("EVV_ADMIN_PASSWORD", "a long admin passphrase")
+		fromEnv, err := config.FromEnv()
+		require.NoError(t, err)
+		cfg.AdminUsername, cfg.AdminPassword = fromEnv.AdminUsername, fromEnv.AdminPassword
+		require.NoError(t, handler.BootstrapUsers(repo, cfg))
+		// Starting again leaves the admin as it is.
+		require.NoError(t, handler.BootstrapUsers(repo, cfg))
+		admins, err := repo.CountUsers(models.RoleAdmin)
+		require.NoError(t, err)
+		assert.Equal(t, 1, admins)
+
+		prodApp := fiber.New(router.AppConfig())
+		require.NoError(t, router.SetupRoutes(prodApp, repo, cfg))
+		loginAs := func(username, password string) int {
+			return send(prodApp, "POST", "/api/auth/login", fmt.Sprintf(`{"username": %q, "password": %q}`, username, password), "").StatusCode
+		}
+		assert.Equal(t, http.StatusOK, loginAs("ops", "a long admin passphrase"))
+		assert.Equal(t, http.StatusUnauthorized, loginAs("admin", "admin-demo"))
+		assert.Equal(t, http.StatusUnauthorized, loginAs("sarah", "sarah-demo"))
+
+		// Reset restores the demo data but not the demo accounts.
+		require.NoError(t, repo.Reset())
+		assert.Equal(t, http.StatusOK, loginAs("ops", "a long admin passphrase"))
+		assert.Equal(t, http.StatusUnauthorized, loginAs("admin", "admin-demo"))
+	})
+
+	t.Run("Refresh Tokens Rotate And Detect Reuse", func(t *testing.T) {
+		first := login(t, app, "marcus")
+		resp := refresh(app, first.RefreshToken)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var second models.TokenResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&second))
+		assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+		assert.Equal(t, http.StatusOK, send(app, "GET", "/api/auth/me", "", second.AccessToken).StatusCode)
+
+		// Replaying the first token revokes the whole family.
+		resp = refresh(app, first.RefreshToken)
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.Equal(t, "invalid_refresh_token", problemCode(t, resp))
+		assert.Equal(t, http.StatusUnauthorized, refresh(app, second.RefreshToken).StatusCode)
+
+		assert.Equal(t, http.StatusUnauthorized, refresh(app, "made-up").StatusCode)
+		resp = refresh(app, "")
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "refreshToken", decodeValidation(t, resp).Fields[0].Field)
+	})
+
+	t.Run("Logout Revokes The Refresh Token", func(t *testing.T) {
+		tokens := login(t, app, "coordinator")
+		other := login(t, app, "coordinator")
+		resp := send(app, "POST", "/api/auth/logout", fmt.Sprintf(`{"refreshToken": %q}`, tokens.RefreshToken), "")
+		require.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Equal(t, http.StatusUnauthorized, refresh(app, tokens.RefreshToken).StatusCode)
+		// Other sessions of the user are not affected.
+		assert.Equal(t, http.StatusOK, refresh(app, other.RefreshToken).StatusCode)
+	})
+
+	t.Run("SQLite Keeps Users And Refresh Tokens", func(t *testing.T) {
+		var tokens models.TokenResponse
+		persists(t, func(sqliteStore *store.SQLiteStore) {
+			tokens = login(t, appWith(sqliteStore, testKey), "admin")
+		}, func(reopened *store.SQLiteStore) {
+			// A reset keeps them too.
+			require.NoError(t, reopened.Reset())
+			sqliteApp := appWith(reopened, testKey)
+			assert.Equal(t, http.StatusOK, send(sqliteApp, "GET", "/api/schedules", "", tokens.AccessToken).StatusCode)
+			resp := refresh(sqliteApp, tokens.RefreshToken)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, http.StatusUnauthorized, refresh(sqliteApp, tokens.RefreshToken).StatusCode)
+		})
+	})
+}
+
+func TestAuthorization(t *testing.T) {
+	app, dataStore := setupTest()
+	ring := must(auth.NewKeyRing([]auth.Key{testKey}, time.Hour))
+	sarah := must(ring.Issue(models.Principal{UserID: "u-sarah", Username: "sarah", Role: models.RoleCaregiver, CaregiverID: "1"}))
+	coordinator := must(ring.Issue(models.Principal{UserID: "u-coordinator", Username: "coordinator", Role: models.RoleCoordinator}))
+	send := func(method, path, body, token string) *http.Response {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, _ := app.Test(req)
+		return resp
+	}
+	requireForbidden := func(t *testing.T, resp *http.Response, permission auth.Permission) {
+		t.Helper()
+		require.Equal(t, http.StatusForbidden, resp.StatusCode)
+		var problem map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+		assert.Equal(t, "forbidden", problem["code"])
+		assert.Equal(t, string(permission), problem["permission"])
+	}
+	location := `{"location": {"latitude": 40.712776, "longitude": -74.005974}}`
+
+	t.Run("Caregivers See Only Their Schedules", func(t *testing.T) {
+		resp := send("GET", "/api/schedules", "", sarah)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var schedules []models.Schedule
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&schedules))
+		require.NotEmpty(t, schedules)
+		for _, schedule := range schedules {
+			assert.Equal(t, "1", schedule.CaregiverID, schedule.ID)
+		}
+		assert.Equal(t, http.StatusOK, send("GET", "/api/schedules?caregiverId=1", "", sarah).StatusCode)
+		assert.Equal(t, http.StatusOK, send("GET", "/api/schedules/today", "", sarah).StatusCode)
+		assert.Equal(t, http.StatusOK, send("GET", "/api/schedules/1", "", sarah).StatusCode)
+		assert.Equal(t, http.StatusOK, send("GET", "/api/schedules/1/tasks/1", "", sarah).StatusCode)
+		assert.Equal(t, http.StatusOK, send("GET", "/api/caregivers/1/schedules", "", sarah).StatusCode)
+
+		requireForbidden(t, send("GET", "/api/schedules?caregiverId=2", "", sarah), auth.PermSchedulesAll)
+		requireForbidden(t, send("GET", "/api/schedules/2", "", sarah), auth.PermSchedulesAll)
+		requireForbidden(t, send("GET", "/api/schedules/2/events", "", sarah), auth.PermSchedulesAll)
+		requireForbidden(t, send("GET", "/api/schedules/2/tasks/3", "", sarah), auth.PermSchedulesAll)
+		requireForbidden(t, send("GET", "/api/caregivers/2/schedules", "", sarah), auth.PermSchedulesAll)
+		requireForbidden(t, send("GET", "/api/caregivers", "", sarah), auth.PermCaregiversRead)
+		requireForbidden(t, send("GET", "/api/recurrences", "", sarah), auth.PermSchedulesAll)
+	})
+
+	t.Run("Caregivers Clock Into Only Their Schedules", func(t *testing.T) {
+		requireForbidden(t, send("POST", "/api/schedules/2/start", location, sarah), auth.PermSchedulesAll)
+		requireForbidden(t, send("GET", "/api/schedules/2/clock-in", "", sarah), auth.PermSchedulesAll)
+		requireForbidden(t, send("PUT", "/api/schedules/2/tasks/3", `{"completed": true}`, sarah), auth.PermSchedulesAll)
+		requireForbidden(t, send("PUT", "/api/tasks/3/update", `{"completed": true}`, sarah), auth.PermSchedulesAll)
+		assert.Equal(t, models.StatusScheduled, getSchedule(t, dataStore, "2").Status)
+		assert.False(t, getSchedule(t, dataStore, "2").Tasks[0].Completed)
+
+		assert.Equal(t, http.StatusOK, send("POST", "/api/schedules/1/start", location, sarah).StatusCode)
+		assert.Equal(t, http.StatusOK, send("PUT", "/api/tasks/1/update", `{"completed": true}`, sarah).StatusCode)
+		assert.Equal(t, models.StatusInProgress, getSchedule(t, dataStore, "1").Status)
+	})
+
+	t.Run("Sync Rejects Other Caregivers' Visits", func(t *testing.T) {
+		resp := send("POST", "/api/sync", `{"deviceId": "phone-1", "mutations": [
+			{"idempotencyKey": "rbac-own", "type": "update_task", "taskId": 2, "completed": true},
+			{"idempotencyKey": "rbac-other", "type": "start_visit", "scheduleId": "2", "location": {"latitude": 40.712776, "longitude": -74.005974}},
+			{"idempotencyKey": "rbac-other-task", "type": "update_task", "taskId": 4, "completed": true}
+		]}`, sarah)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var result models.SyncResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		require.Len(t, result.Results, 3)
+		assert.Equal(t, models.SyncApplied, result.Results[0].Status)
+		for _, r := range result.Results[1:] {
+			assert.Equal(t, models.SyncRejected, r.Status, r.IdempotencyKey)
+			assert.Equal(t, "forbidden", r.Code, r.IdempotencyKey)
+		}
+		assert.Equal(t, models.StatusScheduled, getSchedule(t, dataStore, "2").Status)
+	})
+
+	t.Run("Caregivers Cannot Manage Schedules", func(t *testing.T) {
+		requireForbidden(t, send("POST", "/api/schedules", `{}`, sarah), auth.PermSchedulesWrite)
+		requireForbidden(t, send("POST", "/api/schedules/1/mark-missed", "", sarah), auth.PermSchedulesWrite)
+		requireForbidden(t, send("PATCH", "/api/schedules/1/tasks/1", `{"name": "Renamed"}`, sarah), auth.PermTasksWrite)
+		requireForbidden(t, send("POST", "/api/schedules/1/tasks", `{"name": "Extra"}`, sarah), auth.PermTasksWrite)
+		assert.Len(t, getSchedule(t, dataStore, "1").Tasks, 2)
+	})
+
+	t.Run("Coordinators Manage Schedules And Tasks", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, send("GET", "/api/schedules/2", "", coordinator).StatusCode)
+		assert.Equal(t, http.StatusOK, send("PATCH", "/api/schedules/2/tasks/3", `{"name": "Renamed"}`, coordinator).StatusCode)
+		assert.Equal(t, http.StatusOK, send("POST", "/api/schedules/2/tasks", `{"name": "Extra"}`, coordinator).StatusCode)
+		assert.Equal(t, http.StatusOK, send("POST", "/api/schedules/2/start", location, coordinator).StatusCode)
+		assert.Equal(t, http.StatusOK, send("GET", "/api/recurrences", "", coordinator).StatusCode)
+
+		requireForbidden(t, send("POST", "/api/caregivers", `{"name": "New"}`, coordinator), auth.PermCaregiversWrite)
+		requireForbidden(t, send("POST", "/api/reset", "", coordinator), auth.PermStoreReset)
+		requireForbidden(t, send("POST", "/api/recurrences/roll", "", coordinator), auth.PermRecurrencesRoll)
+		assert.Equal(t, models.StatusInProgress, getSchedule(t, dataStore, "2").Status)
+	})
+
+	t.Run("Only Admins Reset The Store", func(t *testing.T) {
+		requireForbidden(t, send("POST", "/api/reset", "", sarah), auth.PermStoreReset)
+		assert.Equal(t, http.StatusOK, send("POST", "/api/reset", "", adminToken).StatusCode)
+		assert.Equal(t, models.StatusScheduled, getSchedule(t, dataStore, "2").Status)
+	})
+}
+
+func TestAPIKeys(t *testing.T) {
+	app, _ := setupTest()
+	send := func(method, path, body string, headers ...string) *http.Response {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		resp, _ := app.Test(req)
+		return resp
+	}
+	create := func(t *testing.T, body string) models.CreatedAPIKey {
+		t.Helper()
+		resp := send("POST", "/api/api-keys", body)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		var created models.CreatedAPIKey
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+		return created
+	}
+	listKeys := func(t *testing.T) []map[string]any {
+		t.Helper()
+		resp := send("GET", "/api/api-keys", "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var keys []map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&keys))
+		return keys
+	}
+	requireProblem := func(t *testing.T, resp *http.Response, status int, code string) map[string]any {
+		t.Helper()
+		require.Equal(t, status, resp.StatusCode)
+		var problem map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+		assert.Equal(t, code, problem["code"])
+		return problem
+	}
+
+	payroll := create(t, `{"name": "Payroll", "scopes": ["schedules:read", "schedules:read"]}`)
+	withKey := func(key string) []string { return []string{handler.APIKeyHeader, key} }
+
+	t.Run("Create Returns The Key Once", func(t *testing.T) {
+		assert.Equal(t, "Payroll", payroll.Name)
+		assert.Equal(t, []string{"schedules:read"}, payroll.Scopes)
+		assert.Equal(t, "u-admin", payroll.CreatedBy)
+		assert.True(t, strings.HasPrefix(payroll.Key, payroll.Prefix+"_"), payroll.Key)
+		assert.Nil(t, payroll.LastUsedAt)
+
+		keys := listKeys(t)
+		require.Len(t, keys, 1)
+		assert.Equal(t, payroll.ID, keys[0]["id"])
+		assert.Equal(t, payroll.Prefix, keys[0]["prefix"])
+		assert.NotContains(t, keys[0], "key")
+		assert.NotContains(t, keys[0], "hash")
+	})
+
+	t.Run("Keys Are Checked", func(t *testing.T) {
+		for _, key := range []string{payroll.Key + "x", "evv_00000000_made-up", "not-a-key"} {
+			requireProblem(t, send("GET", "/api/schedules", "", withKey(key)...), http.StatusUnauthorized, "invalid_api_key")
+		}
+		problem := requireProblem(t, send("POST", "/api/api-keys", `{"name": " ", "scopes": ["payroll"]}`), http.StatusBadRequest, "validation_failed")
+		fields := problem["fields"].([]any)
+		require.Len(t, fields, 2)
+		assert.Equal(t, "name", fields[0].(map[string]any)["field"])
+		assert.Equal(t, "scopes[0]", fields[1].(map[string]any)["field"])
+		problem = requireProblem(t, send("POST", "/api/api-keys", `{"name": "Empty"}`), http.StatusBadRequest, "validation_failed")
+		assert.Equal(t, "scopes", problem["fields"].([]any)[0].(map[string]any)["field"])
+	})
+
+	t.Run("Scopes Limit What A Key Can Do", func(t *testing.T) {
+		resp := send("GET", "/api/schedules", "", withKey(payroll.Key)...)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var schedules []models.Schedule
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&schedules))
+		caregivers := map[string]bool{}
+		for _, schedule := range schedules {
+			caregivers[schedule.CaregiverID] = true
+		}
+		assert.Equal(t, map[string]bool{"1": true, "2": true}, caregivers)
+		assert.Equal(t, http.StatusOK, send("GET", "/api/schedules/2/tasks/3", "", withKey(payroll.Key)...).StatusCode)
+
+		resp = send("GET", "/api/auth/me", "", withKey(payroll.Key)...)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var principal models.Principal
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&principal))
+		assert.Equal(t, models.Principal{APIKeyID: payroll.ID, Scopes: []string{"schedules:read"}}, principal)
+
+		for path, permission := range map[string]auth.Permission{
+			"/api/schedules/1/start": auth.PermVisitsRecord,
+			"/api/reset":             auth.PermStoreReset,
+			"/api/api-keys":          auth.PermAPIKeysManage,
+		} {
+			problem := requireProblem(t, send("POST", path, `{"location": {"latitude": 40.712776, "longitude": -74.005974}}`, withKey(payroll.Key)...), http.StatusForbidden, "forbidden")
+			assert.Equal(t, string(permission), problem["permission"], path)
+		}
+
+		for _, scope := range []string{"export", "webhooks:admin"} {
+			problem := requireProblem(t, send("POST", "/api/api-keys", `{"name": "Unused", "scopes": ["`+scope+`"]}`), http.StatusBadRequest, "validation_failed")
+			assert.Equal(t, "scopes[0]", problem["fields"].([]any)[0].(map[string]any)["field"], scope)
+		}
+	})
+
+	t.Run("Use Is Recorded", func(t *testing.T) {
+		for _, key := range listKeys(t) {
+			if key["id"] == payroll.ID {
+				lastUsed, err := time.Parse(time.RFC3339Nano, key["lastUsedAt"].(string))
+				require.NoError(t, err)
+				assert.WithinDuration(t, time.Now(), lastUsed, time.Minute)
+			}
+		}
+	})
+
+	t.Run("Only Admins Manage Keys", func(t *testing.T) {
+		ring := must(auth.NewKeyRing([]auth.Key{testKey}, time.Hour))
+		coordinator := must(ring.Issue(models.Principal{UserID: "u-coordinator", Username: "coordinator", Role: models.RoleCoordinator}))
+		problem := requireProblem(t, send("GET", "/api/api-keys", "", "Authorization", "Bearer "+coordinator), http.StatusForbidden, "forbidden")
+		assert.Equal(t, string(auth.PermAPIKeysManage), problem["permission"])
+	})
+
+	t.Run("Revoked Keys Stop Working", func(t *testing.T) {
+		resp := send("POST", "/api/api-keys/"+payroll.ID+"/revoke", "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var revoked models.APIKey
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&revoked))
+		require.NotNil(t, revoked.RevokedAt)
+		requireProblem(t, send("GET", "/api/schedules", "", withKey(payroll.Key)...), http.StatusUnauthorized, "invalid_api_key")
+
+		resp = send("POST", "/api/api-keys/"+payroll.ID+"/revoke", "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var again models.APIKey
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&again))
+		assert.True(t, revoked.RevokedAt.Equal(*again.RevokedAt))
+		assert.Equal(t, http.StatusNotFound, send("POST", "/api/api-keys/missing/revoke", "").StatusCode)
+	})
+
+	t.Run("SQLite Keeps API Keys", func(t *testing.T) {
+		var created models.CreatedAPIKey
+		persists(t, func(sqliteStore *store.SQLiteStore) {
+			sqliteApp := newApp(sqliteStore, config.Default())
+			req := httptest.NewRequest("POST", "/api/api-keys", bytes.NewBufferString(`{"name": "Payroll", "scopes": ["schedules:read"]}`))
+			req.Header.Set("Content-Type", "application/json")
+			resp, _ := sqliteApp.Test(req)
+			require.Equal(t, http.StatusCreated, resp.StatusCode)
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+		}, func(reopened *store.SQLiteStore) {
+			sqliteApp := newApp(reopened, config.Default())
+			req := httptest.NewRequest("GET", "/api/schedules/1", nil)
+			req.Header.Set(handler.APIKeyHeader, created.Key)
+			resp, _ := sqliteApp.Test(req)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+			keys, err := reopened.ListAPIKeys()
+			require.NoError(t, err)
+			require.Len(t, keys, 1)
+			assert.Equal(t, []string{"schedules:read"}, keys[0].Scopes)
+			assert.NotNil(t, keys[0].LastUsedAt)
+			_, err = reopened.RevokeAPIKey(created.ID, time.Now())
+			require.NoError(t, err)
+			req = httptest.NewRequest("GET", "/api/schedules/1", nil)
+			req.Header.Set(handler.APIKeyHeader, created.Key)
+			resp, _ = sqliteApp.Test(req)
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		})
 	})
 }
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// NewRefreshToken returns a random opaque refresh token and the hash the
+// store keeps of it.
+func NewRefreshToken() (token, hash string, err error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", fmt.Errorf("generate refresh token: %w", err)
+	}
+	token = base64.RawURLEncoding.EncodeToString(raw)
+	return token, HashRefreshToken(token), nil
+}
+
+// HashRefreshToken returns the hex SHA-256 of a refresh token. Refresh
+// tokens are random, so a fast hash is enough to keep a stolen database from
+// yielding usable tokens.
+func HashRefreshToken(token string) string {
+	return hashSecret(token)
+}
+
+// APIKeyPrefix starts every API key, so that leaked keys are easy to spot.
+const APIKeyPrefix = "evv_"
+
+// NewAPIKey returns a random API key written "evv_<id>_<secret>", the part
+// before the secret to show in listings, and the hash the store keeps.
+func NewAPIKey() (key, prefix, hash string, err error) {
+	raw := make([]byte, 36)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", "", fmt.Errorf("generate API key: %w", err)
+	}
+	prefix = APIKeyPrefix + hex.EncodeToString(raw[:4])
+	key = prefix + "_" + base64.RawURLEncoding.EncodeToString(raw[4:])
+	return key, prefix, HashAPIKey(key), nil
+}
+
+// HashAPIKey returns the hex SHA-256 of an API key, or "" for a string that
+// is not shaped like one. API keys are random like refresh tokens, so the
+// same fast hash does.
+func HashAPIKey(key string) string {
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		return ""
+	}
+	return hashSecret(key)
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// dummyHash is compared against when the user does not exist, so that a
+// login for an unknown user takes as long as one with a wrong password.
+var dummyHash = []byte("$2a$10$vC6e2Tk8ZfFOGD1Kva3At.MLlSLjJAvSYtQOR0pL9ia3DzOjZ9qDS")
+
+// HashPassword returns the bcrypt hash of password that CheckPassword
+// compares against.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// CheckPassword reports whether password matches the bcrypt hash. An empty
+// hash never matches but takes as long as one that does not.
+func CheckPassword(hash, password string) bool {
+	if hash == "" {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -0,0 +1,117 @@
+// Package auth issues and verifies the API's credentials: JWT access tokens
+// signed with a rotating set of keys, opaque refresh tokens, API keys and
+// password hashes, and decides what each role or API key may do.
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// MinHMACKeyBytes is the shortest HS256 secret accepted.
+const MinHMACKeyBytes = 32
+
+// Key is one signing key, named by the kid header of the tokens it signs.
+type Key struct {
+	ID        string
+	method    jwt.SigningMethod
+	signKey   any
+	verifyKey any
+}
+
+// Algorithm is the JWT alg the key signs with: HS256 or EdDSA.
+func (k Key) Algorithm() string {
+	return k.method.Alg()
+}
+
+// NewHMACKey returns an HS256 key.
+func NewHMACKey(id string, secret []byte) (Key, error) {
+	if id == "" {
+		return Key{}, errors.New("key ID is required")
+	}
+	if len(secret) < MinHMACKeyBytes {
+		return Key{}, fmt.Errorf("HS256 key %s is %d bytes; it must be at least %d", id, len(secret), MinHMACKeyBytes)
+	}
+	return Key{ID: id, method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}, nil
+}
+
+// NewEd25519Key returns an EdDSA key.
+func NewEd25519Key(id string, private ed25519.PrivateKey) (Key, error) {
+	if id == "" {
+		return Key{}, errors.New("key ID is required")
+	}
+	if len(private) != ed25519.PrivateKeySize {
+		return Key{}, fmt.Errorf("EdDSA key %s is not an Ed25519 private key", id)
+	}
+	return Key{ID: id, method: jwt.SigningMethodEdDSA, signKey: private, verifyKey: private.Public()}, nil
+}
+
+// GenerateKey returns a new random EdDSA key.
+func GenerateKey(id string) (Key, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return Key{}, fmt.Errorf("generate key %s: %w", id, err)
+	}
+	return NewEd25519Key(id, private)
+}
+
+// ParseKeys reads a comma-separated list of keys, each written
+// "kid:HS256:<base64 secret>" or "kid:EdDSA:<base64 Ed25519 seed>". The
+// first key signs new tokens; all of them verify.
+func ParseKeys(spec string) ([]Key, error) {
+	var keys []Key
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, alg, encoded, ok := splitKey(entry)
+		if !ok {
+			return nil, fmt.Errorf("key %q is not kid:alg:base64", entry)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("key ID %s is listed twice", id)
+		}
+		seen[id] = true
+		material, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", id, err)
+		}
+
+		var key Key
+		switch alg {
+		case "HS256":
+			key, err = NewHMACKey(id, material)
+		case "EdDSA":
+			if len(material) != ed25519.SeedSize {
+				return nil, fmt.Errorf("EdDSA key %s must be a %d-byte seed", id, ed25519.SeedSize)
+			}
+			key, err = NewEd25519Key(id, ed25519.NewKeyFromSeed(material))
+		default:
+			return nil, fmt.Errorf("key %s has unsupported algorithm %q; use HS256 or EdDSA", id, alg)
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no keys given")
+	}
+	return keys, nil
+}
+
+func splitKey(entry string) (id, alg, encoded string, ok bool) {
+	parts := strings.SplitN(entry, ":", 3)
+	if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+		return "", "", "", false
+	}
+	return parts[0], parts[1], parts[2], true
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"slices"
+
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/models"
+)
+
+// Permission is something a role or an API key scope allows.
+type Permission string
+
+const (
+	// PermSchedulesRead allows reading schedules, their tasks and events.
+	// Without PermSchedulesAll it covers only schedules assigned to the
+	// caller.
+	PermSchedulesRead Permission = "schedules:read"
+	// PermSchedulesAll lifts that restriction from every schedule and visit
+	// permission, and allows reading recurring bookings.
+	PermSchedulesAll Permission = "schedules:all"
+	// PermSchedulesWrite allows booking, changing, cancelling and deleting
+	// schedules and recurring bookings.
+	PermSchedulesWrite Permission = "schedules:write"
+	// PermTasksWrite allows adding, editing, reordering and deleting the
+	// tasks of a schedule.
+	PermTasksWrite Permission = "tasks:write"
+	// PermVisitsRecord allows clocking in and out, recording task outcomes
+	// and syncing them from a device.
+	PermVisitsRecord    Permission = "visits:record"
+	PermCaregiversRead  Permission = "caregivers:read"
+	PermCaregiversWrite Permission = "caregivers:write"
+	PermClientsRead     Permission = "clients:read"
+	PermClientsWrite    Permission = "clients:write"
+	// PermCarePlansRead and PermCarePlansWrite cover care plans and the task
+	// template catalog.
+	PermCarePlansRead  Permission = "care_plans:read"
+	PermCarePlansWrite Permission = "care_plans:write"
+	// PermStoreReset allows wiping the data back to the seed.
+	PermStoreReset Permission = "store:reset"
+	// PermRecurrencesRoll allows expanding every recurring booking on
+	// demand, as the server does hourly.
+	PermRecurrencesRoll Permission = "recurrences:roll"
+	// PermAPIKeysManage allows creating, listing and revoking API keys.
+	PermAPIKeysManage Permission = "api_keys:manage"
+)
+
+// Scope is what an API key is allowed to do. Each scope grants a fixed set
+// of permissions.
+type Scope string
+
+const (
+	// ScopeSchedulesRead allows reading every schedule, but nothing else.
+	ScopeSchedulesRead Scope = "schedules:read"
+)
+
+// scopePermissions is what each API key scope is granted.
+var scopePermissions = map[Scope][]Permission{
+	ScopeSchedulesRead: {PermSchedulesRead, PermSchedulesAll},
+}
+
+// Scopes lists the scopes an API key can be given.
+func Scopes() []Scope {
+	return []Scope{ScopeSchedulesRead}
+}
+
+// ValidScope reports whether scope is one of Scopes.
+func ValidScope(scope string) bool {
+	_, ok := scopePermissions[Scope(scope)]
+	return ok
+}
+
+// rolePermissions is what each role is granted. Admins hold every
+// permission.
+var rolePermissions = map[models.Role][]Permission{
+	models.RoleCaregiver: {
+		PermSchedulesRead,
+		PermVisitsRecord,
+	},
+	models.RoleCoordinator: {
+		PermSchedulesRead,
+		PermSchedulesAll,
+		PermSchedulesWrite,
+		PermTasksWrite,
+		PermVisitsRecord,
+		PermCaregiversRead,
+		PermClientsRead,
+		PermClientsWrite,
+		PermCarePlansRead,
+		PermCarePlansWrite,
+	},
+	models.RoleAdmin: {
+		PermSchedulesRead,
+		PermSchedulesAll,
+		PermSchedulesWrite,
+		PermTasksWrite,
+		PermVisitsRecord,
+		PermCaregiversRead,
+		PermCaregiversWrite,
+		PermClientsRead,
+		PermClientsWrite,
+		PermCarePlansRead,
+		PermCarePlansWrite,
+		PermStoreReset,
+		PermRecurrencesRoll,
+		PermAPIKeysManage,
+	},
+}
+
+// Permissions lists what the role is granted.
+func Permissions(role models.Role) []Permission {
+	return rolePermissions[role]
+}
+
+// Can reports whether the principal's role, or for an API key its scopes,
+// grants the permission. A nil principal can do nothing.
+func Can(principal *models.Principal, permission Permission) bool {
+	if principal == nil {
+		return false
+	}
+	if principal.APIKeyID != "" {
+		for _, scope := range principal.Scopes {
+			if slices.Contains(scopePermissions[Scope(scope)], permission) {
+				return true
+			}
+		}
+		return false
+	}
+	return slices.Contains(rolePermissions[principal.Role], permission)
+}
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/models"
+)
+
+// Issuer is the iss claim of every access token.
+const Issuer = "mini-evv-logger"
+
+var (
+	// ErrTokenExpired is returned by Verify for a token past its exp.
+	ErrTokenExpired = errors.New("token expired")
+	// ErrTokenInvalid is returned by Verify for a token that is malformed,
+	// badly signed or signed with a key that is no longer in the ring.
+	ErrTokenInvalid = errors.New("token invalid")
+)
+
+// claims are the contents of an access token. The subject is the user ID.
+type claims struct {
+	jwt.RegisteredClaims
+	Username    string      `json:"username"`
+	Role        models.Role `json:"role"`
+	CaregiverID string      `json:"caregiverId,omitempty"`
+}
+
+// KeyRing signs access tokens with its first key and verifies them with any
+// of its keys, chosen by the token's kid. To rotate, put a new key first and
+// drop the old one once the tokens it signed have expired.
+type KeyRing struct {
+	keys []Key
+	ttl  time.Duration
+	now  func() time.Time
+}
+
+// NewKeyRing returns a ring issuing tokens that live for ttl.
+func NewKeyRing(keys []Key, ttl time.Duration) (*KeyRing, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("key ring needs at least one key")
+	}
+	return &KeyRing{keys: keys, ttl: ttl, now: time.Now}, nil
+}
+
+// TTL is how long issued tokens live.
+func (r *KeyRing) TTL() time.Duration {
+	return r.ttl
+}
+
+// Issue signs an access token for the principal.
+func (r *KeyRing) Issue(principal models.Principal) (string, error) {
+	key := r.keys[0]
+	now := r.now()
+	token := jwt.NewWithClaims(key.method, claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    Issuer,
+			Subject:   principal.UserID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(r.ttl)),
+		},
+		Username:    principal.Username,
+		Role:        principal.Role,
+		CaregiverID: principal.CaregiverID,
+	})
+	token.Header["kid"] = key.ID
+	signed, err := token.SignedString(key.signKey)
+	if err != nil {
+		return "", fmt.Errorf("sign access token with key %s: %w", key.ID, err)
+	}
+	return signed, nil
+}
+
+// Verify checks an access token and returns its principal. It fails with
+// ErrTokenExpired or ErrTokenInvalid.
+func (r *KeyRing) Verify(token string) (*models.Principal, error) {
+	var c claims
+	_, err := jwt.ParseWithClaims(token, &c, r.verifyKey,
+		jwt.WithIssuer(Issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(r.now),
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+	)
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return nil, ErrTokenExpired
+	}
+	if err != nil || c.Subject == "" {
+		return nil, ErrTokenInvalid
+	}
+	return &models.Principal{
+		UserID:      c.Subject,
+		Username:    c.Username,
+		Role:        c.Role,
+		CaregiverID: c.CaregiverID,
+	}, nil
+}
+
+// verifyKey picks the key named by the token's kid. The token's alg must be
+// the key's, so an HS256 token cannot be checked against an EdDSA public
+// key or the other way round.
+func (r *KeyRing) verifyKey(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	for _, key := range r.keys {
+		if key.ID == kid {
+			if token.Method.Alg() != key.method.Alg() {
+				return nil, fmt.Errorf("key %s does not sign %s tokens", kid, token.Method.Alg())
+			}
+			return key.verifyKey, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
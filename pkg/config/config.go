@@ -0,0 +1,164 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/auth"
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/models"
+)
+
+type GeofenceMode string
+
+const (
+	// GeofenceFlag accepts clock events outside the geofence and records an
+	// exception on the visit.
+	GeofenceFlag GeofenceMode = "flag"
+	// GeofenceReject refuses clock events outside the geofence.
+	GeofenceReject GeofenceMode = "reject"
+)
+
+// Config holds the runtime settings shared by the handlers.
+type Config struct {
+	// GeofenceRadiusMeters applies to schedules whose location does not set
+	// its own radius.
+	GeofenceRadiusMeters float64
+	GeofenceMode         GeofenceMode
+
+	// Sanity bounds for device timestamps on offline clock events.
+	MaxClockSkew      time.Duration
+	MaxOfflineAge     time.Duration
+	EarlyClockInGrace time.Duration
+
+	// RecurrenceWindowDays is how far ahead recurring bookings are expanded
+	// into schedules.
+	RecurrenceWindowDays int
+
+	// TimeZone is the IANA zone shifts are planned in when a request does
+	// not name one.
+	TimeZone string
+
+	// SigningKeys verify access tokens, and the first one signs them. In
+	// Demo mode FromEnv generates one when none are configured, so tokens
+	// do not survive a restart.
+	SigningKeys     []auth.Key
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	// AdminUsername and AdminPassword create the first admin account at
+	// startup when no user has that username yet.
+	AdminUsername string
+	AdminPassword string
+
+	// Demo relaxes the settings a production server must have, for local
+	// development and the public demo, and adds the demo accounts.
+	Demo bool
+}
+
+// Default returns the settings used when nothing is configured.
+func Default() Config {
+	return Config{
+		GeofenceRadiusMeters: 150,
+		GeofenceMode:         GeofenceFlag,
+		MaxClockSkew:         2 * time.Minute,
+		MaxOfflineAge:        72 * time.Hour,
+		EarlyClockInGrace:    time.Hour,
+		RecurrenceWindowDays: 28,
+		TimeZone:             models.DefaultTimeZone(),
+		AccessTokenTTL:       15 * time.Minute,
+		RefreshTokenTTL:      30 * 24 * time.Hour,
+		AdminUsername:        "admin",
+	}
+}
+
+// FromEnv returns Default overridden by any EVV_* environment variables.
+// Invalid security settings are an error, so the server does not start
+// without them; other invalid values are logged and ignored.
+func FromEnv() (Config, error) {
+	cfg := Default()
+	if v := os.Getenv("EVV_GEOFENCE_RADIUS_METERS"); v != "" {
+		radius, err := strconv.ParseFloat(v, 64)
+		if err != nil || radius <= 0 {
+			log.Printf("Ignoring invalid EVV_GEOFENCE_RADIUS_METERS %q", v)
+		} else {
+			cfg.GeofenceRadiusMeters = radius
+		}
+	}
+	if v := os.Getenv("EVV_GEOFENCE_MODE"); v != "" {
+		switch mode := GeofenceMode(v); mode {
+		case GeofenceFlag, GeofenceReject:
+			cfg.GeofenceMode = mode
+		default:
+			log.Printf("Ignoring invalid EVV_GEOFENCE_MODE %q", v)
+		}
+	}
+	durationFromEnv("EVV_MAX_CLOCK_SKEW", &cfg.MaxClockSkew)
+	durationFromEnv("EVV_MAX_OFFLINE_AGE", &cfg.MaxOfflineAge)
+	durationFromEnv("EVV_EARLY_CLOCK_IN_GRACE", &cfg.EarlyClockInGrace)
+	if v := os.Getenv("EVV_RECURRENCE_WINDOW_DAYS"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days < 1 {
+			log.Printf("Ignoring invalid EVV_RECURRENCE_WINDOW_DAYS %q", v)
+		} else {
+			cfg.RecurrenceWindowDays = days
+		}
+	}
+	if v := os.Getenv("EVV_TIME_ZONE"); v != "" {
+		if _, err := models.LoadZone(v); err != nil {
+			log.Printf("Ignoring invalid EVV_TIME_ZONE %q", v)
+		} else {
+			cfg.TimeZone = v
+		}
+	}
+	if v := os.Getenv("EVV_DEMO"); v != "" {
+		demo, err := strconv.ParseBool(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid EVV_DEMO %q: %w", v, err)
+		}
+		cfg.Demo = demo
+	}
+	if v := os.Getenv("EVV_JWT_KEYS"); v != "" {
+		keys, err := auth.ParseKeys(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid EVV_JWT_KEYS: %w", err)
+		}
+		cfg.SigningKeys = keys
+	}
+	if len(cfg.SigningKeys) == 0 {
+		if !cfg.Demo {
+			return cfg, errors.New("EVV_JWT_KEYS is not set; configure signing keys, or set EVV_DEMO=true to sign with a key generated at startup")
+		}
+		key, err := auth.GenerateKey("generated-" + uuid.NewString())
+		if err != nil {
+			return cfg, fmt.Errorf("generate signing key: %w", err)
+		}
+		log.Println("Demo mode without EVV_JWT_KEYS; signing with a generated key, so tokens will not survive a restart.")
+		cfg.SigningKeys = []auth.Key{key}
+	}
+	if v := os.Getenv("EVV_ADMIN_USERNAME"); v != "" {
+		cfg.AdminUsername = v
+	}
+	cfg.AdminPassword = os.Getenv("EVV_ADMIN_PASSWORD")
+	durationFromEnv("EVV_ACCESS_TOKEN_TTL", &cfg.AccessTokenTTL)
+	durationFromEnv("EVV_REFRESH_TOKEN_TTL", &cfg.RefreshTokenTTL)
+	return cfg, nil
+}
+
+func durationFromEnv(key string, dst *time.Duration) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		log.Printf("Ignoring invalid %s %q", key, v)
+		return
+	}
+	*dst = d
+}
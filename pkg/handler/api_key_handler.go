@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/auth"
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/models"
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/store"
+)
+
+type APIKeyHandler struct {
+	store store.Repository
+}
+
+func NewAPIKeyHandler(st store.Repository) *APIKeyHandler {
+	return &APIKeyHandler{store: st}
+}
+
+// GetAPIKeys handles listing API keys.
+// @Summary      Get all API keys
+// @Description  Lists every API key, revoked ones included, oldest first. The keys themselves are never shown again after creation.
+// @Tags         API Keys
+// @Produce      json
+// @Success      200  {array}   models.APIKey
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/api-keys [get]
+func (h *APIKeyHandler) GetAPIKeys(c *fiber.Ctx) error {
+	keys, err := h.store.ListAPIKeys()
+	if err != nil {
+		return err
+	}
+	return c.JSON(keys)
+}
+
+// CreateAPIKey handles issuing an API key.
+// @Summary      Create an API key
+// @Description  Issues a key for an unattended integration, limited to the given scopes. The only scope so far is "schedules:read" (read every schedule). The key is sent as the X-API-Key header and is returned only in this response; store it then.
+// @Tags         API Keys
+// @Accept       json
+// @Produce      json
+// @Param        key  body      models.CreateAPIKeyRequest  true  "API key"
+// @Success      201  {object}  models.CreatedAPIKey
+// @Failure      400  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/api-keys [post]
+func (h *APIKeyHandler) CreateAPIKey(c *fiber.Ctx) error {
+	var req models.CreateAPIKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+	if err := req.Validate(auth.ValidScope); err != nil {
+		return err
+	}
+
+	secret, prefix, hash, err := auth.NewAPIKey()
+	if err != nil {
+		return err
+	}
+	key := &models.APIKey{
+		ID:        uuid.NewString(),
+		Name:      strings.TrimSpace(req.Name),
+		Prefix:    prefix,
+		Hash:      hash,
+		Scopes:    slices.Compact(slices.Sorted(slices.Values(req.Scopes))),
+		CreatedBy: CurrentPrincipal(c).UserID,
+		CreatedAt: time.Now(),
+	}
+	if err := h.store.CreateAPIKey(key); err != nil {
+		return err
+	}
+	log.Printf("Created API key %s (%s) with scopes %v", key.ID, key.Name, key.Scopes)
+	return c.Status(fiber.StatusCreated).JSON(models.CreatedAPIKey{APIKey: *key, Key: secret})
+}
+
+// RevokeAPIKey handles revoking an API key.
+// @Summary      Revoke an API key
+// @Description  Stops the key from working at once. The key stays in the listing with its revocation time; revoking it again changes nothing.
+// @Tags         API Keys
+// @Produce      json
+// @Param        id   path      string  true  "API key ID"
+// @Success      200  {object}  models.APIKey
+// @Failure      404  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/api-keys/{id}/revoke [post]
+func (h *APIKeyHandler) RevokeAPIKey(c *fiber.Ctx) error {
+	key, err := h.store.RevokeAPIKey(c.Params("id"), time.Now())
+	if err != nil {
+		return err
+	}
+	log.Printf("Revoked API key %s (%s)", key.ID, key.Name)
+	return c.JSON(key)
+}
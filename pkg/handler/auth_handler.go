@@ -0,0 +1,332 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/auth"
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/config"
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/models"
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/store"
+)
+
+var (
+	errMissingToken        = &models.UnauthorizedError{Reason: "unauthorized", Message: "An Authorization: Bearer token or an X-API-Key header is required"}
+	errInvalidToken        = &models.UnauthorizedError{Reason: "invalid_token", Message: "Access token is invalid"}
+	errTokenExpired        = &models.UnauthorizedError{Reason: "token_expired", Message: "Access token has expired; use the refresh token to get a new one"}
+	errInvalidCredentials  = &models.UnauthorizedError{Reason: "invalid_credentials", Message: "Username or password is incorrect"}
+	errInvalidRefreshToken = &models.UnauthorizedError{Reason: "invalid_refresh_token", Message: "Refresh token is invalid, expired or revoked; log in again"}
+	errInvalidAPIKey       = &models.UnauthorizedError{Reason: "invalid_api_key", Message: "API key is invalid or revoked"}
+)
+
+const (
+	// principalKey is the fiber.Ctx local holding the caller's principal.
+	principalKey = "principal"
+	// APIKeyHeader carries an API key in place of a bearer token.
+	APIKeyHeader = "X-API-Key"
+	// apiKeyTouchInterval is how stale an API key's last use may get before
+	// it is written again, so a busy integration does not write on every
+	// request.
+	apiKeyTouchInterval = time.Minute
+)
+
+type AuthHandler struct {
+	store store.Repository
+	keys  *auth.KeyRing
+	cfg   config.Config
+}
+
+// NewAuthHandler signs tokens with cfg.SigningKeys, and fails when there are
+// none.
+func NewAuthHandler(st store.Repository, cfg config.Config) (*AuthHandler, error) {
+	ring, err := auth.NewKeyRing(cfg.SigningKeys, cfg.AccessTokenTTL)
+	if err != nil {
+		return nil, fmt.Errorf("signing keys: %w", err)
+	}
+	return &AuthHandler{store: st, keys: ring, cfg: cfg}, nil
+}
+
+// BootstrapUsers makes sure the server can be administered: in Demo mode it
+// adds the demo accounts, and with cfg.AdminPassword set it creates the admin
+// cfg.AdminUsername unless that username is taken. It fails when no admin
+// exists afterwards, so a server does not start that nobody can manage.
+func BootstrapUsers(repo store.Repository, cfg config.Config) error {
+	if cfg.Demo {
+		if err := store.SeedDemoUsers(repo); err != nil {
+			return fmt.Errorf("seed demo users: %w", err)
+		}
+	} else {
+		demo, err := store.UsersWithDemoPasswords(repo)
+		if err != nil {
+			return err
+		}
+		if len(demo) > 0 {
+			log.Printf("Users %s still have their public demo passwords; change or remove them", strings.Join(demo, ", "))
+		}
+	}
+	if cfg.AdminPassword != "" {
+		hash, err := auth.HashPassword(cfg.AdminPassword)
+		if err != nil {
+			return err
+		}
+		admin := &models.User{ID: uuid.NewString(), Username: cfg.AdminUsername, PasswordHash: hash, Role: models.RoleAdmin}
+		err = repo.CreateUser(admin)
+		if err != nil && !errors.Is(err, store.ErrExists) {
+			return fmt.Errorf("create admin %s: %w", admin.Username, err)
+		}
+		if err == nil {
+			log.Printf("Created admin %s from EVV_ADMIN_PASSWORD", admin.Username)
+		}
+	}
+	admins, err := repo.CountUsers(models.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	if admins == 0 {
+		return errors.New("no admin account; set EVV_ADMIN_PASSWORD (and optionally EVV_ADMIN_USERNAME) to create one, or EVV_DEMO=true for the demo accounts")
+	}
+	return nil
+}
+
+// Authenticate is middleware that admits requests carrying a valid API key
+// in the X-API-Key header or, failing that, a valid access token in the
+// Authorization header, and puts the caller's principal on the context for
+// CurrentPrincipal.
+func (h *AuthHandler) Authenticate(c *fiber.Ctx) error {
+	if key := c.Get(APIKeyHeader); key != "" {
+		principal, err := h.apiKeyPrincipal(strings.TrimSpace(key))
+		if err != nil {
+			return err
+		}
+		c.Locals(principalKey, principal)
+		return c.Next()
+	}
+
+	header := c.Get(fiber.HeaderAuthorization)
+	scheme, token, _ := strings.Cut(header, " ")
+	if header == "" || !strings.EqualFold(scheme, "Bearer") {
+		return errMissingToken
+	}
+	principal, err := h.keys.Verify(strings.TrimSpace(token))
+	if errors.Is(err, auth.ErrTokenExpired) {
+		return errTokenExpired
+	}
+	if err != nil {
+		return errInvalidToken
+	}
+	c.Locals(principalKey, principal)
+	return c.Next()
+}
+
+// apiKeyPrincipal looks up an active API key and records its use.
+func (h *AuthHandler) apiKeyPrincipal(secret string) (*models.Principal, error) {
+	hash := auth.HashAPIKey(secret)
+	if hash == "" {
+		return nil, errInvalidAPIKey
+	}
+	key, err := h.store.GetAPIKeyByHash(hash)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, errInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if key.RevokedAt != nil {
+		return nil, errInvalidAPIKey
+	}
+
+	now := time.Now()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		// A failed write only loses the timestamp, so the request goes on.
+		if err := h.store.TouchAPIKey(key.ID, now); err != nil {
+			log.Printf("Error recording use of API key %s: %v", key.ID, err)
+		}
+	}
+	return &models.Principal{APIKeyID: key.ID, Scopes: key.Scopes}, nil
+}
+
+// CurrentPrincipal returns the caller of an authenticated request, or nil
+// outside the routes behind Authenticate.
+func CurrentPrincipal(c *fiber.Ctx) *models.Principal {
+	principal, _ := c.Locals(principalKey).(*models.Principal)
+	return principal
+}
+
+// Login handles exchanging a username and password for tokens.
+// @Summary      Log in
+// @Description  Checks the username and password and returns a short-lived access token (a JWT to send as "Authorization: Bearer <token>") and a refresh token to get the next one with. Wrong credentials return 401 with code "invalid_credentials".
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        credentials body models.LoginRequest true "Credentials"
+// @Success      200  {object}  models.TokenResponse
+// @Failure      400  {object}  models.Problem
+// @Failure      401  {object}  models.Problem
+// @Router       /api/auth/login [post]
+func (h *AuthHandler) Login(c *fiber.Ctx) error {
+	var req models.LoginRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+
+	user, err := h.store.GetUserByUsername(strings.TrimSpace(req.Username))
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	var hash string
+	if user != nil {
+		hash = user.PasswordHash
+	}
+	// Checked even for an unknown user, so both failures take as long.
+	if !auth.CheckPassword(hash, req.Password) {
+		return errInvalidCredentials
+	}
+
+	log.Printf("User %s logged in", user.Username)
+	return h.issue(c, user, uuid.NewString())
+}
+
+// Refresh handles exchanging a refresh token for new tokens.
+// @Summary      Refresh tokens
+// @Description  Exchanges a refresh token for a new access token and a new refresh token; the old refresh token stops working. Presenting a refresh token that was already exchanged revokes every refresh token of the user, since it may have been stolen.
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        token body models.RefreshRequest true "Refresh token"
+// @Success      200  {object}  models.TokenResponse
+// @Failure      400  {object}  models.Problem
+// @Failure      401  {object}  models.Problem
+// @Router       /api/auth/refresh [post]
+func (h *AuthHandler) Refresh(c *fiber.Ctx) error {
+	var req models.RefreshRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+	token, err := h.refreshToken(req.RefreshToken)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if token.ReplacedBy != "" {
+		// A used token coming back means two parties hold it.
+		if err := h.store.RevokeUserRefreshTokens(token.UserID, now); err != nil {
+			return err
+		}
+		log.Printf("Refresh token %s of user %s was reused; revoked all of the user's refresh tokens", token.ID, token.UserID)
+		return errInvalidRefreshToken
+	}
+	if token.RevokedAt != nil || !now.Before(token.ExpiresAt) {
+		return errInvalidRefreshToken
+	}
+	next := uuid.NewString()
+	revoked, err := h.store.RevokeRefreshToken(token.ID, now, next)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		// Another request used it first.
+		return errInvalidRefreshToken
+	}
+
+	// The user is read again, so a changed role takes effect now.
+	user, err := h.store.GetUser(token.UserID)
+	if errors.Is(err, store.ErrNotFound) {
+		return errInvalidRefreshToken
+	}
+	if err != nil {
+		return err
+	}
+	return h.issue(c, user, next)
+}
+
+// Logout handles revoking a refresh token.
+// @Summary      Log out
+// @Description  Revokes the refresh token. Access tokens already issued stay valid until they expire.
+// @Tags         Auth
+// @Accept       json
+// @Param        token body models.RefreshRequest true "Refresh token"
+// @Success      204
+// @Failure      400  {object}  models.Problem
+// @Failure      401  {object}  models.Problem
+// @Router       /api/auth/logout [post]
+func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+	var req models.RefreshRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+	token, err := h.refreshToken(req.RefreshToken)
+	if err != nil {
+		return err
+	}
+	if _, err := h.store.RevokeRefreshToken(token.ID, time.Now(), ""); err != nil {
+		return err
+	}
+	log.Printf("User %s logged out", token.UserID)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetCurrentPrincipal handles fetching the caller's identity.
+// @Summary      Get the current user
+// @Description  Returns the principal the access token or API key identifies
+// @Tags         Auth
+// @Produce      json
+// @Success      200  {object}  models.Principal
+// @Failure      401  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/auth/me [get]
+func (h *AuthHandler) GetCurrentPrincipal(c *fiber.Ctx) error {
+	return c.JSON(CurrentPrincipal(c))
+}
+
+// refreshToken looks up the stored record of a refresh token.
+func (h *AuthHandler) refreshToken(token string) (*models.RefreshToken, error) {
+	if token == "" {
+		return nil, models.InvalidField("refreshToken", "required", "Refresh token is required")
+	}
+	stored, err := h.store.GetRefreshToken(auth.HashRefreshToken(token))
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, errInvalidRefreshToken
+	}
+	return stored, err
+}
+
+// issue responds with a new access token for the user, and a refresh token
+// stored under refreshID.
+func (h *AuthHandler) issue(c *fiber.Ctx, user *models.User, refreshID string) error {
+	principal := user.Principal()
+	accessToken, err := h.keys.Issue(principal)
+	if err != nil {
+		return err
+	}
+	refreshToken, hash, err := auth.NewRefreshToken()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	stored := &models.RefreshToken{
+		ID:        refreshID,
+		UserID:    user.ID,
+		Hash:      hash,
+		CreatedAt: now,
+		ExpiresAt: now.Add(h.cfg.RefreshTokenTTL),
+	}
+	if err := h.store.CreateRefreshToken(stored); err != nil {
+		return err
+	}
+	return c.JSON(models.TokenResponse{
+		AccessToken:      accessToken,
+		TokenType:        "Bearer",
+		ExpiresIn:        int(h.keys.TTL().Seconds()),
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: stored.ExpiresAt,
+		Principal:        principal,
+	})
+}
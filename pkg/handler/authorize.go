@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/auth"
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/models"
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/store"
+)
+
+// Require is middleware that admits callers whose role grants the
+// permission, and refuses the rest with a 403 naming it.
+func Require(permission auth.Permission) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !auth.Can(CurrentPrincipal(c), permission) {
+			return forbidden(permission)
+		}
+		return c.Next()
+	}
+}
+
+// AssignedSchedule is middleware that admits callers who may act on every
+// schedule, and caregivers assigned the schedule in the :id parameter.
+func AssignedSchedule(repo store.Repository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := checkAssigned(repo, CurrentPrincipal(c), c.Params("id")); err != nil {
+			return err
+		}
+		return c.Next()
+	}
+}
+
+// AssignedTask is AssignedSchedule for the schedule owning the task in the
+// :taskId parameter.
+func AssignedTask(repo store.Repository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		principal := CurrentPrincipal(c)
+		if !auth.Can(principal, auth.PermSchedulesAll) {
+			taskID, err := strconv.Atoi(c.Params("taskId"))
+			if err != nil {
+				return errInvalidTaskID
+			}
+			scheduleID, err := repo.TaskSchedule(taskID)
+			if err != nil {
+				return err
+			}
+			if err := checkAssigned(repo, principal, scheduleID); err != nil {
+				return err
+			}
+		}
+		return c.Next()
+	}
+}
+
+// OwnCaregiver is middleware that admits callers who may act on every
+// schedule, and caregivers whose own record is in the :id parameter.
+func OwnCaregiver(c *fiber.Ctx) error {
+	principal := CurrentPrincipal(c)
+	if !auth.Can(principal, auth.PermSchedulesAll) && !isCaregiver(principal, c.Params("id")) {
+		return forbidden(auth.PermSchedulesAll)
+	}
+	return c.Next()
+}
+
+// checkAssigned fails unless the principal may act on every schedule or is
+// the caregiver assigned to this one.
+func checkAssigned(repo store.Repository, principal *models.Principal, scheduleID string) error {
+	if auth.Can(principal, auth.PermSchedulesAll) {
+		return nil
+	}
+	schedule, err := repo.GetSchedule(scheduleID)
+	if err != nil {
+		return err
+	}
+	if !isCaregiver(principal, schedule.CaregiverID) {
+		return forbidden(auth.PermSchedulesAll)
+	}
+	return nil
+}
+
+// caregiverScope returns the caregiver a listing must be narrowed to: the
+// caller's own record unless the caller may see every schedule, in which
+// case requested (possibly empty) is used as is.
+func caregiverScope(principal *models.Principal, requested string) (string, error) {
+	if auth.Can(principal, auth.PermSchedulesAll) {
+		return requested, nil
+	}
+	if principal == nil || principal.CaregiverID == "" || (requested != "" && requested != principal.CaregiverID) {
+		return "", forbidden(auth.PermSchedulesAll)
+	}
+	return principal.CaregiverID, nil
+}
+
+// isCaregiver reports whether the principal is the caregiver with this ID.
+func isCaregiver(principal *models.Principal, caregiverID string) bool {
+	return principal != nil && principal.CaregiverID != "" && principal.CaregiverID == caregiverID
+}
+
+func forbidden(permission auth.Permission) error {
+	return &models.ForbiddenError{Permission: string(permission)}
+}
@@ -0,0 +1,261 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/models"
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/store"
+)
+
+// templateCode is the shape of task template codes, e.g. "MED" or "ROM_EXERCISE".
+var templateCode = regexp.MustCompile(`^[A-Z0-9_-]{1,32}$`)
+
+type CarePlanHandler struct {
+	store store.Repository
+}
+
+func NewCarePlanHandler(st store.Repository) *CarePlanHandler {
+	return &CarePlanHandler{store: st}
+}
+
+// GetTaskTemplates handles fetching the task catalog.
+// @Summary      Get all task templates
+// @Description  Fetches the catalog of reusable tasks, ordered by code
+// @Tags         Care Plans
+// @Accept       json
+// @Produce      json
+// @Success      200  {array}   models.TaskTemplate
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/task-templates [get]
+func (h *CarePlanHandler) GetTaskTemplates(c *fiber.Ctx) error {
+	templates, err := h.store.ListTaskTemplates()
+	if err != nil {
+		return err
+	}
+	return c.JSON(templates)
+}
+
+// GetTaskTemplate handles fetching a single task template.
+// @Summary      Get task template by code
+// @Description  Fetches a task template using its code
+// @Tags         Care Plans
+// @Accept       json
+// @Produce      json
+// @Param        code   path      string  true  "Template code"
+// @Success      200  {object}  models.TaskTemplate
+// @Failure      404  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/task-templates/{code} [get]
+func (h *CarePlanHandler) GetTaskTemplate(c *fiber.Ctx) error {
+	code := c.Params("code")
+	template, err := h.store.GetTaskTemplate(code)
+	if err != nil {
+		return err
+	}
+	return c.JSON(template)
+}
+
+// CreateTaskTemplate handles adding a task to the catalog.
+// @Summary      Create a task template
+// @Description  Adds a reusable task. The code (upper-case letters, digits, "_" and "-") identifies it and cannot be changed later. Returns 409 if the code is taken.
+// @Tags         Care Plans
+// @Accept       json
+// @Produce      json
+// @Param        template body models.TaskTemplateRequest true "Task template"
+// @Success      201  {object}  models.TaskTemplate
+// @Failure      400  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/task-templates [post]
+func (h *CarePlanHandler) CreateTaskTemplate(c *fiber.Ctx) error {
+	var req models.TaskTemplateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+	if !templateCode.MatchString(req.Code) {
+		return models.InvalidField("code", "invalid", "Code must be 1-32 upper-case letters, digits, _ or -")
+	}
+	if err := validateTaskTemplate(req); err != nil {
+		return err
+	}
+
+	template := taskTemplateFrom(req.Code, req)
+	if err := h.store.CreateTaskTemplate(template); err != nil {
+		return err
+	}
+	log.Printf("Created task template %s (%s)", template.Code, template.Name)
+	return c.Status(fiber.StatusCreated).JSON(template)
+}
+
+// UpdateTaskTemplate handles replacing a task template.
+// @Summary      Update a task template
+// @Description  Replaces a template's name, description, category and required flag. Schedules keep the copies they already have; care plans pick up the change for visits created from now on.
+// @Tags         Care Plans
+// @Accept       json
+// @Produce      json
+// @Param        code   path      string  true  "Template code"
+// @Param        template body models.TaskTemplateRequest true "Task template"
+// @Success      200  {object}  models.TaskTemplate
+// @Failure      400  {object}  models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/task-templates/{code} [put]
+func (h *CarePlanHandler) UpdateTaskTemplate(c *fiber.Ctx) error {
+	// The code is stored, so it must not alias Fiber's reused request buffer.
+	code := utils.CopyString(c.Params("code"))
+	var req models.TaskTemplateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+	if req.Code != "" && req.Code != code {
+		return models.InvalidField("code", "conflict", "Code cannot be changed")
+	}
+	if err := validateTaskTemplate(req); err != nil {
+		return err
+	}
+
+	template := taskTemplateFrom(code, req)
+	if err := h.store.UpdateTaskTemplate(template); err != nil {
+		return err
+	}
+	log.Printf("Updated task template %s", code)
+	return c.JSON(template)
+}
+
+// DeleteTaskTemplate handles removing a task from the catalog.
+// @Summary      Delete a task template
+// @Description  Removes a task template. Returns 409 while care plans still list it.
+// @Tags         Care Plans
+// @Param        code   path      string  true  "Template code"
+// @Success      204
+// @Failure      404  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/task-templates/{code} [delete]
+func (h *CarePlanHandler) DeleteTaskTemplate(c *fiber.Ctx) error {
+	code := c.Params("code")
+	if err := h.store.DeleteTaskTemplate(code); err != nil {
+		return err
+	}
+	log.Printf("Deleted task template %s", code)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetCarePlan handles fetching a client's care plan.
+// @Summary      Get a client's care plan
+// @Description  Fetches the templates every new visit to the client starts with. A client without a plan has an empty one.
+// @Tags         Care Plans
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Client ID"
+// @Success      200  {object}  models.CarePlan
+// @Failure      404  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/clients/{id}/care-plan [get]
+func (h *CarePlanHandler) GetCarePlan(c *fiber.Ctx) error {
+	id := c.Params("id")
+	plan, err := h.store.GetCarePlan(id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(plan)
+}
+
+// SetCarePlan handles replacing a client's care plan.
+// @Summary      Set a client's care plan
+// @Description  Replaces the list of templates, in order, that are copied onto every schedule created or generated for the client from now on. Existing schedules keep their tasks.
+// @Tags         Care Plans
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Client ID"
+// @Param        plan body models.CarePlanRequest true "Care plan"
+// @Success      200  {object}  models.CarePlan
+// @Failure      400  {object}  models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/clients/{id}/care-plan [put]
+func (h *CarePlanHandler) SetCarePlan(c *fiber.Ctx) error {
+	id := utils.CopyString(c.Params("id"))
+	var req models.CarePlanRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+	if _, err := h.store.GetClient(id); err != nil {
+		return err
+	}
+
+	plan := &models.CarePlan{ClientID: id, Tasks: make([]models.CarePlanTask, 0, len(req.Tasks))}
+	for i, task := range req.Tasks {
+		_, err := h.store.GetTaskTemplate(task.TemplateCode)
+		if errors.Is(err, store.ErrNotFound) {
+			return models.InvalidField(fmt.Sprintf("tasks[%d].templateCode", i), "invalid", fmt.Sprintf("Task template %q does not exist", task.TemplateCode))
+		}
+		if err != nil {
+			return err
+		}
+		plan.Tasks = append(plan.Tasks, task)
+	}
+	if err := h.store.SetCarePlan(plan); err != nil {
+		return err
+	}
+	log.Printf("Set care plan of client %s: %d tasks", id, len(plan.Tasks))
+	return c.JSON(plan)
+}
+
+// carePlanTasks returns the tasks a new schedule for the client starts
+// with, copied from the client's care plan.
+func carePlanTasks(st store.Repository, clientID string) ([]models.Task, error) {
+	plan, err := st.GetCarePlan(clientID)
+	if err != nil {
+		return nil, err
+	}
+	tasks := make([]models.Task, 0, len(plan.Tasks))
+	for _, item := range plan.Tasks {
+		template, err := st.GetTaskTemplate(item.TemplateCode)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, item.Task(template))
+	}
+	return tasks, nil
+}
+
+// validateTaskTemplate returns a *models.ValidationError for the first
+// problem with req, or nil if it is valid.
+func validateTaskTemplate(req models.TaskTemplateRequest) error {
+	if strings.TrimSpace(req.Name) == "" {
+		return models.InvalidField("name", "required", "Task template name is required")
+	}
+	return nil
+}
+
+func taskTemplateFrom(code string, req models.TaskTemplateRequest) *models.TaskTemplate {
+	return &models.TaskTemplate{
+		Code:        code,
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+		Category:    strings.TrimSpace(req.Category),
+		Required:    req.Required,
+	}
+}
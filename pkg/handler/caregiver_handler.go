@@ -0,0 +1,217 @@
+package handler
+
+import (
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/config"
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/models"
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/store"
+)
+
+type CaregiverHandler struct {
+	store store.Repository
+	cfg   config.Config
+}
+
+func NewCaregiverHandler(st store.Repository, cfg config.Config) *CaregiverHandler {
+	return &CaregiverHandler{store: st, cfg: cfg}
+}
+
+// GetCaregivers handles fetching all caregivers.
+// @Summary      Get all caregivers
+// @Description  Fetches every caregiver, ordered by ID
+// @Tags         Caregivers
+// @Accept       json
+// @Produce      json
+// @Success      200  {array}   models.Caregiver
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/caregivers [get]
+func (h *CaregiverHandler) GetCaregivers(c *fiber.Ctx) error {
+	caregivers, err := h.store.ListCaregivers()
+	if err != nil {
+		return err
+	}
+	return c.JSON(caregivers)
+}
+
+// GetCaregiverByID handles fetching a single caregiver.
+// @Summary      Get caregiver by ID
+// @Description  Fetches a single caregiver using their ID
+// @Tags         Caregivers
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Caregiver ID"
+// @Success      200  {object}  models.Caregiver
+// @Failure      404  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/caregivers/{id} [get]
+func (h *CaregiverHandler) GetCaregiverByID(c *fiber.Ctx) error {
+	id := c.Params("id")
+	caregiver, err := h.store.GetCaregiver(id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(caregiver)
+}
+
+// CreateCaregiver handles adding a caregiver.
+// @Summary      Create a caregiver
+// @Description  Adds a caregiver with a generated ID
+// @Tags         Caregivers
+// @Accept       json
+// @Produce      json
+// @Param        caregiver body models.CaregiverRequest true "Caregiver"
+// @Success      201  {object}  models.Caregiver
+// @Failure      400  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/caregivers [post]
+func (h *CaregiverHandler) CreateCaregiver(c *fiber.Ctx) error {
+	var req models.CaregiverRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		return models.InvalidField("name", "required", "Caregiver name is required")
+	}
+	if req.TimeZone != "" {
+		if _, err := models.LoadZone(req.TimeZone); err != nil {
+			return models.InvalidField("timeZone", "invalid", "Invalid timeZone: "+err.Error())
+		}
+	}
+
+	caregiver := caregiverFrom(uuid.NewString(), req)
+	if err := h.store.CreateCaregiver(caregiver); err != nil {
+		return err
+	}
+	log.Printf("Created caregiver %s (%s)", caregiver.ID, caregiver.Name)
+	return c.Status(fiber.StatusCreated).JSON(caregiver)
+}
+
+// UpdateCaregiver handles replacing a caregiver's details.
+// @Summary      Update a caregiver
+// @Description  Replaces the name, credentials, phone and time zone of a caregiver
+// @Tags         Caregivers
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Caregiver ID"
+// @Param        caregiver body models.CaregiverRequest true "Caregiver"
+// @Success      200  {object}  models.Caregiver
+// @Failure      400  {object}  models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/caregivers/{id} [put]
+func (h *CaregiverHandler) UpdateCaregiver(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var req models.CaregiverRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		return models.InvalidField("name", "required", "Caregiver name is required")
+	}
+	if req.TimeZone != "" {
+		if _, err := models.LoadZone(req.TimeZone); err != nil {
+			return models.InvalidField("timeZone", "invalid", "Invalid timeZone: "+err.Error())
+		}
+	}
+
+	caregiver := caregiverFrom(id, req)
+	if err := h.store.UpdateCaregiver(caregiver); err != nil {
+		return err
+	}
+	return c.JSON(caregiver)
+}
+
+// DeleteCaregiver handles removing a caregiver.
+// @Summary      Delete a caregiver
+// @Description  Removes a caregiver. Returns 409 while schedules are still assigned to them; reassign those first.
+// @Tags         Caregivers
+// @Param        id   path      string  true  "Caregiver ID"
+// @Success      204
+// @Failure      404  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/caregivers/{id} [delete]
+func (h *CaregiverHandler) DeleteCaregiver(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if err := h.store.DeleteCaregiver(id); err != nil {
+		return err
+	}
+	log.Printf("Deleted caregiver %s", id)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetCaregiverSchedules handles fetching the schedules assigned to a caregiver.
+// @Summary      Get a caregiver's schedules
+// @Description  Fetches the schedules assigned to a caregiver, sorted chronologically. "from" and "to" (YYYY-MM-DD, inclusive) limit the shifts returned to those starting on those days, read in the "tz" zone, else the caregiver's, else the agency's.
+// @Tags         Caregivers
+// @Accept       json
+// @Produce      json
+// @Param        id    path      string  true   "Caregiver ID"
+// @Param        from  query     string  false  "First shift date (YYYY-MM-DD)"
+// @Param        to    query     string  false  "Last shift date (YYYY-MM-DD)"
+// @Param        tz    query     string  false  "IANA time zone the dates are read in"
+// @Success      200  {array}   models.Schedule
+// @Failure      400  {object}  models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/caregivers/{id}/schedules [get]
+func (h *CaregiverHandler) GetCaregiverSchedules(c *fiber.Ctx) error {
+	id := c.Params("id")
+	caregiver, err := h.store.GetCaregiver(id)
+	if err != nil {
+		return err
+	}
+	loc, err := viewZone(c, caregiver, h.cfg.TimeZone)
+	if err != nil {
+		return err
+	}
+	start, end, err := dayRange(c.Query("from"), c.Query("to"), loc)
+	if err != nil {
+		return err
+	}
+
+	schedules, err := h.store.ListSchedules()
+	if err != nil {
+		return err
+	}
+	assigned := make([]*models.Schedule, 0)
+	for _, schedule := range schedules {
+		if schedule.CaregiverID == id {
+			assigned = append(assigned, schedule)
+		}
+	}
+	assigned = startingWithin(assigned, start, end)
+	sortByShift(assigned)
+	return c.JSON(assigned)
+}
+
+func caregiverFrom(id string, req models.CaregiverRequest) *models.Caregiver {
+	credentials := req.Credentials
+	if credentials == nil {
+		credentials = []string{}
+	}
+	return &models.Caregiver{
+		ID:          id,
+		Name:        strings.TrimSpace(req.Name),
+		Credentials: credentials,
+		Phone:       req.Phone,
+		TimeZone:    req.TimeZone,
+	}
+}
@@ -0,0 +1,228 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/models"
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/store"
+)
+
+type ClientHandler struct {
+	store store.Repository
+}
+
+func NewClientHandler(st store.Repository) *ClientHandler {
+	return &ClientHandler{store: st}
+}
+
+// GetClients handles fetching all clients.
+// @Summary      Get all clients
+// @Description  Fetches every care recipient, ordered by ID
+// @Tags         Clients
+// @Accept       json
+// @Produce      json
+// @Success      200  {array}   models.Client
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/clients [get]
+func (h *ClientHandler) GetClients(c *fiber.Ctx) error {
+	clients, err := h.store.ListClients()
+	if err != nil {
+		return err
+	}
+	return c.JSON(clients)
+}
+
+// GetClientByID handles fetching a single client.
+// @Summary      Get client by ID
+// @Description  Fetches a single care recipient using their ID
+// @Tags         Clients
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Client ID"
+// @Success      200  {object}  models.Client
+// @Failure      404  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/clients/{id} [get]
+func (h *ClientHandler) GetClientByID(c *fiber.Ctx) error {
+	id := c.Params("id")
+	client, err := h.store.GetClient(id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(client)
+}
+
+// CreateClient handles adding a client.
+// @Summary      Create a client
+// @Description  Adds a care recipient with a generated ID
+// @Tags         Clients
+// @Accept       json
+// @Produce      json
+// @Param        client body models.ClientRequest true "Client"
+// @Success      201  {object}  models.Client
+// @Failure      400  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/clients [post]
+func (h *ClientHandler) CreateClient(c *fiber.Ctx) error {
+	var req models.ClientRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+	if err := validateClient(req); err != nil {
+		return err
+	}
+
+	client := clientFrom(uuid.NewString(), req)
+	if err := h.store.CreateClient(client); err != nil {
+		return err
+	}
+	log.Printf("Created client %s (%s)", client.ID, client.Name)
+	return c.Status(fiber.StatusCreated).JSON(client)
+}
+
+// UpdateClient handles replacing a client's details.
+// @Summary      Update a client
+// @Description  Replaces a care recipient's details. The new name, contact details and location are copied onto the client's upcoming visits: those still scheduled, never clocked into and starting in the future. Visits under way or over keep the location their clock events were checked against.
+// @Tags         Clients
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Client ID"
+// @Param        client body models.ClientRequest true "Client"
+// @Success      200  {object}  models.Client
+// @Failure      400  {object}  models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/clients/{id} [put]
+func (h *ClientHandler) UpdateClient(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var req models.ClientRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+	if err := validateClient(req); err != nil {
+		return err
+	}
+
+	client := clientFrom(id, req)
+	if err := h.store.UpdateClient(client); err != nil {
+		return err
+	}
+	if err := h.refreshUpcoming(client); err != nil {
+		return err
+	}
+	log.Printf("Updated client %s", id)
+	return c.JSON(client)
+}
+
+// refreshUpcoming copies the client's details onto its upcoming visits, so
+// an address fix reaches them, and leaves the rest as they were planned.
+func (h *ClientHandler) refreshUpcoming(client *models.Client) error {
+	schedules, err := h.store.ListSchedules()
+	if err != nil {
+		return err
+	}
+	for _, schedule := range schedules {
+		if schedule.ClientID != client.ID {
+			continue
+		}
+		if err := h.refreshSchedule(schedule.ID, client); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshSchedule copies the client's details onto one schedule if it is
+// still upcoming, under the schedule's lock so that a clock-in cannot slip
+// in between the check and the write.
+func (h *ClientHandler) refreshSchedule(id string, client *models.Client) error {
+	unlock := h.store.LockSchedule(id)
+	defer unlock()
+	schedule, err := h.store.GetSchedule(id)
+	if errors.Is(err, store.ErrNotFound) {
+		// Deleted since it was listed.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if schedule.ClientID != client.ID || !isUpcoming(schedule, time.Now()) {
+		return nil
+	}
+	schedule.SetClient(client)
+	return h.store.UpdateSchedule(schedule)
+}
+
+// isUpcoming reports whether a visit is still only planned: scheduled,
+// never clocked into and starting after now.
+func isUpcoming(schedule *models.Schedule, now time.Time) bool {
+	return schedule.Status == models.StatusScheduled && !schedule.HasClockData() && schedule.ShiftStart.After(now)
+}
+
+// DeleteClient handles removing a client.
+// @Summary      Delete a client
+// @Description  Removes a care recipient. Returns 409 while schedules still reference them.
+// @Tags         Clients
+// @Param        id   path      string  true  "Client ID"
+// @Success      204
+// @Failure      404  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/clients/{id} [delete]
+func (h *ClientHandler) DeleteClient(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if err := h.store.DeleteClient(id); err != nil {
+		return err
+	}
+	log.Printf("Deleted client %s", id)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// validateClient returns a *models.ValidationError for the first problem
+// with req, or nil if it is valid.
+func validateClient(req models.ClientRequest) error {
+	coordinates := req.Location.Coordinates
+	switch {
+	case strings.TrimSpace(req.Name) == "":
+		return models.InvalidField("name", "required", "Client name is required")
+	case coordinates.Latitude < -90 || coordinates.Latitude > 90:
+		return models.InvalidField("location.coordinates.latitude", "out_of_range", "Latitude must be between -90 and 90")
+	case coordinates.Longitude < -180 || coordinates.Longitude > 180:
+		return models.InvalidField("location.coordinates.longitude", "out_of_range", "Longitude must be between -180 and 180")
+	case req.Location.GeofenceRadiusMeters < 0:
+		return models.InvalidField("location.geofenceRadiusMeters", "out_of_range", "Geofence radius cannot be negative")
+	}
+	return nil
+}
+
+func clientFrom(id string, req models.ClientRequest) *models.Client {
+	contacts := req.EmergencyContacts
+	if contacts == nil {
+		contacts = []models.EmergencyContact{}
+	}
+	return &models.Client{
+		ID:                id,
+		Name:              strings.TrimSpace(req.Name),
+		Contact:           req.Contact,
+		Location:          req.Location,
+		EmergencyContacts: contacts,
+		MedicaidID:        req.MedicaidID,
+		CareNotes:         req.CareNotes,
+	}
+}
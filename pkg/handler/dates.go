@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/models"
+)
+
+// viewZone resolves the zone that "today" and from/to dates are read in:
+// the tz query parameter, else the caregiver's own zone (when the listing is
+// for one), else the agency's configured zone.
+func viewZone(c *fiber.Ctx, caregiver *models.Caregiver, agencyZone string) (*time.Location, error) {
+	zone := agencyZone
+	if caregiver != nil && caregiver.TimeZone != "" {
+		zone = caregiver.TimeZone
+	}
+	if tz := c.Query("tz"); tz != "" {
+		zone = tz
+	}
+	loc, err := models.LoadZone(zone)
+	if err != nil {
+		return nil, badRequest("Invalid tz: " + err.Error())
+	}
+	return loc, nil
+}
+
+// dayRange returns the instants bounding the calendar days from and to
+// (YYYY-MM-DD, inclusive) in loc: midnight starting from, and midnight
+// after to. An empty date leaves that end open (a zero time).
+func dayRange(from, to string, loc *time.Location) (start, end time.Time, err error) {
+	if from != "" {
+		if start, err = time.ParseInLocation("2006-01-02", from, loc); err != nil {
+			return time.Time{}, time.Time{}, badRequest("Dates must be formatted as YYYY-MM-DD")
+		}
+	}
+	if to != "" {
+		last, err := time.ParseInLocation("2006-01-02", to, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, badRequest("Dates must be formatted as YYYY-MM-DD")
+		}
+		end = last.AddDate(0, 0, 1)
+	}
+	if !start.IsZero() && !end.IsZero() && !end.After(start) {
+		return time.Time{}, time.Time{}, badRequest("from must not be after to")
+	}
+	return start, end, nil
+}
+
+// todayIn returns the date it is now in loc, as YYYY-MM-DD.
+func todayIn(loc *time.Location) string {
+	return time.Now().In(loc).Format("2006-01-02")
+}
+
+// startingWithin returns the schedules whose shift starts in [start, end);
+// a zero bound is open.
+func startingWithin(schedules []*models.Schedule, start, end time.Time) []*models.Schedule {
+	within := make([]*models.Schedule, 0)
+	for _, schedule := range schedules {
+		if !start.IsZero() && schedule.ShiftStart.Before(start) {
+			continue
+		}
+		if !end.IsZero() && !schedule.ShiftStart.Before(end) {
+			continue
+		}
+		within = append(within, schedule)
+	}
+	return within
+}
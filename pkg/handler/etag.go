@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/models"
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/store"
+)
+
+// Schedules and tasks are served with their version as a strong ETag.
+// Mutations honour If-Match: a schedule endpoint compares it with the
+// schedule's ETag, a task endpoint with the task's, and a mismatch fails
+// with 412 before anything changes. GET requests honour If-None-Match with
+// a 304, so clients can poll cheaply.
+
+// etag renders a version as an entity tag.
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// setETag sends the ETag for version with the response.
+func setETag(c *fiber.Ctx, version int64) {
+	c.Set(fiber.HeaderETag, etag(version))
+}
+
+// notModified sets the ETag for version and reports whether the request's
+// If-None-Match already lists it, in which case the handler answers 304.
+// Weak tags match too, as RFC 9110 asks for If-None-Match.
+func notModified(c *fiber.Ctx, version int64) bool {
+	setETag(c, version)
+	header := c.Get(fiber.HeaderIfNoneMatch)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch fails with a PreconditionFailedError unless the If-Match
+// header ifMatch is empty, "*" or lists the tag for version.
+func checkIfMatch(ifMatch, resource, id string, version int64) error {
+	if ifMatch == "" {
+		return nil
+	}
+	for _, tag := range strings.Split(ifMatch, ",") {
+		if tag = strings.TrimSpace(tag); tag == "*" || tag == etag(version) {
+			return nil
+		}
+	}
+	return &models.PreconditionFailedError{Resource: resource, ID: id, Version: version}
+}
+
+// lockSchedule takes the schedule lock for a read-check-write sequence and,
+// while holding it, checks the request's If-Match against the schedule. The
+// caller defers unlock when err is nil.
+func lockSchedule(c *fiber.Ctx, repo store.Repository, id string) (unlock func(), err error) {
+	unlock = repo.LockSchedule(id)
+	if ifMatch := c.Get(fiber.HeaderIfMatch); ifMatch != "" {
+		schedule, err := repo.GetSchedule(id)
+		if err == nil {
+			err = checkIfMatch(ifMatch, "schedule", id, schedule.Version)
+		}
+		if err != nil {
+			unlock()
+			return nil, err
+		}
+	}
+	return unlock, nil
+}
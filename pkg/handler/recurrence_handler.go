@@ -0,0 +1,623 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/config"
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/models"
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/store"
+)
+
+var errInvalidScope = &models.BadRequestError{Reason: "invalid_scope", Message: "scope must be this or following"}
+
+type RecurrenceHandler struct {
+	store     store.Repository
+	schedules *ScheduleHandler
+	cfg       config.Config
+}
+
+func NewRecurrenceHandler(st store.Repository, schedules *ScheduleHandler, cfg config.Config) *RecurrenceHandler {
+	return &RecurrenceHandler{store: st, schedules: schedules, cfg: cfg}
+}
+
+// ExpandRecurrences generates the missing schedules of every recurrence up
+// to the end of the window. The server runs it hourly, since recurrences in
+// different zones reach their next day at different times; running it again
+// or alongside requests that change a series is harmless.
+func ExpandRecurrences(repo store.Repository, cfg config.Config) error {
+	recurrences, err := repo.ListRecurrences()
+	if err != nil {
+		return err
+	}
+	for _, recurrence := range recurrences {
+		if err := expandRecurrence(repo, cfg, recurrence.ID); err != nil {
+			return fmt.Errorf("recurrence %s: %w", recurrence.ID, err)
+		}
+	}
+	return nil
+}
+
+func expandRecurrence(repo store.Repository, cfg config.Config, id string) error {
+	unlock := repo.LockRecurrence(id)
+	defer unlock()
+	recurrence, err := repo.GetRecurrence(id)
+	if errors.Is(err, store.ErrNotFound) {
+		// Deleted since it was listed.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return generate(repo, cfg, recurrence)
+}
+
+// RollRecurrences handles expanding every recurrence on demand.
+// @Summary      Expand recurrences
+// @Description  Generates the missing schedules of every recurring booking up to the end of the window, as the server does hourly. For deployments that cannot run the hourly job, such as serverless ones. Schedules that exist or were deleted are left alone.
+// @Tags         Recurrences
+// @Success      204
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/recurrences/roll [post]
+func (h *RecurrenceHandler) RollRecurrences(c *fiber.Ctx) error {
+	if err := ExpandRecurrences(h.store, h.cfg); err != nil {
+		return err
+	}
+	log.Printf("Expanded recurrences")
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetRecurrences handles fetching all recurring bookings.
+// @Summary      Get all recurrences
+// @Description  Fetches every recurring booking
+// @Tags         Recurrences
+// @Accept       json
+// @Produce      json
+// @Success      200  {array}   models.Recurrence
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/recurrences [get]
+func (h *RecurrenceHandler) GetRecurrences(c *fiber.Ctx) error {
+	recurrences, err := h.store.ListRecurrences()
+	if err != nil {
+		return err
+	}
+	return c.JSON(recurrences)
+}
+
+// GetRecurrenceByID handles fetching a single recurring booking.
+// @Summary      Get recurrence by ID
+// @Description  Fetches a single recurring booking using its ID
+// @Tags         Recurrences
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Recurrence ID"
+// @Success      200  {object}  models.Recurrence
+// @Failure      404  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/recurrences/{id} [get]
+func (h *RecurrenceHandler) GetRecurrenceByID(c *fiber.Ctx) error {
+	id := c.Params("id")
+	recurrence, err := h.store.GetRecurrence(id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(recurrence)
+}
+
+// CreateRecurrence handles booking recurring care.
+// @Summary      Create a recurrence
+// @Description  Books recurring visits from an RFC 5545 rule. Supported: FREQ=DAILY or WEEKLY, INTERVAL, BYDAY (weekdays such as MO,WE,FR), COUNT and UNTIL. Schedules are generated for the occurrences from today through the configured window, and the window rolls forward hourly.
+// @Tags         Recurrences
+// @Accept       json
+// @Produce      json
+// @Param        recurrence body models.RecurrenceRequest true "Recurrence"
+// @Success      201  {object}  models.Recurrence
+// @Failure      400  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/recurrences [post]
+func (h *RecurrenceHandler) CreateRecurrence(c *fiber.Ctx) error {
+	var req models.RecurrenceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+
+	recurrence := &models.Recurrence{
+		ID:             uuid.NewString(),
+		ClientID:       req.ClientID,
+		CaregiverID:    req.CaregiverID,
+		ServiceName:    strings.TrimSpace(req.ServiceName),
+		ServiceNotes:   req.ServiceNotes,
+		StartDate:      req.StartDate,
+		ShiftTime:      req.ShiftTime,
+		AmOrPm:         strings.ToUpper(req.AmOrPm),
+		TimeZone:       req.TimeZone,
+		RRule:          req.RRule,
+		ExceptionDates: req.ExceptionDates,
+		Tasks:          req.Tasks,
+	}
+	if recurrence.TimeZone == "" {
+		recurrence.TimeZone = h.cfg.TimeZone
+	}
+	if recurrence.ExceptionDates == nil {
+		recurrence.ExceptionDates = []string{}
+	}
+	if recurrence.Tasks == nil {
+		recurrence.Tasks = []models.AddTaskRequest{}
+	}
+	if err := h.validate(recurrence); err != nil {
+		return err
+	}
+
+	unlock := h.store.LockRecurrence(recurrence.ID)
+	defer unlock()
+	if err := h.store.CreateRecurrence(recurrence); err != nil {
+		return err
+	}
+	if err := generate(h.store, h.cfg, recurrence); err != nil {
+		return err
+	}
+	log.Printf("Created recurrence %s (%s) for client %s", recurrence.ID, recurrence.RRule, recurrence.ClientID)
+	return c.Status(fiber.StatusCreated).JSON(recurrence)
+}
+
+// DeleteRecurrence handles ending a recurring booking.
+// @Summary      Delete a recurrence
+// @Description  Ends the series: upcoming schedules that have not started are removed along with the rule. Past schedules and any with clock data are kept.
+// @Tags         Recurrences
+// @Param        id   path      string  true  "Recurrence ID"
+// @Success      204
+// @Failure      404  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/recurrences/{id} [delete]
+func (h *RecurrenceHandler) DeleteRecurrence(c *fiber.Ctx) error {
+	id := c.Params("id")
+	unlock := h.store.LockRecurrence(id)
+	defer unlock()
+	recurrence, err := h.store.GetRecurrence(id)
+	if err != nil {
+		return err
+	}
+	if _, err := h.removeFrom(recurrence, todayOf(recurrence)); err != nil {
+		return err
+	}
+	if err := h.store.DeleteRecurrence(id); err != nil {
+		return err
+	}
+	log.Printf("Deleted recurrence %s", id)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// UpdateOccurrence handles editing one occurrence, or it and the rest of the series.
+// @Summary      Update an occurrence
+// @Description  With scope=this (the default) only the schedule for that date changes, exactly like PATCH /api/schedules/{id}. With scope=following the series is split: the original rule ends the day before, and a new rule with the changes (including a new rrule) takes over from that date. Upcoming schedules that have not started are regenerated; schedules with clock data are kept as they are.
+// @Tags         Recurrences
+// @Accept       json
+// @Produce      json
+// @Param        id     path      string  true   "Recurrence ID"
+// @Param        date   path      string  true   "Occurrence date (YYYY-MM-DD)"
+// @Param        scope  query     string  false  "this or following"  Enums(this, following)
+// @Param        If-Match header string false "ETag of the occurrence's schedule the change is based on (scope=this)"
+// @Param        changes body models.UpdateOccurrenceRequest true "Fields to change"
+// @Success      200  {object}  models.Schedule  "scope=this; scope=following returns the models.Recurrence now covering the date"
+// @Failure      400  {object}  models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      412  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/recurrences/{id}/occurrences/{date} [patch]
+func (h *RecurrenceHandler) UpdateOccurrence(c *fiber.Ctx) error {
+	unlock := h.store.LockRecurrence(c.Params("id"))
+	defer unlock()
+	recurrence, date, err := h.occurrence(c)
+	if err != nil {
+		return err
+	}
+	var req models.UpdateOccurrenceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+
+	switch c.Query("scope", "this") {
+	case "this":
+		if req.RRule != nil {
+			return badRequest("rrule can only change with scope=following")
+		}
+		unlockSchedule := h.store.LockSchedule(recurrence.InstanceID(date))
+		defer unlockSchedule()
+		schedule, err := h.instance(recurrence, date)
+		if err != nil {
+			return err
+		}
+		if err := checkIfMatch(c.Get(fiber.HeaderIfMatch), "schedule", schedule.ID, schedule.Version); err != nil {
+			return err
+		}
+		if err := h.schedules.patchSchedule(schedule, req.UpdateScheduleRequest); err != nil {
+			return err
+		}
+		log.Printf("Updated occurrence %s of recurrence %s", schedule.OccurrenceDate, recurrence.ID)
+		setETag(c, schedule.Version)
+		return c.JSON(schedule)
+	case "following":
+		following, err := h.split(recurrence, date, req)
+		if err != nil {
+			return err
+		}
+		log.Printf("Recurrence %s continues as %s from %s", recurrence.ID, following.ID, following.StartDate)
+		return c.JSON(following)
+	}
+	return errInvalidScope
+}
+
+// DeleteOccurrence handles cancelling one occurrence, or it and the rest of the series.
+// @Summary      Delete an occurrence
+// @Description  With scope=this (the default) the date becomes an exception and its schedule is removed; 409 if that visit has clock data. With scope=following the series ends the day before; upcoming schedules that have not started are removed and any with clock data are kept.
+// @Tags         Recurrences
+// @Param        id     path      string  true   "Recurrence ID"
+// @Param        date   path      string  true   "Occurrence date (YYYY-MM-DD)"
+// @Param        scope  query     string  false  "this or following"  Enums(this, following)
+// @Param        If-Match header string false "ETag of the occurrence's schedule the change is based on (scope=this)"
+// @Success      204
+// @Failure      400  {object}  models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      412  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/recurrences/{id}/occurrences/{date} [delete]
+func (h *RecurrenceHandler) DeleteOccurrence(c *fiber.Ctx) error {
+	unlock := h.store.LockRecurrence(c.Params("id"))
+	defer unlock()
+	recurrence, date, err := h.occurrence(c)
+	if err != nil {
+		return err
+	}
+
+	switch c.Query("scope", "this") {
+	case "this":
+		id := recurrence.InstanceID(date)
+		removed, err := h.remove(id, c.Get(fiber.HeaderIfMatch))
+		if err != nil {
+			return err
+		}
+		if !removed {
+			return &models.ProtectedScheduleError{ScheduleID: id}
+		}
+		if err := h.store.AddExceptionDate(recurrence.ID, date.Format("2006-01-02")); err != nil {
+			return err
+		}
+	case "following":
+		if _, err := h.removeFrom(recurrence, date); err != nil {
+			return err
+		}
+		if date.Format("2006-01-02") == recurrence.StartDate {
+			err = h.store.DeleteRecurrence(recurrence.ID)
+		} else {
+			err = h.endBefore(recurrence, date)
+		}
+		if err != nil {
+			return err
+		}
+	default:
+		return errInvalidScope
+	}
+	log.Printf("Deleted occurrence %s of recurrence %s (%s)", date.Format("2006-01-02"), recurrence.ID, c.Query("scope", "this"))
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// occurrence resolves the :id and :date params to a recurrence and one of
+// its dates.
+func (h *RecurrenceHandler) occurrence(c *fiber.Ctx) (*models.Recurrence, time.Time, error) {
+	id := c.Params("id")
+	recurrence, err := h.store.GetRecurrence(id)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	date, err := time.Parse("2006-01-02", c.Params("date"))
+	if err != nil {
+		return nil, time.Time{}, badRequest("Occurrence date must be formatted as YYYY-MM-DD")
+	}
+	ok, err := recurrence.IsOccurrence(date)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if !ok {
+		return nil, time.Time{}, fmt.Errorf("occurrence %s of recurrence %s: %w", c.Params("date"), id, store.ErrNotFound)
+	}
+	return recurrence, date, nil
+}
+
+// split ends recurrence the day before date and returns the recurrence that
+// covers date onwards with req applied. When date is the first occurrence
+// the recurrence is changed in place instead.
+func (h *RecurrenceHandler) split(recurrence *models.Recurrence, date time.Time, req models.UpdateOccurrenceRequest) (*models.Recurrence, error) {
+	if req.ShiftDate != nil {
+		return nil, badRequest("shiftDate can only change with scope=this")
+	}
+	if req.ShiftStart != nil || req.ShiftEnd != nil {
+		return nil, badRequest("shiftStart and shiftEnd can only change with scope=this; use shiftTime and amOrPm for the series")
+	}
+	if date.Before(todayOf(recurrence)) {
+		return nil, badRequest("Only today's or a later occurrence can change the rest of the series")
+	}
+	inPlace := date.Format("2006-01-02") == recurrence.StartDate
+
+	following := *recurrence
+	following.ExceptionDates = make([]string, 0)
+	for _, exception := range recurrence.ExceptionDates {
+		if exception >= date.Format("2006-01-02") {
+			following.ExceptionDates = append(following.ExceptionDates, exception)
+		}
+	}
+	if !inPlace {
+		following.ID = uuid.NewString()
+		following.StartDate = date.Format("2006-01-02")
+		rule, err := recurrence.Rule()
+		if err != nil {
+			return nil, err
+		}
+		start, err := recurrence.Start()
+		if err != nil {
+			return nil, err
+		}
+		if rule.Count > 0 {
+			rule.Count -= rule.CountBefore(start, date)
+			following.RRule = rule.String()
+		}
+	}
+	applyToRecurrence(&following, req)
+	if err := h.validate(&following); err != nil {
+		return nil, err
+	}
+
+	kept, err := h.removeFrom(recurrence, date)
+	if err != nil {
+		return nil, err
+	}
+	if inPlace {
+		err = h.store.UpdateRecurrence(&following)
+	} else {
+		// Visits already under way stay with the original series; keep the
+		// new one from booking the same dates again.
+		following.ExceptionDates = append(following.ExceptionDates, kept...)
+		sort.Strings(following.ExceptionDates)
+		if err = h.endBefore(recurrence, date); err == nil {
+			err = h.store.CreateRecurrence(&following)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &following, generate(h.store, h.cfg, &following)
+}
+
+func applyToRecurrence(recurrence *models.Recurrence, req models.UpdateOccurrenceRequest) {
+	if req.ClientID != nil {
+		recurrence.ClientID = *req.ClientID
+	}
+	if req.CaregiverID != nil {
+		recurrence.CaregiverID = *req.CaregiverID
+	}
+	if req.ServiceName != nil {
+		recurrence.ServiceName = strings.TrimSpace(*req.ServiceName)
+	}
+	if req.ServiceNotes != nil {
+		recurrence.ServiceNotes = *req.ServiceNotes
+	}
+	if req.ShiftTime != nil {
+		recurrence.ShiftTime = *req.ShiftTime
+	}
+	if req.AmOrPm != nil {
+		recurrence.AmOrPm = strings.ToUpper(*req.AmOrPm)
+	}
+	if req.TimeZone != nil {
+		recurrence.TimeZone = *req.TimeZone
+	}
+	if req.RRule != nil {
+		recurrence.RRule = *req.RRule
+	}
+}
+
+// endBefore makes date's previous day the recurrence's last possible date.
+func (h *RecurrenceHandler) endBefore(recurrence *models.Recurrence, date time.Time) error {
+	rule, err := recurrence.Rule()
+	if err != nil {
+		return err
+	}
+	rule.Count = 0
+	rule.Until = date.AddDate(0, 0, -1)
+	recurrence.RRule = rule.String()
+	kept := recurrence.ExceptionDates[:0:0]
+	for _, exception := range recurrence.ExceptionDates {
+		if exception < date.Format("2006-01-02") {
+			kept = append(kept, exception)
+		}
+	}
+	recurrence.ExceptionDates = kept
+	return h.store.UpdateRecurrence(recurrence)
+}
+
+// removeFrom deletes the schedules generated from recurrence for date and
+// later that can still be removed, and returns the occurrence dates of the
+// ones it kept.
+func (h *RecurrenceHandler) removeFrom(recurrence *models.Recurrence, date time.Time) ([]string, error) {
+	schedules, err := h.store.ListSchedules()
+	if err != nil {
+		return nil, err
+	}
+	kept := make([]string, 0)
+	for _, schedule := range schedules {
+		if schedule.RecurrenceID != recurrence.ID || schedule.OccurrenceDate < date.Format("2006-01-02") {
+			continue
+		}
+		removed, err := h.remove(schedule.ID, "")
+		if err != nil {
+			return nil, err
+		}
+		if !removed {
+			kept = append(kept, schedule.OccurrenceDate)
+		}
+	}
+	return kept, nil
+}
+
+// remove deletes a generated schedule if it can still be dropped from its
+// series, and reports whether it is gone. A schedule that does not exist
+// counts as removed; one that does must match the If-Match header ifMatch.
+func (h *RecurrenceHandler) remove(id, ifMatch string) (bool, error) {
+	unlock := h.store.LockSchedule(id)
+	defer unlock()
+	schedule, err := h.store.GetSchedule(id)
+	if errors.Is(err, store.ErrNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := checkIfMatch(ifMatch, "schedule", id, schedule.Version); err != nil {
+		return false, err
+	}
+	removable, err := h.removable(schedule)
+	if err != nil || !removable {
+		return false, err
+	}
+	return true, h.store.DeleteSchedule(id)
+}
+
+// removable reports whether a generated schedule can be dropped from its
+// series: it is still scheduled and nothing has been recorded on it.
+func (h *RecurrenceHandler) removable(schedule *models.Schedule) (bool, error) {
+	if schedule.Status != models.StatusScheduled || schedule.HasClockData() {
+		return false, nil
+	}
+	recorded, err := hasEvents(h.store, schedule.ID)
+	return !recorded, err
+}
+
+// instance returns the schedule generated for date, generating it first if
+// it lies beyond the window. The caller holds the schedule's lock.
+func (h *RecurrenceHandler) instance(recurrence *models.Recurrence, date time.Time) (*models.Schedule, error) {
+	if err := generateInstance(h.store, recurrence, date); err != nil {
+		return nil, err
+	}
+	return h.store.GetSchedule(recurrence.InstanceID(date))
+}
+
+// generate creates the recurrence's missing schedules from today through the
+// end of the window. The caller holds the recurrence's lock.
+func generate(repo store.Repository, cfg config.Config, recurrence *models.Recurrence) error {
+	from := todayOf(recurrence)
+	dates, err := recurrence.Dates(from, from.AddDate(0, 0, cfg.RecurrenceWindowDays))
+	if err != nil {
+		return err
+	}
+	for _, date := range dates {
+		unlock := repo.LockSchedule(recurrence.InstanceID(date))
+		err := generateInstance(repo, recurrence, date)
+		unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generateInstance creates the schedule of the recurrence for date unless it
+// exists or date is no longer an occurrence, so each (recurrence, date) is
+// generated at most once. The caller holds the schedule's lock.
+func generateInstance(repo store.Repository, recurrence *models.Recurrence, date time.Time) error {
+	_, err := repo.GetSchedule(recurrence.InstanceID(date))
+	if !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	// Read the rule again under the lock: deleting a generated schedule
+	// records its date as an exception without the recurrence's lock.
+	recurrence, err = repo.GetRecurrence(recurrence.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	ok, err := recurrence.IsOccurrence(date)
+	if err != nil || !ok {
+		return err
+	}
+
+	client, err := repo.GetClient(recurrence.ClientID)
+	if err != nil {
+		return err
+	}
+	planned, err := carePlanTasks(repo, recurrence.ClientID)
+	if err != nil {
+		return err
+	}
+	schedule, err := recurrence.Instance(date)
+	if err != nil {
+		return err
+	}
+	schedule.SetClient(client)
+	// Each occurrence gets its own copies; the store assigns the IDs.
+	schedule.Tasks = append(schedule.Tasks, planned...)
+	for _, task := range recurrence.Tasks {
+		schedule.Tasks = append(schedule.Tasks, task.Task())
+	}
+	return repo.CreateSchedule(schedule)
+}
+
+// validate checks a recurrence before it is stored, returning a badRequest
+// for anything the client got wrong.
+func (h *RecurrenceHandler) validate(recurrence *models.Recurrence) error {
+	if recurrence.ServiceName == "" {
+		return badRequest("Service name is required")
+	}
+	if _, err := recurrence.Rule(); err != nil {
+		return badRequest("Invalid rrule: " + err.Error())
+	}
+	if _, err := recurrence.Start(); err != nil {
+		return badRequest("Invalid startDate: " + err.Error())
+	}
+	if err := setShift(&models.Schedule{}, "", "", recurrence.StartDate, recurrence.ShiftTime, recurrence.AmOrPm, recurrence.TimeZone); err != nil {
+		return err
+	}
+	for _, exception := range recurrence.ExceptionDates {
+		if _, err := time.Parse("2006-01-02", exception); err != nil {
+			return badRequest("Exception dates must be formatted as YYYY-MM-DD")
+		}
+	}
+	if err := models.ValidateTasks(recurrence.Tasks); err != nil {
+		return err
+	}
+	// assign checks that the client and caregiver exist.
+	return h.schedules.assign(&models.Schedule{}, recurrence.ClientID, recurrence.CaregiverID)
+}
+
+// todayOf returns the current date where the recurrence's visits happen,
+// as a civil date.
+func todayOf(recurrence *models.Recurrence) time.Time {
+	loc, err := models.LoadZone(recurrence.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+	y, m, d := time.Now().In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
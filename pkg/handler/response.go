@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/models"
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/store"
+)
+
+var (
+	errInvalidBody   = &models.BadRequestError{Reason: "invalid_body", Message: "Cannot parse request body"}
+	errInvalidTaskID = &models.BadRequestError{Reason: "invalid_task_id", Message: "Task ID must be a number"}
+
+	errInvalidTimestamp = &models.BadRequestError{
+		Reason:  "invalid_timestamp",
+		Message: "Timestamp must be RFC 3339 with a UTC offset, e.g. 2025-01-15T09:02:00-05:00",
+	}
+)
+
+// badRequest returns an error whose message is returned to the client as-is
+// with a 400 and code bad_request.
+func badRequest(message string) error {
+	return &models.BadRequestError{Reason: "bad_request", Message: message}
+}
+
+// ErrorHandler is the Fiber error handler for the API. Handlers return
+// errors rather than writing error responses; ErrorHandler renders each as
+// an application/problem+json document.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	problem := problemFor(err)
+	problem.Instance = c.OriginalURL()
+	if problem.Status == fiber.StatusInternalServerError {
+		log.Printf("Error handling %s %s: %v", c.Method(), c.OriginalURL(), err)
+	}
+	if problem.Status == fiber.StatusUnauthorized {
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="evv"`)
+	}
+	c.Status(problem.Status)
+	c.Set(fiber.HeaderContentType, models.ProblemContentType)
+	return c.JSON(problem, models.ProblemContentType)
+}
+
+// problemFor maps a Repository, lifecycle or request error onto a problem
+// document.
+func problemFor(err error) models.Problem {
+	status, code, detail := fiber.StatusInternalServerError, "internal_error", "Internal server error"
+	extensions := map[string]any{}
+
+	var (
+		fiberErr         *fiber.Error
+		notFoundErr      *models.NotFoundError
+		badReqErr        *models.BadRequestError
+		conflictErr      *models.ConflictError
+		unauthErr        *models.UnauthorizedError
+		forbiddenErr     *models.ForbiddenError
+		staleErr         *models.PreconditionFailedError
+		validationErr    *models.ValidationError
+		transitionErr    *models.TransitionError
+		protectedErr     *models.ProtectedScheduleError
+		recordedErr      *models.RecordedScheduleError
+		requiredErr      *models.RequiredTasksError
+		outcomeErr       *models.TaskOutcomeError
+		notInProgressErr *models.VisitNotInProgressError
+		geofenceErr      *models.GeofenceError
+		clockTimeErr     *models.ClockTimeError
+	)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		status, code = fiber.StatusNotFound, "not_found"
+		detail = sentence(strings.TrimSuffix(err.Error(), ": "+store.ErrNotFound.Error()) + " not found")
+	case errors.As(err, &notFoundErr):
+		status, code, detail = fiber.StatusNotFound, notFoundErr.Code(), sentence(notFoundErr.Error())
+	case errors.Is(err, store.ErrInUse):
+		status, code = fiber.StatusConflict, "in_use"
+		detail = "Cannot delete: " + strings.TrimSuffix(err.Error(), ": "+store.ErrInUse.Error())
+	case errors.Is(err, store.ErrExists):
+		status, code = fiber.StatusConflict, "already_exists"
+		detail = "Cannot create: " + strings.TrimSuffix(err.Error(), ": "+store.ErrExists.Error()) + " already exists"
+	case errors.As(err, &badReqErr):
+		status, code, detail = fiber.StatusBadRequest, badReqErr.Code(), badReqErr.Message
+	case errors.As(err, &validationErr):
+		status, code, detail = fiber.StatusBadRequest, validationErr.Code(), "Validation failed: "+validationErr.Error()
+		extensions["fields"] = validationErr.Fields
+	case errors.As(err, &conflictErr):
+		status, code, detail = fiber.StatusConflict, conflictErr.Code(), conflictErr.Message
+	case errors.As(err, &unauthErr):
+		status, code, detail = fiber.StatusUnauthorized, unauthErr.Code(), unauthErr.Message
+	case errors.As(err, &forbiddenErr):
+		status, code, detail = fiber.StatusForbidden, forbiddenErr.Code(), sentence(forbiddenErr.Error())
+		extensions["permission"] = forbiddenErr.Permission
+	case errors.As(err, &staleErr):
+		status, code, detail = fiber.StatusPreconditionFailed, staleErr.Code(), sentence(staleErr.Error())
+		extensions["etag"] = etag(staleErr.Version)
+	case errors.As(err, &transitionErr):
+		status, code = fiber.StatusConflict, transitionErr.Code()
+		detail = fmt.Sprintf("Visit cannot move from %s to %s", transitionErr.From, transitionErr.To)
+		extensions["from"] = transitionErr.From
+		extensions["to"] = transitionErr.To
+	case errors.As(err, &protectedErr):
+		status, code, detail = fiber.StatusConflict, protectedErr.Code(), "Schedule is protected: "+protectedErr.Error()
+	case errors.As(err, &recordedErr):
+		status, code, detail = fiber.StatusConflict, recordedErr.Code(), "Schedule is protected: "+recordedErr.Error()
+	case errors.As(err, &requiredErr):
+		status, code, detail = fiber.StatusConflict, requiredErr.Code(), "Visit cannot end: "+requiredErr.Error()
+		extensions["taskIds"] = requiredErr.TaskIDs
+	case errors.As(err, &outcomeErr):
+		status, code, detail = fiber.StatusConflict, outcomeErr.Code(), "Task is protected: "+outcomeErr.Error()
+	case errors.As(err, &notInProgressErr):
+		status, code, detail = fiber.StatusConflict, notInProgressErr.Code(), sentence(notInProgressErr.Error())
+		extensions["visitStatus"] = notInProgressErr.Status
+	case errors.As(err, &geofenceErr):
+		status, code = fiber.StatusUnprocessableEntity, geofenceErr.Code()
+		detail = "Clock event failed location verification: " + geofenceErr.Error()
+		extensions["radiusMeters"] = geofenceErr.RadiusMeters
+		if geofenceErr.DistanceMeters != nil {
+			extensions["distanceMeters"] = *geofenceErr.DistanceMeters
+		}
+	case errors.As(err, &clockTimeErr):
+		status, code, detail = fiber.StatusUnprocessableEntity, clockTimeErr.Code(), "Timestamp rejected: "+clockTimeErr.Error()
+	case errors.As(err, &fiberErr):
+		// Raised by Fiber itself, e.g. for an unknown route or an oversized
+		// body.
+		status, detail = fiberErr.Code, fiberErr.Message
+		code = strings.ReplaceAll(strings.ToLower(http.StatusText(fiberErr.Code)), " ", "_")
+		if status == fiber.StatusNotFound {
+			code = "route_not_found"
+		}
+	}
+
+	problem := models.Problem{
+		Type:   "/problems/" + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+	if len(extensions) > 0 {
+		problem.Extensions = extensions
+	}
+	return problem
+}
+
+// sentence capitalises the first letter of an error message.
+func sentence(message string) string {
+	if message == "" {
+		return message
+	}
+	return strings.ToUpper(message[:1]) + message[1:]
+}
+
+// errorCode returns the machine-readable reason for err, as reported by
+// offline sync for rejected mutations.
+func errorCode(err error) string {
+	return problemFor(err).Code
+}
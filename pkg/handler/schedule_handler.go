@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"sort"
@@ -8,89 +9,152 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/config"
 	"github.com/IkoAfianando/mini_evv_logger_go/pkg/models"
 	"github.com/IkoAfianando/mini_evv_logger_go/pkg/store"
 )
 
 type ScheduleHandler struct {
-	store *store.Store
+	store store.Repository
+	cfg   config.Config
 }
 
-func NewScheduleHandler(st *store.Store) *ScheduleHandler {
-	return &ScheduleHandler{store: st}
+func NewScheduleHandler(st store.Repository, cfg config.Config) *ScheduleHandler {
+	return &ScheduleHandler{store: st, cfg: cfg}
 }
 
-// ResetStore handles resetting the in-memory data store to its initial state.
+// ResetStore handles resetting the data store to its initial state.
 // @Summary      Reset data store
-// @Description  Resets the in-memory data to the initial set of schedules and tasks, useful for testing.
+// @Description  Resets the stored data to the initial set of schedules and tasks, useful for testing. Users, refresh tokens and API keys are kept.
 // @Tags         Admin
 // @Accept       json
 // @Produce      json
 // @Success      200  {object}  map[string]string
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
 // @Router       /api/reset [post]
 func (h *ScheduleHandler) ResetStore(c *fiber.Ctx) error {
 	log.Println("Received request to reset data store.")
-	h.store.SetupInitialData()
-	log.Println("In-memory data store has been reset.")
+	if err := h.store.Reset(); err != nil {
+		return fmt.Errorf("resetting data store: %w", err)
+	}
+	log.Println("Data store has been reset.")
 	return c.JSON(fiber.Map{"message": "Data store has been reset to initial state"})
 }
 
-// GetSchedules handles fetching all caregiver schedules, sorted by date and time.
-// @Summary      Get all schedules
-// @Description  Fetches a list of all caregiver schedules, sorted chronologically
+// GetSchedules handles listing schedules with filters, sorting and cursor pagination.
+// @Summary      List schedules
+// @Description  Lists schedules matching the filters, a page at a time. "from" and "to" (YYYY-MM-DD, inclusive) limit the shifts returned to those starting on those days, read in the "tz" zone, else the caregiver's zone when "caregiverId" is given, else the agency's. "q" searches client and service names, notes, address and tasks. The X-Total-Count header holds the number of matches; when there are more, X-Next-Cursor and a Link rel="next" header give the next page, which stays consistent while schedules change.
 // @Tags         Schedules
 // @Accept       json
 // @Produce      json
+// @Param        status       query     string  false  "Comma-separated visit statuses"
+// @Param        clientId     query     string  false  "Only this client's schedules"
+// @Param        caregiverId  query     string  false  "Only this caregiver's schedules"
+// @Param        from         query     string  false  "First shift date (YYYY-MM-DD)"
+// @Param        to           query     string  false  "Last shift date (YYYY-MM-DD)"
+// @Param        tz           query     string  false  "IANA time zone the dates are read in"
+// @Param        q            query     string  false  "Free-text search"
+// @Param        sort         query     string  false  "shiftStart (default), clientName, serviceName or status; prefix with - to reverse"
+// @Param        limit        query     int     false  "Page size (default 100, max 500)"
+// @Param        cursor       query     string  false  "X-Next-Cursor from the previous page"
 // @Success      200  {array}   models.Schedule
+// @Header       200  {integer}  X-Total-Count  "Number of matching schedules"
+// @Header       200  {string}   X-Next-Cursor  "Cursor for the next page, if any"
+// @Failure      400  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
 // @Router       /api/schedules [get]
 func (h *ScheduleHandler) GetSchedules(c *fiber.Ctx) error {
-	schedulesList := make([]*models.Schedule, 0, len(h.store.Schedules))
-	for _, schedule := range h.store.Schedules {
-		schedulesList = append(schedulesList, schedule)
+	query, err := parseScheduleQuery(c)
+	if err != nil {
+		return err
+	}
+	schedulesList, loc, err := h.listFor(c)
+	if err != nil {
+		return err
+	}
+	start, end, err := dayRange(c.Query("from"), c.Query("to"), loc)
+	if err != nil {
+		return err
 	}
 
-	sort.Slice(schedulesList, func(i, j int) bool {
-		timeStrI := schedulesList[i].ShiftDate + " " + strings.Split(schedulesList[i].ShiftTime, " - ")[0] + " " + schedulesList[i].AmOrPm
-		timeStrJ := schedulesList[j].ShiftDate + " " + strings.Split(schedulesList[j].ShiftTime, " - ")[0] + " " + schedulesList[j].AmOrPm
-
-		layout := "2006-01-02 3:04 PM"
-
-		timeI, errI := time.Parse(layout, timeStrI)
-		if errI != nil {
-			log.Printf("Error parsing time for schedule %s: %v", schedulesList[i].ID, errI)
-			return false
-		}
-
-		timeJ, errJ := time.Parse(layout, timeStrJ)
-		if errJ != nil {
-			log.Printf("Error parsing time for schedule %s: %v", schedulesList[j].ID, errJ)
-			return true
-		}
-		return timeI.Before(timeJ)
-	})
-
-	return c.JSON(schedulesList)
+	page, total, next := query.page(startingWithin(schedulesList, start, end))
+	setPageHeaders(c, total, next)
+	return c.JSON(page)
 }
 
 // GetTodaySchedules handles fetching all schedules for the current date.
 // @Summary      Get today's schedules
-// @Description  Fetches all schedules scheduled for the current date
+// @Description  Fetches all schedules starting today, where "today" is read in the "tz" zone, else the caregiver's zone when "caregiverId" is given, else the agency's.
 // @Tags         Schedules
 // @Accept       json
 // @Produce      json
+// @Param        caregiverId  query     string  false  "Only this caregiver's schedules"
+// @Param        tz           query     string  false  "IANA time zone"
 // @Success      200  {array}   models.Schedule
+// @Failure      400  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
 // @Router       /api/schedules/today [get]
 func (h *ScheduleHandler) GetTodaySchedules(c *fiber.Ctx) error {
-	today := time.Now().Format("2006-01-02")
-	todaySchedules := make([]*models.Schedule, 0)
+	schedules, loc, err := h.listFor(c)
+	if err != nil {
+		return err
+	}
+	today := todayIn(loc)
+	start, end, err := dayRange(today, today, loc)
+	if err != nil {
+		return err
+	}
+
+	todaySchedules := startingWithin(schedules, start, end)
+	sortByShift(todaySchedules)
+	return c.JSON(todaySchedules)
+}
 
-	for _, schedule := range h.store.Schedules {
-		if schedule.ShiftDate == today {
-			todaySchedules = append(todaySchedules, schedule)
+// listFor returns the schedules a listing covers, narrowed to the caregiver
+// in the caregiverId query parameter, or to the caller when the caller may
+// only see their own, and the zone its dates are read in.
+func (h *ScheduleHandler) listFor(c *fiber.Ctx) ([]*models.Schedule, *time.Location, error) {
+	id, err := caregiverScope(CurrentPrincipal(c), c.Query("caregiverId"))
+	if err != nil {
+		return nil, nil, err
+	}
+	var caregiver *models.Caregiver
+	if id != "" {
+		caregiver, err = h.store.GetCaregiver(id)
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, nil, badRequest(fmt.Sprintf("Caregiver %s does not exist", id))
+		}
+		if err != nil {
+			return nil, nil, err
 		}
 	}
-	return c.JSON(todaySchedules)
+	loc, err := viewZone(c, caregiver, h.cfg.TimeZone)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	schedules, err := h.store.ListSchedules()
+	if err != nil {
+		return nil, nil, err
+	}
+	if caregiver == nil {
+		return schedules, loc, nil
+	}
+	assigned := make([]*models.Schedule, 0)
+	for _, schedule := range schedules {
+		if schedule.CaregiverID == caregiver.ID {
+			assigned = append(assigned, schedule)
+		}
+	}
+	return assigned, loc, nil
 }
 
 // GetScheduleByID handles fetching a single schedule by its ID.
@@ -100,183 +164,794 @@ func (h *ScheduleHandler) GetTodaySchedules(c *fiber.Ctx) error {
 // @Accept       json
 // @Produce      json
 // @Param        id   path      string  true  "Schedule ID"
+// @Param        If-None-Match header string false "ETag from an earlier response"
 // @Success      200  {object}  models.Schedule
-// @Failure      404  {object}  map[string]string
+// @Success      304
+// @Failure      404  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
 // @Router       /api/schedules/{id} [get]
 func (h *ScheduleHandler) GetScheduleByID(c *fiber.Ctx) error {
 	id := c.Params("id")
-	schedule, ok := h.store.Schedules[id]
-	if !ok {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": fmt.Sprintf("Schedule with ID %s not found", id),
-		})
+	schedule, err := h.store.GetSchedule(id)
+	if err != nil {
+		return err
+	}
+	if notModified(c, schedule.Version) {
+		return c.SendStatus(fiber.StatusNotModified)
 	}
 	return c.JSON(schedule)
 }
 
+// CreateSchedule handles booking a new visit.
+// @Summary      Create a schedule
+// @Description  Books a visit for a client, optionally assigned to a caregiver. The client's name, contact details and location are copied onto the schedule, and its care plan tasks come before the tasks in the request. The shift is given in the legacy shiftDate/shiftTime/amOrPm form.
+// @Tags         Schedules
+// @Accept       json
+// @Produce      json
+// @Param        schedule body models.CreateScheduleRequest true "Schedule"
+// @Success      201  {object}  models.Schedule
+// @Failure      400  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/schedules [post]
+func (h *ScheduleHandler) CreateSchedule(c *fiber.Ctx) error {
+	var req models.CreateScheduleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+	if strings.TrimSpace(req.ServiceName) == "" {
+		return models.InvalidField("serviceName", "required", "Service name is required")
+	}
+	if err := models.ValidateTasks(req.Tasks); err != nil {
+		return err
+	}
+
+	schedule := &models.Schedule{
+		ID:           uuid.NewString(),
+		ServiceName:  strings.TrimSpace(req.ServiceName),
+		ServiceNotes: req.ServiceNotes,
+		Tasks:        make([]models.Task, 0, len(req.Tasks)),
+		Visit:        models.Visit{Status: models.StatusScheduled},
+	}
+	zone := req.TimeZone
+	if zone == "" {
+		zone = h.cfg.TimeZone
+	}
+	if err := setShift(schedule, req.ShiftStart, req.ShiftEnd, req.ShiftDate, req.ShiftTime, req.AmOrPm, zone); err != nil {
+		return err
+	}
+	if err := h.assign(schedule, req.ClientID, req.CaregiverID); err != nil {
+		return err
+	}
+
+	// The client's care plan comes first, then any tasks for this visit.
+	planned, err := carePlanTasks(h.store, schedule.ClientID)
+	if err != nil {
+		return err
+	}
+	schedule.Tasks = append(schedule.Tasks, planned...)
+	for _, task := range req.Tasks {
+		schedule.Tasks = append(schedule.Tasks, task.Task())
+	}
+
+	if err := h.store.CreateSchedule(schedule); err != nil {
+		return err
+	}
+	log.Printf("Created schedule %s for client %s", schedule.ID, schedule.ClientID)
+	return c.Status(fiber.StatusCreated).JSON(schedule)
+}
+
+// UpdateSchedule handles changing the plan of a visit.
+// @Summary      Update a schedule
+// @Description  Changes the fields present in the body. Once a visit has clock data only serviceNotes can change; anything else returns 409. Visit status, clock data and tasks have their own endpoints.
+// @Tags         Schedules
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Schedule ID"
+// @Param        changes body models.UpdateScheduleRequest true "Fields to change"
+// @Param        If-Match header string false "ETag the change is based on"
+// @Success      200  {object}  models.Schedule
+// @Failure      400  {object}  models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      412  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/schedules/{id} [patch]
+func (h *ScheduleHandler) UpdateSchedule(c *fiber.Ctx) error {
+	id := c.Params("id")
+	unlock, err := lockSchedule(c, h.store, id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	schedule, err := h.store.GetSchedule(id)
+	if err != nil {
+		return err
+	}
+
+	var req models.UpdateScheduleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+
+	if err := h.patchSchedule(schedule, req); err != nil {
+		return err
+	}
+	log.Printf("Updated schedule %s", id)
+	setETag(c, schedule.Version)
+	return c.JSON(schedule)
+}
+
+// DeleteSchedule handles removing a visit that has not happened.
+// @Summary      Delete a schedule
+// @Description  Removes a schedule with its tasks. Schedules whose visit has clock data are part of the EVV record and return 409; cancel the visit instead. Deleting an occurrence of a recurrence adds its date to the recurrence's exceptionDates, so it is not generated again.
+// @Tags         Schedules
+// @Param        id   path      string  true  "Schedule ID"
+// @Param        If-Match header string false "ETag the change is based on"
+// @Success      204
+// @Failure      404  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      412  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/schedules/{id} [delete]
+func (h *ScheduleHandler) DeleteSchedule(c *fiber.Ctx) error {
+	id := c.Params("id")
+	unlock, err := lockSchedule(c, h.store, id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	schedule, err := h.store.GetSchedule(id)
+	if err != nil {
+		return err
+	}
+	protected, err := isProtected(h.store, schedule)
+	if err != nil {
+		return err
+	}
+	if protected {
+		return &models.ProtectedScheduleError{ScheduleID: id}
+	}
+	recorded, err := hasEvents(h.store, id)
+	if err != nil {
+		return err
+	}
+	if recorded {
+		return &models.RecordedScheduleError{ScheduleID: id}
+	}
+
+	// An occurrence of a recurrence becomes an exception first, so the
+	// series does not generate it again.
+	if schedule.RecurrenceID != "" {
+		err := h.store.AddExceptionDate(schedule.RecurrenceID, schedule.OccurrenceDate)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+	}
+	if err := h.store.DeleteSchedule(id); err != nil {
+		return err
+	}
+	log.Printf("Deleted schedule %s", id)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetScheduleEvents handles fetching the visit event log of a schedule.
+// @Summary      Get visit events
+// @Description  Fetches the immutable, ordered log of visit and task events recorded for a schedule
+// @Tags         Visits
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Schedule ID"
+// @Success      200  {array}   models.VisitEvent
+// @Failure      404  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/schedules/{id}/events [get]
+func (h *ScheduleHandler) GetScheduleEvents(c *fiber.Ctx) error {
+	id := c.Params("id")
+	events, err := h.store.ListEvents(id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(events)
+}
+
 // StartVisit handles the start of a visit.
 // @Summary      Start a visit
-// @Description  Marks a scheduled visit as "in_progress" and records the start time and location.
+// @Description  Marks a scheduled visit as "in_progress" and records the start time and location. An optional RFC 3339 "timestamp" from the device (for events queued offline) is used as the clock-in time if it is not in the future, not too old and not well before the shift; otherwise 422. The server receive time is stored alongside. Returns 409 unless the visit is "scheduled". The location is checked against the client's geofence: outside it the visit is flagged with an exception, or rejected with 422 when geofences are enforced.
 // @Tags         Visits
 // @Accept       json
 // @Produce      json
 // @Param        id   path      string  true  "Schedule ID"
 // @Param        location body models.StartVisitRequest true "Start Location"
+// @Param        If-Match header string false "ETag the change is based on"
 // @Success      200  {object}  models.Schedule
-// @Failure      400  {object}  map[string]string
-// @Failure      404  {object}  map[string]string
+// @Failure      400  {object}  models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      422  {object}  models.Problem
+// @Failure      412  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
 // @Router       /api/schedules/{id}/start [post]
 func (h *ScheduleHandler) StartVisit(c *fiber.Ctx) error {
 	id := c.Params("id")
-	schedule, ok := h.store.Schedules[id]
-	if !ok {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Schedule not found"})
+	if _, err := h.store.GetVisit(id); err != nil {
+		return err
 	}
 
 	var req models.StartVisitRequest
 	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse request body"})
+		return errInvalidBody
 	}
 
-	now := time.Now()
-	schedule.Status = "in_progress"
-	schedule.ClockInTime = &now
-	schedule.ClockInLocation = &models.Geolocation{
-		Latitude:  req.Location.Latitude,
-		Longitude: req.Location.Longitude,
+	schedule, event, err := h.recordClockEvent(id, models.EventVisitStarted, req.Timestamp, req.Location, c.Get(fiber.HeaderIfMatch), "")
+	if err != nil {
+		return err
 	}
 
-	log.Printf("Started visit for schedule ID %s at %v", id, now)
+	log.Printf("Started visit for schedule ID %s at %v (received %v)", id, event.OccurredAt, event.ReceivedAt)
+	setETag(c, schedule.Version)
 	return c.JSON(schedule)
 }
 
 // EndVisit handles the end of a visit.
 // @Summary      End a visit
-// @Description  Marks an in-progress visit as "completed" and records the end time and location.
+// @Description  Marks an in-progress visit as "completed" and records the end time and location. An optional device "timestamp" is honoured like on start, and must not precede the clock-in. Returns 409 unless the visit is "in_progress", or (code "required_tasks_incomplete") while a required task is neither completed nor given a not-completed reason. The location is checked against the client's geofence like on start.
 // @Tags         Visits
 // @Accept       json
 // @Produce      json
 // @Param        id   path      string  true  "Schedule ID"
 // @Param        location body models.EndVisitRequest true "End Location"
+// @Param        If-Match header string false "ETag the change is based on"
 // @Success      200  {object}  models.Schedule
-// @Failure      400  {object}  map[string]string
-// @Failure      404  {object}  map[string]string
+// @Failure      400  {object}  models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      422  {object}  models.Problem
+// @Failure      412  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
 // @Router       /api/schedules/{id}/end [post]
 func (h *ScheduleHandler) EndVisit(c *fiber.Ctx) error {
 	id := c.Params("id")
-	schedule, ok := h.store.Schedules[id]
-	if !ok {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Schedule not found"})
+	if _, err := h.store.GetVisit(id); err != nil {
+		return err
 	}
 
 	var req models.EndVisitRequest
 	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse request body"})
+		return errInvalidBody
 	}
 
-	now := time.Now()
-	schedule.Status = "completed"
-	schedule.ClockOutTime = &now
-	schedule.ClockOutLocation = &models.Geolocation{
-		Latitude:  req.Location.Latitude,
-		Longitude: req.Location.Longitude,
+	schedule, event, err := h.recordClockEvent(id, models.EventVisitEnded, req.Timestamp, req.Location, c.Get(fiber.HeaderIfMatch), "")
+	if err != nil {
+		return err
 	}
 
-	log.Printf("Ended visit for schedule ID %s at %v", id, now)
+	log.Printf("Ended visit for schedule ID %s at %v (received %v)", id, event.OccurredAt, event.ReceivedAt)
+	setETag(c, schedule.Version)
 	return c.JSON(schedule)
 }
 
 // ClockIn handles clocking in for a schedule.
 // @Summary      Clock in for a schedule
-// @Description  Records the clock-in time and location for a schedule.
+// @Description  Records the clock-in time for a schedule. No location is sent, so the visit gets a "clock_in_location_missing" exception, or a 422 when geofences are enforced.
 // @Tags         Visits
 // @Accept       json
 // @Produce      json
 // @Param        id   path      string  true  "Schedule ID"
+// @Param        If-Match header string false "ETag the change is based on"
 // @Success      200  {object}  models.Schedule
-// @Failure      404  {object}  map[string]string
+// @Failure      404  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      422  {object}  models.Problem
+// @Failure      412  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
 // @Router       /api/schedules/{id}/clock-in [get]
 func (h *ScheduleHandler) ClockIn(c *fiber.Ctx) error {
 	id := c.Params("id")
-	schedule, ok := h.store.Schedules[id]
-	if !ok {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Schedule not found"})
+	unlock, err := lockSchedule(c, h.store, id)
+	if err != nil {
+		return err
 	}
-
-	if schedule.ClockInTime != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Already clocked in"})
+	defer unlock()
+	schedule, err := h.store.GetSchedule(id)
+	if err != nil {
+		return err
 	}
 
-	now := time.Now()
-	schedule.ClockInTime = &now
-	schedule.ClockInLocation = &models.Geolocation{
-		Latitude:  0, // Placeholder, should be replaced with actual location
-		Longitude: 0, // Placeholder, should be replaced with actual location
+	// This endpoint carries no location, so the clock-in is recorded as
+	// unverified (or rejected when the geofence mode is "reject").
+	event, err := h.clockEvent(schedule, models.EventClockedIn, nil, nil)
+	if err != nil {
+		return err
+	}
+	schedule, err = h.recordEvent(&event)
+	if err != nil {
+		return err
 	}
-	schedule.Status = "in_progress"
 
-	log.Printf("Clocked in for schedule ID %s at %v", id, now)
+	log.Printf("Clocked in for schedule ID %s at %v", id, event.OccurredAt)
+	setETag(c, schedule.Version)
 	return c.JSON(schedule)
 }
 
 // CancelClockIn handles cancellation of a previously recorded clock-in.
 // @Summary      Cancel clock-in
-// @Description  Cancels the clock-in by clearing time and location, and sets status back to "scheduled"
+// @Description  Cancels the clock-in by clearing time and location, and sets status back to "scheduled". The cancelled clock-in stays in the visit event log.
 // @Tags         Visits
 // @Accept       json
 // @Produce      json
 // @Param        id   path      string  true  "Schedule ID"
+// @Param        If-Match header string false "ETag the change is based on"
 // @Success      200  {object}  models.Schedule
-// @Failure      404  {object}  map[string]string
+// @Failure      404  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      412  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
 // @Router       /api/schedules/{id}/cancel-clock-in [post]
 func (h *ScheduleHandler) CancelClockIn(c *fiber.Ctx) error {
 	id := c.Params("id")
-	schedule, ok := h.store.Schedules[id]
-	if !ok {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Schedule not found"})
+	unlock, err := lockSchedule(c, h.store, id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	schedule, err := h.recordEvent(&models.VisitEvent{
+		ScheduleID: id,
+		Type:       models.EventClockInCancelled,
+		OccurredAt: time.Now(),
+	})
+	if err != nil {
+		return err
 	}
-
-	schedule.ClockInTime = nil
-	schedule.ClockInLocation = nil
-	schedule.Status = "scheduled"
 
 	log.Printf("Cancelled clock-in for schedule ID %s", id)
+	setETag(c, schedule.Version)
+	return c.JSON(schedule)
+}
+
+// MarkVisitMissed handles recording that a scheduled visit did not happen.
+// @Summary      Mark a visit missed
+// @Description  Moves a "scheduled" visit to "missed". Returns 409 from any other status.
+// @Tags         Visits
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Schedule ID"
+// @Param        If-Match header string false "ETag the change is based on"
+// @Success      200  {object}  models.Schedule
+// @Failure      404  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      412  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/schedules/{id}/mark-missed [post]
+func (h *ScheduleHandler) MarkVisitMissed(c *fiber.Ctx) error {
+	id := c.Params("id")
+	unlock, err := lockSchedule(c, h.store, id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	schedule, err := h.recordEvent(&models.VisitEvent{
+		ScheduleID: id,
+		Type:       models.EventVisitMissed,
+		OccurredAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Marked visit missed for schedule ID %s", id)
+	setETag(c, schedule.Version)
+	return c.JSON(schedule)
+}
+
+// CancelVisit handles cancelling a scheduled visit.
+// @Summary      Cancel a visit
+// @Description  Moves a "scheduled" visit to "cancelled". Returns 409 from any other status.
+// @Tags         Visits
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Schedule ID"
+// @Param        If-Match header string false "ETag the change is based on"
+// @Success      200  {object}  models.Schedule
+// @Failure      404  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      412  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/schedules/{id}/cancel [post]
+func (h *ScheduleHandler) CancelVisit(c *fiber.Ctx) error {
+	id := c.Params("id")
+	unlock, err := lockSchedule(c, h.store, id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	schedule, err := h.recordEvent(&models.VisitEvent{
+		ScheduleID: id,
+		Type:       models.EventVisitCancelled,
+		OccurredAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Cancelled visit for schedule ID %s", id)
+	setETag(c, schedule.Version)
 	return c.JSON(schedule)
 }
 
 // AddTaskToSchedule adds a new task to a schedule.
 // @Summary      Add a task to schedule
-// @Description  Adds a new task with name and description to the given schedule
+// @Description  Adds a new task with name and description to the given schedule, optionally marked required
 // @Tags         Tasks
 // @Accept       json
 // @Produce      json
 // @Param        id   path      string  true  "Schedule ID"
 // @Param        task body models.AddTaskRequest true "Task to add"
+// @Param        If-Match header string false "ETag the change is based on"
 // @Success      200  {object}  models.Schedule
-// @Failure      400  {object}  map[string]string
-// @Failure      404  {object}  map[string]string
+// @Failure      400  {object}  models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      412  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
 // @Router       /api/schedules/{id}/tasks [post]
 func (h *ScheduleHandler) AddTaskToSchedule(c *fiber.Ctx) error {
 	id := c.Params("id")
-	schedule, ok := h.store.Schedules[id]
-	if !ok {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Schedule not found"})
+	unlock, err := lockSchedule(c, h.store, id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	schedule, err := h.store.GetSchedule(id)
+	if err != nil {
+		return err
 	}
 
 	var req models.AddTaskRequest
 	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse request body"})
+		return errInvalidBody
 	}
-
-	newTaskID := len(schedule.Tasks) + 1
-	newTask := models.Task{
-		ID:          newTaskID,
-		Name:        req.Name,
-		Description: req.Description,
+	if err := req.Validate(); err != nil {
+		return err
 	}
 
-	schedule.Tasks = append(schedule.Tasks, newTask)
+	// The store assigns the ID.
+	newTask := req.Task()
+
+	if err := h.store.CreateTask(id, &newTask); err != nil {
+		return err
+	}
+	if schedule, err = h.store.GetSchedule(id); err != nil {
+		return err
+	}
 
 	log.Printf("Added task to schedule ID %s: %+v", id, newTask)
+	setETag(c, schedule.Version)
 	return c.JSON(schedule)
 }
+
+// patchSchedule applies the fields present in req to the schedule and
+// stores it. Edits other than the notes fail with a ProtectedScheduleError
+// once the visit has clock data.
+func (h *ScheduleHandler) patchSchedule(schedule *models.Schedule, req models.UpdateScheduleRequest) error {
+	protected, err := isProtected(h.store, schedule)
+	if err != nil {
+		return err
+	}
+	if protected {
+		if field := plannedFieldIn(req); field != "" {
+			return &models.ProtectedScheduleError{ScheduleID: schedule.ID, Field: field}
+		}
+	}
+
+	if req.ServiceName != nil {
+		if strings.TrimSpace(*req.ServiceName) == "" {
+			return models.InvalidField("serviceName", "required", "Service name is required")
+		}
+		schedule.ServiceName = strings.TrimSpace(*req.ServiceName)
+	}
+	if err := patchShift(schedule, req); err != nil {
+		return err
+	}
+	if req.ServiceNotes != nil {
+		schedule.ServiceNotes = *req.ServiceNotes
+	}
+	if req.ClientID != nil || req.CaregiverID != nil {
+		clientID, caregiverID := schedule.ClientID, schedule.CaregiverID
+		if req.ClientID != nil {
+			clientID = *req.ClientID
+		}
+		if req.CaregiverID != nil {
+			caregiverID = *req.CaregiverID
+		}
+		if err := h.assign(schedule, clientID, caregiverID); err != nil {
+			return err
+		}
+	}
+	return h.store.UpdateSchedule(schedule)
+}
+
+// assign points the schedule at a client and caregiver, failing with a
+// *models.ValidationError if either does not exist.
+func (h *ScheduleHandler) assign(schedule *models.Schedule, clientID, caregiverID string) error {
+	if clientID == "" {
+		return models.InvalidField("clientId", "required", "Client ID is required")
+	}
+	client, err := h.store.GetClient(clientID)
+	if errors.Is(err, store.ErrNotFound) {
+		return models.InvalidField("clientId", "invalid", fmt.Sprintf("Client %s does not exist", clientID))
+	}
+	if err != nil {
+		return err
+	}
+	if caregiverID != "" {
+		_, err := h.store.GetCaregiver(caregiverID)
+		if errors.Is(err, store.ErrNotFound) {
+			return models.InvalidField("caregiverId", "invalid", fmt.Sprintf("Caregiver %s does not exist", caregiverID))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	schedule.SetClient(client)
+	schedule.CaregiverID = caregiverID
+	return nil
+}
+
+// isProtected reports whether the schedule's visit has clock data, either on
+// the visit or in its event log (a cancelled clock-in still counts).
+func isProtected(repo store.Repository, schedule *models.Schedule) (bool, error) {
+	if schedule.HasClockData() {
+		return true, nil
+	}
+	events, err := repo.ListEvents(schedule.ID)
+	if err != nil {
+		return false, err
+	}
+	for _, event := range events {
+		if event.Type.IsClockEvent() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// hasEvents reports whether the schedule's event log has any event, a task
+// outcome as much as a clock event. Such a schedule is kept, so that its
+// events are not left without the visit they belong to.
+func hasEvents(repo store.Repository, scheduleID string) (bool, error) {
+	events, err := repo.ListEvents(scheduleID)
+	if err != nil {
+		return false, err
+	}
+	return len(events) > 0, nil
+}
+
+// plannedFieldIn returns the JSON name of the first field in req that
+// re-plans the visit, or "" if it only touches the notes.
+func plannedFieldIn(req models.UpdateScheduleRequest) string {
+	switch {
+	case req.ClientID != nil:
+		return "clientId"
+	case req.CaregiverID != nil:
+		return "caregiverId"
+	case req.ServiceName != nil:
+		return "serviceName"
+	case req.ShiftStart != nil:
+		return "shiftStart"
+	case req.ShiftEnd != nil:
+		return "shiftEnd"
+	case req.TimeZone != nil:
+		return "timeZone"
+	case req.ShiftDate != nil:
+		return "shiftDate"
+	case req.ShiftTime != nil:
+		return "shiftTime"
+	case req.AmOrPm != nil:
+		return "amOrPm"
+	}
+	return ""
+}
+
+// setShift sets the schedule's shift from request fields that give it
+// either as RFC 3339 instants or in the legacy form read in zone, returning
+// a badRequest if they give both or are invalid.
+func setShift(schedule *models.Schedule, start, end, date, timeRange, amOrPm, zone string) error {
+	if _, err := models.LoadZone(zone); err != nil {
+		return badRequest("Invalid timeZone: " + err.Error())
+	}
+	if start == "" && end == "" {
+		if err := schedule.SetLegacyShift(date, timeRange, amOrPm, zone); err != nil {
+			return badRequest("Shift must have shiftDate YYYY-MM-DD, shiftTime \"HH:MM - HH:MM\" and amOrPm AM or PM: " + err.Error())
+		}
+		return nil
+	}
+	if date != "" || timeRange != "" || amOrPm != "" {
+		return badRequest("Give the shift as shiftStart and shiftEnd or as shiftDate, shiftTime and amOrPm, not both")
+	}
+	startAt, errStart := time.Parse(time.RFC3339, start)
+	endAt, errEnd := time.Parse(time.RFC3339, end)
+	if errStart != nil || errEnd != nil {
+		return badRequest("shiftStart and shiftEnd must be RFC 3339 timestamps, e.g. 2025-01-15T09:00:00-06:00")
+	}
+	if err := schedule.SetShift(startAt, endAt, zone); err != nil {
+		return badRequest("Invalid shift: " + err.Error())
+	}
+	return nil
+}
+
+// patchShift applies the shift fields present in req on top of the
+// schedule's current shift, in whichever form req uses.
+func patchShift(schedule *models.Schedule, req models.UpdateScheduleRequest) error {
+	legacy := req.ShiftDate != nil || req.ShiftTime != nil || req.AmOrPm != nil
+	instants := req.ShiftStart != nil || req.ShiftEnd != nil
+	if !legacy && !instants && req.TimeZone == nil {
+		return nil
+	}
+
+	zone := schedule.TimeZone
+	if req.TimeZone != nil {
+		zone = *req.TimeZone
+	}
+	var start, end, date, timeRange, amOrPm string
+	if req.ShiftStart != nil {
+		start = *req.ShiftStart
+	}
+	if req.ShiftEnd != nil {
+		end = *req.ShiftEnd
+	}
+	if legacy {
+		date, timeRange, amOrPm = schedule.ShiftDate, schedule.ShiftTime, schedule.AmOrPm
+		if req.ShiftDate != nil {
+			date = *req.ShiftDate
+		}
+		if req.ShiftTime != nil {
+			timeRange = *req.ShiftTime
+		}
+		if req.AmOrPm != nil {
+			amOrPm = *req.AmOrPm
+		}
+	} else {
+		// Only instants or the zone changed: keep whatever else is planned.
+		if start == "" {
+			start = schedule.ShiftStart.Format(time.RFC3339)
+		}
+		if end == "" {
+			end = schedule.ShiftEnd.Format(time.RFC3339)
+		}
+	}
+	return setShift(schedule, start, end, date, timeRange, amOrPm, zone)
+}
+
+// sortByShift orders schedules chronologically by shift start.
+func sortByShift(schedules []*models.Schedule) {
+	sort.SliceStable(schedules, func(i, j int) bool {
+		if !schedules[i].ShiftStart.Equal(schedules[j].ShiftStart) {
+			return schedules[i].ShiftStart.Before(schedules[j].ShiftStart)
+		}
+		return schedules[i].ID < schedules[j].ID
+	})
+}
+
+// recordEvent checks the event against the visit lifecycle, appends it
+// (setting its ID) and returns the schedule rebuilt with it.
+func (h *ScheduleHandler) recordEvent(event *models.VisitEvent) (*models.Schedule, error) {
+	if next := event.Type.TargetStatus(); next != "" {
+		visit, err := h.store.GetVisit(event.ScheduleID)
+		if err != nil {
+			return nil, err
+		}
+		if err := models.CheckTransition(visit.Status, next); err != nil {
+			return nil, err
+		}
+	}
+	if event.Type.IsClockEvent() && h.cfg.GeofenceMode == config.GeofenceReject {
+		if event.DistanceMeters == nil || *event.DistanceMeters > event.GeofenceRadiusMeters {
+			return nil, &models.GeofenceError{DistanceMeters: event.DistanceMeters, RadiusMeters: event.GeofenceRadiusMeters}
+		}
+	}
+	if err := h.store.AppendEvent(event); err != nil {
+		return nil, err
+	}
+	return h.store.GetSchedule(event.ScheduleID)
+}
+
+// recordClockEvent starts or ends a visit. It backs both the StartVisit and
+// EndVisit endpoints and the matching offline sync mutations, which pass no
+// ifMatch.
+func (h *ScheduleHandler) recordClockEvent(id string, eventType models.VisitEventType, timestamp string, location models.Geolocation, ifMatch, idempotencyKey string) (*models.Schedule, *models.VisitEvent, error) {
+	unlock := h.store.LockSchedule(id)
+	defer unlock()
+	schedule, err := h.store.GetSchedule(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkIfMatch(ifMatch, "schedule", id, schedule.Version); err != nil {
+		return nil, nil, err
+	}
+	deviceTime, err := parseDeviceTime(timestamp)
+	if err != nil {
+		return nil, nil, err
+	}
+	// A visit that cannot end anyway gets the transition error instead.
+	if eventType == models.EventVisitEnded && schedule.Status == models.StatusInProgress {
+		if err := schedule.CheckRequiredTasks(); err != nil {
+			return nil, nil, err
+		}
+	}
+	event, err := h.clockEvent(schedule, eventType, deviceTime, &location)
+	if err != nil {
+		return nil, nil, err
+	}
+	event.IdempotencyKey = idempotencyKey
+	if schedule, err = h.recordEvent(&event); err != nil {
+		return nil, nil, err
+	}
+	return schedule, &event, nil
+}
+
+// clockEvent builds a clock-in/out event, measuring location (nil when the
+// client sent none) against the schedule's geofence. A device time, when
+// given, becomes the event time once it passes the configured bounds.
+func (h *ScheduleHandler) clockEvent(schedule *models.Schedule, eventType models.VisitEventType, deviceTime *time.Time, location *models.Geolocation) (models.VisitEvent, error) {
+	received := time.Now()
+	event := models.VisitEvent{
+		ScheduleID:           schedule.ID,
+		Type:                 eventType,
+		OccurredAt:           received,
+		ReceivedAt:           received,
+		Location:             location,
+		GeofenceRadiusMeters: schedule.GeofenceRadius(h.cfg.GeofenceRadiusMeters),
+	}
+	if deviceTime != nil {
+		bounds := models.ClockTimeBounds{
+			MaxSkew:    h.cfg.MaxClockSkew,
+			MaxAge:     h.cfg.MaxOfflineAge,
+			EarlyGrace: h.cfg.EarlyClockInGrace,
+		}
+		if err := schedule.CheckClockTime(eventType, *deviceTime, received, bounds); err != nil {
+			return event, err
+		}
+		event.OccurredAt = *deviceTime
+		event.DeviceTime = deviceTime
+	}
+	if location != nil {
+		distance := models.DistanceMeters(*location, schedule.Location.Coordinates)
+		event.DistanceMeters = &distance
+	}
+	return event, nil
+}
+
+// parseDeviceTime reads an optional RFC 3339 timestamp sent by a device.
+func parseDeviceTime(raw string) (*time.Time, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, errInvalidTimestamp
+	}
+	return &t, nil
+}
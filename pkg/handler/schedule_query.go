@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/models"
+)
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 500
+)
+
+// scheduleSortKeys are the orders GET /api/schedules can return. Each maps a
+// schedule to a string that sorts in that order; ties are broken by ID so
+// that every schedule has a unique position for cursors to point at.
+var scheduleSortKeys = map[string]func(*models.Schedule) string{
+	"shiftStart": func(s *models.Schedule) string {
+		return s.ShiftStart.UTC().Format("2006-01-02T15:04:05.000000000Z")
+	},
+	"clientName":  func(s *models.Schedule) string { return strings.ToLower(s.ClientName) },
+	"serviceName": func(s *models.Schedule) string { return strings.ToLower(s.ServiceName) },
+	"status":      func(s *models.Schedule) string { return string(s.Status) },
+}
+
+// scheduleQuery is a parsed listing request: filters, order and page.
+type scheduleQuery struct {
+	statuses map[models.VisitStatus]bool
+	clientID string
+	text     string
+
+	sortKey    string
+	descending bool
+	limit      int
+	after      *scheduleCursor
+}
+
+// scheduleCursor marks the last schedule of a page. It holds the sort value
+// rather than an offset, so the next page starts in the right place even
+// when schedules are added or removed in between.
+type scheduleCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+func (cur scheduleCursor) encode() string {
+	data, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*scheduleCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, badRequest("Invalid cursor")
+	}
+	var cur scheduleCursor
+	if err := json.Unmarshal(data, &cur); err != nil || cur.ID == "" {
+		return nil, badRequest("Invalid cursor")
+	}
+	return &cur, nil
+}
+
+// parseScheduleQuery reads the status, clientId, q, sort, limit and cursor
+// query parameters.
+func parseScheduleQuery(c *fiber.Ctx) (*scheduleQuery, error) {
+	q := &scheduleQuery{
+		clientID: c.Query("clientId"),
+		text:     strings.ToLower(strings.TrimSpace(c.Query("q"))),
+		sortKey:  "shiftStart",
+		limit:    defaultPageSize,
+	}
+
+	if statuses := c.Query("status"); statuses != "" {
+		q.statuses = make(map[models.VisitStatus]bool)
+		for _, status := range strings.Split(statuses, ",") {
+			status := models.VisitStatus(strings.TrimSpace(status))
+			if !status.Valid() {
+				return nil, badRequest(fmt.Sprintf("Unknown status %q", status))
+			}
+			q.statuses[status] = true
+		}
+	}
+
+	if sortBy := c.Query("sort"); sortBy != "" {
+		q.descending = strings.HasPrefix(sortBy, "-")
+		q.sortKey = strings.TrimPrefix(sortBy, "-")
+		if _, ok := scheduleSortKeys[q.sortKey]; !ok {
+			return nil, badRequest("sort must be one of shiftStart, clientName, serviceName or status, optionally prefixed with -")
+		}
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxPageSize {
+			return nil, badRequest(fmt.Sprintf("limit must be between 1 and %d", maxPageSize))
+		}
+		q.limit = n
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		cur, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cur.Sort != q.sortParam() {
+			return nil, badRequest("cursor was issued for a different sort")
+		}
+		q.after = cur
+	}
+	return q, nil
+}
+
+// sortParam is the sort as given in the query string.
+func (q *scheduleQuery) sortParam() string {
+	if q.descending {
+		return "-" + q.sortKey
+	}
+	return q.sortKey
+}
+
+// matches reports whether the schedule passes the filters.
+func (q *scheduleQuery) matches(s *models.Schedule) bool {
+	if q.statuses != nil && !q.statuses[s.Status] {
+		return false
+	}
+	if q.clientID != "" && s.ClientID != q.clientID {
+		return false
+	}
+	if q.text == "" {
+		return true
+	}
+	fields := []string{s.ClientName, s.ServiceName, s.ServiceNotes, s.Location.Address}
+	for _, task := range s.Tasks {
+		fields = append(fields, task.Name, task.Description)
+	}
+	for _, field := range fields {
+		if strings.Contains(strings.ToLower(field), q.text) {
+			return true
+		}
+	}
+	return false
+}
+
+// less orders two schedules by the sort key, then ID.
+func (q *scheduleQuery) less(aValue, aID, bValue, bID string) bool {
+	if aValue != bValue {
+		return (aValue < bValue) != q.descending
+	}
+	return (aID < bID) != q.descending
+}
+
+// page filters and sorts schedules and cuts out the page after the cursor.
+// It returns the page, how many schedules matched in all, and the cursor
+// for the next page ("" on the last one).
+func (q *scheduleQuery) page(schedules []*models.Schedule) (page []*models.Schedule, total int, next string) {
+	value := scheduleSortKeys[q.sortKey]
+	matched := make([]*models.Schedule, 0)
+	for _, schedule := range schedules {
+		if q.matches(schedule) {
+			matched = append(matched, schedule)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return q.less(value(matched[i]), matched[i].ID, value(matched[j]), matched[j].ID)
+	})
+
+	start := 0
+	if q.after != nil {
+		start = sort.Search(len(matched), func(i int) bool {
+			return q.less(q.after.Value, q.after.ID, value(matched[i]), matched[i].ID)
+		})
+	}
+	end := start + q.limit
+	if end >= len(matched) {
+		return matched[start:], len(matched), ""
+	}
+	last := matched[end-1]
+	return matched[start:end], len(matched), scheduleCursor{Sort: q.sortParam(), Value: value(last), ID: last.ID}.encode()
+}
+
+// setPageHeaders exposes the total match count and, when there is another
+// page, its cursor and link.
+func setPageHeaders(c *fiber.Ctx, total int, next string) {
+	c.Set("X-Total-Count", strconv.Itoa(total))
+	if next == "" {
+		return
+	}
+	c.Set("X-Next-Cursor", next)
+	params, _ := url.ParseQuery(string(c.Request().URI().QueryString()))
+	params.Set("cursor", next)
+	c.Set(fiber.HeaderLink, fmt.Sprintf(`<%s?%s>; rel="next"`, c.Path(), params.Encode()))
+}
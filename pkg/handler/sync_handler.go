@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/auth"
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/models"
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/store"
+)
+
+// maxSyncMutations caps a single sync batch; devices with a longer queue
+// send it in several requests.
+const maxSyncMutations = 500
+
+type SyncHandler struct {
+	store     store.Repository
+	schedules *ScheduleHandler
+	tasks     *TaskHandler
+}
+
+func NewSyncHandler(st store.Repository, schedules *ScheduleHandler, tasks *TaskHandler) *SyncHandler {
+	return &SyncHandler{store: st, schedules: schedules, tasks: tasks}
+}
+
+// Sync handles a batch of mutations queued by a device while offline.
+// @Summary      Sync offline mutations
+// @Description  Replays start-visit, end-visit and task-update mutations in order. Each mutation carries a client-generated idempotency key; a key that was already applied is reported as a duplicate and not applied again, so batches are safe to retry. One rejected mutation does not stop the rest of the batch.
+// @Tags         Sync
+// @Accept       json
+// @Produce      json
+// @Param        batch  body      models.SyncRequest  true  "Queued mutations"
+// @Success      200    {object}  models.SyncResponse
+// @Failure      400    {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/sync [post]
+func (h *SyncHandler) Sync(c *fiber.Ctx) error {
+	var req models.SyncRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+	if len(req.Mutations) > maxSyncMutations {
+		return &models.BadRequestError{
+			Reason:  "batch_too_large",
+			Message: fmt.Sprintf("A sync batch holds at most %d mutations", maxSyncMutations),
+		}
+	}
+
+	results := make([]models.SyncResult, 0, len(req.Mutations))
+	for _, mutation := range req.Mutations {
+		result := h.apply(CurrentPrincipal(c), mutation)
+		if result.Status == models.SyncRejected {
+			log.Printf("Sync from device %q rejected %s %s: %s", req.DeviceID, mutation.Type, mutation.IdempotencyKey, result.Code)
+		}
+		results = append(results, result)
+	}
+	return c.JSON(models.SyncResponse{Results: results})
+}
+
+// apply records a single mutation unless its key has been seen before.
+func (h *SyncHandler) apply(principal *models.Principal, mutation models.SyncMutation) models.SyncResult {
+	result := models.SyncResult{IdempotencyKey: mutation.IdempotencyKey}
+	if mutation.IdempotencyKey == "" {
+		return rejected(result, "missing_idempotency_key", "Every mutation needs an idempotency key")
+	}
+	eventType := mutation.Type.EventType()
+	if eventType == "" {
+		return rejected(result, "unknown_mutation_type", fmt.Sprintf("Unknown mutation type %q", mutation.Type))
+	}
+	// Checked before the key, so that callers cannot probe other
+	// caregivers' visits through duplicate reports.
+	if err := h.checkAssigned(principal, mutation); err != nil {
+		return rejectedFor(result, mutation, err)
+	}
+
+	if existing, err := h.store.GetEventByIdempotencyKey(mutation.IdempotencyKey); err == nil {
+		return h.duplicate(result, mutation, existing)
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return rejected(result, errorCode(err), "Internal server error")
+	}
+
+	var event *models.VisitEvent
+	var err error
+	switch mutation.Type {
+	case models.MutationStartVisit, models.MutationEndVisit:
+		_, event, err = h.schedules.recordClockEvent(mutation.ScheduleID, eventType, mutation.Timestamp, mutation.Location, "", mutation.IdempotencyKey)
+	case models.MutationUpdateTask:
+		_, event, err = h.tasks.markTask(mutation.ScheduleID, mutation.TaskID, models.UpdateTaskRequest{
+			Completed:          mutation.Completed,
+			NotCompletedReason: mutation.NotCompletedReason,
+			ReasonCode:         mutation.ReasonCode,
+			Measurements:       mutation.Measurements,
+		}, "", mutation.IdempotencyKey)
+	}
+
+	if errors.Is(err, store.ErrDuplicateKey) {
+		// Another request recorded the same key after our lookup.
+		existing, lookupErr := h.store.GetEventByIdempotencyKey(mutation.IdempotencyKey)
+		if lookupErr != nil {
+			return rejected(result, errorCode(lookupErr), "Internal server error")
+		}
+		return h.duplicate(result, mutation, existing)
+	}
+	if err != nil {
+		return rejectedFor(result, mutation, err)
+	}
+
+	result.Status = models.SyncApplied
+	result.EventID = event.ID
+	return result
+}
+
+// duplicate reports a key that was already applied, unless the device reused
+// it for a different action.
+func (h *SyncHandler) duplicate(result models.SyncResult, mutation models.SyncMutation, existing *models.VisitEvent) models.SyncResult {
+	sameTarget := existing.ScheduleID == mutation.ScheduleID
+	if mutation.Type == models.MutationUpdateTask {
+		sameTarget = existing.TaskID == mutation.TaskID
+	}
+	if existing.Type != mutation.Type.EventType() || !sameTarget {
+		return rejected(result, "idempotency_key_reused", "Idempotency key was already used for a different mutation")
+	}
+	result.Status = models.SyncDuplicate
+	result.EventID = existing.ID
+	return result
+}
+
+// checkAssigned fails unless the principal may act on the schedule the
+// mutation targets.
+func (h *SyncHandler) checkAssigned(principal *models.Principal, mutation models.SyncMutation) error {
+	if auth.Can(principal, auth.PermSchedulesAll) {
+		return nil
+	}
+	scheduleID := mutation.ScheduleID
+	if mutation.Type == models.MutationUpdateTask && scheduleID == "" {
+		owner, err := h.store.TaskSchedule(mutation.TaskID)
+		if err != nil {
+			return err
+		}
+		scheduleID = owner
+	}
+	return checkAssigned(h.store, principal, scheduleID)
+}
+
+// rejectedFor rejects a mutation with the code and detail the same failure
+// would get as a problem document.
+func rejectedFor(result models.SyncResult, mutation models.SyncMutation, err error) models.SyncResult {
+	problem := problemFor(err)
+	if problem.Status == fiber.StatusInternalServerError {
+		log.Printf("Error applying sync mutation %s: %v", mutation.IdempotencyKey, err)
+	}
+	return rejected(result, problem.Code, problem.Detail)
+}
+
+func rejected(result models.SyncResult, code, message string) models.SyncResult {
+	result.Status = models.SyncRejected
+	result.Code = code
+	result.Error = message
+	return result
+}
@@ -1,8 +1,11 @@
 package handler
 
 import (
+	"fmt"
 	"log"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 
@@ -11,64 +14,380 @@ import (
 )
 
 type TaskHandler struct {
-	store *store.Store
+	store store.Repository
 }
 
-func NewTaskHandler(st *store.Store) *TaskHandler {
+func NewTaskHandler(st store.Repository) *TaskHandler {
 	return &TaskHandler{store: st}
 }
 
-// UpdateTask handles updating the status of a specific task.
+// GetTaskOutcomeOptions handles fetching the coded values task outcomes use.
+// @Summary      Get task outcome codes
+// @Description  Lists the not-completed reason codes and the bounds of every measurement. Readings outside min..max are rejected; readings outside the normal range are recorded and flagged on the visit.
+// @Tags         Tasks
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  models.TaskOutcomeOptions
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/task-outcomes [get]
+func (h *TaskHandler) GetTaskOutcomeOptions(c *fiber.Ctx) error {
+	return c.JSON(models.TaskOutcomeOptions{
+		NotCompletedReasons: models.ListNotCompletedReasons(),
+		MeasurementRanges:   models.MeasurementRanges,
+	})
+}
+
+// GetScheduleTask handles fetching one task of a schedule.
+// @Summary      Get a task
+// @Description  Fetches a task, with its outcome, from the schedule that owns it
+// @Tags         Tasks
+// @Accept       json
+// @Produce      json
+// @Param        id       path      string  true  "Schedule ID"
+// @Param        taskId   path      int     true  "Task ID"
+// @Param        If-None-Match header string false "ETag from an earlier response"
+// @Success      200  {object}  models.Task
+// @Success      304
+// @Failure      400  {object}  models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/schedules/{id}/tasks/{taskId} [get]
+func (h *TaskHandler) GetScheduleTask(c *fiber.Ctx) error {
+	scheduleID := c.Params("id")
+	taskID, err := strconv.Atoi(c.Params("taskId"))
+	if err != nil {
+		return errInvalidTaskID
+	}
+	task, err := h.store.GetTask(scheduleID, taskID)
+	if err != nil {
+		return err
+	}
+	if notModified(c, task.Version) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+	return c.JSON(task)
+}
+
+// UpdateScheduleTask handles recording the outcome of a task.
 // @Summary      Update a task status
-// @Description  Updates the status of a specific task to "completed" or "not_completed".
+// @Description  Marks a task of the given schedule as completed, or not completed with a reason. Outcomes are only recorded while the visit is in progress; a visit not yet started, completed, missed or cancelled refuses them (409).
+// @Tags         Tasks
+// @Accept       json
+// @Produce      json
+// @Param        id       path      string  true  "Schedule ID"
+// @Param        taskId   path      int     true  "Task ID"
+// @Param        update   body models.UpdateTaskRequest true "Task Status Update"
+// @Param        If-Match header string false "ETag the change is based on"
+// @Success      200  {object}  models.Task
+// @Failure      400  {object}  models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      412  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/schedules/{id}/tasks/{taskId} [put]
+func (h *TaskHandler) UpdateScheduleTask(c *fiber.Ctx) error {
+	scheduleID := c.Params("id")
+	taskID, err := strconv.Atoi(c.Params("taskId"))
+	if err != nil {
+		return errInvalidTaskID
+	}
+
+	var req models.UpdateTaskRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+
+	updatedTask, _, err := h.markTask(scheduleID, taskID, req, c.Get(fiber.HeaderIfMatch), "")
+	if err != nil {
+		return err
+	}
+	setETag(c, updatedTask.Version)
+	return c.JSON(updatedTask)
+}
+
+// EditTask handles changing a task's name, description or required flag.
+// @Summary      Edit a task
+// @Description  Renames or re-describes a task, or marks it required or optional. Fields left out are not changed. A task that already has an outcome, or whose visit has clock data, is part of the visit record and cannot be edited (409).
+// @Tags         Tasks
+// @Accept       json
+// @Produce      json
+// @Param        id       path      string  true  "Schedule ID"
+// @Param        taskId   path      int     true  "Task ID"
+// @Param        task     body models.EditTaskRequest true "Task changes"
+// @Param        If-Match header string false "ETag the change is based on"
+// @Success      200  {object}  models.Task
+// @Failure      400  {object}  models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      412  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/schedules/{id}/tasks/{taskId} [patch]
+func (h *TaskHandler) EditTask(c *fiber.Ctx) error {
+	scheduleID := c.Params("id")
+	taskID, err := strconv.Atoi(c.Params("taskId"))
+	if err != nil {
+		return errInvalidTaskID
+	}
+
+	var req models.EditTaskRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+
+	unlock := h.store.LockSchedule(scheduleID)
+	defer unlock()
+	task, err := h.store.GetTask(scheduleID, taskID)
+	if err != nil {
+		return err
+	}
+	if err := checkIfMatch(c.Get(fiber.HeaderIfMatch), "task", strconv.Itoa(taskID), task.Version); err != nil {
+		return err
+	}
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	if field := editedFieldIn(req); field != "" {
+		if err := h.checkEditable(scheduleID, task, field); err != nil {
+			return err
+		}
+	}
+	if req.Name != nil {
+		task.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Description != nil {
+		task.Description = *req.Description
+	}
+	if req.Required != nil {
+		task.Required = *req.Required
+	}
+	if err := h.store.UpdateTask(scheduleID, task); err != nil {
+		return err
+	}
+
+	log.Printf("Edited task %d in schedule %s: %+v", taskID, scheduleID, *task)
+	setETag(c, task.Version)
+	return c.JSON(task)
+}
+
+// checkEditable fails with a TaskOutcomeError if the task already has an
+// outcome, or a ProtectedScheduleError if its visit has clock data: the task
+// as it was planned is then part of the visit record.
+func (h *TaskHandler) checkEditable(scheduleID string, task *models.Task, field string) error {
+	if task.HasOutcome() {
+		return &models.TaskOutcomeError{ScheduleID: scheduleID, TaskID: task.ID, Field: field}
+	}
+	schedule, err := h.store.GetSchedule(scheduleID)
+	if err != nil {
+		return err
+	}
+	protected, err := isProtected(h.store, schedule)
+	if err != nil {
+		return err
+	}
+	if protected {
+		return &models.ProtectedScheduleError{ScheduleID: scheduleID, Field: fmt.Sprintf("task %d %s", task.ID, field)}
+	}
+	return nil
+}
+
+// editedFieldIn returns the JSON name of the first field req changes, or ""
+// if it is empty.
+func editedFieldIn(req models.EditTaskRequest) string {
+	switch {
+	case req.Name != nil:
+		return "name"
+	case req.Description != nil:
+		return "description"
+	case req.Required != nil:
+		return "required"
+	}
+	return ""
+}
+
+// DeleteTask handles removing a task from a schedule.
+// @Summary      Delete a task
+// @Description  Removes a task from the schedule. Tasks that were already marked completed or not completed stay part of the visit record and cannot be deleted (409).
+// @Tags         Tasks
+// @Accept       json
+// @Produce      json
+// @Param        id       path      string  true  "Schedule ID"
+// @Param        taskId   path      int     true  "Task ID"
+// @Param        If-Match header string false "ETag the change is based on"
+// @Success      204
+// @Failure      400  {object}  models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      412  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/schedules/{id}/tasks/{taskId} [delete]
+func (h *TaskHandler) DeleteTask(c *fiber.Ctx) error {
+	scheduleID := c.Params("id")
+	taskID, err := strconv.Atoi(c.Params("taskId"))
+	if err != nil {
+		return errInvalidTaskID
+	}
+
+	unlock := h.store.LockSchedule(scheduleID)
+	defer unlock()
+	task, err := h.store.GetTask(scheduleID, taskID)
+	if err != nil {
+		return err
+	}
+	if err := checkIfMatch(c.Get(fiber.HeaderIfMatch), "task", strconv.Itoa(taskID), task.Version); err != nil {
+		return err
+	}
+	if task.HasOutcome() {
+		return &models.TaskOutcomeError{ScheduleID: scheduleID, TaskID: taskID}
+	}
+	if err := h.store.DeleteTask(scheduleID, taskID); err != nil {
+		return err
+	}
+
+	log.Printf("Deleted task %d from schedule %s", taskID, scheduleID)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ReorderTasks handles changing the order of a schedule's tasks.
+// @Summary      Reorder tasks
+// @Description  Puts the schedule's tasks in the given order. taskIds must list every task of the schedule exactly once.
+// @Tags         Tasks
+// @Accept       json
+// @Produce      json
+// @Param        id     path      string  true  "Schedule ID"
+// @Param        order  body models.ReorderTasksRequest true "Task order"
+// @Param        If-Match header string false "ETag the change is based on"
+// @Success      200  {array}   models.Task
+// @Failure      400  {object}  models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      412  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/schedules/{id}/tasks/order [put]
+func (h *TaskHandler) ReorderTasks(c *fiber.Ctx) error {
+	scheduleID := c.Params("id")
+
+	var req models.ReorderTasksRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+
+	unlock, err := lockSchedule(c, h.store, scheduleID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := h.store.ReorderTasks(scheduleID, req.TaskIDs); err != nil {
+		return err
+	}
+	tasks, err := h.store.ListTasks(scheduleID)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Reordered tasks of schedule %s: %v", scheduleID, req.TaskIDs)
+	return c.JSON(tasks)
+}
+
+// UpdateTask handles updating the status of a specific task.
+// @Summary      Update a task status (deprecated)
+// @Description  Updates the status of a specific task to "completed" or "not_completed". Task IDs are unique across schedules, so the owning schedule is looked up; prefer PUT /api/schedules/{id}/tasks/{taskId}.
 // @Tags         Tasks
 // @Accept       json
 // @Produce      json
 // @Param        taskId   path      int  true  "Task ID"
 // @Param        update   body models.UpdateTaskRequest true "Task Status Update"
+// @Param        If-Match header string false "ETag the change is based on"
 // @Success      200  {object}  models.Task
-// @Failure      400  {object}  map[string]string
-// @Failure      404  {object}  map[string]string
+// @Failure      400  {object}  models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Deprecated
+// @Failure      412  {object}  models.Problem
+// @Failure      403  {object}  models.Problem
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
 // @Router       /api/tasks/{taskId}/update [put]
 func (h *TaskHandler) UpdateTask(c *fiber.Ctx) error {
 	taskIDStr := c.Params("taskId")
 	taskID, err := strconv.Atoi(taskIDStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid task ID"})
+		return errInvalidTaskID
 	}
 
 	var req models.UpdateTaskRequest
 	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse request body"})
-	}
-
-	var updatedTask *models.Task
-	var found bool
-
-	for _, schedule := range h.store.Schedules {
-		for i, task := range schedule.Tasks {
-			if task.ID == taskID {
-				schedule.Tasks[i].Completed = req.Completed
-				if req.NotCompletedReason != "" {
-					schedule.Tasks[i].NotCompletedReason = req.NotCompletedReason
-				} else {
-					schedule.Tasks[i].NotCompletedReason = ""
-				}
-
-				updatedTask = &schedule.Tasks[i]
-				found = true
-				log.Printf("Task %d in Schedule %s updated: completed=%v, reason=%s", taskID, schedule.ID, schedule.Tasks[i].Completed, schedule.Tasks[i].NotCompletedReason)
-				break
-			}
-		}
-		if found {
-			break
-		}
+		return errInvalidBody
 	}
 
-	if !found {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Task not found in any schedule"})
+	updatedTask, _, err := h.markTask("", taskID, req, c.Get(fiber.HeaderIfMatch), "")
+	if err != nil {
+		return err
 	}
-
+	setETag(c, updatedTask.Version)
 	return c.JSON(updatedTask)
 }
+
+// markTask records a task outcome. It backs the task endpoints and the
+// matching offline sync mutation, which passes no ifMatch. An empty
+// scheduleID means the task's owner is looked up.
+func (h *TaskHandler) markTask(scheduleID string, taskID int, req models.UpdateTaskRequest, ifMatch, idempotencyKey string) (*models.Task, *models.VisitEvent, error) {
+	if err := req.Normalize(); err != nil {
+		return nil, nil, err
+	}
+	if scheduleID == "" {
+		owner, err := h.store.TaskSchedule(taskID)
+		if err != nil {
+			return nil, nil, err
+		}
+		scheduleID = owner
+	}
+	unlock := h.store.LockSchedule(scheduleID)
+	defer unlock()
+	task, err := h.store.GetTask(scheduleID, taskID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkIfMatch(ifMatch, "task", strconv.Itoa(taskID), task.Version); err != nil {
+		return nil, nil, err
+	}
+	schedule, err := h.store.GetSchedule(scheduleID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !schedule.Status.AcceptsTaskOutcomes() {
+		return nil, nil, &models.VisitNotInProgressError{ScheduleID: scheduleID, Status: schedule.Status}
+	}
+
+	event := models.VisitEvent{
+		ScheduleID:     scheduleID,
+		Type:           models.EventTaskMarked,
+		OccurredAt:     time.Now(),
+		IdempotencyKey: idempotencyKey,
+		TaskID:         taskID,
+		Completed:      req.Completed,
+
+		NotCompletedReason: req.NotCompletedReason,
+		ReasonCode:         req.ReasonCode,
+		Measurements:       req.Measurements,
+	}
+	if err := h.store.AppendEvent(&event); err != nil {
+		return nil, nil, err
+	}
+	updatedTask, err := h.store.GetTask(scheduleID, taskID)
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Printf("Task %d in Schedule %s updated: completed=%v, reason=%s", taskID, scheduleID, updatedTask.Completed, updatedTask.NotCompletedReason)
+	return updatedTask, &event, nil
+}
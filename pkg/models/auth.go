@@ -0,0 +1,105 @@
+package models
+
+import "time"
+
+// Role decides what a user may do.
+type Role string
+
+const (
+	RoleCaregiver   Role = "caregiver"
+	RoleCoordinator Role = "coordinator"
+	RoleAdmin       Role = "admin"
+)
+
+// User is an account that can log in to the API.
+type User struct {
+	ID           string `json:"id" example:"u-coordinator"`
+	Username     string `json:"username" example:"coordinator"`
+	PasswordHash string `json:"-"`
+	Role         Role   `json:"role" example:"coordinator"`
+	// CaregiverID links a caregiver's account to their caregiver record.
+	CaregiverID string `json:"caregiverId,omitempty" example:"1"`
+}
+
+// Principal returns the identity the user's access tokens carry.
+func (u *User) Principal() Principal {
+	return Principal{UserID: u.ID, Username: u.Username, Role: u.Role, CaregiverID: u.CaregiverID}
+}
+
+// Principal is the authenticated caller of a request, as read from its
+// access token or API key.
+type Principal struct {
+	UserID      string `json:"userId,omitempty" example:"u-coordinator"`
+	Username    string `json:"username,omitempty" example:"coordinator"`
+	Role        Role   `json:"role,omitempty" example:"coordinator"`
+	CaregiverID string `json:"caregiverId,omitempty" example:"1"`
+	// APIKeyID is set when the caller used an API key; it has no role and
+	// may do only what the key's Scopes allow.
+	APIKeyID string   `json:"apiKeyId,omitempty"`
+	Scopes   []string `json:"scopes,omitempty"`
+}
+
+// RefreshToken is the server-side record of a refresh token. Only a hash of
+// the token is kept; the token itself is known to the client alone.
+type RefreshToken struct {
+	ID     string
+	UserID string
+	// Hash is the hex SHA-256 of the token.
+	Hash      string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	// RevokedAt is set once the token was used or logged out.
+	RevokedAt *time.Time
+	// ReplacedBy is the ID of the token issued when this one was used.
+	ReplacedBy string
+}
+
+// APIKey lets an unattended integration call the API. Only a hash of the key
+// is kept; the key itself is shown once, when it is created.
+type APIKey struct {
+	ID   string `json:"id" example:"4b8f2c1e-7a3d-4f6b-9e2a-1c5d8f0b3a7e"`
+	Name string `json:"name" example:"Payroll export"`
+	// Prefix is the start of the key, to tell keys apart.
+	Prefix     string     `json:"prefix" example:"evv_3f9a1c2b"`
+	Hash       string     `json:"-"`
+	Scopes     []string   `json:"scopes" example:"schedules:read"`
+	CreatedBy  string     `json:"createdBy" example:"u-admin"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+// CreateAPIKeyRequest is the body for creating an API key.
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" example:"Payroll export"`
+	Scopes []string `json:"scopes" example:"schedules:read"`
+}
+
+// CreatedAPIKey is a new API key together with the key itself.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key" example:"evv_3f9a1c2b_Jx0q5mZ2c4b7t9v1w3y5A7C9E1G3I5K7M9O1Q3S5U7W"`
+}
+
+// LoginRequest is the body for logging in.
+type LoginRequest struct {
+	Username string `json:"username" example:"coordinator"`
+	Password string `json:"password" example:"coordinator-demo"`
+}
+
+// RefreshRequest is the body for exchanging or revoking a refresh token.
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// TokenResponse carries a new access token and the refresh token to get the
+// next one with.
+type TokenResponse struct {
+	AccessToken string `json:"accessToken"`
+	TokenType   string `json:"tokenType" example:"Bearer"`
+	// ExpiresIn is the lifetime of the access token in seconds.
+	ExpiresIn        int       `json:"expiresIn" example:"900"`
+	RefreshToken     string    `json:"refreshToken"`
+	RefreshExpiresAt time.Time `json:"refreshExpiresAt"`
+	Principal        Principal `json:"principal"`
+}
@@ -0,0 +1,57 @@
+package models
+
+// TaskTemplate is a reusable task from the agency's catalog, identified by
+// a short code such as "MED".
+type TaskTemplate struct {
+	Code        string `json:"code" example:"MED"`
+	Name        string `json:"name" example:"Give medication"`
+	Description string `json:"description" example:"Administer medication as prescribed."`
+	Category    string `json:"category" example:"medication"`
+	Required    bool   `json:"required"`
+}
+
+type TaskTemplateRequest struct {
+	// Code is only read on create; it cannot be changed afterwards.
+	Code        string `json:"code,omitempty" example:"MED"`
+	Name        string `json:"name" example:"Give medication"`
+	Description string `json:"description" example:"Administer medication as prescribed."`
+	Category    string `json:"category" example:"medication"`
+	Required    bool   `json:"required"`
+}
+
+// CarePlan lists the tasks every visit to a client starts with.
+type CarePlan struct {
+	ClientID string         `json:"clientId" example:"1"`
+	Tasks    []CarePlanTask `json:"tasks"`
+}
+
+// CarePlanTask puts a template on a care plan. Description, when set,
+// replaces the template's with instructions for this client; Required
+// overrides the template's flag.
+type CarePlanTask struct {
+	TemplateCode string `json:"templateCode" example:"MED"`
+	Description  string `json:"description,omitempty" example:"Metformin 500 mg with breakfast."`
+	Required     *bool  `json:"required,omitempty"`
+}
+
+type CarePlanRequest struct {
+	Tasks []CarePlanTask `json:"tasks"`
+}
+
+// Task builds the schedule task for an item of a care plan, from its
+// template.
+func (t CarePlanTask) Task(template *TaskTemplate) Task {
+	task := Task{
+		Name:         template.Name,
+		Description:  template.Description,
+		Required:     template.Required,
+		TemplateCode: template.Code,
+	}
+	if t.Description != "" {
+		task.Description = t.Description
+	}
+	if t.Required != nil {
+		task.Required = *t.Required
+	}
+	return task
+}
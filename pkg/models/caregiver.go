@@ -0,0 +1,22 @@
+package models
+
+// Caregiver is the person who delivers visits. Schedules are assigned to a
+// caregiver through Schedule.CaregiverID.
+type Caregiver struct {
+	ID   string `json:"id" example:"1"`
+	Name string `json:"name" example:"Sarah Lee"`
+	// Credentials lists the caregiver's certifications, e.g. "HHA" or "CNA".
+	Credentials []string `json:"credentials" example:"HHA,CPR"`
+	Phone       string   `json:"phone" example:"+1 555 987 6543"`
+	// TimeZone (IANA) is where the caregiver works, for reading "today" and
+	// date ranges in their listings. Empty means the agency's zone.
+	TimeZone string `json:"timeZone,omitempty" example:"America/Chicago"`
+}
+
+// CaregiverRequest is the body for creating or replacing a caregiver.
+type CaregiverRequest struct {
+	Name        string   `json:"name" example:"Sarah Lee"`
+	Credentials []string `json:"credentials" example:"HHA,CPR"`
+	Phone       string   `json:"phone" example:"+1 555 987 6543"`
+	TimeZone    string   `json:"timeZone,omitempty" example:"America/Chicago"`
+}
@@ -0,0 +1,40 @@
+package models
+
+// Client is the care recipient visits are delivered to. Schedules reference
+// a client through Schedule.ClientID and carry a copy of the client's name,
+// contact details and location, refreshed whenever the client changes.
+type Client struct {
+	ID       string        `json:"id" example:"1"`
+	Name     string        `json:"name" example:"Melisa Adam"`
+	Contact  ClientContact `json:"contact"`
+	Location Location      `json:"location"`
+	// EmergencyContacts are the people to call about the client, in order.
+	EmergencyContacts []EmergencyContact `json:"emergencyContacts"`
+	MedicaidID        string             `json:"medicaidId,omitempty" example:"NY12345678"`
+	CareNotes         string             `json:"careNotes,omitempty" example:"Uses a walker; keep hallways clear."`
+}
+
+type EmergencyContact struct {
+	Name         string `json:"name" example:"Tom Adam"`
+	Relationship string `json:"relationship" example:"Son"`
+	Phone        string `json:"phone" example:"+1 555 222 3333"`
+}
+
+// ClientRequest is the body for creating or replacing a client.
+type ClientRequest struct {
+	Name              string             `json:"name" example:"Melisa Adam"`
+	Contact           ClientContact      `json:"contact"`
+	Location          Location           `json:"location"`
+	EmergencyContacts []EmergencyContact `json:"emergencyContacts"`
+	MedicaidID        string             `json:"medicaidId,omitempty" example:"NY12345678"`
+	CareNotes         string             `json:"careNotes,omitempty" example:"Uses a walker; keep hallways clear."`
+}
+
+// SetClient points the schedule at client and copies the client's details
+// onto it.
+func (s *Schedule) SetClient(client *Client) {
+	s.ClientID = client.ID
+	s.ClientName = client.Name
+	s.ClientContact = client.Contact
+	s.Location = client.Location
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// ClockTimeBounds limit how far a device-supplied clock time may stray from
+// the server clock and from the shift.
+type ClockTimeBounds struct {
+	// MaxSkew is how far ahead of the server a device clock may run.
+	MaxSkew time.Duration
+	// MaxAge is the oldest queued offline event still accepted.
+	MaxAge time.Duration
+	// EarlyGrace is how long before the shift starts a clock event may be.
+	EarlyGrace time.Duration
+}
+
+// ClockTimeError reports a device-supplied clock time outside the bounds.
+type ClockTimeError struct {
+	Reason  string
+	Message string
+}
+
+func (e *ClockTimeError) Error() string {
+	return e.Message
+}
+
+// Code is the machine-readable reason returned to API clients.
+func (e *ClockTimeError) Code() string {
+	return e.Reason
+}
+
+// CheckClockTime validates the device time of a clock event received by
+// the server at received.
+func (s *Schedule) CheckClockTime(eventType VisitEventType, device, received time.Time, bounds ClockTimeBounds) error {
+	if device.After(received.Add(bounds.MaxSkew)) {
+		return &ClockTimeError{
+			Reason:  "timestamp_in_future",
+			Message: fmt.Sprintf("timestamp %s is ahead of the server clock by more than %s", device.Format(time.RFC3339), bounds.MaxSkew),
+		}
+	}
+	if device.Before(received.Add(-bounds.MaxAge)) {
+		return &ClockTimeError{
+			Reason:  "timestamp_too_old",
+			Message: fmt.Sprintf("timestamp %s is older than %s", device.Format(time.RFC3339), bounds.MaxAge),
+		}
+	}
+	// Shifts whose legacy fields do not parse are not bounded here.
+	if start, _, err := s.ShiftWindow(); err == nil && device.Before(start.Add(-bounds.EarlyGrace)) {
+		return &ClockTimeError{
+			Reason:  "timestamp_before_shift",
+			Message: fmt.Sprintf("timestamp %s is more than %s before the shift starts at %s", device.Format(time.RFC3339), bounds.EarlyGrace, start.Format(time.RFC3339)),
+		}
+	}
+	if eventType == EventVisitEnded && s.ClockInTime != nil && device.Before(*s.ClockInTime) {
+		return &ClockTimeError{
+			Reason:  "timestamp_before_clock_in",
+			Message: fmt.Sprintf("timestamp %s is before the clock-in at %s", device.Format(time.RFC3339), s.ClockInTime.Format(time.RFC3339)),
+		}
+	}
+	return nil
+}
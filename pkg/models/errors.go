@@ -0,0 +1,102 @@
+package models
+
+import "fmt"
+
+// The errors in this file are the generic kinds of failure the API reports;
+// the handler package renders each as a problem document with its Code.
+// Failures specific to one domain rule, like a TransitionError, have their
+// own types next to the rule.
+
+// NotFoundError reports a resource that does not exist.
+type NotFoundError struct {
+	// Resource names the kind of resource, e.g. "schedule".
+	Resource string
+	ID       string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s %s not found", e.Resource, e.ID)
+}
+
+// Code is the machine-readable reason returned to API clients.
+func (e *NotFoundError) Code() string {
+	return "not_found"
+}
+
+// ConflictError reports a request that clashes with the current state of a
+// resource.
+type ConflictError struct {
+	// Reason is the machine-readable reason, e.g. "in_use".
+	Reason  string
+	Message string
+}
+
+func (e *ConflictError) Error() string { return e.Message }
+
+// Code is the machine-readable reason returned to API clients.
+func (e *ConflictError) Code() string {
+	return e.Reason
+}
+
+// BadRequestError reports a request that cannot be understood, such as a
+// body that is not JSON or a malformed path or query parameter. Problems
+// with the fields of a well-formed body are a ValidationError.
+type BadRequestError struct {
+	// Reason is the machine-readable reason, e.g. "invalid_body".
+	Reason  string
+	Message string
+}
+
+func (e *BadRequestError) Error() string { return e.Message }
+
+// Code is the machine-readable reason returned to API clients.
+func (e *BadRequestError) Code() string {
+	return e.Reason
+}
+
+// UnauthorizedError reports a request without valid credentials.
+type UnauthorizedError struct {
+	// Reason is the machine-readable reason, e.g. "token_expired".
+	Reason  string
+	Message string
+}
+
+func (e *UnauthorizedError) Error() string { return e.Message }
+
+// Code is the machine-readable reason returned to API clients.
+func (e *UnauthorizedError) Code() string {
+	return e.Reason
+}
+
+// ForbiddenError reports a request the caller is not allowed to make.
+type ForbiddenError struct {
+	// Permission is the permission the caller lacks.
+	Permission string
+}
+
+func (e *ForbiddenError) Error() string {
+	return fmt.Sprintf("missing permission %s", e.Permission)
+}
+
+// Code is the machine-readable reason returned to API clients.
+func (e *ForbiddenError) Code() string {
+	return "forbidden"
+}
+
+// PreconditionFailedError reports a conditional request (If-Match) made
+// against a version of a resource that is no longer current.
+type PreconditionFailedError struct {
+	Resource string
+	ID       string
+	// Version is the current version.
+	Version int64
+}
+
+func (e *PreconditionFailedError) Error() string {
+	return fmt.Sprintf("%s %s has changed; it is now at version %d", e.Resource, e.ID, e.Version)
+}
+
+// Code is the machine-readable reason returned to API clients.
+func (e *PreconditionFailedError) Code() string {
+	return "precondition_failed"
+}
@@ -0,0 +1,179 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+type VisitEventType string
+
+const (
+	EventVisitStarted     VisitEventType = "VisitStarted"
+	EventVisitEnded       VisitEventType = "VisitEnded"
+	EventClockedIn        VisitEventType = "ClockedIn"
+	EventClockInCancelled VisitEventType = "ClockInCancelled"
+	EventVisitMissed      VisitEventType = "VisitMissed"
+	EventVisitCancelled   VisitEventType = "VisitCancelled"
+	EventTaskMarked       VisitEventType = "TaskMarked"
+)
+
+// TargetStatus returns the visit status an event moves to, or "" for events
+// that do not touch the visit lifecycle.
+func (t VisitEventType) TargetStatus() VisitStatus {
+	switch t {
+	case EventVisitStarted, EventClockedIn:
+		return StatusInProgress
+	case EventVisitEnded:
+		return StatusCompleted
+	case EventClockInCancelled:
+		return StatusScheduled
+	case EventVisitMissed:
+		return StatusMissed
+	case EventVisitCancelled:
+		return StatusCancelled
+	}
+	return ""
+}
+
+// IsClockEvent reports whether the event records a clock-in or clock-out,
+// whose location is verified against the schedule's geofence.
+func (t VisitEventType) IsClockEvent() bool {
+	return t == EventVisitStarted || t == EventClockedIn || t == EventVisitEnded
+}
+
+// VisitEvent is an immutable record of something that happened during a
+// visit. The visit state and task outcomes of a Schedule are never written
+// directly; they are rebuilt by replaying its events in order.
+type VisitEvent struct {
+	ID         int64          `json:"id" example:"1"`
+	ScheduleID string         `json:"scheduleId" example:"1"`
+	Type       VisitEventType `json:"type" example:"VisitStarted"`
+	// OccurredAt is when the event happened: the device's timestamp when
+	// one was sent, otherwise ReceivedAt.
+	OccurredAt time.Time  `json:"occurredAt"`
+	ReceivedAt time.Time  `json:"receivedAt"`
+	DeviceTime *time.Time `json:"deviceTime,omitempty"`
+	// IdempotencyKey is the client-generated key of the synced mutation that
+	// produced the event, if any. Keys are unique across the log.
+	IdempotencyKey string `json:"idempotencyKey,omitempty" example:"3f1c9a6e-5d7b-4e2a-9c1f-0b8d2e4a6c71"`
+
+	Location *Geolocation `json:"location,omitempty"`
+	// Clock events only: distance from the client and the radius it was
+	// checked against. DistanceMeters is nil when no location was sent.
+	DistanceMeters       *float64 `json:"distanceMeters,omitempty" example:"42.5"`
+	GeofenceRadiusMeters float64  `json:"geofenceRadiusMeters,omitempty" example:"150"`
+
+	// TaskMarked only.
+	TaskID             int           `json:"taskId,omitempty" example:"1"`
+	Completed          bool          `json:"completed,omitempty"`
+	NotCompletedReason string        `json:"notCompletedReason,omitempty"`
+	ReasonCode         string        `json:"reasonCode,omitempty"`
+	Measurements       *Measurements `json:"measurements,omitempty"`
+}
+
+// Apply folds a single event into the schedule, counting it in the
+// schedule's version (and the task's, for a task event). Events are assumed
+// to have passed CheckTransition when they were recorded.
+func (s *Schedule) Apply(event VisitEvent) {
+	s.Version++
+	if status := event.Type.TargetStatus(); status != "" {
+		s.Status = status
+	}
+	switch event.Type {
+	case EventVisitStarted, EventClockedIn:
+		at := event.OccurredAt
+		s.ClockInTime = &at
+		s.ClockInReceivedAt = event.receivedAt()
+		s.ClockInLocation = copyLocation(event.Location)
+		s.ClockInDistanceMeters = copyFloat(event.DistanceMeters)
+		s.addException(geofenceException(event, "clock_in", "Clock-in"))
+	case EventVisitEnded:
+		at := event.OccurredAt
+		s.ClockOutTime = &at
+		s.ClockOutReceivedAt = event.receivedAt()
+		s.ClockOutLocation = copyLocation(event.Location)
+		s.ClockOutDistanceMeters = copyFloat(event.DistanceMeters)
+		s.addException(geofenceException(event, "clock_out", "Clock-out"))
+	case EventClockInCancelled:
+		s.ClockInTime = nil
+		s.ClockInReceivedAt = nil
+		s.ClockInLocation = nil
+		s.ClockInDistanceMeters = nil
+		s.dropExceptions("clock_in_")
+	case EventTaskMarked:
+		for i := range s.Tasks {
+			if s.Tasks[i].ID == event.TaskID {
+				s.Tasks[i].Version++
+				s.Tasks[i].Completed = event.Completed
+				s.Tasks[i].NotCompletedReason = event.NotCompletedReason
+				s.Tasks[i].ReasonCode = event.ReasonCode
+				s.Tasks[i].Measurements = event.Measurements.clone()
+				s.dropTaskExceptions(event.TaskID)
+				s.Exceptions = append(s.Exceptions, measurementExceptions(s.Tasks[i], event)...)
+			}
+		}
+	}
+}
+
+// Replay applies events, in order, on top of the schedule as it was planned.
+func (s *Schedule) Replay(events []VisitEvent) {
+	for _, event := range events {
+		s.Apply(event)
+	}
+}
+
+// receivedAt falls back to OccurredAt for events logged before ReceivedAt
+// was recorded.
+func (e VisitEvent) receivedAt() *time.Time {
+	at := e.ReceivedAt
+	if at.IsZero() {
+		at = e.OccurredAt
+	}
+	return &at
+}
+
+func (s *Schedule) addException(exception *VisitException) {
+	if exception != nil {
+		s.Exceptions = append(s.Exceptions, *exception)
+	}
+}
+
+// dropExceptions removes the exceptions whose code starts with prefix, e.g.
+// those raised by a clock-in that has since been cancelled.
+func (s *Schedule) dropExceptions(prefix string) {
+	kept := s.Exceptions[:0:0]
+	for _, exception := range s.Exceptions {
+		if !strings.HasPrefix(exception.Code, prefix) {
+			kept = append(kept, exception)
+		}
+	}
+	s.Exceptions = kept
+}
+
+// dropTaskExceptions removes the exceptions raised by an earlier outcome of
+// the task.
+func (s *Schedule) dropTaskExceptions(taskID int) {
+	kept := s.Exceptions[:0:0]
+	for _, exception := range s.Exceptions {
+		if exception.TaskID != taskID {
+			kept = append(kept, exception)
+		}
+	}
+	s.Exceptions = kept
+}
+
+func copyFloat(v *float64) *float64 {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
+
+func copyLocation(location *Geolocation) *Geolocation {
+	if location == nil {
+		return nil
+	}
+	c := *location
+	return &c
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"fmt"
+	"math"
+)
+
+const earthRadiusMeters = 6371000.0
+
+// DistanceMeters returns the great-circle (haversine) distance between a and b.
+func DistanceMeters(a, b Geolocation) float64 {
+	lat1 := a.Latitude * math.Pi / 180
+	lat2 := b.Latitude * math.Pi / 180
+	dLat := lat2 - lat1
+	dLng := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// GeofenceRadius returns the schedule's own radius, or fallback if it has none.
+func (s *Schedule) GeofenceRadius(fallback float64) float64 {
+	if s.Location.GeofenceRadiusMeters > 0 {
+		return s.Location.GeofenceRadiusMeters
+	}
+	return fallback
+}
+
+// GeofenceError reports a clock event rejected for its location.
+type GeofenceError struct {
+	// DistanceMeters is nil when no location was sent.
+	DistanceMeters *float64
+	RadiusMeters   float64
+}
+
+func (e *GeofenceError) Error() string {
+	if e.DistanceMeters == nil {
+		return "clock event has no location to verify"
+	}
+	return fmt.Sprintf("clock event is %.0fm from the client, outside the %.0fm geofence", *e.DistanceMeters, e.RadiusMeters)
+}
+
+// Code is the machine-readable reason returned to API clients.
+func (e *GeofenceError) Code() string {
+	if e.DistanceMeters == nil {
+		return "location_missing"
+	}
+	return "outside_geofence"
+}
+
+// VisitException flags something about a visit that needs review, such as
+// a clock event recorded outside the geofence or a reading outside its
+// normal range.
+type VisitException struct {
+	Code    string `json:"code" example:"clock_in_outside_geofence"`
+	Message string `json:"message" example:"Clock-in recorded 420m from the client, outside the 150m geofence."`
+	EventID int64  `json:"eventId" example:"1"`
+	// TaskID and Field say which task reading an exception is about.
+	TaskID int    `json:"taskId,omitempty" example:"6"`
+	Field  string `json:"field,omitempty" example:"pulseBpm"`
+}
+
+// geofenceException returns the exception a clock event raises, if any.
+// prefix is "clock_in" or "clock_out".
+func geofenceException(event VisitEvent, prefix, label string) *VisitException {
+	if event.Location == nil || event.DistanceMeters == nil {
+		return &VisitException{
+			Code:    prefix + "_location_missing",
+			Message: label + " has no location to verify.",
+			EventID: event.ID,
+		}
+	}
+	if *event.DistanceMeters > event.GeofenceRadiusMeters {
+		return &VisitException{
+			Code: prefix + "_outside_geofence",
+			Message: fmt.Sprintf("%s recorded %.0fm from the client, outside the %.0fm geofence.",
+				label, *event.DistanceMeters, event.GeofenceRadiusMeters),
+			EventID: event.ID,
+		}
+	}
+	return nil
+}
@@ -8,12 +8,24 @@ type Geolocation struct {
 }
 
 type Task struct {
-	ID          int    `json:"id" example:"1"`
+	ID int `json:"id" example:"1"`
+	// Version counts the edits to the task and the times it was marked. It
+	// is served as the task's ETag.
+	Version     int64  `json:"version" example:"2"`
 	Name        string `json:"name" example:"Give medication"`
 	Description string `json:"description" example:"Administer morning pills with water."`
+	// Required tasks must be completed, or given a not-completed reason,
+	// before the visit can end.
+	Required bool `json:"required"`
+	// TemplateCode is the catalog template the task was copied from, if any.
+	TemplateCode string `json:"templateCode,omitempty" example:"MED"`
 
 	Completed          bool   `json:"completed"`
 	NotCompletedReason string `json:"notCompletedReason,omitempty" example:"Client refused medication."`
+	// ReasonCode is the coded reason a task was not completed, one of
+	// NotCompletedReasons.
+	ReasonCode   string        `json:"reasonCode,omitempty" example:"client_refused"`
+	Measurements *Measurements `json:"measurements,omitempty"`
 }
 
 type ClientContact struct {
@@ -24,43 +36,140 @@ type ClientContact struct {
 type Location struct {
 	Address     string      `json:"address" example:"123 Main St"`
 	Coordinates Geolocation `json:"coordinates"`
+	// GeofenceRadiusMeters overrides the configured default radius for
+	// clock-in/out verification at this address.
+	GeofenceRadiusMeters float64 `json:"geofenceRadiusMeters,omitempty" example:"150"`
 }
 
 type Schedule struct {
-	ID            string        `json:"id" example:"1"`
+	ID string `json:"id" example:"1"`
+	// Version counts the changes to the schedule: edits to its plan or
+	// tasks, and every visit event recorded against it. It is served as the
+	// schedule's ETag.
+	Version       int64         `json:"version" example:"3"`
+	CaregiverID   string        `json:"caregiverId,omitempty" example:"1"`
+	ClientID      string        `json:"clientId,omitempty" example:"1"`
 	ClientName    string        `json:"clientName" example:"Melisa Adam"`
 	ServiceName   string        `json:"serviceName" example:"Casa Grande Apartment"`
-	ShiftDate     string        `json:"shiftDate" example:"2025-01-15"`
-	ShiftTime     string        `json:"shiftTime" example:"09:00 - 10:00"`
-	AmOrPm        string        `json:"amOrPm" example:"AM"`        // "AM" or "PM"
-	Status        string        `json:"status" example:"scheduled"` // "scheduled", "in-progress", "completed", "missed", "cancelled"
 	Tasks         []Task        `json:"tasks"`
 	ClientContact ClientContact `json:"clientContact"`
 	ServiceNotes  string        `json:"serviceNotes,omitempty" example:"Client may be a bit groggy."`
 
-	ClockInTime      *time.Time   `json:"clockInTime,omitempty"`
-	ClockOutTime     *time.Time   `json:"clockOutTime,omitempty"`
-	ClockInLocation  *Geolocation `json:"clockInLocation,omitempty"`
-	ClockOutLocation *Geolocation `json:"clockOutLocation,omitempty"`
-	Location         Location     `json:"location"`
+	// ShiftStart and ShiftEnd are the planned instants, rendered in TimeZone
+	// (an IANA name). Set them with SetShift or SetLegacyShift.
+	ShiftStart time.Time `json:"shiftStart" example:"2025-01-15T09:00:00-06:00"`
+	ShiftEnd   time.Time `json:"shiftEnd" example:"2025-01-15T10:00:00-06:00"`
+	TimeZone   string    `json:"timeZone" example:"America/Chicago"`
+	// ShiftDate, ShiftTime and AmOrPm are the legacy form of the shift,
+	// derived from ShiftStart and ShiftEnd for older clients.
+	ShiftDate string `json:"shiftDate" example:"2025-01-15"`
+	ShiftTime string `json:"shiftTime" example:"09:00 - 10:00"`
+	AmOrPm    string `json:"amOrPm" example:"AM"` // "AM" or "PM"
+
+	// RecurrenceID and OccurrenceDate are set on schedules generated from a
+	// Recurrence. OccurrenceDate keeps the generated date when the shift is
+	// moved.
+	RecurrenceID   string `json:"recurrenceId,omitempty" example:"6f0b8c1e-2d4a-4c8e-9a7b-1e2f3a4b5c6d"`
+	OccurrenceDate string `json:"occurrenceDate,omitempty" example:"2025-01-15"`
+
+	Visit
+	Location Location `json:"location"`
+}
+
+// Visit holds the status and clock-in/out data recorded against a schedule.
+// It is embedded in Schedule, so its fields render at the top level.
+type Visit struct {
+	Status VisitStatus `json:"status" example:"scheduled" enums:"scheduled,in_progress,completed,missed,cancelled"`
+
+	// Clock times as reported by the device (or the server when the device
+	// sent none), and when the server actually received them.
+	ClockInTime        *time.Time   `json:"clockInTime,omitempty"`
+	ClockOutTime       *time.Time   `json:"clockOutTime,omitempty"`
+	ClockInReceivedAt  *time.Time   `json:"clockInReceivedAt,omitempty"`
+	ClockOutReceivedAt *time.Time   `json:"clockOutReceivedAt,omitempty"`
+	ClockInLocation    *Geolocation `json:"clockInLocation,omitempty"`
+	ClockOutLocation   *Geolocation `json:"clockOutLocation,omitempty"`
+
+	// Distance of each clock event from the client's coordinates.
+	ClockInDistanceMeters  *float64         `json:"clockInDistanceMeters,omitempty" example:"42.5"`
+	ClockOutDistanceMeters *float64         `json:"clockOutDistanceMeters,omitempty" example:"12.1"`
+	Exceptions             []VisitException `json:"exceptions,omitempty"`
 }
 
 type StartVisitRequest struct {
-	Timestamp string      `json:"timestamp"`
+	// Timestamp is the device's RFC 3339 clock time, for events queued
+	// offline. Optional; the server time is used when empty.
+	Timestamp string      `json:"timestamp" example:"2025-01-15T09:02:00-05:00"`
 	Location  Geolocation `json:"location"`
 }
 
 type EndVisitRequest struct {
-	Timestamp string      `json:"timestamp"`
+	// Timestamp is the device's RFC 3339 clock time, for events queued
+	// offline. Optional; the server time is used when empty.
+	Timestamp string      `json:"timestamp" example:"2025-01-15T10:05:00-05:00"`
 	Location  Geolocation `json:"location"`
 }
 
+// UpdateTaskRequest records a task outcome: completed, optionally with
+// measurements, or not completed with a reasonCode (notCompletedReason adds
+// free text, and is required for "other").
 type UpdateTaskRequest struct {
-	Completed          bool   `json:"completed"`
-	NotCompletedReason string `json:"notCompletedReason,omitempty"`
+	Completed          bool          `json:"completed"`
+	NotCompletedReason string        `json:"notCompletedReason,omitempty"`
+	ReasonCode         string        `json:"reasonCode,omitempty" example:"client_refused"`
+	Measurements       *Measurements `json:"measurements,omitempty"`
 }
 
 type AddTaskRequest struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
+	Required    bool   `json:"required"`
+}
+
+// EditTaskRequest changes a task's plan. Absent fields are left as they are;
+// the outcome is recorded separately, with UpdateTaskRequest.
+type EditTaskRequest struct {
+	Name        *string `json:"name,omitempty" example:"Give medication"`
+	Description *string `json:"description,omitempty"`
+	Required    *bool   `json:"required,omitempty"`
+}
+
+// ReorderTasksRequest lists every task of a schedule in its new order.
+type ReorderTasksRequest struct {
+	TaskIDs []int `json:"taskIds" example:"2,1"`
+}
+
+// CreateScheduleRequest is the body for booking a visit. The client's name,
+// contact details and location are copied from ClientID. The shift is given
+// either as RFC 3339 shiftStart and shiftEnd or in the legacy
+// shiftDate/shiftTime/amOrPm form; timeZone defaults to the server's.
+type CreateScheduleRequest struct {
+	ClientID     string           `json:"clientId" example:"1"`
+	CaregiverID  string           `json:"caregiverId,omitempty" example:"1"`
+	ServiceName  string           `json:"serviceName" example:"Casa Grande Apartment"`
+	ShiftStart   string           `json:"shiftStart,omitempty" example:"2025-01-15T09:00:00-06:00"`
+	ShiftEnd     string           `json:"shiftEnd,omitempty" example:"2025-01-15T10:00:00-06:00"`
+	TimeZone     string           `json:"timeZone,omitempty" example:"America/Chicago"`
+	ShiftDate    string           `json:"shiftDate,omitempty" example:"2025-01-15"`
+	ShiftTime    string           `json:"shiftTime,omitempty" example:"09:00 - 10:00"`
+	AmOrPm       string           `json:"amOrPm,omitempty" example:"AM"`
+	ServiceNotes string           `json:"serviceNotes,omitempty" example:"Client may be a bit groggy."`
+	Tasks        []AddTaskRequest `json:"tasks"`
+}
+
+// UpdateScheduleRequest is the body for PATCH /api/schedules/{id}. Only the
+// fields present are changed; an empty caregiverId unassigns the visit.
+// Legacy shift fields are read in the schedule's zone (or the new timeZone);
+// a timeZone on its own keeps the shift's instants and re-renders them.
+type UpdateScheduleRequest struct {
+	ClientID     *string `json:"clientId,omitempty" example:"1"`
+	CaregiverID  *string `json:"caregiverId,omitempty" example:"2"`
+	ServiceName  *string `json:"serviceName,omitempty" example:"Casa Grande Apartment"`
+	ShiftStart   *string `json:"shiftStart,omitempty" example:"2025-01-16T09:00:00-06:00"`
+	ShiftEnd     *string `json:"shiftEnd,omitempty" example:"2025-01-16T10:00:00-06:00"`
+	TimeZone     *string `json:"timeZone,omitempty" example:"America/Chicago"`
+	ShiftDate    *string `json:"shiftDate,omitempty" example:"2025-01-16"`
+	ShiftTime    *string `json:"shiftTime,omitempty" example:"09:00 - 10:00"`
+	AmOrPm       *string `json:"amOrPm,omitempty" example:"AM"`
+	ServiceNotes *string `json:"serviceNotes,omitempty" example:"Bring the new glucose meter."`
 }
@@ -0,0 +1,218 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Measurements are the readings taken while doing a task, such as vitals or
+// an insulin dose. Absent readings are nil.
+type Measurements struct {
+	SystolicBP   *float64 `json:"systolicBp,omitempty" example:"120"`
+	DiastolicBP  *float64 `json:"diastolicBp,omitempty" example:"80"`
+	PulseBPM     *float64 `json:"pulseBpm,omitempty" example:"72"`
+	GlucoseMgDL  *float64 `json:"glucoseMgDl,omitempty" example:"110"`
+	InsulinUnits *float64 `json:"insulinUnits,omitempty" example:"6"`
+}
+
+// MeasurementRange bounds one reading. Values outside Min..Max cannot be
+// right and are rejected; values outside NormalMin..NormalMax are recorded
+// and flagged for review.
+type MeasurementRange struct {
+	Field     string  `json:"field" example:"pulseBpm"`
+	Label     string  `json:"label" example:"Pulse"`
+	Unit      string  `json:"unit" example:"bpm"`
+	Min       float64 `json:"min" example:"20"`
+	Max       float64 `json:"max" example:"250"`
+	NormalMin float64 `json:"normalMin" example:"60"`
+	NormalMax float64 `json:"normalMax" example:"100"`
+}
+
+// MeasurementRanges lists the bounds of every reading, in display order.
+var MeasurementRanges = []MeasurementRange{
+	{Field: "systolicBp", Label: "Systolic blood pressure", Unit: "mmHg", Min: 50, Max: 300, NormalMin: 90, NormalMax: 140},
+	{Field: "diastolicBp", Label: "Diastolic blood pressure", Unit: "mmHg", Min: 30, Max: 200, NormalMin: 60, NormalMax: 90},
+	{Field: "pulseBpm", Label: "Pulse", Unit: "bpm", Min: 20, Max: 250, NormalMin: 60, NormalMax: 100},
+	{Field: "glucoseMgDl", Label: "Blood glucose", Unit: "mg/dL", Min: 20, Max: 800, NormalMin: 70, NormalMax: 180},
+	// Doses depend on the prescription, so none is flagged.
+	{Field: "insulinUnits", Label: "Insulin", Unit: "units", Min: 0.5, Max: 100, NormalMin: 0.5, NormalMax: 100},
+}
+
+// readings pairs each range with the value given for it, if any.
+func (m *Measurements) readings() map[string]*float64 {
+	return map[string]*float64{
+		"systolicBp":   m.SystolicBP,
+		"diastolicBp":  m.DiastolicBP,
+		"pulseBpm":     m.PulseBPM,
+		"glucoseMgDl":  m.GlucoseMgDL,
+		"insulinUnits": m.InsulinUnits,
+	}
+}
+
+// Validate returns a *ValidationError for readings outside their possible
+// range, or a blood pressure that is incomplete or inverted.
+func (m *Measurements) Validate() error {
+	var errs fieldErrors
+	readings := m.readings()
+	for _, r := range MeasurementRanges {
+		v := readings[r.Field]
+		if v != nil && (*v < r.Min || *v > r.Max) {
+			errs.add(r.Field, "out_of_range", "%s must be between %g and %g %s", r.Label, r.Min, r.Max, r.Unit)
+		}
+	}
+	if (m.SystolicBP == nil) != (m.DiastolicBP == nil) {
+		field := "diastolicBp"
+		if m.SystolicBP == nil {
+			field = "systolicBp"
+		}
+		errs.add(field, "required", "Blood pressure needs both systolicBp and diastolicBp")
+	} else if m.SystolicBP != nil && *m.DiastolicBP >= *m.SystolicBP {
+		errs.add("diastolicBp", "invalid", "Diastolic blood pressure must be below systolic")
+	}
+	return errs.err()
+}
+
+// Empty reports whether no reading was given.
+func (m *Measurements) Empty() bool {
+	for _, v := range m.readings() {
+		if v != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// abnormal returns the ranges of the readings outside their normal range,
+// with the values.
+func (m *Measurements) abnormal() ([]MeasurementRange, []float64) {
+	readings := m.readings()
+	var ranges []MeasurementRange
+	var values []float64
+	for _, r := range MeasurementRanges {
+		if v := readings[r.Field]; v != nil && (*v < r.NormalMin || *v > r.NormalMax) {
+			ranges = append(ranges, r)
+			values = append(values, *v)
+		}
+	}
+	return ranges, values
+}
+
+func (m *Measurements) clone() *Measurements {
+	if m == nil {
+		return nil
+	}
+	return &Measurements{
+		SystolicBP:   copyFloat(m.SystolicBP),
+		DiastolicBP:  copyFloat(m.DiastolicBP),
+		PulseBPM:     copyFloat(m.PulseBPM),
+		GlucoseMgDL:  copyFloat(m.GlucoseMgDL),
+		InsulinUnits: copyFloat(m.InsulinUnits),
+	}
+}
+
+// NotCompletedReasons are the codes a task can be marked not completed
+// with, and their labels.
+var NotCompletedReasons = map[string]string{
+	"client_refused":       "Client refused",
+	"client_not_home":      "Client not home",
+	"client_asleep":        "Client asleep",
+	"client_hospitalized":  "Client hospitalized",
+	"supplies_unavailable": "Supplies unavailable",
+	"not_needed":           "Not needed today",
+	"unsafe":               "Unsafe to perform",
+	"other":                "Other",
+}
+
+// NotCompletedReason is an entry of NotCompletedReasons.
+type NotCompletedReason struct {
+	Code  string `json:"code" example:"client_refused"`
+	Label string `json:"label" example:"Client refused"`
+}
+
+// ListNotCompletedReasons returns NotCompletedReasons ordered by code.
+func ListNotCompletedReasons() []NotCompletedReason {
+	reasons := make([]NotCompletedReason, 0, len(NotCompletedReasons))
+	for code, label := range NotCompletedReasons {
+		reasons = append(reasons, NotCompletedReason{Code: code, Label: label})
+	}
+	sort.Slice(reasons, func(i, j int) bool { return reasons[i].Code < reasons[j].Code })
+	return reasons
+}
+
+// TaskOutcomeOptions lists the coded values a task outcome can use.
+type TaskOutcomeOptions struct {
+	NotCompletedReasons []NotCompletedReason `json:"notCompletedReasons"`
+	MeasurementRanges   []MeasurementRange   `json:"measurementRanges"`
+}
+
+// Normalize checks a task outcome and fills in what can be derived: a
+// free-text reason without a code is coded "other", and a code without text
+// gets its label as the reason. A task that is not completed needs a reason;
+// a completed one cannot have one. It returns a *ValidationError listing
+// every problem found.
+func (r *UpdateTaskRequest) Normalize() error {
+	var errs fieldErrors
+	r.NotCompletedReason = strings.TrimSpace(r.NotCompletedReason)
+	errs.text("notCompletedReason", r.NotCompletedReason, MaxReasonLength)
+	if r.Measurements != nil && r.Measurements.Empty() {
+		r.Measurements = nil
+	}
+
+	if r.Completed {
+		if r.ReasonCode != "" {
+			errs.add("reasonCode", "conflict", "A completed task cannot have a not-completed reason")
+		}
+		if r.NotCompletedReason != "" {
+			errs.add("notCompletedReason", "conflict", "A completed task cannot have a not-completed reason")
+		}
+		if r.Measurements != nil {
+			errs.nested("measurements", r.Measurements.Validate())
+		}
+		return errs.err()
+	}
+
+	if r.Measurements != nil {
+		errs.add("measurements", "conflict", "Measurements can only be recorded on a completed task")
+	}
+	if r.ReasonCode == "" && r.NotCompletedReason != "" {
+		r.ReasonCode = "other"
+	}
+	label, known := NotCompletedReasons[r.ReasonCode]
+	switch {
+	case r.ReasonCode == "":
+		errs.add("reasonCode", "required", "A reason is required when the task is not completed")
+	case !known:
+		errs.add("reasonCode", "invalid", "Unknown reason code %q", r.ReasonCode)
+	case r.NotCompletedReason == "" && r.ReasonCode == "other":
+		errs.add("notCompletedReason", "required", "Reason code \"other\" needs a notCompletedReason")
+	case r.NotCompletedReason == "":
+		r.NotCompletedReason = label
+	}
+	return errs.err()
+}
+
+// measurementExceptions flags the readings of a task outside their normal
+// range.
+func measurementExceptions(task Task, event VisitEvent) []VisitException {
+	if task.Measurements == nil {
+		return nil
+	}
+	ranges, values := task.Measurements.abnormal()
+	exceptions := make([]VisitException, 0, len(ranges))
+	for i, r := range ranges {
+		side := "above"
+		if values[i] < r.NormalMin {
+			side = "below"
+		}
+		exceptions = append(exceptions, VisitException{
+			Code: "measurement_out_of_range",
+			Message: fmt.Sprintf("%s: %s %g %s is %s the normal range %g-%g.",
+				task.Name, r.Label, values[i], r.Unit, side, r.NormalMin, r.NormalMax),
+			EventID: event.ID,
+			TaskID:  task.ID,
+			Field:   r.Field,
+		})
+	}
+	return exceptions
+}
@@ -0,0 +1,40 @@
+package models
+
+import "encoding/json"
+
+// ProblemContentType is the media type of a Problem.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details document, the body of every error
+// response. Code is stable and is what clients should branch on; Title and
+// Detail are for people.
+type Problem struct {
+	Type     string `json:"type" example:"/problems/not_found"`
+	Title    string `json:"title" example:"Not Found"`
+	Status   int    `json:"status" example:"404"`
+	Detail   string `json:"detail,omitempty" example:"Schedule 42 not found"`
+	Instance string `json:"instance,omitempty" example:"/api/schedules/42"`
+	Code     string `json:"code" example:"not_found"`
+	// Extensions are extra members for some codes, such as the fields of a
+	// validation_failed problem. They are rendered at the top level.
+	Extensions map[string]any `json:"-"`
+}
+
+// MarshalJSON renders the extensions alongside the standard members.
+func (p Problem) MarshalJSON() ([]byte, error) {
+	body := make(map[string]any, len(p.Extensions)+6)
+	for key, value := range p.Extensions {
+		body[key] = value
+	}
+	body["type"] = p.Type
+	body["title"] = p.Title
+	body["status"] = p.Status
+	body["code"] = p.Code
+	if p.Detail != "" {
+		body["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		body["instance"] = p.Instance
+	}
+	return json.Marshal(body)
+}
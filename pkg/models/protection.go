@@ -0,0 +1,47 @@
+package models
+
+import "fmt"
+
+// HasClockData reports whether a visit has been clocked into, in which case
+// its schedule is part of the EVV record and must not be deleted or
+// re-planned.
+func (v Visit) HasClockData() bool {
+	return v.ClockInTime != nil || v.ClockOutTime != nil ||
+		v.Status == StatusInProgress || v.Status == StatusCompleted
+}
+
+// ProtectedScheduleError reports a delete or destructive edit of a schedule
+// whose visit has clock data.
+type ProtectedScheduleError struct {
+	ScheduleID string
+	// Field is the field the edit tried to change, or "" for a delete.
+	Field string
+}
+
+func (e *ProtectedScheduleError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("schedule %s has clock data and cannot be deleted", e.ScheduleID)
+	}
+	return fmt.Sprintf("schedule %s has clock data; %s can no longer be changed", e.ScheduleID, e.Field)
+}
+
+// Code is the machine-readable reason returned to API clients.
+func (e *ProtectedScheduleError) Code() string {
+	return "schedule_has_clock_data"
+}
+
+// RecordedScheduleError reports a delete of a schedule with visit events,
+// such as a task outcome recorded before clock-in, which deleting it would
+// lose from the audit trail.
+type RecordedScheduleError struct {
+	ScheduleID string
+}
+
+func (e *RecordedScheduleError) Error() string {
+	return fmt.Sprintf("schedule %s has recorded visit events and cannot be deleted", e.ScheduleID)
+}
+
+// Code is the machine-readable reason returned to API clients.
+func (e *RecordedScheduleError) Code() string {
+	return "schedule_has_events"
+}
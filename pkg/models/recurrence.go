@@ -0,0 +1,121 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Recurrence is a recurring booking, such as Mon/Wed/Fri 9-10 AM. It is
+// expanded into concrete Schedules over a rolling window; each generated
+// schedule records the recurrence and the date it was generated for.
+type Recurrence struct {
+	ID           string `json:"id" example:"6f0b8c1e-2d4a-4c8e-9a7b-1e2f3a4b5c6d"`
+	ClientID     string `json:"clientId" example:"1"`
+	CaregiverID  string `json:"caregiverId,omitempty" example:"1"`
+	ServiceName  string `json:"serviceName" example:"Casa Grande Apartment"`
+	ServiceNotes string `json:"serviceNotes,omitempty" example:"Client may be a bit groggy."`
+	// StartDate (YYYY-MM-DD) is the first possible occurrence, like DTSTART.
+	StartDate string `json:"startDate" example:"2025-01-13"`
+	ShiftTime string `json:"shiftTime" example:"09:00 - 10:00"`
+	AmOrPm    string `json:"amOrPm" example:"AM"`
+	// TimeZone (IANA) is the zone the shift time is read in on each date.
+	TimeZone string `json:"timeZone" example:"America/Chicago"`
+	// RRule is an RFC 5545 rule; see ParseRRule for the supported subset.
+	RRule string `json:"rrule" example:"FREQ=WEEKLY;BYDAY=MO,WE,FR;UNTIL=20250630"`
+	// ExceptionDates (YYYY-MM-DD) are skipped, like EXDATE.
+	ExceptionDates []string         `json:"exceptionDates"`
+	Tasks          []AddTaskRequest `json:"tasks"`
+}
+
+// RecurrenceRequest is the body for creating a recurrence.
+type RecurrenceRequest struct {
+	ClientID       string           `json:"clientId" example:"1"`
+	CaregiverID    string           `json:"caregiverId,omitempty" example:"1"`
+	ServiceName    string           `json:"serviceName" example:"Casa Grande Apartment"`
+	ServiceNotes   string           `json:"serviceNotes,omitempty" example:"Client may be a bit groggy."`
+	StartDate      string           `json:"startDate" example:"2025-01-13"`
+	ShiftTime      string           `json:"shiftTime" example:"09:00 - 10:00"`
+	AmOrPm         string           `json:"amOrPm" example:"AM"`
+	TimeZone       string           `json:"timeZone,omitempty" example:"America/Chicago"`
+	RRule          string           `json:"rrule" example:"FREQ=WEEKLY;BYDAY=MO,WE,FR;UNTIL=20250630"`
+	ExceptionDates []string         `json:"exceptionDates"`
+	Tasks          []AddTaskRequest `json:"tasks"`
+}
+
+// UpdateOccurrenceRequest is the body for editing one occurrence of a
+// recurrence, or it and every later one. RRule may only change the
+// following occurrences; ShiftDate may only move a single one.
+type UpdateOccurrenceRequest struct {
+	UpdateScheduleRequest
+	RRule *string `json:"rrule,omitempty" example:"FREQ=WEEKLY;BYDAY=TU,TH"`
+}
+
+// Rule parses the recurrence's RRule.
+func (r *Recurrence) Rule() (RRule, error) {
+	return ParseRRule(r.RRule)
+}
+
+// Start parses StartDate.
+func (r *Recurrence) Start() (time.Time, error) {
+	start, err := time.Parse("2006-01-02", r.StartDate)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("start date %q: want YYYY-MM-DD", r.StartDate)
+	}
+	return start, nil
+}
+
+// Dates returns the occurrence dates between from and to, inclusive, with
+// the exception dates removed.
+func (r *Recurrence) Dates(from, to time.Time) ([]time.Time, error) {
+	rule, err := r.Rule()
+	if err != nil {
+		return nil, err
+	}
+	start, err := r.Start()
+	if err != nil {
+		return nil, err
+	}
+	skip := make(map[string]bool, len(r.ExceptionDates))
+	for _, date := range r.ExceptionDates {
+		skip[date] = true
+	}
+	dates := make([]time.Time, 0)
+	for _, date := range rule.Dates(start, from, to) {
+		if !skip[date.Format("2006-01-02")] {
+			dates = append(dates, date)
+		}
+	}
+	return dates, nil
+}
+
+// IsOccurrence reports whether date is one of the recurrence's dates.
+func (r *Recurrence) IsOccurrence(date time.Time) (bool, error) {
+	dates, err := r.Dates(date, date)
+	return len(dates) == 1, err
+}
+
+// InstanceID is the ID of the schedule generated for date. It is derived
+// from the date so that generating the same window twice is harmless.
+func (r *Recurrence) InstanceID(date time.Time) string {
+	return r.ID + "-" + date.Format("20060102")
+}
+
+// Instance returns the schedule generated for date, without its client
+// details or tasks.
+func (r *Recurrence) Instance(date time.Time) (*Schedule, error) {
+	schedule := &Schedule{
+		ID:             r.InstanceID(date),
+		RecurrenceID:   r.ID,
+		OccurrenceDate: date.Format("2006-01-02"),
+		CaregiverID:    r.CaregiverID,
+		ClientID:       r.ClientID,
+		ServiceName:    r.ServiceName,
+		ServiceNotes:   r.ServiceNotes,
+		Tasks:          make([]Task, 0, len(r.Tasks)),
+		Visit:          Visit{Status: StatusScheduled},
+	}
+	if err := schedule.SetLegacyShift(date.Format("2006-01-02"), r.ShiftTime, r.AmOrPm, r.TimeZone); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
@@ -0,0 +1,201 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RRule is the subset of an RFC 5545 recurrence rule that schedules use:
+// FREQ (DAILY or WEEKLY), INTERVAL, BYDAY (plain weekdays), COUNT and
+// UNTIL. Weeks start on Monday.
+type RRule struct {
+	Freq     string
+	Interval int
+	ByDay    []time.Weekday
+	// Count and Until are mutually exclusive; both zero means no end.
+	Count int
+	Until time.Time
+}
+
+var rruleWeekdays = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+// ParseRRule reads a rule such as "FREQ=WEEKLY;BYDAY=MO,WE,FR;UNTIL=20250630".
+// A leading "RRULE:" is accepted.
+func ParseRRule(s string) (RRule, error) {
+	rule := RRule{Interval: 1}
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" {
+		return rule, fmt.Errorf("rrule is empty")
+	}
+	for _, part := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return rule, fmt.Errorf("rrule part %q: want NAME=VALUE", part)
+		}
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			rule.Freq = strings.ToUpper(value)
+			if rule.Freq != "DAILY" && rule.Freq != "WEEKLY" {
+				return rule, fmt.Errorf("rrule FREQ %q: only DAILY and WEEKLY are supported", value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return rule, fmt.Errorf("rrule INTERVAL %q: want a positive integer", value)
+			}
+			rule.Interval = n
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				weekday, ok := rruleWeekdays[strings.ToUpper(day)]
+				if !ok {
+					return rule, fmt.Errorf("rrule BYDAY %q: want weekdays like MO,WE,FR", day)
+				}
+				rule.ByDay = append(rule.ByDay, weekday)
+			}
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return rule, fmt.Errorf("rrule COUNT %q: want a positive integer", value)
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, err := parseRRuleDate(value)
+			if err != nil {
+				return rule, err
+			}
+			rule.Until = until
+		case "WKST":
+			if strings.ToUpper(value) != "MO" {
+				return rule, fmt.Errorf("rrule WKST %q: only MO is supported", value)
+			}
+		default:
+			return rule, fmt.Errorf("rrule part %s is not supported", name)
+		}
+	}
+	if rule.Freq == "" {
+		return rule, fmt.Errorf("rrule needs FREQ")
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return rule, fmt.Errorf("rrule cannot have both COUNT and UNTIL")
+	}
+	return rule, nil
+}
+
+// parseRRuleDate reads an UNTIL value, either a date (20250630) or a UTC
+// date-time (20250630T235959Z), keeping only the date.
+func parseRRuleDate(value string) (time.Time, error) {
+	if len(value) >= 8 {
+		if t, err := time.Parse("20060102", value[:8]); err == nil && (len(value) == 8 || value[8] == 'T') {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("rrule UNTIL %q: want YYYYMMDD or YYYYMMDDTHHMMSSZ", value)
+}
+
+// String renders the rule back in RFC 5545 form.
+func (r RRule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, weekday := range r.ByDay {
+			for name, d := range rruleWeekdays {
+				if d == weekday {
+					days = append(days, name)
+				}
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Dates returns the occurrence dates of a series starting on start that fall
+// between from and to (inclusive), before exception dates are removed. All
+// values are calendar dates; only their year, month and day are used.
+func (r RRule) Dates(start, from, to time.Time) []time.Time {
+	start, from, to = civilDate(start), civilDate(from), civilDate(to)
+	if !r.Until.IsZero() && civilDate(r.Until).Before(to) {
+		to = civilDate(r.Until)
+	}
+	weekStart := start.AddDate(0, 0, -daysSinceMonday(start))
+
+	var dates []time.Time
+	seen := 0
+	for day := start; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if !r.matches(day, start, weekStart) {
+			continue
+		}
+		seen++
+		if r.Count > 0 && seen > r.Count {
+			break
+		}
+		if !day.Before(from) {
+			dates = append(dates, day)
+		}
+	}
+	return dates
+}
+
+// CountBefore returns how many occurrences fall before day, for splitting a
+// COUNT-limited series.
+func (r RRule) CountBefore(start, day time.Time) int {
+	day = civilDate(day)
+	if !day.After(civilDate(start)) {
+		return 0
+	}
+	return len(r.Dates(start, start, day.AddDate(0, 0, -1)))
+}
+
+func (r RRule) matches(day, start, weekStart time.Time) bool {
+	elapsed := int(day.Sub(start).Hours() / 24)
+	switch r.Freq {
+	case "DAILY":
+		if elapsed%r.Interval != 0 {
+			return false
+		}
+		return len(r.ByDay) == 0 || hasWeekday(r.ByDay, day.Weekday())
+	case "WEEKLY":
+		weeks := int(day.Sub(weekStart).Hours()/24) / 7
+		if weeks%r.Interval != 0 {
+			return false
+		}
+		if len(r.ByDay) == 0 {
+			return day.Weekday() == start.Weekday()
+		}
+		return hasWeekday(r.ByDay, day.Weekday())
+	}
+	return false
+}
+
+func hasWeekday(days []time.Weekday, weekday time.Weekday) bool {
+	for _, d := range days {
+		if d == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+func daysSinceMonday(t time.Time) int {
+	return (int(t.Weekday()) + 6) % 7
+}
+
+// civilDate drops the time of day and zone, so date arithmetic is never
+// thrown off by DST.
+func civilDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,161 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	// Embedded so IANA zones resolve on hosts without a zoneinfo database,
+	// such as serverless runtimes.
+	_ "time/tzdata"
+)
+
+// ParseLegacyShift turns the ShiftDate ("2006-01-02"), ShiftTime
+// ("09:00 - 10:00") and AmOrPm ("AM"/"PM") triple into instants in loc.
+// Both times are read on a 12-hour clock in the given half of the day, so
+// "00:00" and "12:00" are the start of it, and an end that does not come
+// after the start is taken to be in the following half ("06:00 - 12:00 AM"
+// ends at noon). The times are wall-clock times on that date, so a shift on
+// a daylight saving change keeps the hours it was booked for.
+func ParseLegacyShift(date, timeRange, amOrPm string, loc *time.Location) (start, end time.Time, err error) {
+	day, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(date), loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("shift date %q: %w", date, err)
+	}
+
+	var half int
+	switch strings.ToUpper(strings.TrimSpace(amOrPm)) {
+	case "AM":
+	case "PM":
+		half = 12
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("shift half %q: want AM or PM", amOrPm)
+	}
+
+	from, to, ok := strings.Cut(timeRange, "-")
+	if !ok {
+		return time.Time{}, time.Time{}, fmt.Errorf("shift time %q: want \"HH:MM - HH:MM\"", timeRange)
+	}
+	startHour, startMinute, err := parseClock(from)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("shift time %q: %w", timeRange, err)
+	}
+	endHour, endMinute, err := parseClock(to)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("shift time %q: %w", timeRange, err)
+	}
+
+	y, m, d := day.Date()
+	startHour += half
+	endHour += half
+	if endHour*60+endMinute <= startHour*60+startMinute {
+		endHour += 12
+	}
+	start = time.Date(y, m, d, startHour, startMinute, 0, 0, loc)
+	end = time.Date(y, m, d, endHour, endMinute, 0, 0, loc)
+	return start, end, nil
+}
+
+// parseClock reads "H:MM" on a 12-hour clock, where 12 means 0.
+func parseClock(s string) (hour, minute int, err error) {
+	h, m, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok {
+		return 0, 0, fmt.Errorf("clock %q: want H:MM", s)
+	}
+	hour, err = strconv.Atoi(h)
+	if err != nil || hour < 0 || hour > 12 {
+		return 0, 0, fmt.Errorf("clock %q: bad hour", s)
+	}
+	minute, err = strconv.Atoi(m)
+	if err != nil || minute < 0 || minute > 59 || len(m) != 2 {
+		return 0, 0, fmt.Errorf("clock %q: bad minute", s)
+	}
+	return hour % 12, minute, nil
+}
+
+// FormatLegacyShift renders a shift whose instants are already in its zone
+// as the legacy ShiftDate, ShiftTime and AmOrPm fields, the inverse of
+// ParseLegacyShift. The half of the day is the start's; an end on the hour
+// boundary renders as "12:00". Shifts longer than 12 hours cannot be
+// expressed this way and render with the end wrapped onto the clock face.
+func FormatLegacyShift(start, end time.Time) (date, timeRange, amOrPm string) {
+	amOrPm = "AM"
+	if start.Hour() >= 12 {
+		amOrPm = "PM"
+	}
+	endHour := end.Hour() % 12
+	if endHour == 0 && end.Minute() == 0 {
+		endHour = 12
+	}
+	timeRange = fmt.Sprintf("%02d:%02d - %02d:%02d", start.Hour()%12, start.Minute(), endHour, end.Minute())
+	return start.Format("2006-01-02"), timeRange, amOrPm
+}
+
+// LoadZone loads an IANA time zone such as "America/Chicago". "Local" is
+// refused: a stored zone must mean the same thing on every server.
+func LoadZone(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("time zone %q: want an IANA name such as America/Chicago", name)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("time zone %q: %w", name, err)
+	}
+	return loc, nil
+}
+
+// DefaultTimeZone returns the IANA name of the server's zone, from $TZ or
+// the /etc/localtime link, falling back to UTC.
+func DefaultTimeZone() string {
+	if name := strings.TrimPrefix(os.Getenv("TZ"), ":"); name != "" {
+		if _, err := LoadZone(name); err == nil {
+			return name
+		}
+	}
+	if target, err := filepath.EvalSymlinks("/etc/localtime"); err == nil {
+		if _, name, ok := strings.Cut(target, "zoneinfo/"); ok {
+			if _, err := LoadZone(name); err == nil {
+				return name
+			}
+		}
+	}
+	return "UTC"
+}
+
+// SetShift sets when the shift starts and ends and the zone it is planned
+// in, and renders the legacy fields from them.
+func (s *Schedule) SetShift(start, end time.Time, zone string) error {
+	loc, err := LoadZone(zone)
+	if err != nil {
+		return err
+	}
+	if !end.After(start) {
+		return fmt.Errorf("shift end %s is not after its start %s", end.Format(time.RFC3339), start.Format(time.RFC3339))
+	}
+	s.ShiftStart, s.ShiftEnd, s.TimeZone = start.In(loc), end.In(loc), zone
+	s.ShiftDate, s.ShiftTime, s.AmOrPm = FormatLegacyShift(s.ShiftStart, s.ShiftEnd)
+	return nil
+}
+
+// SetLegacyShift sets the shift from legacy fields read in zone.
+func (s *Schedule) SetLegacyShift(date, timeRange, amOrPm, zone string) error {
+	loc, err := LoadZone(zone)
+	if err != nil {
+		return err
+	}
+	start, end, err := ParseLegacyShift(date, timeRange, amOrPm, loc)
+	if err != nil {
+		return err
+	}
+	return s.SetShift(start, end, zone)
+}
+
+// ShiftWindow returns when the shift starts and ends, in its zone.
+func (s *Schedule) ShiftWindow() (start, end time.Time, err error) {
+	if s.ShiftStart.IsZero() || s.ShiftEnd.IsZero() {
+		return time.Time{}, time.Time{}, fmt.Errorf("schedule %s has no shift times", s.ID)
+	}
+	return s.ShiftStart, s.ShiftEnd, nil
+}
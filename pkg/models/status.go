@@ -0,0 +1,71 @@
+package models
+
+import "fmt"
+
+type VisitStatus string
+
+const (
+	StatusScheduled  VisitStatus = "scheduled"
+	StatusInProgress VisitStatus = "in_progress"
+	StatusCompleted  VisitStatus = "completed"
+	StatusMissed     VisitStatus = "missed"
+	StatusCancelled  VisitStatus = "cancelled"
+)
+
+// visitTransitions is the visit lifecycle: the statuses each status may
+// move to. in_progress -> scheduled is a cancelled clock-in. Completed,
+// missed and cancelled visits are final.
+var visitTransitions = map[VisitStatus][]VisitStatus{
+	StatusScheduled:  {StatusInProgress, StatusMissed, StatusCancelled},
+	StatusInProgress: {StatusCompleted, StatusScheduled},
+	StatusCompleted:  {},
+	StatusMissed:     {},
+	StatusCancelled:  {},
+}
+
+// Valid reports whether s is a known visit status.
+func (s VisitStatus) Valid() bool {
+	_, ok := visitTransitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether the lifecycle allows moving from s to next.
+func (s VisitStatus) CanTransitionTo(next VisitStatus) bool {
+	for _, allowed := range visitTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// AcceptsTaskOutcomes reports whether task outcomes may be recorded on a
+// visit in status s: only while it is under way, so every outcome falls
+// between a clock-in and a clock-out. Once the visit is completed, missed or
+// cancelled its outcomes are final.
+func (s VisitStatus) AcceptsTaskOutcomes() bool {
+	return s == StatusInProgress
+}
+
+// TransitionError reports a status change the visit lifecycle forbids.
+type TransitionError struct {
+	From VisitStatus
+	To   VisitStatus
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("visit cannot move from %s to %s", e.From, e.To)
+}
+
+// Code is the machine-readable reason returned to API clients.
+func (e *TransitionError) Code() string {
+	return "illegal_status_transition"
+}
+
+// CheckTransition returns a *TransitionError unless from -> to is allowed.
+func CheckTransition(from, to VisitStatus) error {
+	if !from.CanTransitionTo(to) {
+		return &TransitionError{From: from, To: to}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package models
+
+// SyncMutationType names an action a device queued while offline.
+type SyncMutationType string
+
+const (
+	MutationStartVisit SyncMutationType = "start_visit"
+	MutationEndVisit   SyncMutationType = "end_visit"
+	MutationUpdateTask SyncMutationType = "update_task"
+)
+
+// EventType returns the visit event a mutation records, or "" if the type is
+// unknown.
+func (t SyncMutationType) EventType() VisitEventType {
+	switch t {
+	case MutationStartVisit:
+		return EventVisitStarted
+	case MutationEndVisit:
+		return EventVisitEnded
+	case MutationUpdateTask:
+		return EventTaskMarked
+	}
+	return ""
+}
+
+// SyncRequest is a batch of mutations queued by a device while offline,
+// replayed in order.
+type SyncRequest struct {
+	DeviceID  string         `json:"deviceId,omitempty" example:"tablet-7"`
+	Mutations []SyncMutation `json:"mutations"`
+}
+
+// SyncMutation is one queued action. IdempotencyKey is generated by the
+// device once per action and reused on every retry, so an action is applied
+// at most once however often the batch is resent.
+type SyncMutation struct {
+	IdempotencyKey string           `json:"idempotencyKey" example:"3f1c9a6e-5d7b-4e2a-9c1f-0b8d2e4a6c71"`
+	Type           SyncMutationType `json:"type" example:"start_visit" enums:"start_visit,end_visit,update_task"`
+	// ScheduleID is required for start_visit and end_visit. For update_task
+	// it is optional; when given it must own the task.
+	ScheduleID string `json:"scheduleId,omitempty" example:"1"`
+	// TaskID is required for update_task.
+	TaskID int `json:"taskId,omitempty" example:"1"`
+
+	// start_visit and end_visit only.
+	Timestamp string      `json:"timestamp,omitempty" example:"2025-01-15T09:02:00-05:00"`
+	Location  Geolocation `json:"location"`
+
+	// update_task only.
+	Completed          bool          `json:"completed,omitempty"`
+	NotCompletedReason string        `json:"notCompletedReason,omitempty"`
+	ReasonCode         string        `json:"reasonCode,omitempty"`
+	Measurements       *Measurements `json:"measurements,omitempty"`
+}
+
+// SyncStatus is the outcome of a single mutation.
+type SyncStatus string
+
+const (
+	// SyncApplied means the mutation was recorded by this request.
+	SyncApplied SyncStatus = "applied"
+	// SyncDuplicate means the key was already recorded by an earlier request;
+	// nothing changed.
+	SyncDuplicate SyncStatus = "duplicate"
+	// SyncRejected means the mutation was not recorded; Code says why.
+	SyncRejected SyncStatus = "rejected"
+)
+
+type SyncResult struct {
+	IdempotencyKey string     `json:"idempotencyKey" example:"3f1c9a6e-5d7b-4e2a-9c1f-0b8d2e4a6c71"`
+	Status         SyncStatus `json:"status" example:"applied" enums:"applied,duplicate,rejected"`
+	// EventID is the visit event recorded for the key, for applied and
+	// duplicate results.
+	EventID int64  `json:"eventId,omitempty" example:"12"`
+	Code    string `json:"code,omitempty" example:"illegal_status_transition"`
+	Error   string `json:"error,omitempty" example:"visit cannot move from completed to in_progress"`
+}
+
+// SyncResponse holds one result per mutation, in request order.
+type SyncResponse struct {
+	Results []SyncResult `json:"results"`
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HasOutcome reports whether the task has been marked completed or given a
+// not-completed reason.
+func (t Task) HasOutcome() bool {
+	return t.Completed || t.NotCompletedReason != ""
+}
+
+// CheckRequiredTasks returns a *RequiredTasksError if a required task has no
+// outcome yet.
+func (s *Schedule) CheckRequiredTasks() error {
+	var open []int
+	for _, task := range s.Tasks {
+		if task.Required && !task.HasOutcome() {
+			open = append(open, task.ID)
+		}
+	}
+	if len(open) > 0 {
+		return &RequiredTasksError{ScheduleID: s.ID, TaskIDs: open}
+	}
+	return nil
+}
+
+// RequiredTasksError reports an attempt to end a visit while required tasks
+// are neither completed nor given a not-completed reason.
+type RequiredTasksError struct {
+	ScheduleID string
+	TaskIDs    []int
+}
+
+func (e *RequiredTasksError) Error() string {
+	ids := make([]string, len(e.TaskIDs))
+	for i, id := range e.TaskIDs {
+		ids[i] = fmt.Sprint(id)
+	}
+	return fmt.Sprintf("schedule %s has required tasks without an outcome: %s", e.ScheduleID, strings.Join(ids, ", "))
+}
+
+// Code is the machine-readable reason returned to API clients.
+func (e *RequiredTasksError) Code() string {
+	return "required_tasks_incomplete"
+}
+
+// VisitNotInProgressError reports a task outcome recorded on a visit that
+// is not under way: not clocked into yet, or already over.
+type VisitNotInProgressError struct {
+	ScheduleID string
+	Status     VisitStatus
+}
+
+func (e *VisitNotInProgressError) Error() string {
+	return fmt.Sprintf("visit %s is %s; task outcomes are only recorded while it is in progress", e.ScheduleID, e.Status)
+}
+
+// Code is the machine-readable reason returned to API clients.
+func (e *VisitNotInProgressError) Code() string {
+	return "visit_not_in_progress"
+}
+
+// TaskOutcomeError reports a delete or edit of a task whose outcome is
+// already part of the visit record.
+type TaskOutcomeError struct {
+	ScheduleID string
+	TaskID     int
+	// Field is the field the edit tried to change, or "" for a delete.
+	Field string
+}
+
+func (e *TaskOutcomeError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("task %d in schedule %s has a recorded outcome and cannot be deleted", e.TaskID, e.ScheduleID)
+	}
+	return fmt.Sprintf("task %d in schedule %s has a recorded outcome; %s can no longer be changed", e.TaskID, e.ScheduleID, e.Field)
+}
+
+// Code is the machine-readable reason returned to API clients.
+func (e *TaskOutcomeError) Code() string {
+	return "task_has_outcome"
+}
+
+// Task returns the task to create, with its name trimmed. The store assigns
+// the ID.
+func (r AddTaskRequest) Task() Task {
+	return Task{Name: strings.TrimSpace(r.Name), Description: r.Description, Required: r.Required}
+}
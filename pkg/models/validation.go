@@ -0,0 +1,136 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Length limits on task text, in characters.
+const (
+	MaxTaskNameLength        = 100
+	MaxTaskDescriptionLength = 1000
+	MaxReasonLength          = 500
+)
+
+// FieldError is a problem with one field of a request body, for the UI to
+// show next to the input.
+type FieldError struct {
+	// Field is the JSON path of the field, e.g. "name" or "tasks[1].name".
+	Field string `json:"field" example:"notCompletedReason"`
+	// Code is one of required, too_long, out_of_range, invalid and conflict.
+	Code    string `json:"code" example:"required"`
+	Message string `json:"message" example:"A reason is required when the task is not completed"`
+}
+
+// ValidationError lists every problem found with a request body.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Field + ": " + field.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Code is the machine-readable reason returned to API clients.
+func (e *ValidationError) Code() string {
+	return "validation_failed"
+}
+
+// fieldErrors collects FieldErrors while a request is checked.
+type fieldErrors []FieldError
+
+func (f *fieldErrors) add(field, code, format string, args ...any) {
+	*f = append(*f, FieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+// text checks an optional free-text field against its length limit.
+func (f *fieldErrors) text(field, value string, max int) {
+	if utf8.RuneCountInString(value) > max {
+		f.add(field, "too_long", "Must be at most %d characters", max)
+	}
+}
+
+// name checks a required, trimmed name against its length limit.
+func (f *fieldErrors) name(field, value string, max int) {
+	if strings.TrimSpace(value) == "" {
+		f.add(field, "required", "Name is required")
+		return
+	}
+	f.text(field, strings.TrimSpace(value), max)
+}
+
+// nested adds the errors of a nested object, prefixing their fields.
+func (f *fieldErrors) nested(prefix string, err error) {
+	if v, ok := err.(*ValidationError); ok {
+		for _, field := range v.Fields {
+			field.Field = prefix + "." + field.Field
+			*f = append(*f, field)
+		}
+	}
+}
+
+// err returns the collected errors as a *ValidationError, or nil.
+func (f fieldErrors) err() error {
+	if len(f) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: f}
+}
+
+// Validate checks a new task.
+func (r AddTaskRequest) Validate() error {
+	var errs fieldErrors
+	errs.name("name", r.Name, MaxTaskNameLength)
+	errs.text("description", r.Description, MaxTaskDescriptionLength)
+	return errs.err()
+}
+
+// Validate checks the fields present in a task edit.
+func (r EditTaskRequest) Validate() error {
+	var errs fieldErrors
+	if r.Name != nil {
+		errs.name("name", *r.Name, MaxTaskNameLength)
+	}
+	if r.Description != nil {
+		errs.text("description", *r.Description, MaxTaskDescriptionLength)
+	}
+	return errs.err()
+}
+
+// ValidateTasks checks the tasks of a schedule or recurrence request, with
+// fields reported as tasks[i].name.
+func ValidateTasks(tasks []AddTaskRequest) error {
+	var errs fieldErrors
+	for i, task := range tasks {
+		errs.nested(fmt.Sprintf("tasks[%d]", i), task.Validate())
+	}
+	return errs.err()
+}
+
+// InvalidField returns a *ValidationError for a single field.
+func InvalidField(field, code, message string) error {
+	return &ValidationError{Fields: []FieldError{{Field: field, Code: code, Message: message}}}
+}
+
+// MaxAPIKeyNameLength limits the name of an API key, in characters.
+const MaxAPIKeyNameLength = 100
+
+// Validate checks a new API key. knownScope reports whether a scope exists.
+func (r CreateAPIKeyRequest) Validate(knownScope func(string) bool) error {
+	var errs fieldErrors
+	errs.name("name", r.Name, MaxAPIKeyNameLength)
+	if len(r.Scopes) == 0 {
+		errs.add("scopes", "required", "At least one scope is required")
+	}
+	for i, scope := range r.Scopes {
+		if !knownScope(scope) {
+			errs.add(fmt.Sprintf("scopes[%d]", i), "invalid", "Unknown scope %q", scope)
+		}
+	}
+	return errs.err()
+}
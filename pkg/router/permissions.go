@@ -0,0 +1,128 @@
+package router
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/auth"
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/handler"
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/store"
+)
+
+// scope narrows a route to the caller's own records when the caller lacks
+// auth.PermSchedulesAll.
+type scope int
+
+const (
+	anyRecord scope = iota
+	// assignedSchedule: the schedule in :id is assigned to the caller.
+	assignedSchedule
+	// assignedTask: the task in :taskId belongs to such a schedule.
+	assignedTask
+	// ownCaregiver: :id is the caller's caregiver record.
+	ownCaregiver
+)
+
+// access is what a route demands of its caller.
+type access struct {
+	permission auth.Permission
+	scope      scope
+}
+
+// permissions lists, for every route under /api behind Authenticate, the
+// permission needed to call it. Schedule listings and sync narrow what they
+// return or apply to the caller's own schedules themselves.
+var permissions = map[string]access{
+	"POST /reset": {permission: auth.PermStoreReset},
+
+	"GET /schedules":                  {permission: auth.PermSchedulesRead},
+	"POST /schedules":                 {permission: auth.PermSchedulesWrite},
+	"GET /schedules/today":            {permission: auth.PermSchedulesRead},
+	"GET /schedules/:id":              {permission: auth.PermSchedulesRead, scope: assignedSchedule},
+	"PATCH /schedules/:id":            {permission: auth.PermSchedulesWrite},
+	"DELETE /schedules/:id":           {permission: auth.PermSchedulesWrite},
+	"GET /schedules/:id/events":       {permission: auth.PermSchedulesRead, scope: assignedSchedule},
+	"POST /schedules/:id/mark-missed": {permission: auth.PermSchedulesWrite},
+	"POST /schedules/:id/cancel":      {permission: auth.PermSchedulesWrite},
+
+	"POST /schedules/:id/start":           {permission: auth.PermVisitsRecord, scope: assignedSchedule},
+	"POST /schedules/:id/end":             {permission: auth.PermVisitsRecord, scope: assignedSchedule},
+	"GET /schedules/:id/clock-in":         {permission: auth.PermVisitsRecord, scope: assignedSchedule},
+	"POST /schedules/:id/cancel-clock-in": {permission: auth.PermVisitsRecord, scope: assignedSchedule},
+	"POST /sync":                          {permission: auth.PermVisitsRecord},
+
+	"POST /schedules/:id/tasks":           {permission: auth.PermTasksWrite},
+	"PUT /schedules/:id/tasks/order":      {permission: auth.PermTasksWrite},
+	"GET /schedules/:id/tasks/:taskId":    {permission: auth.PermSchedulesRead, scope: assignedSchedule},
+	"PUT /schedules/:id/tasks/:taskId":    {permission: auth.PermVisitsRecord, scope: assignedSchedule},
+	"PATCH /schedules/:id/tasks/:taskId":  {permission: auth.PermTasksWrite},
+	"DELETE /schedules/:id/tasks/:taskId": {permission: auth.PermTasksWrite},
+	"GET /task-outcomes":                  {permission: auth.PermVisitsRecord},
+	"PUT /tasks/:taskId/update":           {permission: auth.PermVisitsRecord, scope: assignedTask},
+
+	"GET /caregivers":               {permission: auth.PermCaregiversRead},
+	"POST /caregivers":              {permission: auth.PermCaregiversWrite},
+	"GET /caregivers/:id":           {permission: auth.PermCaregiversRead},
+	"PUT /caregivers/:id":           {permission: auth.PermCaregiversWrite},
+	"DELETE /caregivers/:id":        {permission: auth.PermCaregiversWrite},
+	"GET /caregivers/:id/schedules": {permission: auth.PermSchedulesRead, scope: ownCaregiver},
+
+	"GET /clients":               {permission: auth.PermClientsRead},
+	"POST /clients":              {permission: auth.PermClientsWrite},
+	"GET /clients/:id":           {permission: auth.PermClientsRead},
+	"PUT /clients/:id":           {permission: auth.PermClientsWrite},
+	"DELETE /clients/:id":        {permission: auth.PermClientsWrite},
+	"GET /clients/:id/care-plan": {permission: auth.PermCarePlansRead},
+	"PUT /clients/:id/care-plan": {permission: auth.PermCarePlansWrite},
+
+	"GET /task-templates":          {permission: auth.PermCarePlansRead},
+	"POST /task-templates":         {permission: auth.PermCarePlansWrite},
+	"GET /task-templates/:code":    {permission: auth.PermCarePlansRead},
+	"PUT /task-templates/:code":    {permission: auth.PermCarePlansWrite},
+	"DELETE /task-templates/:code": {permission: auth.PermCarePlansWrite},
+
+	"GET /recurrences":                          {permission: auth.PermSchedulesAll},
+	"POST /recurrences":                         {permission: auth.PermSchedulesWrite},
+	"POST /recurrences/roll":                    {permission: auth.PermRecurrencesRoll},
+	"GET /recurrences/:id":                      {permission: auth.PermSchedulesAll},
+	"DELETE /recurrences/:id":                   {permission: auth.PermSchedulesWrite},
+	"PATCH /recurrences/:id/occurrences/:date":  {permission: auth.PermSchedulesWrite},
+	"DELETE /recurrences/:id/occurrences/:date": {permission: auth.PermSchedulesWrite},
+
+	"GET /api-keys":             {permission: auth.PermAPIKeysManage},
+	"POST /api-keys":            {permission: auth.PermAPIKeysManage},
+	"POST /api-keys/:id/revoke": {permission: auth.PermAPIKeysManage},
+}
+
+// guardedRouter registers routes behind the checks their entry in
+// permissions demands. Registering a route without an entry panics, so no
+// route is left open by omission.
+type guardedRouter struct {
+	router fiber.Router
+	store  store.Repository
+}
+
+func (g guardedRouter) Get(path string, h fiber.Handler)    { g.add(fiber.MethodGet, path, h) }
+func (g guardedRouter) Post(path string, h fiber.Handler)   { g.add(fiber.MethodPost, path, h) }
+func (g guardedRouter) Put(path string, h fiber.Handler)    { g.add(fiber.MethodPut, path, h) }
+func (g guardedRouter) Patch(path string, h fiber.Handler)  { g.add(fiber.MethodPatch, path, h) }
+func (g guardedRouter) Delete(path string, h fiber.Handler) { g.add(fiber.MethodDelete, path, h) }
+
+func (g guardedRouter) add(method, path string, h fiber.Handler) {
+	route := method + " " + path
+	rule, ok := permissions[route]
+	if !ok {
+		panic(fmt.Sprintf("route %s has no entry in the permission table", route))
+	}
+	handlers := []fiber.Handler{handler.Require(rule.permission)}
+	switch rule.scope {
+	case assignedSchedule:
+		handlers = append(handlers, handler.AssignedSchedule(g.store))
+	case assignedTask:
+		handlers = append(handlers, handler.AssignedTask(g.store))
+	case ownCaregiver:
+		handlers = append(handlers, handler.OwnCaregiver)
+	}
+	g.router.Add(method, path, append(handlers, h)...)
+}
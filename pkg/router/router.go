@@ -6,19 +6,46 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/config"
 	"github.com/IkoAfianando/mini_evv_logger_go/pkg/handler"
 	"github.com/IkoAfianando/mini_evv_logger_go/pkg/store"
 )
 
-func SetupRoutes(app *fiber.App, st *store.Store) {
-	scheduleHandler := handler.NewScheduleHandler(st)
+// AppConfig is the Fiber configuration the routes expect: errors returned by
+// handlers are rendered as application/problem+json documents, and request
+// values are copied, since the in-memory store keeps the path parameters it
+// is given.
+func AppConfig() fiber.Config {
+	return fiber.Config{
+		ErrorHandler: handler.ErrorHandler,
+		Immutable:    true,
+	}
+}
+
+// SetupRoutes registers every route on app. It fails when cfg cannot be
+// served, such as without signing keys.
+func SetupRoutes(app *fiber.App, st store.Repository, cfg config.Config) error {
+	scheduleHandler := handler.NewScheduleHandler(st, cfg)
 	taskHandler := handler.NewTaskHandler(st)
+	caregiverHandler := handler.NewCaregiverHandler(st, cfg)
+	clientHandler := handler.NewClientHandler(st)
+	carePlanHandler := handler.NewCarePlanHandler(st)
+	recurrenceHandler := handler.NewRecurrenceHandler(st, scheduleHandler, cfg)
+	syncHandler := handler.NewSyncHandler(st, scheduleHandler, taskHandler)
+	authHandler, err := handler.NewAuthHandler(st, cfg)
+	if err != nil {
+		return err
+	}
+	apiKeyHandler := handler.NewAPIKeyHandler(st)
 
 	app.Use(logger.New())
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: "*",
-		AllowHeaders: "Origin, Content-Type, Accept",
-		AllowMethods: "GET, POST, PUT",
+		AllowHeaders: "Origin, Content-Type, Accept, Authorization, X-API-Key, If-Match, If-None-Match",
+		AllowMethods: "GET, POST, PUT, PATCH, DELETE",
+		// Pagination headers on schedule listings, and the version of a
+		// schedule or task.
+		ExposeHeaders: "X-Total-Count, X-Next-Cursor, Link, ETag",
 	}))
 
 	app.Get("/", func(c *fiber.Ctx) error {
@@ -26,23 +53,90 @@ func SetupRoutes(app *fiber.App, st *store.Store) {
 	})
 	api := app.Group("/api")
 
+	// Auth routes; everything registered after Authenticate needs a token
+	// or an API key.
+	api.Post("/auth/login", authHandler.Login)
+	api.Post("/auth/refresh", authHandler.Refresh)
+	api.Post("/auth/logout", authHandler.Logout)
+	api.Use(authHandler.Authenticate)
+	api.Get("/auth/me", authHandler.GetCurrentPrincipal)
+
+	// Every route below needs the permission its entry in the permission
+	// table names.
+	protected := guardedRouter{router: api, store: st}
+
 	// Admin route
-	api.Post("/reset", scheduleHandler.ResetStore)
+	protected.Post("/reset", scheduleHandler.ResetStore)
 
 	// Schedule routes
-	api.Get("/schedules", scheduleHandler.GetSchedules)
-	api.Get("/schedules/today", scheduleHandler.GetTodaySchedules)
-	api.Get("/schedules/:id", scheduleHandler.GetScheduleByID)
+	protected.Get("/schedules", scheduleHandler.GetSchedules)
+	protected.Post("/schedules", scheduleHandler.CreateSchedule)
+	protected.Get("/schedules/today", scheduleHandler.GetTodaySchedules)
+	protected.Get("/schedules/:id", scheduleHandler.GetScheduleByID)
+	protected.Patch("/schedules/:id", scheduleHandler.UpdateSchedule)
+	protected.Delete("/schedules/:id", scheduleHandler.DeleteSchedule)
+	protected.Get("/schedules/:id/events", scheduleHandler.GetScheduleEvents)
 
 	// Visit routes
-	api.Post("/schedules/:id/start", scheduleHandler.StartVisit)
-	api.Post("/schedules/:id/end", scheduleHandler.EndVisit)
-	api.Get("/schedules/:id/clock-in", scheduleHandler.ClockIn)
-	api.Post("/schedules/:id/cancel-clock-in", scheduleHandler.CancelClockIn)
+	protected.Post("/schedules/:id/start", scheduleHandler.StartVisit)
+	protected.Post("/schedules/:id/end", scheduleHandler.EndVisit)
+	protected.Get("/schedules/:id/clock-in", scheduleHandler.ClockIn)
+	protected.Post("/schedules/:id/cancel-clock-in", scheduleHandler.CancelClockIn)
+	protected.Post("/schedules/:id/mark-missed", scheduleHandler.MarkVisitMissed)
+	protected.Post("/schedules/:id/cancel", scheduleHandler.CancelVisit)
 
 	// Task routes
-	api.Post("/schedules/:id/tasks", scheduleHandler.AddTaskToSchedule)
-	api.Put("/tasks/:taskId/update", taskHandler.UpdateTask)
+	protected.Post("/schedules/:id/tasks", scheduleHandler.AddTaskToSchedule)
+	protected.Put("/schedules/:id/tasks/order", taskHandler.ReorderTasks)
+	protected.Get("/schedules/:id/tasks/:taskId", taskHandler.GetScheduleTask)
+	protected.Put("/schedules/:id/tasks/:taskId", taskHandler.UpdateScheduleTask)
+	protected.Patch("/schedules/:id/tasks/:taskId", taskHandler.EditTask)
+	protected.Delete("/schedules/:id/tasks/:taskId", taskHandler.DeleteTask)
+	protected.Get("/task-outcomes", taskHandler.GetTaskOutcomeOptions)
+	// Deprecated: task IDs are unique, but prefer the schedule-scoped route.
+	protected.Put("/tasks/:taskId/update", taskHandler.UpdateTask)
+
+	// Caregiver routes
+	protected.Get("/caregivers", caregiverHandler.GetCaregivers)
+	protected.Post("/caregivers", caregiverHandler.CreateCaregiver)
+	protected.Get("/caregivers/:id", caregiverHandler.GetCaregiverByID)
+	protected.Put("/caregivers/:id", caregiverHandler.UpdateCaregiver)
+	protected.Delete("/caregivers/:id", caregiverHandler.DeleteCaregiver)
+	protected.Get("/caregivers/:id/schedules", caregiverHandler.GetCaregiverSchedules)
+
+	// Client routes
+	protected.Get("/clients", clientHandler.GetClients)
+	protected.Post("/clients", clientHandler.CreateClient)
+	protected.Get("/clients/:id", clientHandler.GetClientByID)
+	protected.Put("/clients/:id", clientHandler.UpdateClient)
+	protected.Delete("/clients/:id", clientHandler.DeleteClient)
+	protected.Get("/clients/:id/care-plan", carePlanHandler.GetCarePlan)
+	protected.Put("/clients/:id/care-plan", carePlanHandler.SetCarePlan)
+
+	// Task template routes
+	protected.Get("/task-templates", carePlanHandler.GetTaskTemplates)
+	protected.Post("/task-templates", carePlanHandler.CreateTaskTemplate)
+	protected.Get("/task-templates/:code", carePlanHandler.GetTaskTemplate)
+	protected.Put("/task-templates/:code", carePlanHandler.UpdateTaskTemplate)
+	protected.Delete("/task-templates/:code", carePlanHandler.DeleteTaskTemplate)
+
+	// Recurrence routes
+	protected.Get("/recurrences", recurrenceHandler.GetRecurrences)
+	protected.Post("/recurrences", recurrenceHandler.CreateRecurrence)
+	protected.Post("/recurrences/roll", recurrenceHandler.RollRecurrences)
+	protected.Get("/recurrences/:id", recurrenceHandler.GetRecurrenceByID)
+	protected.Delete("/recurrences/:id", recurrenceHandler.DeleteRecurrence)
+	protected.Patch("/recurrences/:id/occurrences/:date", recurrenceHandler.UpdateOccurrence)
+	protected.Delete("/recurrences/:id/occurrences/:date", recurrenceHandler.DeleteOccurrence)
+
+	// Offline sync
+	protected.Post("/sync", syncHandler.Sync)
+
+	// API key routes
+	protected.Get("/api-keys", apiKeyHandler.GetAPIKeys)
+	protected.Post("/api-keys", apiKeyHandler.CreateAPIKey)
+	protected.Post("/api-keys/:id/revoke", apiKeyHandler.RevokeAPIKey)
 
 	app.Get("/swagger/*", swagger.HandlerDefault)
+	return nil
 }
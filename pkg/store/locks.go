@@ -0,0 +1,83 @@
+package store
+
+import "sync"
+
+// recordLocks gives every schedule, and every recurrence, its own mutex, so
+// that a handler can read a record, check it and store the result without
+// another request changing it in between, while requests for other records
+// go ahead. Both stores embed it to implement LockSchedule and
+// LockRecurrence.
+type recordLocks struct {
+	schedules   keyedLocks
+	recurrences keyedLocks
+}
+
+// LockSchedule implements Repository.
+func (l *recordLocks) LockSchedule(id string) (unlock func()) {
+	return l.schedules.lock(id)
+}
+
+// LockRecurrence implements Repository.
+func (l *recordLocks) LockRecurrence(id string) (unlock func()) {
+	return l.recurrences.lock(id)
+}
+
+// lockAll waits until no record is locked and keeps new locks from being
+// taken until the returned function is called. Recurrences are locked
+// first, the order in which a handler may hold both.
+func (l *recordLocks) lockAll() (unlock func()) {
+	unlockRecurrences := l.recurrences.lockAll()
+	unlockSchedules := l.schedules.lockAll()
+	return func() {
+		unlockSchedules()
+		unlockRecurrences()
+	}
+}
+
+// keyedLocks is a set of mutexes looked up by key.
+type keyedLocks struct {
+	// all is held for reading by every lock and for writing by lockAll, so
+	// a Reset waits for requests under way and holds off new ones.
+	all   sync.RWMutex
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	sync.Mutex
+	// waiters counts the holders and waiters, so the entry can be dropped
+	// once nobody needs it.
+	waiters int
+}
+
+func (l *keyedLocks) lock(id string) (unlock func()) {
+	l.all.RLock()
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*keyedLock)
+	}
+	lock, ok := l.locks[id]
+	if !ok {
+		lock = &keyedLock{}
+		l.locks[id] = lock
+	}
+	lock.waiters++
+	l.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		l.mu.Lock()
+		lock.waiters--
+		if lock.waiters == 0 {
+			delete(l.locks, id)
+		}
+		l.mu.Unlock()
+		l.all.RUnlock()
+	}
+}
+
+func (l *keyedLocks) lockAll() (unlock func()) {
+	l.all.Lock()
+	return l.all.Unlock
+}
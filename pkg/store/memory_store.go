@@ -1,174 +1,948 @@
 package store
 
 import (
+	"fmt"
 	"log"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/IkoAfianando/mini_evv_logger_go/pkg/models"
 )
 
+// Store is the in-memory Repository implementation.
 type Store struct {
-	mu        sync.Mutex
-	Schedules map[string]*models.Schedule
-	Tasks     map[int]*models.Task
+	recordLocks
+
+	mu sync.Mutex
+	// schedules holds each schedule as planned; visit events are replayed
+	// on top of it on every read.
+	schedules   map[string]*models.Schedule
+	caregivers  map[string]*models.Caregiver
+	clients     map[string]*models.Client
+	templates   map[string]*models.TaskTemplate
+	carePlans   map[string]*models.CarePlan
+	recurrences map[string]*models.Recurrence
+	events      map[string][]models.VisitEvent
+	eventKeys   map[string]models.VisitEvent
+	nextEventID int64
+	nextTaskID  int
+
+	users map[string]*models.User
+	// refreshTokens is keyed by token ID.
+	refreshTokens map[string]*models.RefreshToken
+	apiKeys       map[string]*models.APIKey
 }
 
 func NewStore() *Store {
 	return &Store{
-		Schedules: make(map[string]*models.Schedule),
-		Tasks:     make(map[int]*models.Task),
+		schedules:   make(map[string]*models.Schedule),
+		caregivers:  make(map[string]*models.Caregiver),
+		clients:     make(map[string]*models.Client),
+		templates:   make(map[string]*models.TaskTemplate),
+		carePlans:   make(map[string]*models.CarePlan),
+		recurrences: make(map[string]*models.Recurrence),
+		events:      make(map[string][]models.VisitEvent),
+		eventKeys:   make(map[string]models.VisitEvent),
+		nextTaskID:  1,
+
+		users:         make(map[string]*models.User),
+		refreshTokens: make(map[string]*models.RefreshToken),
+		apiKeys:       make(map[string]*models.APIKey),
 	}
 }
 
+var _ Repository = (*Store)(nil)
+
 func (s *Store) SetupInitialData() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.Schedules = make(map[string]*models.Schedule)
-	s.Tasks = make(map[int]*models.Task)
-
-	initialSchedules := []*models.Schedule{
-		{
-			ID:          "1",
-			ClientName:  "Melisa Adam",
-			ServiceName: "Casa Grande Apartment",
-			ShiftDate:   time.Now().Format("2006-01-02"),
-			ShiftTime:   "00:00 - 6:00",
-			AmOrPm:      "AM",
-			Status:      "scheduled",
-			Tasks: []models.Task{
-				{ID: 1, Name: "Give medication", Description: "Administer morning pills with water."},
-				{ID: 2, Name: "Assist with bathing", Description: "Ensure safety during shower."},
-			},
-			ClientContact: models.ClientContact{Email: "melisa@example.com", Phone: "+44 1232 212 3233"},
-			ServiceNotes:  "Client may be a bit groggy in the morning. Speak clearly and be patient.",
-			Location: models.Location{
-				Address: "123 Main St, Springfield, IL",
-				Coordinates: models.Geolocation{
-					Latitude:  40.712776,
-					Longitude: -74.005974,
-				},
-			},
-		},
-		{
-			ID:          "2",
-			ClientName:  "John Doe",
-			ServiceName: "Senior Living Center",
-			ShiftDate:   time.Now().Format("2006-01-02"),
-			ShiftTime:   "06:00 - 12:00",
-			AmOrPm:      "AM",
-			Status:      "scheduled",
-			Tasks: []models.Task{
-				{ID: 3, Name: "Prepare lunch", Description: "Low-sodium, soft food diet."},
-				{ID: 4, Name: "Light housekeeping", Description: "Tidy up living room and kitchen."},
-			},
-			ClientContact: models.ClientContact{Email: "john.doe@example.com", Phone: "+1 555 123 4567"},
-			ServiceNotes:  "John enjoys listening to classical music during his lunch.",
-			Location: models.Location{
-				Address: "456 Oak Ave, Springfield, IL",
-				Coordinates: models.Geolocation{
-					Latitude:  40.712776,
-					Longitude: -74.005974,
-				},
-			},
-		},
-		{
-			ID:          "3",
-			ClientName:  "Jane Smith",
-			ServiceName: "Private Residence",
-			ShiftDate:   time.Now().Format("2006-01-02"),
-			ShiftTime:   "2:00 - 3:00",
-			AmOrPm:      "AM",
-			Status:      "completed",
-			Tasks: []models.Task{
-				{ID: 5, Name: "Physical therapy exercises", Description: "Follow the chart from Dr. Evans.", Completed: false, NotCompletedReason: "Client was too tired."},
-				{ID: 6, Name: "Check vitals", Description: "Measure blood pressure and heart rate.", Completed: true},
-			},
-			ClientContact: models.ClientContact{Email: "jane.s@example.com", Phone: "+1 555 987 6543"},
-			ServiceNotes:  "Client was in good spirits and completed all exercises without issue.",
-			Location: models.Location{
-				Address: "789 Pine Rd, Springfield, IL",
-				Coordinates: models.Geolocation{
-					Latitude:  40.712776,
-					Longitude: -74.005974,
-				},
-			},
-		},
-		{
-			ID:          "4",
-			ClientName:  "Alice Johnson",
-			ServiceName: "Community Health Center",
-			ShiftDate:   time.Now().Format("2006-01-02"),
-			ShiftTime:   "00:00 - 06:00",
-			AmOrPm:      "PM",
-			Status:      "scheduled",
-			Tasks: []models.Task{
-				{ID: 7, Name: "Administer insulin", Description: "Check blood sugar before administering."},
-				{ID: 8, Name: "Assist with mobility", Description: "Help client walk to the therapy room."},
-			},
-			ClientContact: models.ClientContact{Email: "alice@example.com", Phone: "+1 555 321 6543"},
-			ServiceNotes:  "Alice is diabetic and requires regular monitoring. Ensure she has her glucose meter.",
-			Location: models.Location{
-				Address: "321 Maple St, Springfield, IL",
-				Coordinates: models.Geolocation{
-					Latitude:  40.712776,
-					Longitude: -74.005974,
-				},
-			},
-		},
-		{
-			ID:          "5",
-			ClientName:  "Bob Brown",
-			ServiceName: "Assisted Living Facility",
-			ShiftDate:   time.Now().Format("2006-01-02"),
-			ShiftTime:   "06:00 - 11:59",
-			AmOrPm:      "PM",
-			Status:      "scheduled",
-			Tasks: []models.Task{
-				{ID: 9, Name: "Monitor heart rate", Description: "Use the portable ECG machine."},
-				{ID: 10, Name: "Provide companionship", Description: "Spend time reading and chatting."},
-			},
-			ClientContact: models.ClientContact{Email: "bob@example.com", Phone: "+1 555 456 7890"},
-			ServiceNotes:  "Bob enjoys reading mystery novels. Bring a book to read together.",
-			Location: models.Location{
-				Address: "654 Cedar Blvd, Springfield, IL",
-				Coordinates: models.Geolocation{
-					Latitude:  40.712776,
-					Longitude: -74.005974,
-				},
-			},
-		},
-		{
-			ID:          "6",
-			ClientName:  "Charlie Green",
-			ServiceName: "Home Care Services",
-			ShiftDate:   time.Now().Format("2006-01-02"),
-			ShiftTime:   "2:00 - 3:00",
-			AmOrPm:      "PM",
-			Status:      "missed",
-			Tasks: []models.Task{
-				{ID: 11, Name: "Check medication schedule", Description: "Ensure all medications are taken as prescribed.", Completed: false, NotCompletedReason: "Client was not home."},
-				{ID: 12, Name: "Assist with meal prep", Description: "Prepare a light snack for the client.", Completed: false, NotCompletedReason: "Client refused meal."},
-			},
-			ClientContact: models.ClientContact{Email: "charlie@example.com", Phone: "+1 555 789 1234"},
-			ServiceNotes:  "Charlie was not home during the scheduled visit. Attempted to call but no answer.",
-			Location: models.Location{
-				Address: "987 Birch St, Springfield, IL",
-				Coordinates: models.Geolocation{
-					Latitude:  40.712776,
-					Longitude: -74.005974,
-				},
-			},
-		},
-	}
-
-	for _, schedule := range initialSchedules {
-		s.Schedules[schedule.ID] = schedule
-		for i := range schedule.Tasks {
-			task := schedule.Tasks[i]
-			s.Tasks[task.ID] = &task
-		}
+	s.schedules = make(map[string]*models.Schedule)
+	s.caregivers = make(map[string]*models.Caregiver)
+	s.clients = make(map[string]*models.Client)
+	s.templates = make(map[string]*models.TaskTemplate)
+	s.carePlans = make(map[string]*models.CarePlan)
+	s.recurrences = make(map[string]*models.Recurrence)
+	s.events = make(map[string][]models.VisitEvent)
+	s.eventKeys = make(map[string]models.VisitEvent)
+	s.nextEventID = 0
+	// nextTaskID is kept, so that IDs handed out before a reset are not
+	// given to other tasks after it. Users, refresh tokens and API keys are
+	// kept too: accounts are not part of the seed data.
+
+	for _, caregiver := range seedCaregivers() {
+		s.caregivers[caregiver.ID] = caregiver
+	}
+	for _, client := range seedClients() {
+		s.clients[client.ID] = client
+	}
+	for _, template := range seedTaskTemplates() {
+		s.templates[template.Code] = template
+	}
+	for _, plan := range seedCarePlans() {
+		s.carePlans[plan.ClientID] = plan
+	}
+	for _, schedule := range seedSchedules() {
+		s.assignTaskIDs(schedule.Tasks)
+		firstVersion(schedule)
+		s.schedules[schedule.ID] = schedule
 	}
 	log.Println("In-memory data store initialized.")
 }
+
+// Reset implements Repository by reloading the seed data.
+func (s *Store) Reset() error {
+	unlock := s.lockAll()
+	defer unlock()
+	s.SetupInitialData()
+	return nil
+}
+
+func (s *Store) ListSchedules() ([]*models.Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedules := make([]*models.Schedule, 0, len(s.schedules))
+	for id := range s.schedules {
+		schedules = append(schedules, s.project(id))
+	}
+	return schedules, nil
+}
+
+func (s *Store) GetSchedule(id string) (*models.Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.schedules[id]; !ok {
+		return nil, fmt.Errorf("schedule %s: %w", id, ErrNotFound)
+	}
+	return s.project(id), nil
+}
+
+func (s *Store) CreateSchedule(schedule *models.Schedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.schedules[schedule.ID]; ok {
+		return fmt.Errorf("schedule %s already exists", schedule.ID)
+	}
+	if err := s.checkTaskIDs(schedule.ID, schedule.Tasks); err != nil {
+		return err
+	}
+	s.assignTaskIDs(schedule.Tasks)
+	firstVersion(schedule)
+	s.schedules[schedule.ID] = cloneSchedule(schedule)
+	return nil
+}
+
+func (s *Store) UpdateSchedule(schedule *models.Schedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.schedules[schedule.ID]
+	if !ok {
+		return fmt.Errorf("schedule %s: %w", schedule.ID, ErrNotFound)
+	}
+	if err := s.checkTaskIDs(schedule.ID, schedule.Tasks); err != nil {
+		return err
+	}
+	s.assignTaskIDs(schedule.Tasks)
+	updated := cloneSchedule(schedule)
+	updated.Visit = existing.Visit
+	updated.Version = existing.Version + 1
+	for i := range updated.Tasks {
+		updated.Tasks[i].Version = 1
+		for _, old := range existing.Tasks {
+			if old.ID == updated.Tasks[i].ID {
+				updated.Tasks[i].Completed = old.Completed
+				updated.Tasks[i].NotCompletedReason = old.NotCompletedReason
+				updated.Tasks[i].Version = old.Version
+			}
+		}
+	}
+	s.schedules[schedule.ID] = updated
+	schedule.Version++
+	return nil
+}
+
+func (s *Store) DeleteSchedule(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.schedules[id]; !ok {
+		return fmt.Errorf("schedule %s: %w", id, ErrNotFound)
+	}
+	// The events are kept: they are the audit trail of the visit.
+	delete(s.schedules, id)
+	return nil
+}
+
+func (s *Store) ListTasks(scheduleID string) ([]models.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.schedules[scheduleID]; !ok {
+		return nil, fmt.Errorf("schedule %s: %w", scheduleID, ErrNotFound)
+	}
+	return s.project(scheduleID).Tasks, nil
+}
+
+func (s *Store) GetTask(scheduleID string, taskID int) (*models.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.schedules[scheduleID]; !ok {
+		return nil, fmt.Errorf("schedule %s: %w", scheduleID, ErrNotFound)
+	}
+	for _, task := range s.project(scheduleID).Tasks {
+		if task.ID == taskID {
+			return &task, nil
+		}
+	}
+	return nil, fmt.Errorf("task %d in schedule %s: %w", taskID, scheduleID, ErrNotFound)
+}
+
+func (s *Store) CreateTask(scheduleID string, task *models.Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedule, ok := s.schedules[scheduleID]
+	if !ok {
+		return fmt.Errorf("schedule %s: %w", scheduleID, ErrNotFound)
+	}
+	tasks := []models.Task{*task}
+	if err := s.checkTaskIDs(scheduleID, tasks); err != nil {
+		return err
+	}
+	s.assignTaskIDs(tasks)
+	task.ID = tasks[0].ID
+	task.Version = 1
+	schedule.Tasks = append(schedule.Tasks, *task)
+	schedule.Version++
+	return nil
+}
+
+func (s *Store) UpdateTask(scheduleID string, task *models.Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedule, ok := s.schedules[scheduleID]
+	if !ok {
+		return fmt.Errorf("schedule %s: %w", scheduleID, ErrNotFound)
+	}
+	for i := range schedule.Tasks {
+		if schedule.Tasks[i].ID == task.ID {
+			schedule.Tasks[i].Name = task.Name
+			schedule.Tasks[i].Description = task.Description
+			schedule.Tasks[i].Required = task.Required
+			schedule.Tasks[i].Version++
+			schedule.Version++
+			task.Version++
+			return nil
+		}
+	}
+	return fmt.Errorf("task %d in schedule %s: %w", task.ID, scheduleID, ErrNotFound)
+}
+
+func (s *Store) DeleteTask(scheduleID string, taskID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedule, ok := s.schedules[scheduleID]
+	if !ok {
+		return fmt.Errorf("schedule %s: %w", scheduleID, ErrNotFound)
+	}
+	for i := range schedule.Tasks {
+		if schedule.Tasks[i].ID == taskID {
+			schedule.Tasks = append(schedule.Tasks[:i:i], schedule.Tasks[i+1:]...)
+			schedule.Version++
+			return nil
+		}
+	}
+	return fmt.Errorf("task %d in schedule %s: %w", taskID, scheduleID, ErrNotFound)
+}
+
+func (s *Store) ReorderTasks(scheduleID string, taskIDs []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedule, ok := s.schedules[scheduleID]
+	if !ok {
+		return fmt.Errorf("schedule %s: %w", scheduleID, ErrNotFound)
+	}
+	if err := checkTaskOrder(schedule.Tasks, taskIDs); err != nil {
+		return err
+	}
+	byID := make(map[int]models.Task, len(schedule.Tasks))
+	for _, task := range schedule.Tasks {
+		byID[task.ID] = task
+	}
+	reordered := make([]models.Task, 0, len(taskIDs))
+	for _, id := range taskIDs {
+		reordered = append(reordered, byID[id])
+	}
+	schedule.Tasks = reordered
+	schedule.Version++
+	return nil
+}
+
+func (s *Store) TaskSchedule(taskID int) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, schedule := range s.schedules {
+		for _, task := range schedule.Tasks {
+			if task.ID == taskID {
+				return id, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("task %d: %w", taskID, ErrNotFound)
+}
+
+// assignTaskIDs numbers tasks with ID 0 from the sequence, and moves the
+// sequence past any ID given explicitly.
+func (s *Store) assignTaskIDs(tasks []models.Task) {
+	for i := range tasks {
+		if tasks[i].ID == 0 {
+			tasks[i].ID = s.nextTaskID
+		}
+		if tasks[i].ID >= s.nextTaskID {
+			s.nextTaskID = tasks[i].ID + 1
+		}
+	}
+}
+
+// checkTaskIDs fails if another schedule already owns one of the tasks.
+func (s *Store) checkTaskIDs(scheduleID string, tasks []models.Task) error {
+	for id, schedule := range s.schedules {
+		if id == scheduleID {
+			continue
+		}
+		for _, owned := range schedule.Tasks {
+			for _, task := range tasks {
+				if task.ID != 0 && task.ID == owned.ID {
+					return fmt.Errorf("task %d already belongs to schedule %s", task.ID, id)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Store) ListCaregivers() ([]*models.Caregiver, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	caregivers := make([]*models.Caregiver, 0, len(s.caregivers))
+	for _, caregiver := range s.caregivers {
+		caregivers = append(caregivers, cloneCaregiver(caregiver))
+	}
+	sort.Slice(caregivers, func(i, j int) bool { return caregivers[i].ID < caregivers[j].ID })
+	return caregivers, nil
+}
+
+func (s *Store) GetCaregiver(id string) (*models.Caregiver, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	caregiver, ok := s.caregivers[id]
+	if !ok {
+		return nil, fmt.Errorf("caregiver %s: %w", id, ErrNotFound)
+	}
+	return cloneCaregiver(caregiver), nil
+}
+
+func (s *Store) CreateCaregiver(caregiver *models.Caregiver) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.caregivers[caregiver.ID]; ok {
+		return fmt.Errorf("caregiver %s already exists", caregiver.ID)
+	}
+	s.caregivers[caregiver.ID] = cloneCaregiver(caregiver)
+	return nil
+}
+
+func (s *Store) UpdateCaregiver(caregiver *models.Caregiver) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.caregivers[caregiver.ID]; !ok {
+		return fmt.Errorf("caregiver %s: %w", caregiver.ID, ErrNotFound)
+	}
+	s.caregivers[caregiver.ID] = cloneCaregiver(caregiver)
+	return nil
+}
+
+func (s *Store) DeleteCaregiver(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.caregivers[id]; !ok {
+		return fmt.Errorf("caregiver %s: %w", id, ErrNotFound)
+	}
+	assigned := 0
+	for _, schedule := range s.schedules {
+		if schedule.CaregiverID == id {
+			assigned++
+		}
+	}
+	if assigned > 0 {
+		return fmt.Errorf("caregiver %s is assigned to %d schedules: %w", id, assigned, ErrInUse)
+	}
+	delete(s.caregivers, id)
+	return nil
+}
+
+func (s *Store) ListClients() ([]*models.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clients := make([]*models.Client, 0, len(s.clients))
+	for _, client := range s.clients {
+		clients = append(clients, cloneClient(client))
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].ID < clients[j].ID })
+	return clients, nil
+}
+
+func (s *Store) GetClient(id string) (*models.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	client, ok := s.clients[id]
+	if !ok {
+		return nil, fmt.Errorf("client %s: %w", id, ErrNotFound)
+	}
+	return cloneClient(client), nil
+}
+
+func (s *Store) CreateClient(client *models.Client) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.clients[client.ID]; ok {
+		return fmt.Errorf("client %s already exists", client.ID)
+	}
+	s.clients[client.ID] = cloneClient(client)
+	return nil
+}
+
+func (s *Store) UpdateClient(client *models.Client) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.clients[client.ID]; !ok {
+		return fmt.Errorf("client %s: %w", client.ID, ErrNotFound)
+	}
+	s.clients[client.ID] = cloneClient(client)
+	return nil
+}
+
+func (s *Store) DeleteClient(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.clients[id]; !ok {
+		return fmt.Errorf("client %s: %w", id, ErrNotFound)
+	}
+	referenced := 0
+	for _, schedule := range s.schedules {
+		if schedule.ClientID == id {
+			referenced++
+		}
+	}
+	if referenced > 0 {
+		return fmt.Errorf("client %s has %d schedules: %w", id, referenced, ErrInUse)
+	}
+	delete(s.clients, id)
+	delete(s.carePlans, id)
+	return nil
+}
+
+func (s *Store) ListTaskTemplates() ([]*models.TaskTemplate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	templates := make([]*models.TaskTemplate, 0, len(s.templates))
+	for _, template := range s.templates {
+		clone := *template
+		templates = append(templates, &clone)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Code < templates[j].Code })
+	return templates, nil
+}
+
+func (s *Store) GetTaskTemplate(code string) (*models.TaskTemplate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	template, ok := s.templates[code]
+	if !ok {
+		return nil, fmt.Errorf("task template %s: %w", code, ErrNotFound)
+	}
+	clone := *template
+	return &clone, nil
+}
+
+func (s *Store) CreateTaskTemplate(template *models.TaskTemplate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.templates[template.Code]; ok {
+		return fmt.Errorf("task template %s: %w", template.Code, ErrExists)
+	}
+	clone := *template
+	s.templates[template.Code] = &clone
+	return nil
+}
+
+func (s *Store) UpdateTaskTemplate(template *models.TaskTemplate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.templates[template.Code]; !ok {
+		return fmt.Errorf("task template %s: %w", template.Code, ErrNotFound)
+	}
+	clone := *template
+	s.templates[template.Code] = &clone
+	return nil
+}
+
+func (s *Store) DeleteTaskTemplate(code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.templates[code]; !ok {
+		return fmt.Errorf("task template %s: %w", code, ErrNotFound)
+	}
+	referenced := 0
+	for _, plan := range s.carePlans {
+		for _, task := range plan.Tasks {
+			if task.TemplateCode == code {
+				referenced++
+				break
+			}
+		}
+	}
+	if referenced > 0 {
+		return fmt.Errorf("task template %s is on %d care plans: %w", code, referenced, ErrInUse)
+	}
+	delete(s.templates, code)
+	return nil
+}
+
+func (s *Store) GetCarePlan(clientID string) (*models.CarePlan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.clients[clientID]; !ok {
+		return nil, fmt.Errorf("client %s: %w", clientID, ErrNotFound)
+	}
+	plan, ok := s.carePlans[clientID]
+	if !ok {
+		return &models.CarePlan{ClientID: clientID, Tasks: []models.CarePlanTask{}}, nil
+	}
+	return cloneCarePlan(plan), nil
+}
+
+func (s *Store) SetCarePlan(plan *models.CarePlan) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.clients[plan.ClientID]; !ok {
+		return fmt.Errorf("client %s: %w", plan.ClientID, ErrNotFound)
+	}
+	for _, task := range plan.Tasks {
+		if _, ok := s.templates[task.TemplateCode]; !ok {
+			return fmt.Errorf("task template %s: %w", task.TemplateCode, ErrNotFound)
+		}
+	}
+	s.carePlans[plan.ClientID] = cloneCarePlan(plan)
+	return nil
+}
+
+func (s *Store) ListRecurrences() ([]*models.Recurrence, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recurrences := make([]*models.Recurrence, 0, len(s.recurrences))
+	for _, recurrence := range s.recurrences {
+		recurrences = append(recurrences, cloneRecurrence(recurrence))
+	}
+	sort.Slice(recurrences, func(i, j int) bool { return recurrences[i].ID < recurrences[j].ID })
+	return recurrences, nil
+}
+
+func (s *Store) GetRecurrence(id string) (*models.Recurrence, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recurrence, ok := s.recurrences[id]
+	if !ok {
+		return nil, fmt.Errorf("recurrence %s: %w", id, ErrNotFound)
+	}
+	return cloneRecurrence(recurrence), nil
+}
+
+func (s *Store) CreateRecurrence(recurrence *models.Recurrence) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.recurrences[recurrence.ID]; ok {
+		return fmt.Errorf("recurrence %s already exists", recurrence.ID)
+	}
+	s.recurrences[recurrence.ID] = cloneRecurrence(recurrence)
+	return nil
+}
+
+func (s *Store) UpdateRecurrence(recurrence *models.Recurrence) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.recurrences[recurrence.ID]; !ok {
+		return fmt.Errorf("recurrence %s: %w", recurrence.ID, ErrNotFound)
+	}
+	s.recurrences[recurrence.ID] = cloneRecurrence(recurrence)
+	return nil
+}
+
+func (s *Store) AddExceptionDate(recurrenceID, date string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recurrence, ok := s.recurrences[recurrenceID]
+	if !ok {
+		return fmt.Errorf("recurrence %s: %w", recurrenceID, ErrNotFound)
+	}
+	recurrence.ExceptionDates = addExceptionDate(recurrence.ExceptionDates, date)
+	return nil
+}
+
+func (s *Store) DeleteRecurrence(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.recurrences[id]; !ok {
+		return fmt.Errorf("recurrence %s: %w", id, ErrNotFound)
+	}
+	delete(s.recurrences, id)
+	return nil
+}
+
+func (s *Store) GetVisit(scheduleID string) (*models.Visit, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.schedules[scheduleID]; !ok {
+		return nil, fmt.Errorf("schedule %s: %w", scheduleID, ErrNotFound)
+	}
+	visit := s.project(scheduleID).Visit
+	return &visit, nil
+}
+
+func (s *Store) AppendEvent(event *models.VisitEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.schedules[event.ScheduleID]; !ok {
+		return fmt.Errorf("schedule %s: %w", event.ScheduleID, ErrNotFound)
+	}
+	if event.IdempotencyKey != "" {
+		if _, ok := s.eventKeys[event.IdempotencyKey]; ok {
+			return fmt.Errorf("event %s: %w", event.IdempotencyKey, ErrDuplicateKey)
+		}
+	}
+	s.nextEventID++
+	event.ID = s.nextEventID
+	s.events[event.ScheduleID] = append(s.events[event.ScheduleID], cloneEvent(*event))
+	if event.IdempotencyKey != "" {
+		s.eventKeys[event.IdempotencyKey] = cloneEvent(*event)
+	}
+	return nil
+}
+
+func (s *Store) GetEventByIdempotencyKey(key string) (*models.VisitEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event, ok := s.eventKeys[key]
+	if !ok {
+		return nil, fmt.Errorf("event %s: %w", key, ErrNotFound)
+	}
+	event = cloneEvent(event)
+	return &event, nil
+}
+
+func (s *Store) ListEvents(scheduleID string) ([]models.VisitEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.schedules[scheduleID]; !ok {
+		return nil, fmt.Errorf("schedule %s: %w", scheduleID, ErrNotFound)
+	}
+	events := make([]models.VisitEvent, 0, len(s.events[scheduleID]))
+	for _, event := range s.events[scheduleID] {
+		events = append(events, cloneEvent(event))
+	}
+	return events, nil
+}
+
+func (s *Store) GetUser(id string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return nil, fmt.Errorf("user %s: %w", id, ErrNotFound)
+	}
+	return clonePtr(user), nil
+}
+
+func (s *Store) GetUserByUsername(username string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if user.Username == username {
+			return clonePtr(user), nil
+		}
+	}
+	return nil, fmt.Errorf("user %s: %w", username, ErrNotFound)
+}
+
+func (s *Store) CreateUser(user *models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.users {
+		if existing.Username == user.Username {
+			return fmt.Errorf("user %s: %w", user.Username, ErrExists)
+		}
+	}
+	s.users[user.ID] = clonePtr(user)
+	return nil
+}
+
+func (s *Store) CountUsers(role models.Role) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for _, user := range s.users {
+		if user.Role == role {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Store) CreateRefreshToken(token *models.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[token.UserID]; !ok {
+		return fmt.Errorf("user %s: %w", token.UserID, ErrNotFound)
+	}
+	s.refreshTokens[token.ID] = cloneRefreshToken(token)
+	return nil
+}
+
+func (s *Store) GetRefreshToken(hash string) (*models.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.refreshTokens {
+		if token.Hash == hash {
+			return cloneRefreshToken(token), nil
+		}
+	}
+	return nil, fmt.Errorf("refresh token: %w", ErrNotFound)
+}
+
+func (s *Store) RevokeRefreshToken(id string, at time.Time, replacedBy string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.refreshTokens[id]
+	if !ok {
+		return false, fmt.Errorf("refresh token %s: %w", id, ErrNotFound)
+	}
+	if token.RevokedAt != nil {
+		return false, nil
+	}
+	token.RevokedAt = &at
+	token.ReplacedBy = replacedBy
+	return true, nil
+}
+
+func (s *Store) RevokeUserRefreshTokens(userID string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.refreshTokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			token.RevokedAt = &at
+		}
+	}
+	return nil
+}
+
+func (s *Store) CreateAPIKey(key *models.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.apiKeys[key.ID] = cloneAPIKey(key)
+	return nil
+}
+
+func (s *Store) ListAPIKeys() ([]*models.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]*models.APIKey, 0, len(s.apiKeys))
+	for _, key := range s.apiKeys {
+		keys = append(keys, cloneAPIKey(key))
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+	return keys, nil
+}
+
+func (s *Store) GetAPIKeyByHash(hash string) (*models.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range s.apiKeys {
+		if key.Hash == hash {
+			return cloneAPIKey(key), nil
+		}
+	}
+	return nil, fmt.Errorf("API key: %w", ErrNotFound)
+}
+
+func (s *Store) RevokeAPIKey(id string, at time.Time) (*models.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.apiKeys[id]
+	if !ok {
+		return nil, fmt.Errorf("API key %s: %w", id, ErrNotFound)
+	}
+	if key.RevokedAt == nil {
+		key.RevokedAt = &at
+	}
+	return cloneAPIKey(key), nil
+}
+
+func (s *Store) TouchAPIKey(id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.apiKeys[id]
+	if !ok {
+		return fmt.Errorf("API key %s: %w", id, ErrNotFound)
+	}
+	key.LastUsedAt = &at
+	return nil
+}
+
+// project rebuilds the current state of a schedule from its planned record
+// and its event log. Callers must hold s.mu.
+func (s *Store) project(id string) *models.Schedule {
+	schedule := cloneSchedule(s.schedules[id])
+	schedule.Replay(s.events[id])
+	return schedule
+}
+
+// cloneSchedule returns a deep copy so callers never share slices or
+// pointers with the map.
+func cloneSchedule(schedule *models.Schedule) *models.Schedule {
+	clone := *schedule
+	clone.Tasks = cloneTasks(schedule.Tasks)
+	clone.Visit = cloneVisit(schedule.Visit)
+	return &clone
+}
+
+func cloneCaregiver(caregiver *models.Caregiver) *models.Caregiver {
+	clone := *caregiver
+	clone.Credentials = append([]string{}, caregiver.Credentials...)
+	return &clone
+}
+
+func cloneClient(client *models.Client) *models.Client {
+	clone := *client
+	clone.EmergencyContacts = append([]models.EmergencyContact{}, client.EmergencyContacts...)
+	return &clone
+}
+
+func cloneCarePlan(plan *models.CarePlan) *models.CarePlan {
+	clone := *plan
+	clone.Tasks = make([]models.CarePlanTask, len(plan.Tasks))
+	for i, task := range plan.Tasks {
+		task.Required = clonePtr(task.Required)
+		clone.Tasks[i] = task
+	}
+	return &clone
+}
+
+func cloneRecurrence(recurrence *models.Recurrence) *models.Recurrence {
+	clone := *recurrence
+	clone.ExceptionDates = append([]string{}, recurrence.ExceptionDates...)
+	clone.Tasks = append([]models.AddTaskRequest{}, recurrence.Tasks...)
+	return &clone
+}
+
+func cloneTasks(tasks []models.Task) []models.Task {
+	clone := make([]models.Task, len(tasks))
+	copy(clone, tasks)
+	return clone
+}
+
+func cloneVisit(visit models.Visit) models.Visit {
+	visit.ClockInTime = clonePtr(visit.ClockInTime)
+	visit.ClockOutTime = clonePtr(visit.ClockOutTime)
+	visit.ClockInReceivedAt = clonePtr(visit.ClockInReceivedAt)
+	visit.ClockOutReceivedAt = clonePtr(visit.ClockOutReceivedAt)
+	visit.ClockInLocation = clonePtr(visit.ClockInLocation)
+	visit.ClockOutLocation = clonePtr(visit.ClockOutLocation)
+	visit.ClockInDistanceMeters = clonePtr(visit.ClockInDistanceMeters)
+	visit.ClockOutDistanceMeters = clonePtr(visit.ClockOutDistanceMeters)
+	visit.Exceptions = append([]models.VisitException(nil), visit.Exceptions...)
+	return visit
+}
+
+func cloneEvent(event models.VisitEvent) models.VisitEvent {
+	event.Location = clonePtr(event.Location)
+	event.DistanceMeters = clonePtr(event.DistanceMeters)
+	event.DeviceTime = clonePtr(event.DeviceTime)
+	return event
+}
+
+func cloneRefreshToken(token *models.RefreshToken) *models.RefreshToken {
+	clone := *token
+	clone.RevokedAt = clonePtr(token.RevokedAt)
+	return &clone
+}
+
+func cloneAPIKey(key *models.APIKey) *models.APIKey {
+	clone := *key
+	clone.Scopes = append([]string(nil), key.Scopes...)
+	clone.LastUsedAt = clonePtr(key.LastUsedAt)
+	clone.RevokedAt = clonePtr(key.RevokedAt)
+	return &clone
+}
+
+func clonePtr[T any](v *T) *T {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
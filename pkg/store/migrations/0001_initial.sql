@@ -0,0 +1,32 @@
+CREATE TABLE schedules (
+    id                  TEXT PRIMARY KEY,
+    client_name         TEXT NOT NULL,
+    service_name        TEXT NOT NULL,
+    shift_date          TEXT NOT NULL,
+    shift_time          TEXT NOT NULL,
+    am_or_pm            TEXT NOT NULL,
+    client_email        TEXT NOT NULL DEFAULT '',
+    client_phone        TEXT NOT NULL DEFAULT '',
+    service_notes       TEXT NOT NULL DEFAULT '',
+    address             TEXT NOT NULL DEFAULT '',
+    latitude            REAL NOT NULL DEFAULT 0,
+    longitude           REAL NOT NULL DEFAULT 0,
+    status              TEXT NOT NULL,
+    clock_in_time       TEXT,
+    clock_in_latitude   REAL,
+    clock_in_longitude  REAL,
+    clock_out_time      TEXT,
+    clock_out_latitude  REAL,
+    clock_out_longitude REAL
+);
+
+CREATE TABLE tasks (
+    schedule_id          TEXT    NOT NULL REFERENCES schedules (id) ON DELETE CASCADE,
+    id                   INTEGER NOT NULL,
+    position             INTEGER NOT NULL,
+    name                 TEXT    NOT NULL,
+    description          TEXT    NOT NULL DEFAULT '',
+    completed            INTEGER NOT NULL DEFAULT 0,
+    not_completed_reason TEXT    NOT NULL DEFAULT '',
+    PRIMARY KEY (schedule_id, id)
+);
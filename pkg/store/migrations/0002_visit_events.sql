@@ -0,0 +1,12 @@
+-- Visit state is now event sourced. The status and clock columns on
+-- schedules (and task completion columns) keep whatever they held at this
+-- point and act as the base the events are replayed on.
+CREATE TABLE visit_events (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    schedule_id TEXT NOT NULL REFERENCES schedules (id) ON DELETE CASCADE,
+    type        TEXT NOT NULL,
+    occurred_at TEXT NOT NULL,
+    data        TEXT NOT NULL
+);
+
+CREATE INDEX visit_events_schedule_id ON visit_events (schedule_id, id);
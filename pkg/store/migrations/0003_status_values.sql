@@ -0,0 +1,3 @@
+-- Visit statuses are now a closed set; normalise the spelling used by the
+-- old model comment.
+UPDATE schedules SET status = 'in_progress' WHERE status = 'in-progress';
@@ -0,0 +1,2 @@
+-- 0 means "use the configured default radius".
+ALTER TABLE schedules ADD COLUMN geofence_radius_meters REAL NOT NULL DEFAULT 0;
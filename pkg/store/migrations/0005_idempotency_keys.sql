@@ -0,0 +1,4 @@
+ALTER TABLE visit_events ADD COLUMN idempotency_key TEXT;
+
+CREATE UNIQUE INDEX visit_events_idempotency_key ON visit_events (idempotency_key)
+    WHERE idempotency_key IS NOT NULL;
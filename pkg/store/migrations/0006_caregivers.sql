@@ -0,0 +1,10 @@
+CREATE TABLE caregivers (
+    id          TEXT PRIMARY KEY,
+    name        TEXT NOT NULL,
+    credentials TEXT NOT NULL DEFAULT '[]', -- JSON array of strings
+    phone       TEXT NOT NULL DEFAULT ''
+);
+
+ALTER TABLE schedules ADD COLUMN caregiver_id TEXT REFERENCES caregivers (id);
+
+CREATE INDEX schedules_caregiver_id ON schedules (caregiver_id);
@@ -0,0 +1,25 @@
+CREATE TABLE clients (
+    id                     TEXT PRIMARY KEY,
+    name                   TEXT NOT NULL,
+    email                  TEXT NOT NULL DEFAULT '',
+    phone                  TEXT NOT NULL DEFAULT '',
+    address                TEXT NOT NULL DEFAULT '',
+    latitude               REAL NOT NULL DEFAULT 0,
+    longitude              REAL NOT NULL DEFAULT 0,
+    geofence_radius_meters REAL NOT NULL DEFAULT 0,
+    emergency_contacts     TEXT NOT NULL DEFAULT '[]', -- JSON array
+    medicaid_id            TEXT NOT NULL DEFAULT '',
+    care_notes             TEXT NOT NULL DEFAULT ''
+);
+
+ALTER TABLE schedules ADD COLUMN client_id TEXT REFERENCES clients (id);
+
+CREATE INDEX schedules_client_id ON schedules (client_id);
+
+-- Existing schedules each become the reference of a client built from the
+-- details copied onto them.
+INSERT INTO clients (id, name, email, phone, address, latitude, longitude, geofence_radius_meters)
+SELECT id, client_name, client_email, client_phone, address, latitude, longitude, geofence_radius_meters
+FROM schedules;
+
+UPDATE schedules SET client_id = id;
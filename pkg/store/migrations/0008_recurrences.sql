@@ -0,0 +1,20 @@
+CREATE TABLE recurrences (
+    id              TEXT PRIMARY KEY,
+    client_id       TEXT NOT NULL,
+    caregiver_id    TEXT NOT NULL DEFAULT '',
+    service_name    TEXT NOT NULL,
+    service_notes   TEXT NOT NULL DEFAULT '',
+    start_date      TEXT NOT NULL,
+    shift_time      TEXT NOT NULL,
+    am_or_pm        TEXT NOT NULL,
+    rrule           TEXT NOT NULL,
+    exception_dates TEXT NOT NULL DEFAULT '[]', -- JSON array of YYYY-MM-DD
+    tasks           TEXT NOT NULL DEFAULT '[]'  -- JSON array of {name, description}
+);
+
+-- Generated schedules outlive the recurrence that produced them once they
+-- have clock data, so recurrence_id is not a foreign key.
+ALTER TABLE schedules ADD COLUMN recurrence_id TEXT;
+ALTER TABLE schedules ADD COLUMN occurrence_date TEXT;
+
+CREATE INDEX schedules_recurrence_id ON schedules (recurrence_id, occurrence_date);
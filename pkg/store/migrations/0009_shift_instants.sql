@@ -0,0 +1,11 @@
+-- Shifts are stored as instants (RFC 3339 in UTC, so text order is time
+-- order) plus the IANA zone they are planned in. shift_date, shift_time and
+-- am_or_pm are still written, rendered from these. Rows that predate this
+-- migration are backfilled from the legacy columns when the store opens.
+ALTER TABLE schedules ADD COLUMN shift_start TEXT;
+ALTER TABLE schedules ADD COLUMN shift_end TEXT;
+ALTER TABLE schedules ADD COLUMN time_zone TEXT;
+
+CREATE INDEX schedules_shift_start ON schedules (shift_start);
+
+ALTER TABLE recurrences ADD COLUMN time_zone TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- Empty means the caregiver works in the agency's zone.
+ALTER TABLE caregivers ADD COLUMN time_zone TEXT NOT NULL DEFAULT '';
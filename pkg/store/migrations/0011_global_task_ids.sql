@@ -0,0 +1,29 @@
+-- Task IDs used to be unique only within a schedule, and tasks added later
+-- reused IDs from other schedules. Renumber every task whose ID an earlier
+-- row already holds (fixing the taskId of its TaskMarked events too), then
+-- make IDs unique store-wide and hand out new ones from a sequence.
+CREATE TEMP TABLE task_renumbering AS
+    SELECT schedule_id, id AS old_id, (SELECT COALESCE(MAX(id), 0) FROM tasks) + rowid AS new_id
+    FROM tasks
+    WHERE rowid NOT IN (SELECT MIN(rowid) FROM tasks GROUP BY id);
+
+UPDATE visit_events
+SET data = json_set(data, '$.taskId', (
+    SELECT new_id FROM task_renumbering r
+    WHERE r.schedule_id = visit_events.schedule_id AND r.old_id = json_extract(visit_events.data, '$.taskId')))
+WHERE type = 'TaskMarked' AND EXISTS (
+    SELECT 1 FROM task_renumbering r
+    WHERE r.schedule_id = visit_events.schedule_id AND r.old_id = json_extract(visit_events.data, '$.taskId'));
+
+UPDATE tasks
+SET id = (SELECT new_id FROM task_renumbering r WHERE r.schedule_id = tasks.schedule_id AND r.old_id = tasks.id)
+WHERE EXISTS (SELECT 1 FROM task_renumbering r WHERE r.schedule_id = tasks.schedule_id AND r.old_id = tasks.id);
+
+DROP TABLE task_renumbering;
+
+CREATE UNIQUE INDEX tasks_id ON tasks (id);
+
+CREATE TABLE task_sequence (
+    next_id INTEGER NOT NULL
+);
+INSERT INTO task_sequence (next_id) SELECT COALESCE(MAX(id), 0) + 1 FROM tasks;
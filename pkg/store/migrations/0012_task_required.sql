@@ -0,0 +1,3 @@
+-- Tasks can be required: a visit cannot end until each required task is
+-- completed or has a not-completed reason.
+ALTER TABLE tasks ADD COLUMN required INTEGER NOT NULL DEFAULT 0;
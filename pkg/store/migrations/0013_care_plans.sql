@@ -0,0 +1,23 @@
+-- A catalog of reusable task templates, and per-client care plans built
+-- from them. Schedules get copies of the care plan tasks; template_code on
+-- tasks records where a copy came from.
+CREATE TABLE task_templates (
+    code        TEXT PRIMARY KEY,
+    name        TEXT    NOT NULL,
+    description TEXT    NOT NULL DEFAULT '',
+    category    TEXT    NOT NULL DEFAULT '',
+    required    INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE care_plan_tasks (
+    client_id     TEXT    NOT NULL REFERENCES clients (id) ON DELETE CASCADE,
+    position      INTEGER NOT NULL,
+    template_code TEXT    NOT NULL REFERENCES task_templates (code),
+    description   TEXT    NOT NULL DEFAULT '',
+    required      INTEGER, -- NULL keeps the template's flag
+    PRIMARY KEY (client_id, position)
+);
+
+CREATE INDEX care_plan_tasks_template_code ON care_plan_tasks (template_code);
+
+ALTER TABLE tasks ADD COLUMN template_code TEXT NOT NULL DEFAULT '';
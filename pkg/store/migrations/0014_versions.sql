@@ -0,0 +1,5 @@
+-- Version counters for optimistic concurrency: each edit to a schedule's
+-- plan or tasks bumps them, and visit events replayed on top add to them.
+-- They are served as ETags and checked against If-Match.
+ALTER TABLE schedules ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE tasks ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,21 @@
+-- Accounts that can log in, and the refresh tokens issued to them. Only a
+-- SHA-256 of each refresh token is kept.
+CREATE TABLE users (
+    id            TEXT PRIMARY KEY,
+    username      TEXT NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL,
+    role          TEXT NOT NULL,
+    caregiver_id  TEXT
+);
+
+CREATE TABLE refresh_tokens (
+    id         TEXT PRIMARY KEY,
+    user_id    TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL UNIQUE,
+    created_at TEXT NOT NULL,
+    expires_at TEXT NOT NULL,
+    revoked_at TEXT,
+    replaced_by TEXT
+);
+
+CREATE INDEX refresh_tokens_user_id ON refresh_tokens (user_id);
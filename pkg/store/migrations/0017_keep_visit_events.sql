@@ -0,0 +1,21 @@
+-- Visit events are the audit trail and must outlive their schedule, so they
+-- no longer cascade from it. SQLite cannot drop a foreign key, hence the
+-- table is rebuilt.
+CREATE TABLE visit_events_new (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    schedule_id     TEXT NOT NULL,
+    type            TEXT NOT NULL,
+    occurred_at     TEXT NOT NULL,
+    data            TEXT NOT NULL,
+    idempotency_key TEXT
+);
+
+INSERT INTO visit_events_new (id, schedule_id, type, occurred_at, data, idempotency_key)
+    SELECT id, schedule_id, type, occurred_at, data, idempotency_key FROM visit_events;
+
+DROP TABLE visit_events;
+ALTER TABLE visit_events_new RENAME TO visit_events;
+
+CREATE INDEX visit_events_schedule_id ON visit_events (schedule_id, id);
+CREATE UNIQUE INDEX visit_events_idempotency_key ON visit_events (idempotency_key)
+    WHERE idempotency_key IS NOT NULL;
@@ -0,0 +1,208 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/models"
+)
+
+var (
+	// ErrNotFound is returned (wrapped) by every Repository lookup that misses.
+	ErrNotFound = errors.New("not found")
+	// ErrDuplicateKey is returned (wrapped) by AppendEvent when an event with
+	// the same idempotency key was already recorded.
+	ErrDuplicateKey = errors.New("duplicate idempotency key")
+	// ErrInUse is returned (wrapped) when deleting a record that others still
+	// reference, such as a caregiver or client with schedules.
+	ErrInUse = errors.New("still in use")
+	// ErrExists is returned (wrapped) when creating a record whose
+	// caller-chosen key is already taken, such as a task template code.
+	ErrExists = errors.New("already exists")
+)
+
+// Repository is the persistence boundary used by the HTTP handlers. Values
+// returned by it are copies: callers mutate them and hand them back through
+// the matching Update method to persist the change.
+//
+// Visit state (status, clock-in/out) and task outcomes are event sourced:
+// they only change through AppendEvent, and schedules are returned with
+// their events already replayed. UpdateSchedule and UpdateTask leave those
+// fields untouched.
+//
+// Task IDs are unique across all schedules. CreateSchedule, UpdateSchedule
+// and CreateTask give every task with ID 0 the next ID of a store-wide
+// sequence, writing it back into the task passed in; IDs are never reused,
+// not even after Reset, which only brings back the seed tasks' own IDs.
+//
+// Schedules and tasks carry a version: new ones start at 1, and every write
+// that changes a schedule's plan or tasks bumps the schedule's version (and
+// the task's, for UpdateTask), also in the value passed in. Events replayed
+// on top add to them, so a version changes whenever the schedule returned
+// does.
+//
+// Each call is atomic on its own. A handler that reads a schedule, checks it
+// and then writes, such as recording a clock-in only if the visit is still
+// scheduled, holds LockSchedule for the whole sequence.
+type Repository interface {
+	// LockSchedule locks one schedule against the read-check-write sequences
+	// of other requests; call the returned function to release it. It does
+	// not block requests for other schedules, and does not need the schedule
+	// to exist. A caller must not take a second schedule lock while holding
+	// one. Reset waits until no schedule is locked.
+	LockSchedule(id string) (unlock func())
+	// LockRecurrence locks a recurring booking against other changes to
+	// its series and against the generation of its schedules. A caller
+	// holding it may take one schedule lock, never the other way round.
+	LockRecurrence(id string) (unlock func())
+
+	ListSchedules() ([]*models.Schedule, error)
+	GetSchedule(id string) (*models.Schedule, error)
+	CreateSchedule(schedule *models.Schedule) error
+	UpdateSchedule(schedule *models.Schedule) error
+	// DeleteSchedule removes a schedule with its tasks. Its event log is
+	// kept as the audit trail.
+	DeleteSchedule(id string) error
+
+	ListTasks(scheduleID string) ([]models.Task, error)
+	GetTask(scheduleID string, taskID int) (*models.Task, error)
+	CreateTask(scheduleID string, task *models.Task) error
+	// UpdateTask saves a task's name, description and required flag.
+	UpdateTask(scheduleID string, task *models.Task) error
+	DeleteTask(scheduleID string, taskID int) error
+	// ReorderTasks puts a schedule's tasks in the given order; taskIDs must
+	// list each of them exactly once, or a *models.ValidationError is
+	// returned.
+	ReorderTasks(scheduleID string, taskIDs []int) error
+	// TaskSchedule returns the ID of the schedule that owns the task.
+	TaskSchedule(taskID int) (string, error)
+
+	ListCaregivers() ([]*models.Caregiver, error)
+	GetCaregiver(id string) (*models.Caregiver, error)
+	CreateCaregiver(caregiver *models.Caregiver) error
+	UpdateCaregiver(caregiver *models.Caregiver) error
+	// DeleteCaregiver fails with ErrInUse while schedules are assigned to
+	// the caregiver.
+	DeleteCaregiver(id string) error
+
+	ListClients() ([]*models.Client, error)
+	GetClient(id string) (*models.Client, error)
+	CreateClient(client *models.Client) error
+	// UpdateClient changes the client only; the details copied onto its
+	// schedules are left to the caller.
+	UpdateClient(client *models.Client) error
+	// DeleteClient fails with ErrInUse while schedules reference the client.
+	// The client's care plan goes with it.
+	DeleteClient(id string) error
+
+	ListTaskTemplates() ([]*models.TaskTemplate, error)
+	GetTaskTemplate(code string) (*models.TaskTemplate, error)
+	// CreateTaskTemplate fails with ErrExists if the code is taken.
+	CreateTaskTemplate(template *models.TaskTemplate) error
+	UpdateTaskTemplate(template *models.TaskTemplate) error
+	// DeleteTaskTemplate fails with ErrInUse while care plans list the
+	// template. Tasks already copied from it are left alone.
+	DeleteTaskTemplate(code string) error
+
+	// GetCarePlan returns the client's care plan, empty if none was set.
+	GetCarePlan(clientID string) (*models.CarePlan, error)
+	// SetCarePlan replaces the client's care plan; every template it lists
+	// must exist.
+	SetCarePlan(plan *models.CarePlan) error
+
+	ListRecurrences() ([]*models.Recurrence, error)
+	GetRecurrence(id string) (*models.Recurrence, error)
+	CreateRecurrence(recurrence *models.Recurrence) error
+	UpdateRecurrence(recurrence *models.Recurrence) error
+	// AddExceptionDate records date (YYYY-MM-DD) as an exception of the
+	// recurrence, so it is never generated again. Adding it twice changes
+	// nothing.
+	AddExceptionDate(recurrenceID, date string) error
+	// DeleteRecurrence removes the rule only; schedules generated from it
+	// are left alone.
+	DeleteRecurrence(id string) error
+
+	GetVisit(scheduleID string) (*models.Visit, error)
+	// AppendEvent records an immutable visit event and assigns its ID.
+	AppendEvent(event *models.VisitEvent) error
+	ListEvents(scheduleID string) ([]models.VisitEvent, error)
+	GetEventByIdempotencyKey(key string) (*models.VisitEvent, error)
+
+	GetUser(id string) (*models.User, error)
+	GetUserByUsername(username string) (*models.User, error)
+	// CreateUser fails with ErrExists if the username is taken.
+	CreateUser(user *models.User) error
+	// CountUsers returns how many users have role.
+	CountUsers(role models.Role) (int, error)
+
+	// CreateRefreshToken stores a refresh token; GetRefreshToken finds it by
+	// the hash of the token.
+	CreateRefreshToken(token *models.RefreshToken) error
+	GetRefreshToken(hash string) (*models.RefreshToken, error)
+	// RevokeRefreshToken marks a token revoked, recording the token that
+	// replaces it if any, and reports whether it was still active, so that
+	// of two requests using one token only one wins.
+	RevokeRefreshToken(id string, at time.Time, replacedBy string) (bool, error)
+	// RevokeUserRefreshTokens revokes every active token of a user.
+	RevokeUserRefreshTokens(userID string, at time.Time) error
+
+	// API keys
+	CreateAPIKey(key *models.APIKey) error
+	// ListAPIKeys returns every key, revoked ones included, oldest first.
+	ListAPIKeys() ([]*models.APIKey, error)
+	// GetAPIKeyByHash finds a key, revoked or not, by the hash of its secret.
+	GetAPIKeyByHash(hash string) (*models.APIKey, error)
+	// RevokeAPIKey marks a key revoked, keeping the time of an earlier
+	// revocation, and returns it.
+	RevokeAPIKey(id string, at time.Time) (*models.APIKey, error)
+	// TouchAPIKey records that a key was used.
+	TouchAPIKey(id string, at time.Time) error
+
+	// Reset replaces all data with the initial seed set, once no schedule
+	// is locked. Users, their refresh tokens and API keys are kept.
+	Reset() error
+}
+
+// firstVersion gives a schedule about to be created, and its tasks, version
+// 1.
+func firstVersion(schedule *models.Schedule) {
+	schedule.Version = 1
+	for i := range schedule.Tasks {
+		schedule.Tasks[i].Version = 1
+	}
+}
+
+// checkTaskOrder fails with a *models.ValidationError on taskIds unless
+// taskIDs lists each of tasks exactly once.
+func checkTaskOrder(tasks []models.Task, taskIDs []int) error {
+	owned := make(map[int]bool, len(tasks))
+	for _, task := range tasks {
+		owned[task.ID] = true
+	}
+	listed := make(map[int]bool, len(taskIDs))
+	for _, id := range taskIDs {
+		if !owned[id] {
+			return models.InvalidField("taskIds", "invalid", fmt.Sprintf("Task %d is not part of this schedule", id))
+		}
+		if listed[id] {
+			return models.InvalidField("taskIds", "duplicate", fmt.Sprintf("Task %d is listed more than once", id))
+		}
+		listed[id] = true
+	}
+	if len(listed) != len(tasks) {
+		return models.InvalidField("taskIds", "incomplete", "taskIds must list every task of the schedule")
+	}
+	return nil
+}
+
+// addExceptionDate returns dates with date added once, sorted.
+func addExceptionDate(dates []string, date string) []string {
+	if slices.Contains(dates, date) {
+		return dates
+	}
+	dates = append(dates, date)
+	slices.Sort(dates)
+	return dates
+}
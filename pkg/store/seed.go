@@ -0,0 +1,271 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/models"
+)
+
+// seedCaregivers returns the demo caregivers the seed schedules are
+// assigned to.
+func seedCaregivers() []*models.Caregiver {
+	return []*models.Caregiver{
+		{ID: "1", Name: "Sarah Lee", Credentials: []string{"HHA", "CPR"}, Phone: "+1 555 987 6543"},
+		{ID: "2", Name: "Marcus Bell", Credentials: []string{"CNA"}, Phone: "+1 555 246 8101"},
+	}
+}
+
+// seedUsers returns the demo accounts, one per role plus one for each demo
+// caregiver. Each password is the username followed by "-demo", so they are
+// only added in demo mode, by SeedDemoUsers.
+func seedUsers() []*models.User {
+	return []*models.User{
+		{ID: "u-admin", Username: "admin", Role: models.RoleAdmin,
+			PasswordHash: "$2a$10$kraSAeH4gjGbigmNsdOgb.QvKa8r25ro.sHlbsl4SGchuVASeotQ6"},
+		{ID: "u-coordinator", Username: "coordinator", Role: models.RoleCoordinator,
+			PasswordHash: "$2a$10$OYbD9Se2a/P/5Rdm861NhuDKtatino8ExsjOJyxfhnAp2B4cxnW2O"},
+		{ID: "u-sarah", Username: "sarah", Role: models.RoleCaregiver, CaregiverID: "1",
+			PasswordHash: "$2a$10$zlfTvEL5LZyxdo3N/FhQy.BBBInnPTtTKZ7cAfaqBuaz3V16AP24q"},
+		{ID: "u-marcus", Username: "marcus", Role: models.RoleCaregiver, CaregiverID: "2",
+			PasswordHash: "$2a$10$aUFQK7ML0/5axWgpPcTrUe9SXXr6fpXAKROXBc4FxW0MynHq71ODe"},
+	}
+}
+
+// SeedDemoUsers adds the demo accounts repo does not have yet. Their
+// passwords are public: call it only for a demo deployment.
+func SeedDemoUsers(repo Repository) error {
+	for _, user := range seedUsers() {
+		if err := repo.CreateUser(user); err != nil && !errors.Is(err, ErrExists) {
+			return err
+		}
+	}
+	return nil
+}
+
+// UsersWithDemoPasswords returns the usernames of the demo accounts in repo
+// that still have their public password, as a database seeded before demo
+// mode existed does.
+func UsersWithDemoPasswords(repo Repository) ([]string, error) {
+	usernames := make([]string, 0)
+	for _, demo := range seedUsers() {
+		user, err := repo.GetUserByUsername(demo.Username)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if user.PasswordHash == demo.PasswordHash {
+			usernames = append(usernames, user.Username)
+		}
+	}
+	return usernames, nil
+}
+
+// seedTaskTemplates returns the demo task catalog.
+func seedTaskTemplates() []*models.TaskTemplate {
+	return []*models.TaskTemplate{
+		{Code: "MED", Name: "Give medication", Description: "Administer medication as prescribed.", Category: "medication", Required: true},
+		{Code: "VITALS", Name: "Check vitals", Description: "Measure blood pressure and heart rate.", Category: "clinical", Required: true},
+		{Code: "BATH", Name: "Assist with bathing", Description: "Ensure safety during shower.", Category: "personal_care"},
+		{Code: "MOBILITY", Name: "Assist with mobility", Description: "Help the client walk safely.", Category: "personal_care"},
+		{Code: "EXERCISE", Name: "Physical therapy exercises", Description: "Follow the therapist's exercise chart.", Category: "clinical"},
+		{Code: "MEAL", Name: "Prepare meal", Description: "Prepare a meal that fits the client's diet.", Category: "nutrition"},
+		{Code: "HOUSE", Name: "Light housekeeping", Description: "Tidy up living room and kitchen.", Category: "household"},
+		{Code: "COMPANION", Name: "Provide companionship", Description: "Spend time reading and chatting.", Category: "social"},
+	}
+}
+
+// seedCarePlans returns the demo care plans, built from seedTaskTemplates.
+func seedCarePlans() []*models.CarePlan {
+	return []*models.CarePlan{
+		{ClientID: "1", Tasks: []models.CarePlanTask{
+			{TemplateCode: "MED", Description: "Administer morning pills with water."},
+			{TemplateCode: "BATH"},
+		}},
+		{ClientID: "4", Tasks: []models.CarePlanTask{
+			{TemplateCode: "MED", Description: "Check blood sugar before administering insulin."},
+			{TemplateCode: "MOBILITY", Description: "Help client walk to the therapy room."},
+		}},
+	}
+}
+
+// seedClients returns the demo clients, one per seed schedule and sharing
+// its ID.
+func seedClients() []*models.Client {
+	clients := make([]*models.Client, 0)
+	for _, schedule := range seedSchedules() {
+		clients = append(clients, &models.Client{
+			ID:                schedule.ClientID,
+			Name:              schedule.ClientName,
+			Contact:           schedule.ClientContact,
+			Location:          schedule.Location,
+			EmergencyContacts: []models.EmergencyContact{},
+			MedicaidID:        "IL1000000" + schedule.ClientID,
+		})
+	}
+	return clients
+}
+
+// seedSchedules returns the demo schedules every backend starts from and
+// returns to on Reset. Their shifts are written in the legacy form and are
+// planned for today in the server's zone.
+func seedSchedules() []*models.Schedule {
+	zone := models.DefaultTimeZone()
+	// DefaultTimeZone only returns zones that load.
+	loc, _ := models.LoadZone(zone)
+	schedules := []*models.Schedule{
+		{
+			ID:          "1",
+			ClientID:    "1",
+			CaregiverID: "1",
+			ClientName:  "Melisa Adam",
+			ServiceName: "Casa Grande Apartment",
+			ShiftDate:   time.Now().In(loc).Format("2006-01-02"),
+			ShiftTime:   "00:00 - 6:00",
+			AmOrPm:      "AM",
+			Visit:       models.Visit{Status: models.StatusScheduled},
+			Tasks: []models.Task{
+				{ID: 1, Name: "Give medication", Description: "Administer morning pills with water.", TemplateCode: "MED"},
+				{ID: 2, Name: "Assist with bathing", Description: "Ensure safety during shower.", TemplateCode: "BATH"},
+			},
+			ClientContact: models.ClientContact{Email: "melisa@example.com", Phone: "+44 1232 212 3233"},
+			ServiceNotes:  "Client may be a bit groggy in the morning. Speak clearly and be patient.",
+			Location: models.Location{
+				Address: "123 Main St, Springfield, IL",
+				Coordinates: models.Geolocation{
+					Latitude:  40.712776,
+					Longitude: -74.005974,
+				},
+			},
+		},
+		{
+			ID:          "2",
+			ClientID:    "2",
+			CaregiverID: "2",
+			ClientName:  "John Doe",
+			ServiceName: "Senior Living Center",
+			ShiftDate:   time.Now().In(loc).Format("2006-01-02"),
+			ShiftTime:   "06:00 - 12:00",
+			AmOrPm:      "AM",
+			Visit:       models.Visit{Status: models.StatusScheduled},
+			Tasks: []models.Task{
+				{ID: 3, Name: "Prepare lunch", Description: "Low-sodium, soft food diet."},
+				{ID: 4, Name: "Light housekeeping", Description: "Tidy up living room and kitchen.", TemplateCode: "HOUSE"},
+			},
+			ClientContact: models.ClientContact{Email: "john.doe@example.com", Phone: "+1 555 123 4567"},
+			ServiceNotes:  "John enjoys listening to classical music during his lunch.",
+			Location: models.Location{
+				Address: "456 Oak Ave, Springfield, IL",
+				Coordinates: models.Geolocation{
+					Latitude:  40.712776,
+					Longitude: -74.005974,
+				},
+			},
+		},
+		{
+			ID:          "3",
+			ClientID:    "3",
+			CaregiverID: "1",
+			ClientName:  "Jane Smith",
+			ServiceName: "Private Residence",
+			ShiftDate:   time.Now().In(loc).Format("2006-01-02"),
+			ShiftTime:   "2:00 - 3:00",
+			AmOrPm:      "AM",
+			Visit:       models.Visit{Status: models.StatusCompleted},
+			Tasks: []models.Task{
+				{ID: 5, Name: "Physical therapy exercises", Description: "Follow the chart from Dr. Evans.", TemplateCode: "EXERCISE", Completed: false, NotCompletedReason: "Client was too tired."},
+				{ID: 6, Name: "Check vitals", Description: "Measure blood pressure and heart rate.", TemplateCode: "VITALS", Completed: true},
+			},
+			ClientContact: models.ClientContact{Email: "jane.s@example.com", Phone: "+1 555 987 6543"},
+			ServiceNotes:  "Client was in good spirits and completed all exercises without issue.",
+			Location: models.Location{
+				Address: "789 Pine Rd, Springfield, IL",
+				Coordinates: models.Geolocation{
+					Latitude:  40.712776,
+					Longitude: -74.005974,
+				},
+			},
+		},
+		{
+			ID:          "4",
+			ClientID:    "4",
+			CaregiverID: "2",
+			ClientName:  "Alice Johnson",
+			ServiceName: "Community Health Center",
+			ShiftDate:   time.Now().In(loc).Format("2006-01-02"),
+			ShiftTime:   "00:00 - 06:00",
+			AmOrPm:      "PM",
+			Visit:       models.Visit{Status: models.StatusScheduled},
+			Tasks: []models.Task{
+				{ID: 7, Name: "Administer insulin", Description: "Check blood sugar before administering."},
+				{ID: 8, Name: "Assist with mobility", Description: "Help client walk to the therapy room.", TemplateCode: "MOBILITY"},
+			},
+			ClientContact: models.ClientContact{Email: "alice@example.com", Phone: "+1 555 321 6543"},
+			ServiceNotes:  "Alice is diabetic and requires regular monitoring. Ensure she has her glucose meter.",
+			Location: models.Location{
+				Address: "321 Maple St, Springfield, IL",
+				Coordinates: models.Geolocation{
+					Latitude:  40.712776,
+					Longitude: -74.005974,
+				},
+			},
+		},
+		{
+			ID:          "5",
+			ClientID:    "5",
+			CaregiverID: "1",
+			ClientName:  "Bob Brown",
+			ServiceName: "Assisted Living Facility",
+			ShiftDate:   time.Now().In(loc).Format("2006-01-02"),
+			ShiftTime:   "06:00 - 11:59",
+			AmOrPm:      "PM",
+			Visit:       models.Visit{Status: models.StatusScheduled},
+			Tasks: []models.Task{
+				{ID: 9, Name: "Monitor heart rate", Description: "Use the portable ECG machine."},
+				{ID: 10, Name: "Provide companionship", Description: "Spend time reading and chatting.", TemplateCode: "COMPANION"},
+			},
+			ClientContact: models.ClientContact{Email: "bob@example.com", Phone: "+1 555 456 7890"},
+			ServiceNotes:  "Bob enjoys reading mystery novels. Bring a book to read together.",
+			Location: models.Location{
+				Address: "654 Cedar Blvd, Springfield, IL",
+				Coordinates: models.Geolocation{
+					Latitude:  40.712776,
+					Longitude: -74.005974,
+				},
+			},
+		},
+		{
+			ID:          "6",
+			ClientID:    "6",
+			CaregiverID: "2",
+			ClientName:  "Charlie Green",
+			ServiceName: "Home Care Services",
+			ShiftDate:   time.Now().In(loc).Format("2006-01-02"),
+			ShiftTime:   "2:00 - 3:00",
+			AmOrPm:      "PM",
+			Visit:       models.Visit{Status: models.StatusMissed},
+			Tasks: []models.Task{
+				{ID: 11, Name: "Check medication schedule", Description: "Ensure all medications are taken as prescribed.", Completed: false, NotCompletedReason: "Client was not home."},
+				{ID: 12, Name: "Assist with meal prep", Description: "Prepare a light snack for the client.", Completed: false, NotCompletedReason: "Client refused meal."},
+			},
+			ClientContact: models.ClientContact{Email: "charlie@example.com", Phone: "+1 555 789 1234"},
+			ServiceNotes:  "Charlie was not home during the scheduled visit. Attempted to call but no answer.",
+			Location: models.Location{
+				Address: "987 Birch St, Springfield, IL",
+				Coordinates: models.Geolocation{
+					Latitude:  40.712776,
+					Longitude: -74.005974,
+				},
+			},
+		},
+	}
+	for _, schedule := range schedules {
+		if err := schedule.SetLegacyShift(schedule.ShiftDate, schedule.ShiftTime, schedule.AmOrPm, zone); err != nil {
+			panic(fmt.Sprintf("seed schedule %s: %v", schedule.ID, err))
+		}
+	}
+	return schedules
+}
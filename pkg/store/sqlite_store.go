@@ -0,0 +1,1419 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/IkoAfianando/mini_evv_logger_go/pkg/models"
+)
+
+// SQLiteStore is the durable Repository implementation, backed by an
+// embedded pure-Go SQLite database.
+type SQLiteStore struct {
+	recordLocks
+
+	db *sql.DB
+}
+
+var _ Repository = (*SQLiteStore)(nil)
+
+// NewSQLiteStore opens (or creates) the database at path, applies any
+// pending migrations and seeds the demo data if the database is empty. Users
+// are not seeded; see SeedDemoUsers. Pass ":memory:" for a throwaway
+// database.
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite %s: %w", path, err)
+	}
+	// A single connection serialises writers and keeps an in-memory
+	// database from being split across connections.
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	s := &SQLiteStore{db: db}
+	if err := s.backfillShifts(models.DefaultTimeZone()); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := s.seedIfEmpty(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close releases the underlying database handle.
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// backfillShifts sets the shift instants of rows written before they were
+// stored, reading the legacy columns in zone (the server's, which is how
+// they used to be read). Rows that do not parse are logged and left alone.
+func (s *SQLiteStore) backfillShifts(zone string) error {
+	rows, err := s.db.Query(`SELECT id, shift_date, shift_time, am_or_pm FROM schedules WHERE shift_start IS NULL`)
+	if err != nil {
+		return fmt.Errorf("read legacy shifts: %w", err)
+	}
+	legacy := make([]*models.Schedule, 0)
+	for rows.Next() {
+		var schedule models.Schedule
+		if err := rows.Scan(&schedule.ID, &schedule.ShiftDate, &schedule.ShiftTime, &schedule.AmOrPm); err != nil {
+			rows.Close()
+			return err
+		}
+		legacy = append(legacy, &schedule)
+	}
+	if err := closeRows(rows); err != nil {
+		return fmt.Errorf("read legacy shifts: %w", err)
+	}
+
+	return s.withTx(func(tx *sql.Tx) error {
+		for _, schedule := range legacy {
+			if err := schedule.SetLegacyShift(schedule.ShiftDate, schedule.ShiftTime, schedule.AmOrPm, zone); err != nil {
+				log.Printf("Cannot migrate shift of schedule %s: %v", schedule.ID, err)
+				continue
+			}
+			_, err := tx.Exec(`UPDATE schedules SET shift_start = ?, shift_end = ?, time_zone = ?,
+				shift_date = ?, shift_time = ?, am_or_pm = ? WHERE id = ?`,
+				shiftTime(schedule.ShiftStart), shiftTime(schedule.ShiftEnd), schedule.TimeZone,
+				schedule.ShiftDate, schedule.ShiftTime, schedule.AmOrPm, schedule.ID)
+			if err != nil {
+				return fmt.Errorf("migrate shift of schedule %s: %w", schedule.ID, err)
+			}
+		}
+		if _, err := tx.Exec(`UPDATE recurrences SET time_zone = ? WHERE time_zone = ''`, zone); err != nil {
+			return fmt.Errorf("migrate recurrence time zones: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *SQLiteStore) seedIfEmpty() error {
+	var count int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM schedules`).Scan(&count); err != nil {
+		return fmt.Errorf("count schedules: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+	return s.Reset()
+}
+
+// Reset implements Repository by replacing every row with the seed data.
+func (s *SQLiteStore) Reset() error {
+	unlock := s.lockAll()
+	defer unlock()
+	err := s.withTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM visit_events`); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM tasks`); err != nil {
+			return err
+		}
+		// task_sequence is kept, so that IDs handed out before a reset
+		// are not given to other tasks after it.
+		if _, err := tx.Exec(`DELETE FROM schedules`); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM caregivers`); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM recurrences`); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM care_plan_tasks`); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM task_templates`); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM clients`); err != nil {
+			return err
+		}
+		// users, refresh_tokens and api_keys are kept: accounts are not
+		// part of the seed data.
+		for _, client := range seedClients() {
+			if err := insertClient(tx, client); err != nil {
+				return err
+			}
+		}
+		for _, template := range seedTaskTemplates() {
+			if err := insertTaskTemplate(tx, template); err != nil {
+				return err
+			}
+		}
+		for _, plan := range seedCarePlans() {
+			if err := insertCarePlan(tx, plan); err != nil {
+				return err
+			}
+		}
+		for _, caregiver := range seedCaregivers() {
+			if err := insertCaregiver(tx, caregiver); err != nil {
+				return err
+			}
+		}
+		for _, schedule := range seedSchedules() {
+			if err := insertSchedule(tx, schedule); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("reset sqlite store: %w", err)
+	}
+	log.Println("SQLite data store initialized.")
+	return nil
+}
+
+const scheduleColumns = `id, client_name, service_name, shift_date, shift_time, am_or_pm,
+	client_email, client_phone, service_notes, address, latitude, longitude,
+	status, clock_in_time, clock_in_latitude, clock_in_longitude,
+	clock_out_time, clock_out_latitude, clock_out_longitude, geofence_radius_meters,
+	caregiver_id, client_id, recurrence_id, occurrence_date, shift_start, shift_end, time_zone, version`
+
+// ListSchedules reads the schedules, their tasks and the events replayed on
+// them in one transaction, so they are all from the same moment.
+func (s *SQLiteStore) ListSchedules() (schedules []*models.Schedule, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		schedules, err = listSchedules(tx)
+		return err
+	})
+	return schedules, err
+}
+
+func listSchedules(q querier) ([]*models.Schedule, error) {
+	rows, err := q.Query(`SELECT ` + scheduleColumns + ` FROM schedules ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("list schedules: %w", err)
+	}
+	schedules := make([]*models.Schedule, 0)
+	byID := make(map[string]*models.Schedule)
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+		byID[schedule.ID] = schedule
+	}
+	if err := closeRows(rows); err != nil {
+		return nil, fmt.Errorf("list schedules: %w", err)
+	}
+
+	// Tasks are read only after the schedule rows are closed: the pool has
+	// a single connection.
+	rows, err = q.Query(`SELECT ` + taskColumns + ` FROM tasks ORDER BY schedule_id, position`)
+	if err != nil {
+		return nil, fmt.Errorf("list tasks: %w", err)
+	}
+	for rows.Next() {
+		var scheduleID string
+		task, err := scanTask(rows, &scheduleID)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if schedule, ok := byID[scheduleID]; ok {
+			schedule.Tasks = append(schedule.Tasks, task)
+		}
+	}
+	if err := closeRows(rows); err != nil {
+		return nil, fmt.Errorf("list tasks: %w", err)
+	}
+
+	events, err := queryEvents(q, `SELECT data FROM visit_events ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		if schedule, ok := byID[event.ScheduleID]; ok {
+			schedule.Apply(event)
+		}
+	}
+	return schedules, nil
+}
+
+// GetSchedule reads the schedule, its tasks and its events in one
+// transaction, so a concurrent write is seen in all of them or none.
+func (s *SQLiteStore) GetSchedule(id string) (schedule *models.Schedule, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		schedule, err = getSchedule(tx, id)
+		return err
+	})
+	return schedule, err
+}
+
+func getSchedule(q querier, id string) (*models.Schedule, error) {
+	schedule, err := scanSchedule(q.QueryRow(`SELECT `+scheduleColumns+` FROM schedules WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("schedule %s: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if schedule.Tasks, err = baseTasks(q, id); err != nil {
+		return nil, err
+	}
+	events, err := queryEvents(q, `SELECT data FROM visit_events WHERE schedule_id = ? ORDER BY id`, id)
+	if err != nil {
+		return nil, err
+	}
+	schedule.Replay(events)
+	return schedule, nil
+}
+
+func (s *SQLiteStore) CreateSchedule(schedule *models.Schedule) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		return insertSchedule(tx, schedule)
+	})
+}
+
+func (s *SQLiteStore) UpdateSchedule(schedule *models.Schedule) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		res, err := tx.Exec(`UPDATE schedules SET
+			client_name = ?, service_name = ?, shift_date = ?, shift_time = ?, am_or_pm = ?,
+			client_email = ?, client_phone = ?, service_notes = ?, address = ?, latitude = ?, longitude = ?,
+			geofence_radius_meters = ?, caregiver_id = ?, client_id = ?, recurrence_id = ?, occurrence_date = ?,
+			shift_start = ?, shift_end = ?, time_zone = ?, version = version + 1
+			WHERE id = ?`,
+			schedule.ClientName, schedule.ServiceName, schedule.ShiftDate, schedule.ShiftTime, schedule.AmOrPm,
+			schedule.ClientContact.Email, schedule.ClientContact.Phone, schedule.ServiceNotes,
+			schedule.Location.Address, schedule.Location.Coordinates.Latitude, schedule.Location.Coordinates.Longitude,
+			schedule.Location.GeofenceRadiusMeters, nullString(schedule.CaregiverID), nullString(schedule.ClientID),
+			nullString(schedule.RecurrenceID), nullString(schedule.OccurrenceDate),
+			shiftTime(schedule.ShiftStart), shiftTime(schedule.ShiftEnd), nullString(schedule.TimeZone),
+			schedule.ID)
+		if err != nil {
+			return fmt.Errorf("update schedule %s: %w", schedule.ID, err)
+		}
+		if err := requireRow(res, "schedule %s", schedule.ID); err != nil {
+			return err
+		}
+
+		if err := allocateTaskIDs(tx, schedule.Tasks); err != nil {
+			return err
+		}
+		// Upsert so the base completion columns of existing tasks survive.
+		taskIDs := make([]int, 0, len(schedule.Tasks))
+		for i, task := range schedule.Tasks {
+			_, err := tx.Exec(`INSERT INTO tasks (schedule_id, id, position, name, description, required, template_code, completed, not_completed_reason)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+				ON CONFLICT (schedule_id, id) DO UPDATE SET
+					position = excluded.position, name = excluded.name, description = excluded.description,
+					required = excluded.required, template_code = excluded.template_code`,
+				schedule.ID, task.ID, i+1, task.Name, task.Description, task.Required, task.TemplateCode, task.Completed, task.NotCompletedReason)
+			if err != nil {
+				return fmt.Errorf("upsert task %d in schedule %s: %w", task.ID, schedule.ID, err)
+			}
+			taskIDs = append(taskIDs, task.ID)
+		}
+		_, err = tx.Exec(`DELETE FROM tasks WHERE schedule_id = ? AND id NOT IN (SELECT value FROM json_each(?))`,
+			schedule.ID, jsonIDs(taskIDs))
+		if err != nil {
+			return err
+		}
+		schedule.Version++
+		return nil
+	})
+}
+
+// DeleteSchedule implements Repository; tasks and visit events go with the
+// schedule through ON DELETE CASCADE.
+func (s *SQLiteStore) DeleteSchedule(id string) error {
+	res, err := s.db.Exec(`DELETE FROM schedules WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete schedule %s: %w", id, err)
+	}
+	return requireRow(res, "schedule %s", id)
+}
+
+const taskColumns = `schedule_id, id, name, description, required, template_code, completed, not_completed_reason, version`
+
+func (s *SQLiteStore) ListTasks(scheduleID string) ([]models.Task, error) {
+	schedule, err := s.GetSchedule(scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	return schedule.Tasks, nil
+}
+
+func (s *SQLiteStore) GetTask(scheduleID string, taskID int) (*models.Task, error) {
+	tasks, err := s.ListTasks(scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	for _, task := range tasks {
+		if task.ID == taskID {
+			return &task, nil
+		}
+	}
+	return nil, fmt.Errorf("task %d in schedule %s: %w", taskID, scheduleID, ErrNotFound)
+}
+
+// baseTasks reads a schedule's tasks as stored, before events are replayed.
+func baseTasks(q querier, scheduleID string) ([]models.Task, error) {
+	rows, err := q.Query(`SELECT `+taskColumns+` FROM tasks WHERE schedule_id = ? ORDER BY position`, scheduleID)
+	if err != nil {
+		return nil, fmt.Errorf("list tasks for schedule %s: %w", scheduleID, err)
+	}
+	tasks := make([]models.Task, 0)
+	for rows.Next() {
+		var owner string
+		task, err := scanTask(rows, &owner)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, closeRows(rows)
+}
+
+func (s *SQLiteStore) CreateTask(scheduleID string, task *models.Task) error {
+	if err := s.requireSchedule(scheduleID); err != nil {
+		return err
+	}
+	return s.withTx(func(tx *sql.Tx) error {
+		tasks := []models.Task{*task}
+		if err := allocateTaskIDs(tx, tasks); err != nil {
+			return err
+		}
+		task.ID = tasks[0].ID
+		_, err := tx.Exec(`INSERT INTO tasks (schedule_id, id, position, name, description, required, template_code, completed, not_completed_reason)
+			VALUES (?, ?, (SELECT COALESCE(MAX(position), 0) + 1 FROM tasks WHERE schedule_id = ?), ?, ?, ?, ?, ?, ?)`,
+			scheduleID, task.ID, scheduleID, task.Name, task.Description, task.Required, task.TemplateCode, task.Completed, task.NotCompletedReason)
+		if err != nil {
+			return fmt.Errorf("create task %d in schedule %s: %w", task.ID, scheduleID, err)
+		}
+		task.Version = 1
+		return bumpVersion(tx, scheduleID)
+	})
+}
+
+func (s *SQLiteStore) UpdateTask(scheduleID string, task *models.Task) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		res, err := tx.Exec(`UPDATE tasks SET name = ?, description = ?, required = ?, version = version + 1
+			WHERE schedule_id = ? AND id = ?`,
+			task.Name, task.Description, task.Required, scheduleID, task.ID)
+		if err != nil {
+			return fmt.Errorf("update task %d in schedule %s: %w", task.ID, scheduleID, err)
+		}
+		if err := requireRow(res, "task %d in schedule %s", task.ID, scheduleID); err != nil {
+			return err
+		}
+		task.Version++
+		return bumpVersion(tx, scheduleID)
+	})
+}
+
+func (s *SQLiteStore) DeleteTask(scheduleID string, taskID int) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		res, err := tx.Exec(`DELETE FROM tasks WHERE schedule_id = ? AND id = ?`, scheduleID, taskID)
+		if err != nil {
+			return fmt.Errorf("delete task %d in schedule %s: %w", taskID, scheduleID, err)
+		}
+		if err := requireRow(res, "task %d in schedule %s", taskID, scheduleID); err != nil {
+			return err
+		}
+		return bumpVersion(tx, scheduleID)
+	})
+}
+
+func (s *SQLiteStore) ReorderTasks(scheduleID string, taskIDs []int) error {
+	if err := s.requireSchedule(scheduleID); err != nil {
+		return err
+	}
+	tasks, err := baseTasks(s.db, scheduleID)
+	if err != nil {
+		return err
+	}
+	if err := checkTaskOrder(tasks, taskIDs); err != nil {
+		return err
+	}
+	return s.withTx(func(tx *sql.Tx) error {
+		for i, id := range taskIDs {
+			if _, err := tx.Exec(`UPDATE tasks SET position = ? WHERE schedule_id = ? AND id = ?`, i+1, scheduleID, id); err != nil {
+				return fmt.Errorf("reorder task %d in schedule %s: %w", id, scheduleID, err)
+			}
+		}
+		return bumpVersion(tx, scheduleID)
+	})
+}
+
+func (s *SQLiteStore) TaskSchedule(taskID int) (string, error) {
+	var scheduleID string
+	err := s.db.QueryRow(`SELECT schedule_id FROM tasks WHERE id = ?`, taskID).Scan(&scheduleID)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("task %d: %w", taskID, ErrNotFound)
+	}
+	return scheduleID, err
+}
+
+const caregiverColumns = `id, name, credentials, phone, time_zone`
+
+func (s *SQLiteStore) ListCaregivers() ([]*models.Caregiver, error) {
+	rows, err := s.db.Query(`SELECT ` + caregiverColumns + ` FROM caregivers ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("list caregivers: %w", err)
+	}
+	caregivers := make([]*models.Caregiver, 0)
+	for rows.Next() {
+		caregiver, err := scanCaregiver(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		caregivers = append(caregivers, caregiver)
+	}
+	return caregivers, closeRows(rows)
+}
+
+func (s *SQLiteStore) GetCaregiver(id string) (*models.Caregiver, error) {
+	caregiver, err := scanCaregiver(s.db.QueryRow(`SELECT `+caregiverColumns+` FROM caregivers WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("caregiver %s: %w", id, ErrNotFound)
+	}
+	return caregiver, err
+}
+
+func (s *SQLiteStore) CreateCaregiver(caregiver *models.Caregiver) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		return insertCaregiver(tx, caregiver)
+	})
+}
+
+func (s *SQLiteStore) UpdateCaregiver(caregiver *models.Caregiver) error {
+	res, err := s.db.Exec(`UPDATE caregivers SET name = ?, credentials = ?, phone = ?, time_zone = ? WHERE id = ?`,
+		caregiver.Name, jsonValue(caregiver.Credentials), caregiver.Phone, caregiver.TimeZone, caregiver.ID)
+	if err != nil {
+		return fmt.Errorf("update caregiver %s: %w", caregiver.ID, err)
+	}
+	return requireRow(res, "caregiver %s", caregiver.ID)
+}
+
+func (s *SQLiteStore) DeleteCaregiver(id string) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		var assigned int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM schedules WHERE caregiver_id = ?`, id).Scan(&assigned); err != nil {
+			return fmt.Errorf("count schedules for caregiver %s: %w", id, err)
+		}
+		if assigned > 0 {
+			return fmt.Errorf("caregiver %s is assigned to %d schedules: %w", id, assigned, ErrInUse)
+		}
+		res, err := tx.Exec(`DELETE FROM caregivers WHERE id = ?`, id)
+		if err != nil {
+			return fmt.Errorf("delete caregiver %s: %w", id, err)
+		}
+		return requireRow(res, "caregiver %s", id)
+	})
+}
+
+const clientColumns = `id, name, email, phone, address, latitude, longitude, geofence_radius_meters,
+	emergency_contacts, medicaid_id, care_notes`
+
+func (s *SQLiteStore) ListClients() ([]*models.Client, error) {
+	rows, err := s.db.Query(`SELECT ` + clientColumns + ` FROM clients ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("list clients: %w", err)
+	}
+	clients := make([]*models.Client, 0)
+	for rows.Next() {
+		client, err := scanClient(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+	return clients, closeRows(rows)
+}
+
+func (s *SQLiteStore) GetClient(id string) (*models.Client, error) {
+	client, err := scanClient(s.db.QueryRow(`SELECT `+clientColumns+` FROM clients WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("client %s: %w", id, ErrNotFound)
+	}
+	return client, err
+}
+
+func (s *SQLiteStore) CreateClient(client *models.Client) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		return insertClient(tx, client)
+	})
+}
+
+func (s *SQLiteStore) UpdateClient(client *models.Client) error {
+	res, err := s.db.Exec(`UPDATE clients SET
+		name = ?, email = ?, phone = ?, address = ?, latitude = ?, longitude = ?, geofence_radius_meters = ?,
+		emergency_contacts = ?, medicaid_id = ?, care_notes = ?
+		WHERE id = ?`,
+		client.Name, client.Contact.Email, client.Contact.Phone,
+		client.Location.Address, client.Location.Coordinates.Latitude, client.Location.Coordinates.Longitude,
+		client.Location.GeofenceRadiusMeters, jsonValue(client.EmergencyContacts), client.MedicaidID, client.CareNotes,
+		client.ID)
+	if err != nil {
+		return fmt.Errorf("update client %s: %w", client.ID, err)
+	}
+	return requireRow(res, "client %s", client.ID)
+}
+
+func (s *SQLiteStore) DeleteClient(id string) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		var referenced int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM schedules WHERE client_id = ?`, id).Scan(&referenced); err != nil {
+			return fmt.Errorf("count schedules for client %s: %w", id, err)
+		}
+		if referenced > 0 {
+			return fmt.Errorf("client %s has %d schedules: %w", id, referenced, ErrInUse)
+		}
+		res, err := tx.Exec(`DELETE FROM clients WHERE id = ?`, id)
+		if err != nil {
+			return fmt.Errorf("delete client %s: %w", id, err)
+		}
+		return requireRow(res, "client %s", id)
+	})
+}
+
+const taskTemplateColumns = `code, name, description, category, required`
+
+func (s *SQLiteStore) ListTaskTemplates() ([]*models.TaskTemplate, error) {
+	rows, err := s.db.Query(`SELECT ` + taskTemplateColumns + ` FROM task_templates ORDER BY code`)
+	if err != nil {
+		return nil, fmt.Errorf("list task templates: %w", err)
+	}
+	templates := make([]*models.TaskTemplate, 0)
+	for rows.Next() {
+		template, err := scanTaskTemplate(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		templates = append(templates, template)
+	}
+	return templates, closeRows(rows)
+}
+
+func (s *SQLiteStore) GetTaskTemplate(code string) (*models.TaskTemplate, error) {
+	template, err := scanTaskTemplate(s.db.QueryRow(`SELECT `+taskTemplateColumns+` FROM task_templates WHERE code = ?`, code))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("task template %s: %w", code, ErrNotFound)
+	}
+	return template, err
+}
+
+func (s *SQLiteStore) CreateTaskTemplate(template *models.TaskTemplate) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		var exists int
+		err := tx.QueryRow(`SELECT 1 FROM task_templates WHERE code = ?`, template.Code).Scan(&exists)
+		if err == nil {
+			return fmt.Errorf("task template %s: %w", template.Code, ErrExists)
+		}
+		if err != sql.ErrNoRows {
+			return err
+		}
+		return insertTaskTemplate(tx, template)
+	})
+}
+
+func (s *SQLiteStore) UpdateTaskTemplate(template *models.TaskTemplate) error {
+	res, err := s.db.Exec(`UPDATE task_templates SET name = ?, description = ?, category = ?, required = ? WHERE code = ?`,
+		template.Name, template.Description, template.Category, template.Required, template.Code)
+	if err != nil {
+		return fmt.Errorf("update task template %s: %w", template.Code, err)
+	}
+	return requireRow(res, "task template %s", template.Code)
+}
+
+func (s *SQLiteStore) DeleteTaskTemplate(code string) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		var referenced int
+		err := tx.QueryRow(`SELECT COUNT(DISTINCT client_id) FROM care_plan_tasks WHERE template_code = ?`, code).Scan(&referenced)
+		if err != nil {
+			return fmt.Errorf("count care plans for task template %s: %w", code, err)
+		}
+		if referenced > 0 {
+			return fmt.Errorf("task template %s is on %d care plans: %w", code, referenced, ErrInUse)
+		}
+		res, err := tx.Exec(`DELETE FROM task_templates WHERE code = ?`, code)
+		if err != nil {
+			return fmt.Errorf("delete task template %s: %w", code, err)
+		}
+		return requireRow(res, "task template %s", code)
+	})
+}
+
+func (s *SQLiteStore) GetCarePlan(clientID string) (*models.CarePlan, error) {
+	if _, err := s.GetClient(clientID); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(`SELECT template_code, description, required
+		FROM care_plan_tasks WHERE client_id = ? ORDER BY position`, clientID)
+	if err != nil {
+		return nil, fmt.Errorf("read care plan for client %s: %w", clientID, err)
+	}
+	plan := &models.CarePlan{ClientID: clientID, Tasks: []models.CarePlanTask{}}
+	for rows.Next() {
+		var (
+			task     models.CarePlanTask
+			required sql.NullBool
+		)
+		if err := rows.Scan(&task.TemplateCode, &task.Description, &required); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if required.Valid {
+			task.Required = &required.Bool
+		}
+		plan.Tasks = append(plan.Tasks, task)
+	}
+	return plan, closeRows(rows)
+}
+
+func (s *SQLiteStore) SetCarePlan(plan *models.CarePlan) error {
+	if _, err := s.GetClient(plan.ClientID); err != nil {
+		return err
+	}
+	for _, task := range plan.Tasks {
+		if _, err := s.GetTaskTemplate(task.TemplateCode); err != nil {
+			return err
+		}
+	}
+	return s.withTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM care_plan_tasks WHERE client_id = ?`, plan.ClientID); err != nil {
+			return fmt.Errorf("clear care plan for client %s: %w", plan.ClientID, err)
+		}
+		return insertCarePlan(tx, plan)
+	})
+}
+
+const recurrenceColumns = `id, client_id, caregiver_id, service_name, service_notes,
+	start_date, shift_time, am_or_pm, rrule, exception_dates, tasks, time_zone`
+
+func (s *SQLiteStore) ListRecurrences() ([]*models.Recurrence, error) {
+	rows, err := s.db.Query(`SELECT ` + recurrenceColumns + ` FROM recurrences ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("list recurrences: %w", err)
+	}
+	recurrences := make([]*models.Recurrence, 0)
+	for rows.Next() {
+		recurrence, err := scanRecurrence(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		recurrences = append(recurrences, recurrence)
+	}
+	return recurrences, closeRows(rows)
+}
+
+func (s *SQLiteStore) GetRecurrence(id string) (*models.Recurrence, error) {
+	recurrence, err := scanRecurrence(s.db.QueryRow(`SELECT `+recurrenceColumns+` FROM recurrences WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("recurrence %s: %w", id, ErrNotFound)
+	}
+	return recurrence, err
+}
+
+func (s *SQLiteStore) CreateRecurrence(recurrence *models.Recurrence) error {
+	_, err := s.db.Exec(`INSERT INTO recurrences (`+recurrenceColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		recurrence.ID, recurrence.ClientID, recurrence.CaregiverID, recurrence.ServiceName, recurrence.ServiceNotes,
+		recurrence.StartDate, recurrence.ShiftTime, recurrence.AmOrPm, recurrence.RRule,
+		jsonValue(recurrence.ExceptionDates), jsonValue(recurrence.Tasks), recurrence.TimeZone)
+	if err != nil {
+		return fmt.Errorf("insert recurrence %s: %w", recurrence.ID, err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) UpdateRecurrence(recurrence *models.Recurrence) error {
+	res, err := s.db.Exec(`UPDATE recurrences SET
+		client_id = ?, caregiver_id = ?, service_name = ?, service_notes = ?,
+		start_date = ?, shift_time = ?, am_or_pm = ?, rrule = ?, exception_dates = ?, tasks = ?, time_zone = ?
+		WHERE id = ?`,
+		recurrence.ClientID, recurrence.CaregiverID, recurrence.ServiceName, recurrence.ServiceNotes,
+		recurrence.StartDate, recurrence.ShiftTime, recurrence.AmOrPm, recurrence.RRule,
+		jsonValue(recurrence.ExceptionDates), jsonValue(recurrence.Tasks), recurrence.TimeZone,
+		recurrence.ID)
+	if err != nil {
+		return fmt.Errorf("update recurrence %s: %w", recurrence.ID, err)
+	}
+	return requireRow(res, "recurrence %s", recurrence.ID)
+}
+
+func (s *SQLiteStore) AddExceptionDate(recurrenceID, date string) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		var exceptionDates string
+		err := tx.QueryRow(`SELECT exception_dates FROM recurrences WHERE id = ?`, recurrenceID).Scan(&exceptionDates)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("recurrence %s: %w", recurrenceID, ErrNotFound)
+		}
+		if err != nil {
+			return err
+		}
+		var dates []string
+		if err := json.Unmarshal([]byte(exceptionDates), &dates); err != nil {
+			return fmt.Errorf("recurrence %s exception dates: %w", recurrenceID, err)
+		}
+		_, err = tx.Exec(`UPDATE recurrences SET exception_dates = ? WHERE id = ?`,
+			jsonValue(addExceptionDate(dates, date)), recurrenceID)
+		if err != nil {
+			return fmt.Errorf("update recurrence %s: %w", recurrenceID, err)
+		}
+		return nil
+	})
+}
+
+func (s *SQLiteStore) DeleteRecurrence(id string) error {
+	res, err := s.db.Exec(`DELETE FROM recurrences WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete recurrence %s: %w", id, err)
+	}
+	return requireRow(res, "recurrence %s", id)
+}
+
+func (s *SQLiteStore) GetVisit(scheduleID string) (*models.Visit, error) {
+	schedule, err := s.GetSchedule(scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	return &schedule.Visit, nil
+}
+
+func (s *SQLiteStore) AppendEvent(event *models.VisitEvent) error {
+	if err := s.requireSchedule(event.ScheduleID); err != nil {
+		return err
+	}
+	return s.withTx(func(tx *sql.Tx) error {
+		var key any
+		if event.IdempotencyKey != "" {
+			key = event.IdempotencyKey
+			var exists int
+			err := tx.QueryRow(`SELECT 1 FROM visit_events WHERE idempotency_key = ?`, key).Scan(&exists)
+			if err == nil {
+				return fmt.Errorf("event %s: %w", event.IdempotencyKey, ErrDuplicateKey)
+			}
+			if err != sql.ErrNoRows {
+				return err
+			}
+		}
+		res, err := tx.Exec(`INSERT INTO visit_events (schedule_id, type, occurred_at, idempotency_key, data) VALUES (?, ?, ?, ?, '{}')`,
+			event.ScheduleID, event.Type, event.OccurredAt.Format(time.RFC3339Nano), key)
+		if err != nil {
+			return fmt.Errorf("append %s event for schedule %s: %w", event.Type, event.ScheduleID, err)
+		}
+		if event.ID, err = res.LastInsertId(); err != nil {
+			return err
+		}
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`UPDATE visit_events SET data = ? WHERE id = ?`, string(data), event.ID)
+		return err
+	})
+}
+
+func (s *SQLiteStore) ListEvents(scheduleID string) ([]models.VisitEvent, error) {
+	if err := s.requireSchedule(scheduleID); err != nil {
+		return nil, err
+	}
+	return queryEvents(s.db, `SELECT data FROM visit_events WHERE schedule_id = ? ORDER BY id`, scheduleID)
+}
+
+func (s *SQLiteStore) GetEventByIdempotencyKey(key string) (*models.VisitEvent, error) {
+	events, err := queryEvents(s.db, `SELECT data FROM visit_events WHERE idempotency_key = ?`, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("event %s: %w", key, ErrNotFound)
+	}
+	return &events[0], nil
+}
+
+func queryEvents(q querier, query string, args ...any) ([]models.VisitEvent, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list visit events: %w", err)
+	}
+	events := make([]models.VisitEvent, 0)
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		var event models.VisitEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("decode visit event: %w", err)
+		}
+		events = append(events, event)
+	}
+	return events, closeRows(rows)
+}
+
+const userColumns = `id, username, password_hash, role, caregiver_id`
+
+func (s *SQLiteStore) GetUser(id string) (*models.User, error) {
+	user, err := scanUser(s.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user %s: %w", id, ErrNotFound)
+	}
+	return user, err
+}
+
+func (s *SQLiteStore) GetUserByUsername(username string) (*models.User, error) {
+	user, err := scanUser(s.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE username = ?`, username))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user %s: %w", username, ErrNotFound)
+	}
+	return user, err
+}
+
+func (s *SQLiteStore) CreateUser(user *models.User) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		var count int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM users WHERE username = ?`, user.Username).Scan(&count); err != nil {
+			return fmt.Errorf("check user %s: %w", user.Username, err)
+		}
+		if count > 0 {
+			return fmt.Errorf("user %s: %w", user.Username, ErrExists)
+		}
+		return insertUser(tx, user)
+	})
+}
+
+func (s *SQLiteStore) CountUsers(role models.Role) (int, error) {
+	var count int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM users WHERE role = ?`, role).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count users: %w", err)
+	}
+	return count, nil
+}
+
+func (s *SQLiteStore) CreateRefreshToken(token *models.RefreshToken) error {
+	_, err := s.db.Exec(`INSERT INTO refresh_tokens (id, user_id, token_hash, created_at, expires_at, revoked_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		token.ID, token.UserID, token.Hash, token.CreatedAt.Format(time.RFC3339Nano),
+		token.ExpiresAt.Format(time.RFC3339Nano), nullTime(token.RevokedAt))
+	if err != nil {
+		return fmt.Errorf("create refresh token for user %s: %w", token.UserID, err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) GetRefreshToken(hash string) (*models.RefreshToken, error) {
+	var (
+		token                 models.RefreshToken
+		createdAt, expiresAt  string
+		revokedAt, replacedBy sql.NullString
+	)
+	err := s.db.QueryRow(`SELECT id, user_id, token_hash, created_at, expires_at, revoked_at, replaced_by
+		FROM refresh_tokens WHERE token_hash = ?`, hash).
+		Scan(&token.ID, &token.UserID, &token.Hash, &createdAt, &expiresAt, &revokedAt, &replacedBy)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("refresh token: %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var errCreated, errExpires, errRevoked error
+	token.CreatedAt, errCreated = time.Parse(time.RFC3339Nano, createdAt)
+	token.ExpiresAt, errExpires = time.Parse(time.RFC3339Nano, expiresAt)
+	token.RevokedAt, errRevoked = parseNullTime(revokedAt)
+	token.ReplacedBy = replacedBy.String
+	if err := errors.Join(errCreated, errExpires, errRevoked); err != nil {
+		return nil, fmt.Errorf("refresh token %s: %w", token.ID, err)
+	}
+	return &token, nil
+}
+
+func (s *SQLiteStore) RevokeRefreshToken(id string, at time.Time, replacedBy string) (bool, error) {
+	res, err := s.db.Exec(`UPDATE refresh_tokens SET revoked_at = ?, replaced_by = ? WHERE id = ? AND revoked_at IS NULL`,
+		at.Format(time.RFC3339Nano), nullString(replacedBy), id)
+	if err != nil {
+		return false, fmt.Errorf("revoke refresh token %s: %w", id, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if n == 0 {
+		var exists bool
+		if err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM refresh_tokens WHERE id = ?)`, id).Scan(&exists); err != nil {
+			return false, err
+		}
+		if !exists {
+			return false, fmt.Errorf("refresh token %s: %w", id, ErrNotFound)
+		}
+	}
+	return n > 0, nil
+}
+
+func (s *SQLiteStore) RevokeUserRefreshTokens(userID string, at time.Time) error {
+	_, err := s.db.Exec(`UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL`,
+		at.Format(time.RFC3339Nano), userID)
+	if err != nil {
+		return fmt.Errorf("revoke refresh tokens of user %s: %w", userID, err)
+	}
+	return nil
+}
+
+const apiKeyColumns = `id, name, prefix, key_hash, scopes, created_by, created_at, last_used_at, revoked_at`
+
+func (s *SQLiteStore) CreateAPIKey(key *models.APIKey) error {
+	_, err := s.db.Exec(`INSERT INTO api_keys (`+apiKeyColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		key.ID, key.Name, key.Prefix, key.Hash, jsonValue(key.Scopes), key.CreatedBy,
+		key.CreatedAt.Format(time.RFC3339Nano), nullTime(key.LastUsedAt), nullTime(key.RevokedAt))
+	if err != nil {
+		return fmt.Errorf("create API key %s: %w", key.ID, err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) ListAPIKeys() ([]*models.APIKey, error) {
+	rows, err := s.db.Query(`SELECT ` + apiKeyColumns + ` FROM api_keys ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]*models.APIKey, 0)
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, closeRows(rows)
+}
+
+func (s *SQLiteStore) GetAPIKeyByHash(hash string) (*models.APIKey, error) {
+	key, err := scanAPIKey(s.db.QueryRow(`SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = ?`, hash))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("API key: %w", ErrNotFound)
+	}
+	return key, err
+}
+
+func (s *SQLiteStore) RevokeAPIKey(id string, at time.Time) (*models.APIKey, error) {
+	_, err := s.db.Exec(`UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`,
+		at.Format(time.RFC3339Nano), id)
+	if err != nil {
+		return nil, fmt.Errorf("revoke API key %s: %w", id, err)
+	}
+	key, err := scanAPIKey(s.db.QueryRow(`SELECT `+apiKeyColumns+` FROM api_keys WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("API key %s: %w", id, ErrNotFound)
+	}
+	return key, err
+}
+
+func (s *SQLiteStore) TouchAPIKey(id string, at time.Time) error {
+	res, err := s.db.Exec(`UPDATE api_keys SET last_used_at = ? WHERE id = ?`, at.Format(time.RFC3339Nano), id)
+	if err != nil {
+		return fmt.Errorf("touch API key %s: %w", id, err)
+	}
+	return requireRow(res, "API key %s", id)
+}
+
+func (s *SQLiteStore) requireSchedule(id string) error {
+	var exists int
+	err := s.db.QueryRow(`SELECT 1 FROM schedules WHERE id = ?`, id).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("schedule %s: %w", id, ErrNotFound)
+	}
+	return err
+}
+
+// querier is the part of *sql.DB and *sql.Tx that reads use, so that reads
+// belonging together can share a transaction.
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func (s *SQLiteStore) withTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertSchedule(tx *sql.Tx, schedule *models.Schedule) error {
+	firstVersion(schedule)
+	clockInLat, clockInLng := nullGeolocation(schedule.ClockInLocation)
+	clockOutLat, clockOutLng := nullGeolocation(schedule.ClockOutLocation)
+	_, err := tx.Exec(`INSERT INTO schedules (`+scheduleColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		schedule.ID, schedule.ClientName, schedule.ServiceName, schedule.ShiftDate, schedule.ShiftTime, schedule.AmOrPm,
+		schedule.ClientContact.Email, schedule.ClientContact.Phone, schedule.ServiceNotes,
+		schedule.Location.Address, schedule.Location.Coordinates.Latitude, schedule.Location.Coordinates.Longitude,
+		schedule.Status, nullTime(schedule.ClockInTime), clockInLat, clockInLng,
+		nullTime(schedule.ClockOutTime), clockOutLat, clockOutLng, schedule.Location.GeofenceRadiusMeters,
+		nullString(schedule.CaregiverID), nullString(schedule.ClientID),
+		nullString(schedule.RecurrenceID), nullString(schedule.OccurrenceDate),
+		shiftTime(schedule.ShiftStart), shiftTime(schedule.ShiftEnd), nullString(schedule.TimeZone),
+		schedule.Version)
+	if err != nil {
+		return fmt.Errorf("insert schedule %s: %w", schedule.ID, err)
+	}
+	return insertTasks(tx, schedule.ID, schedule.Tasks)
+}
+
+func insertTasks(tx *sql.Tx, scheduleID string, tasks []models.Task) error {
+	if err := allocateTaskIDs(tx, tasks); err != nil {
+		return err
+	}
+	for i, task := range tasks {
+		_, err := tx.Exec(`INSERT INTO tasks (schedule_id, id, position, name, description, required, template_code, completed, not_completed_reason, version)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			scheduleID, task.ID, i+1, task.Name, task.Description, task.Required, task.TemplateCode, task.Completed, task.NotCompletedReason, task.Version)
+		if err != nil {
+			return fmt.Errorf("insert task %d in schedule %s: %w", task.ID, scheduleID, err)
+		}
+	}
+	return nil
+}
+
+// bumpVersion counts a change to a schedule's tasks in its version.
+func bumpVersion(tx *sql.Tx, scheduleID string) error {
+	if _, err := tx.Exec(`UPDATE schedules SET version = version + 1 WHERE id = ?`, scheduleID); err != nil {
+		return fmt.Errorf("bump version of schedule %s: %w", scheduleID, err)
+	}
+	return nil
+}
+
+// allocateTaskIDs numbers tasks with ID 0 from task_sequence, and moves the
+// sequence past any ID given explicitly.
+func allocateTaskIDs(tx *sql.Tx, tasks []models.Task) error {
+	var next int
+	if err := tx.QueryRow(`SELECT next_id FROM task_sequence`).Scan(&next); err != nil {
+		return fmt.Errorf("read task sequence: %w", err)
+	}
+	start := next
+	for i := range tasks {
+		if tasks[i].ID == 0 {
+			tasks[i].ID = next
+		}
+		if tasks[i].ID >= next {
+			next = tasks[i].ID + 1
+		}
+	}
+	if next == start {
+		return nil
+	}
+	if _, err := tx.Exec(`UPDATE task_sequence SET next_id = ?`, next); err != nil {
+		return fmt.Errorf("advance task sequence: %w", err)
+	}
+	return nil
+}
+
+func insertCaregiver(tx *sql.Tx, caregiver *models.Caregiver) error {
+	_, err := tx.Exec(`INSERT INTO caregivers (`+caregiverColumns+`) VALUES (?, ?, ?, ?, ?)`,
+		caregiver.ID, caregiver.Name, jsonValue(caregiver.Credentials), caregiver.Phone, caregiver.TimeZone)
+	if err != nil {
+		return fmt.Errorf("insert caregiver %s: %w", caregiver.ID, err)
+	}
+	return nil
+}
+
+func insertUser(tx *sql.Tx, user *models.User) error {
+	_, err := tx.Exec(`INSERT INTO users (`+userColumns+`) VALUES (?, ?, ?, ?, ?)`,
+		user.ID, user.Username, user.PasswordHash, user.Role, nullString(user.CaregiverID))
+	if err != nil {
+		return fmt.Errorf("insert user %s: %w", user.ID, err)
+	}
+	return nil
+}
+
+func insertClient(tx *sql.Tx, client *models.Client) error {
+	_, err := tx.Exec(`INSERT INTO clients (`+clientColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		client.ID, client.Name, client.Contact.Email, client.Contact.Phone,
+		client.Location.Address, client.Location.Coordinates.Latitude, client.Location.Coordinates.Longitude,
+		client.Location.GeofenceRadiusMeters, jsonValue(client.EmergencyContacts), client.MedicaidID, client.CareNotes)
+	if err != nil {
+		return fmt.Errorf("insert client %s: %w", client.ID, err)
+	}
+	return nil
+}
+
+func insertTaskTemplate(tx *sql.Tx, template *models.TaskTemplate) error {
+	_, err := tx.Exec(`INSERT INTO task_templates (`+taskTemplateColumns+`) VALUES (?, ?, ?, ?, ?)`,
+		template.Code, template.Name, template.Description, template.Category, template.Required)
+	if err != nil {
+		return fmt.Errorf("insert task template %s: %w", template.Code, err)
+	}
+	return nil
+}
+
+func insertCarePlan(tx *sql.Tx, plan *models.CarePlan) error {
+	for i, task := range plan.Tasks {
+		var required any
+		if task.Required != nil {
+			required = *task.Required
+		}
+		_, err := tx.Exec(`INSERT INTO care_plan_tasks (client_id, position, template_code, description, required)
+			VALUES (?, ?, ?, ?, ?)`,
+			plan.ClientID, i+1, task.TemplateCode, task.Description, required)
+		if err != nil {
+			return fmt.Errorf("insert care plan task %s for client %s: %w", task.TemplateCode, plan.ClientID, err)
+		}
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSchedule(row rowScanner) (*models.Schedule, error) {
+	var (
+		schedule                  models.Schedule
+		clockInTime, clockOutTime sql.NullString
+		clockInLat, clockInLng    sql.NullFloat64
+		clockOutLat, clockOutLng  sql.NullFloat64
+		caregiverID, clientID     sql.NullString
+		recurrenceID, occurrence  sql.NullString
+		shiftStart, shiftEnd      sql.NullString
+		timeZone                  sql.NullString
+	)
+	err := row.Scan(&schedule.ID, &schedule.ClientName, &schedule.ServiceName,
+		&schedule.ShiftDate, &schedule.ShiftTime, &schedule.AmOrPm,
+		&schedule.ClientContact.Email, &schedule.ClientContact.Phone, &schedule.ServiceNotes,
+		&schedule.Location.Address, &schedule.Location.Coordinates.Latitude, &schedule.Location.Coordinates.Longitude,
+		&schedule.Status, &clockInTime, &clockInLat, &clockInLng,
+		&clockOutTime, &clockOutLat, &clockOutLng, &schedule.Location.GeofenceRadiusMeters,
+		&caregiverID, &clientID, &recurrenceID, &occurrence, &shiftStart, &shiftEnd, &timeZone, &schedule.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	if schedule.ClockInTime, err = parseNullTime(clockInTime); err != nil {
+		return nil, fmt.Errorf("schedule %s clock_in_time: %w", schedule.ID, err)
+	}
+	if schedule.ClockOutTime, err = parseNullTime(clockOutTime); err != nil {
+		return nil, fmt.Errorf("schedule %s clock_out_time: %w", schedule.ID, err)
+	}
+	if shiftStart.Valid && shiftEnd.Valid {
+		start, errStart := time.Parse(time.RFC3339, shiftStart.String)
+		end, errEnd := time.Parse(time.RFC3339, shiftEnd.String)
+		if errStart != nil || errEnd != nil {
+			return nil, fmt.Errorf("schedule %s shift: %w", schedule.ID, errors.Join(errStart, errEnd))
+		}
+		if err := schedule.SetShift(start, end, timeZone.String); err != nil {
+			return nil, fmt.Errorf("schedule %s shift: %w", schedule.ID, err)
+		}
+	}
+	schedule.CaregiverID = caregiverID.String
+	schedule.ClientID = clientID.String
+	schedule.RecurrenceID = recurrenceID.String
+	schedule.OccurrenceDate = occurrence.String
+	schedule.ClockInLocation = geolocationFrom(clockInLat, clockInLng)
+	schedule.ClockOutLocation = geolocationFrom(clockOutLat, clockOutLng)
+	schedule.Tasks = make([]models.Task, 0)
+	return &schedule, nil
+}
+
+func scanTask(row rowScanner, scheduleID *string) (models.Task, error) {
+	var task models.Task
+	err := row.Scan(scheduleID, &task.ID, &task.Name, &task.Description, &task.Required, &task.TemplateCode, &task.Completed, &task.NotCompletedReason, &task.Version)
+	return task, err
+}
+
+func scanTaskTemplate(row rowScanner) (*models.TaskTemplate, error) {
+	var template models.TaskTemplate
+	err := row.Scan(&template.Code, &template.Name, &template.Description, &template.Category, &template.Required)
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+func scanCaregiver(row rowScanner) (*models.Caregiver, error) {
+	var (
+		caregiver   models.Caregiver
+		credentials string
+	)
+	if err := row.Scan(&caregiver.ID, &caregiver.Name, &credentials, &caregiver.Phone, &caregiver.TimeZone); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(credentials), &caregiver.Credentials); err != nil {
+		return nil, fmt.Errorf("caregiver %s credentials: %w", caregiver.ID, err)
+	}
+	return &caregiver, nil
+}
+
+func scanUser(row rowScanner) (*models.User, error) {
+	var (
+		user        models.User
+		caregiverID sql.NullString
+	)
+	if err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &caregiverID); err != nil {
+		return nil, err
+	}
+	user.CaregiverID = caregiverID.String
+	return &user, nil
+}
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	var (
+		key                   models.APIKey
+		scopes, createdAt     string
+		lastUsedAt, revokedAt sql.NullString
+	)
+	err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.Hash, &scopes, &key.CreatedBy,
+		&createdAt, &lastUsedAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(scopes), &key.Scopes); err != nil {
+		return nil, fmt.Errorf("API key %s scopes: %w", key.ID, err)
+	}
+	var errCreated, errUsed, errRevoked error
+	key.CreatedAt, errCreated = time.Parse(time.RFC3339Nano, createdAt)
+	key.LastUsedAt, errUsed = parseNullTime(lastUsedAt)
+	key.RevokedAt, errRevoked = parseNullTime(revokedAt)
+	if err := errors.Join(errCreated, errUsed, errRevoked); err != nil {
+		return nil, fmt.Errorf("API key %s: %w", key.ID, err)
+	}
+	return &key, nil
+}
+
+func scanClient(row rowScanner) (*models.Client, error) {
+	var (
+		client   models.Client
+		contacts string
+	)
+	err := row.Scan(&client.ID, &client.Name, &client.Contact.Email, &client.Contact.Phone,
+		&client.Location.Address, &client.Location.Coordinates.Latitude, &client.Location.Coordinates.Longitude,
+		&client.Location.GeofenceRadiusMeters, &contacts, &client.MedicaidID, &client.CareNotes)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(contacts), &client.EmergencyContacts); err != nil {
+		return nil, fmt.Errorf("client %s emergency contacts: %w", client.ID, err)
+	}
+	return &client, nil
+}
+
+func scanRecurrence(row rowScanner) (*models.Recurrence, error) {
+	var (
+		recurrence            models.Recurrence
+		exceptionDates, tasks string
+	)
+	err := row.Scan(&recurrence.ID, &recurrence.ClientID, &recurrence.CaregiverID, &recurrence.ServiceName,
+		&recurrence.ServiceNotes, &recurrence.StartDate, &recurrence.ShiftTime, &recurrence.AmOrPm,
+		&recurrence.RRule, &exceptionDates, &tasks, &recurrence.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(exceptionDates), &recurrence.ExceptionDates); err != nil {
+		return nil, fmt.Errorf("recurrence %s exception dates: %w", recurrence.ID, err)
+	}
+	if err := json.Unmarshal([]byte(tasks), &recurrence.Tasks); err != nil {
+		return nil, fmt.Errorf("recurrence %s tasks: %w", recurrence.ID, err)
+	}
+	return &recurrence, nil
+}
+
+func closeRows(rows *sql.Rows) error {
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	return rows.Close()
+}
+
+func requireRow(res sql.Result, format string, args ...any) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf(format+": %w", append(args, ErrNotFound)...)
+	}
+	return nil
+}
+
+func jsonIDs(ids []int) string {
+	data, _ := json.Marshal(ids)
+	return string(data)
+}
+
+// jsonValue encodes a slice column, storing nil as an empty array.
+func jsonValue[T any](values []T) string {
+	if values == nil {
+		values = []T{}
+	}
+	data, _ := json.Marshal(values)
+	return string(data)
+}
+
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// shiftTime stores a shift instant in UTC at second precision, so that the
+// text sorts in time order.
+func shiftTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func nullTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+func parseNullTime(s sql.NullString) (*time.Time, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func nullGeolocation(g *models.Geolocation) (any, any) {
+	if g == nil {
+		return nil, nil
+	}
+	return g.Latitude, g.Longitude
+}
+
+func geolocationFrom(lat, lng sql.NullFloat64) *models.Geolocation {
+	if !lat.Valid || !lng.Valid {
+		return nil
+	}
+	return &models.Geolocation{Latitude: lat.Float64, Longitude: lng.Float64}
+}